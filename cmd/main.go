@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"memkv/internal/config"
+	"memkv/internal/core"
 	"memkv/internal/server"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 )
@@ -14,7 +18,106 @@ import (
 func init() {
 	flag.StringVar(&config.Host, "host", "0.0.0.0", "host")
 	flag.IntVar(&config.Port, "port", config.Port, "port")
+	flag.IntVar(&config.OutputBufferHardLimit, "output-buffer-hard-limit", config.OutputBufferHardLimit,
+		"disconnect a client as soon as its pending replies reach this many bytes, 0 to disable")
+	flag.IntVar(&config.OutputBufferSoftLimit, "output-buffer-soft-limit", config.OutputBufferSoftLimit,
+		"disconnect a client whose pending replies stay above this many bytes for the soft limit period, 0 to disable")
+	flag.DurationVar(&config.OutputBufferSoftLimitPeriod, "output-buffer-soft-period", config.OutputBufferSoftLimitPeriod,
+		"how long a client may stay above the output buffer soft limit")
+	flag.IntVar(&config.PubSubOutputBufferHardLimit, "pubsub-output-buffer-hard-limit", config.PubSubOutputBufferHardLimit,
+		"output buffer hard limit of the clients subscribed to channels, 0 to disable")
+	flag.IntVar(&config.PubSubOutputBufferSoftLimit, "pubsub-output-buffer-soft-limit", config.PubSubOutputBufferSoftLimit,
+		"output buffer soft limit of the clients subscribed to channels, 0 to disable")
+	flag.DurationVar(&config.PubSubOutputBufferSoftLimitPeriod, "pubsub-output-buffer-soft-period", config.PubSubOutputBufferSoftLimitPeriod,
+		"how long a subscribed client may stay above its output buffer soft limit")
+	flag.BoolVar(&config.AOFEnabled, "appendonly", config.AOFEnabled, "log every write to the append only file and replay it at startup")
+	flag.StringVar(&config.AOFFileName, "appendfilename", config.AOFFileName, "append only file path")
+	flag.StringVar(&config.AppendFsync, "appendfsync", config.AppendFsync, "when to fsync the append only file: always, everysec or no")
+	flag.StringVar(&config.SnapshotFileName, "snapshotfilename", config.SnapshotFileName, "snapshot file path for SAVE and BGSAVE")
+	flag.IntVar(&config.KeyNumberLimit, "key-number-limit", config.KeyNumberLimit, "max number of keys before evicting")
+	flag.Func("evict-strategy", "how keys are evicted when the key number limit is reached: first, lru or lfu", func(s string) error {
+		switch s {
+		case "first":
+			config.EvictStrategy = config.EvictFirst
+		case "lru":
+			config.EvictStrategy = config.LRU
+		case "lfu":
+			config.EvictStrategy = config.LFU
+		default:
+			return errors.New("must be first, lru or lfu")
+		}
+		return nil
+	})
+	flag.Func("maxmemory", "evict keys when the estimated memory usage goes above this many bytes, "+
+		"with an optional kb, mb or gb unit, 0 for no limit", func(s string) error {
+		n, err := parseMemory(s)
+		config.MaxMemory = n
+		return err
+	})
+	flag.Func("maxmemory-policy", "how keys are evicted above maxmemory: noeviction, allkeys-lru or volatile-ttl", func(s string) error {
+		switch s {
+		case config.MaxMemoryNoEviction, config.MaxMemoryAllKeysLRU, config.MaxMemoryVolatileTTL:
+			config.MaxMemoryPolicy = s
+			return nil
+		}
+		return errors.New("must be noeviction, allkeys-lru or volatile-ttl")
+	})
+	flag.IntVar(&config.EvictionSamples, "eviction-samples", config.EvictionSamples, "number of keys sampled per LRU/LFU eviction")
+	flag.IntVar(&config.LFULogFactor, "lfu-log-factor", config.LFULogFactor, "how many hits it takes to saturate the LFU counter")
+	flag.IntVar(&config.LFUDecayTime, "lfu-decay-time", config.LFUDecayTime, "minutes it takes an idle key to lose one from its LFU counter")
+	flag.Func("notify-keyspace-events", "classes of keyspace notifications to publish, like Redis notify-keyspace-events, "+
+		"e.g. KEA for all of them", func(s string) error {
+		flags, err := core.ParseNotifyKeyspaceEvents(s)
+		if err != nil {
+			return err
+		}
+		config.NotifyKeyspaceEvents = flags
+		return nil
+	})
+	flag.IntVar(&config.HashMaxListpackEntries, "hash-max-listpack-entries", config.HashMaxListpackEntries,
+		"max number of fields of a hash using the compact encoding")
+	flag.IntVar(&config.HashMaxListpackValue, "hash-max-listpack-value", config.HashMaxListpackValue,
+		"max length of the fields and values of a hash using the compact encoding")
+	flag.IntVar(&config.SetMaxIntsetEntries, "set-max-intset-entries", config.SetMaxIntsetEntries,
+		"max number of members of a set of integers using the intset encoding")
+	flag.IntVar(&config.SetMaxListpackEntries, "set-max-listpack-entries", config.SetMaxListpackEntries,
+		"max number of members of a set using the compact encoding")
+	flag.IntVar(&config.SetMaxListpackValue, "set-max-listpack-value", config.SetMaxListpackValue,
+		"max length of the members of a set using the compact encoding")
+	flag.IntVar(&config.HllSparseMaxBytes, "hll-sparse-max-bytes", config.HllSparseMaxBytes,
+		"max size in bytes of a HyperLogLog using the sparse encoding")
+	flag.IntVar(&config.Hz, "hz", config.Hz, "number of times per second background tasks like the active expiration run, from 1 to 500")
 	flag.Parse()
+	if config.Hz < 1 || config.Hz > 500 {
+		fmt.Fprintln(os.Stderr, "hz must be between 1 and 500")
+		os.Exit(2)
+	}
+	// keys have either an LRU clock or an LFU counter
+	if config.MaxMemoryPolicy == config.MaxMemoryAllKeysLRU && config.EvictStrategy == config.LFU {
+		fmt.Fprintln(os.Stderr, "maxmemory-policy allkeys-lru can't be used with evict-strategy lfu")
+		os.Exit(2)
+	}
+}
+
+// parseMemory parses a number of bytes like 1024, 100mb or 2GB
+func parseMemory(s string) (uint64, error) {
+	units := []struct {
+		suffix string
+		mul    uint64
+	}{{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024}, {"b", 1}}
+	s = strings.ToLower(s)
+	var mul uint64 = 1
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mul = strings.TrimSuffix(s, u.suffix), u.mul
+			break
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid memory size")
+	}
+	return n * mul, nil
 }
 
 func main() {
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 var Host = "0.0.0.0"
 var Port = 8081
 var MaxConnection = 20000
@@ -12,4 +14,95 @@ const (
 )
 
 var EvictStrategy = EvictFirst
+
+// EvictionSamples is the number of keys sampled per eviction by LRU and LFU, more samples are closer to
+// the exact algorithm but slower, like Redis maxmemory-samples
+var EvictionSamples = 5
+
+// MaxMemory is the estimated memory usage in bytes above which keys are evicted according to MaxMemoryPolicy,
+// 0 means no limit
+var MaxMemory uint64 = 0
+
+// MaxMemoryPolicy is how keys are evicted when the memory usage is above MaxMemory, like Redis maxmemory-policy:
+//   - noeviction: nothing is evicted, commands that may use more memory are rejected with an OOM error
+//   - allkeys-lru: evict the least recently used keys, among all the keys
+//   - volatile-ttl: evict the keys with the nearest expiry, among the keys with an expiry
+const (
+	MaxMemoryNoEviction  = "noeviction"
+	MaxMemoryAllKeysLRU  = "allkeys-lru"
+	MaxMemoryVolatileTTL = "volatile-ttl"
+)
+
+var MaxMemoryPolicy = MaxMemoryNoEviction
+
+// LFULogFactor controls how many hits it takes to saturate the LFU counter, with 10 it takes about one million.
+// LFUDecayTime is the number of minutes it takes an idle key to lose one from its LFU counter, 0 disables the decay.
+var LFULogFactor = 10
+var LFUDecayTime = 1
+
+// AOFEnabled turns on the append-only file: every write command is logged to AOFFileName
+// and the file is replayed at startup
+var AOFEnabled = false
 var AOFFileName = "./memkv-master.aof"
+
+// SnapshotFileName is where SAVE and BGSAVE write the binary snapshot of the dataset. It is loaded at startup
+// when the AOF is disabled.
+var SnapshotFileName = "./memkv-master.snapshot"
+
+// AppendFsync is when the AOF is flushed to disk: after every write, once per second or when the OS decides
+const (
+	AppendFsyncAlways   = "always"
+	AppendFsyncEverySec = "everysec"
+	AppendFsyncNo       = "no"
+)
+
+var AppendFsync = AppendFsyncEverySec
+
+// Hz is the number of times per second the server runs its background tasks, like the active expire cycle
+var Hz = 10
+
+// The active expire cycle checks ActiveExpireKeysPerLoop keys with an expiry at a time, and keeps going while
+// more than ActiveExpireAcceptableStalePerc percent of them were expired, for at most
+// ActiveExpireCycleTimePerc percent of the time between two runs
+var ActiveExpireKeysPerLoop = 20
+var ActiveExpireAcceptableStalePerc = 25
+var ActiveExpireCycleTimePerc = 25
+
+// ReadChunkSize is the number of bytes read from a client socket per readiness event
+var ReadChunkSize = 16 * 1024
+
+// MaxQueryBufferSize disconnects clients whose unparsed input grows beyond it, like Redis client-query-buffer-limit
+var MaxQueryBufferSize = 1024 * 1024 * 1024
+
+// Output buffer limits disconnect clients that don't read their replies fast enough, like Redis
+// client-output-buffer-limit: a client is closed as soon as its pending replies reach the hard limit,
+// or when they stay above the soft limit for the soft limit period. 0 disables a limit.
+var OutputBufferHardLimit = 256 * 1024 * 1024
+var OutputBufferSoftLimit = 64 * 1024 * 1024
+var OutputBufferSoftLimitPeriod = 60 * time.Second
+
+// A hash uses the compact listpack encoding while it has at most HashMaxListpackEntries fields, and all its
+// fields and values are at most HashMaxListpackValue bytes long, like Redis hash-max-listpack-*
+var HashMaxListpackEntries = 128
+var HashMaxListpackValue = 64
+
+// A set uses the compact intset encoding while its members are at most SetMaxIntsetEntries integers, otherwise
+// the listpack encoding while it has at most SetMaxListpackEntries members of at most SetMaxListpackValue bytes,
+// like Redis set-max-intset-entries and set-max-listpack-*
+var SetMaxIntsetEntries = 512
+var SetMaxListpackEntries = 128
+var SetMaxListpackValue = 64
+
+// A HyperLogLog uses the sparse encoding while its registers that are not 0 take at most HllSparseMaxBytes bytes,
+// like Redis hll-sparse-max-bytes
+var HllSparseMaxBytes = 3000
+
+// NotifyKeyspaceEvents are the classes of keyspace notifications that are published, parsed from the
+// notify-keyspace-events string by core.ParseNotifyKeyspaceEvents. 0 disables the notifications.
+var NotifyKeyspaceEvents = 0
+
+// PubSub output buffer limits apply instead to the clients subscribed to channels or patterns, which receive
+// messages whether they read them or not
+var PubSubOutputBufferHardLimit = 32 * 1024 * 1024
+var PubSubOutputBufferSoftLimit = 8 * 1024 * 1024
+var PubSubOutputBufferSoftLimitPeriod = 60 * time.Second
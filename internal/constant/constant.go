@@ -1,6 +1,7 @@
 package constant
 
 var RespNil = []byte("$-1\r\n")
+var RespNilArray = []byte("*-1\r\n")
 var RespOk = []byte("+OK\r\n")
 var RespZero = []byte(":0\r\n")
 var RespOne = []byte(":1\r\n")
@@ -10,15 +11,32 @@ var TtlKeyExistNoExpire = []byte(":-1\r\n")
 
 const NoExpire int64 = -1
 
+// The type is stored in the 4 high bits of Obj.TypeEncoding, the encoding in the 4 low bits
 const (
-	ObjTypeString  uint8 = 0
-	ObjTypeSet     uint8 = 1
-	ObjTypeZSet    uint8 = 2
-	ObjTypeGeoHash uint8 = 3
+	ObjTypeString  uint8 = 0 << 4
+	ObjTypeSet     uint8 = 1 << 4
+	ObjTypeZSet    uint8 = 2 << 4
+	ObjTypeGeoHash uint8 = 3 << 4
+	ObjTypeBloom   uint8 = 4 << 4
+	ObjTypeCMS     uint8 = 5 << 4
+	ObjTypeList    uint8 = 6 << 4
+	ObjTypeHash    uint8 = 7 << 4
+	ObjTypeStream  uint8 = 8 << 4
+	ObjTypeHLL     uint8 = 9 << 4
+	ObjTypeCuckoo  uint8 = 10 << 4
+	ObjTypeTopK    uint8 = 11 << 4
+	ObjTypeTDigest uint8 = 12 << 4
+	ObjTypeMorris  uint8 = 13 << 4
 )
 
 const ObjEncodingRaw uint8 = 0
 const ObjEncodingInt uint8 = 1
+const ObjEncodingHashTable uint8 = 2
+const ObjEncodingSkiplist uint8 = 3
+const ObjEncodingQuickList uint8 = 4
+const ObjEncodingListpack uint8 = 5
+const ObjEncodingStream uint8 = 6
+const ObjEncodingIntset uint8 = 7
 
 const EngineStatusWaiting = 1
 const EngineStatusBusy = 2
@@ -0,0 +1,431 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"memkv/internal/config"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Append-only file persistence: every successful write command is appended to the AOF in RESP form,
+// and the file is replayed through EvalAndResponse at startup to rebuild the dataset.
+
+// writeCommands are the commands that may modify the dataset, they are logged to the AOF
+var writeCommands = map[string]struct{}{
+	"SET":            {},
+	"DEL":            {},
+	"EXPIRE":         {},
+	"PEXPIREAT":      {},
+	"INCR":           {},
+	"UNLINK":         {},
+	"RENAME":         {},
+	"RENAMENX":       {},
+	"COPY":           {},
+	"LPUSH":          {},
+	"RPUSH":          {},
+	"LPOP":           {},
+	"RPOP":           {},
+	"LSET":           {},
+	"LREM":           {},
+	"LTRIM":          {},
+	"LINSERT":        {},
+	"LMOVE":          {},
+	"BLPOP":          {},
+	"BRPOP":          {},
+	"BLMOVE":         {},
+	"HSET":           {},
+	"HSETNX":         {},
+	"HDEL":           {},
+	"HINCRBY":        {},
+	"HINCRBYFLOAT":   {},
+	"XADD":           {},
+	"XTRIM":          {},
+	"XDEL":           {},
+	"XGROUP":         {},
+	"XREADGROUP":     {},
+	"XACK":           {},
+	"XCLAIM":         {},
+	"XAUTOCLAIM":     {},
+	"XSETID":         {},
+	"SADD":           {},
+	"SREM":           {},
+	"SPOP":           {},
+	"SINTERSTORE":    {},
+	"SUNIONSTORE":    {},
+	"SDIFFSTORE":     {},
+	"SMOVE":          {},
+	"ZADD":           {},
+	"ZREM":           {},
+	"ZRANGESTORE":    {},
+	"GEOADD":         {},
+	"BF.RESERVE":     {},
+	"BF.MADD":        {},
+	"BF.LOADCHUNK":   {},
+	"TOPK.RESERVE":   {},
+	"TOPK.ADD":       {},
+	"TOPK.INCRBY":    {},
+	"TOPK.LOADCHUNK": {},
+	"PFADD":          {},
+	"PFMERGE":        {},
+	"PFLOADCHUNK":    {},
+	"CF.RESERVE":     {},
+	"CF.ADD":         {},
+	"CF.ADDNX":       {},
+	"CF.INSERT":      {},
+	"CF.DEL":         {},
+	"CF.LOADCHUNK":   {},
+	"CMS.INITBYDIM":  {},
+	"CMS.INITBYPROB": {},
+	"CMS.INCRBY":     {},
+	"CMS.LOADCHUNK":  {},
+
+	"TDIGEST.CREATE":    {},
+	"TDIGEST.ADD":       {},
+	"TDIGEST.MERGE":     {},
+	"TDIGEST.RESET":     {},
+	"TDIGEST.LOADCHUNK": {},
+	"MORRIS.CREATE":     {},
+	"MORRIS.INCRBY":     {},
+	"MORRIS.MERGE":      {},
+	"MORRIS.LOADCHUNK":  {},
+}
+
+func isWriteCommand(cmd string) bool {
+	_, ok := writeCommands[cmd]
+	return ok
+}
+
+type appendOnlyFile struct {
+	// mu guards the file against the background fsync
+	mu   sync.Mutex
+	file *os.File
+	// buf holds the commands executed since the last FlushAOF
+	buf []byte
+	// dirty is true when something was written after the last fsync
+	dirty bool
+	stop  chan struct{}
+	// rewriteBuf collects the commands executed while BGREWRITEAOF runs, they are appended
+	// to the rewritten file before it replaces the current one. It is nil when no rewrite is running.
+	rewriteBuf []byte
+	closed     bool
+}
+
+// aof is nil when the append-only file is disabled
+var aof *appendOnlyFile
+
+// aofLoading is true while the AOF is replayed, so that replayed commands are not logged again
+var aofLoading = false
+
+// aofExec tracks the writes of the EXEC being executed: they are wrapped in MULTI and EXEC in the AOF, so
+// that a transaction is never replayed partially when the file is truncated. MULTI is only logged before the
+// first write, a transaction without writes is not logged.
+var aofExec struct {
+	running  bool
+	multiFed bool
+}
+
+// aofReplayComm swallows the replies of the commands replayed from the AOF
+type aofReplayComm struct{}
+
+func (aofReplayComm) Read(data []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (aofReplayComm) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+// OpenAOF opens the AOF for appending, commands executed from now on are logged to it
+func OpenAOF() error {
+	switch config.AppendFsync {
+	case config.AppendFsyncAlways, config.AppendFsyncEverySec, config.AppendFsyncNo:
+	default:
+		return fmt.Errorf("invalid appendfsync policy '%s', must be always, everysec or no", config.AppendFsync)
+	}
+	f, err := os.OpenFile(config.AOFFileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	aof = &appendOnlyFile{
+		file: f,
+		stop: make(chan struct{}),
+	}
+	if config.AppendFsync == config.AppendFsyncEverySec {
+		go aof.fsyncEverySec()
+	}
+	return nil
+}
+
+// fsyncEverySec flushes the AOF to disk once per second, out of the event loop
+func (a *appendOnlyFile) fsyncEverySec() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.dirty {
+				if err := a.file.Sync(); err != nil {
+					log.Println("can't fsync the append only file:", err)
+				} else {
+					a.dirty = false
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// FlushAOF writes the commands logged since the last call to the AOF. It must be called before replying
+// to the clients, so that a write is never acknowledged before it reaches the file.
+func FlushAOF() error {
+	if aof == nil {
+		return nil
+	}
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	if len(aof.buf) == 0 {
+		return nil
+	}
+	// on error the buffer is kept and written again by the next flush
+	if _, err := aof.file.Write(aof.buf); err != nil {
+		log.Println("can't write to the append only file:", err)
+		return err
+	}
+	aof.buf = aof.buf[:0]
+	if config.AppendFsync == config.AppendFsyncAlways {
+		return aof.file.Sync()
+	}
+	aof.dirty = true
+	return nil
+}
+
+// CloseAOF flushes everything to disk and closes the AOF
+func CloseAOF() error {
+	if aof == nil {
+		return nil
+	}
+	if err := FlushAOF(); err != nil {
+		return err
+	}
+	close(aof.stop)
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	aof.closed = true
+	if err := aof.file.Sync(); err != nil {
+		return err
+	}
+	err := aof.file.Close()
+	aof = nil
+	return err
+}
+
+func feedAOF(tokens ...string) {
+	data := Encode(tokens, false)
+	if aofExec.running && !aofExec.multiFed {
+		aofExec.multiFed = true
+		data = append(Encode([]string{"MULTI"}, false), data...)
+	}
+	aof.mu.Lock()
+	defer aof.mu.Unlock()
+	aof.buf = append(aof.buf, data...)
+	if aof.rewriteBuf != nil {
+		aof.rewriteBuf = append(aof.rewriteBuf, data...)
+	}
+}
+
+/*
+propagate logs a write command that has just been executed. Commands whose effect depends on
+when or where they run are translated into a deterministic form:
+  - relative expiries (SET ... EX, EXPIRE) become an absolute PEXPIREAT
+  - SPOP becomes a SREM of the members that were actually popped
+  - HINCRBYFLOAT becomes a HSET of the new value, float rounding may differ when it is replayed
+  - the blocking pops become the LPOP, RPOP or LMOVE they ended up executing
+  - XADD is logged with the ID of the entry that was added
+  - the consumer group commands that deliver or claim entries log their effects themselves while they run,
+    with alsoPropagate
+*/
+func propagate(cmd *MemKVCmd, res []byte) {
+	if aof == nil || aofLoading {
+		return
+	}
+	switch cmd.Cmd {
+	case "SET", "EXPIRE":
+		key := cmd.Args[0]
+		if cmd.Cmd == "SET" {
+			feedAOF("SET", key, cmd.Args[1])
+		}
+		obj := dictStore.Get(key)
+		if obj == nil {
+			// the key expired right away
+			feedAOF("DEL", key)
+			return
+		}
+		if exp, ok := dictStore.GetExpiry(obj); ok {
+			feedAOF("PEXPIREAT", key, strconv.FormatUint(exp, 10))
+		}
+	case "SPOP":
+		srem := []string{"SREM", cmd.Args[0]}
+		popped, _ := Decode(res)
+		switch v := popped.(type) {
+		case string:
+			srem = append(srem, v)
+		case []interface{}:
+			for _, m := range v {
+				srem = append(srem, m.(string))
+			}
+		}
+		if len(srem) > 2 {
+			feedAOF(srem...)
+		}
+	case "HINCRBYFLOAT":
+		value, _ := Decode(res)
+		feedAOF("HSET", cmd.Args[0], cmd.Args[1], value.(string))
+	case "BLPOP", "BRPOP":
+		// the reply is the key that was popped and the element, or a null array on timeout
+		if popped, _ := Decode(res); popped != nil {
+			feedAOF(cmd.Cmd[1:], popped.([]interface{})[0].(string))
+		}
+	case "BLMOVE":
+		if moved, _ := Decode(res); moved != nil {
+			feedAOF(append([]string{"LMOVE"}, cmd.Args[:4]...)...)
+		}
+	case "XADD":
+		id, _ := Decode(res)
+		if id == nil {
+			// NOMKSTREAM and no stream
+			return
+		}
+		a, _ := parseXAddArgs(cmd.Args)
+		xadd := append([]string{"XADD"}, cmd.Args...)
+		xadd[1+a.idIndex] = id.(string)
+		feedAOF(xadd...)
+	case "XREADGROUP", "XCLAIM", "XAUTOCLAIM":
+		// already logged by alsoPropagate
+	default:
+		feedAOF(append([]string{cmd.Cmd}, cmd.Args...)...)
+	}
+}
+
+// alsoPropagate logs a command that reproduces a part of the effects of the command being executed, for the
+// commands whose effects can't be logged from their arguments and reply once they have run
+func alsoPropagate(tokens ...string) {
+	if aof == nil || aofLoading {
+		return
+	}
+	feedAOF(tokens...)
+}
+
+// beginAOFExec is called before EXEC runs the queued commands
+func beginAOFExec() {
+	aofExec.running = true
+}
+
+// endAOFExec is called after EXEC has run the queued commands, it closes the transaction in the AOF
+func endAOFExec() {
+	if aofExec.multiFed {
+		feedAOF("EXEC")
+	}
+	aofExec.running, aofExec.multiFed = false, false
+}
+
+/*
+LoadAOF replays the AOF to rebuild the dataset. A missing file means an empty dataset.
+If the server crashed while writing, the last command can be incomplete: it is dropped and the file
+is truncated to its last complete command, like Redis aof-load-truncated. The commands of a transaction
+are replayed once its EXEC is read, an incomplete transaction at the end of the file is dropped as a whole.
+*/
+func LoadAOF() error {
+	f, err := os.Open(config.AOFFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	aofLoading = true
+	defer func() {
+		aofLoading = false
+	}()
+
+	start := time.Now()
+	chunk := make([]byte, config.ReadChunkSize)
+	var buf []byte
+	var offset int64 // offset of the first byte of buf in the file
+	loaded := 0
+	replay := func(cmd *MemKVCmd) error {
+		if err := EvalAndResponse(cmd, aofReplayComm{}); err != nil {
+			return fmt.Errorf("can't replay the append only file: %v", err)
+		}
+		loaded++
+		return nil
+	}
+	// tx holds the commands of the transaction being read, txOffset is the offset of its MULTI
+	var tx []*MemKVCmd
+	inTx := false
+	var txOffset int64
+	for {
+		n, readErr := f.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		pos := 0
+		for pos < len(buf) {
+			cmd, consumed, err := parseOneCmd(buf[pos:])
+			if err == ErrIncomplete {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("bad file format reading the append only file at offset %d: %v", offset+int64(pos), err)
+			}
+			switch {
+			case cmd == nil:
+				// an empty inline command
+			case cmd.Cmd == "MULTI":
+				inTx, txOffset = true, offset+int64(pos)
+			case cmd.Cmd == "EXEC":
+				for _, txCmd := range tx {
+					if err := replay(txCmd); err != nil {
+						return err
+					}
+				}
+				tx, inTx = nil, false
+			case inTx:
+				tx = append(tx, cmd)
+			default:
+				if err := replay(cmd); err != nil {
+					return err
+				}
+			}
+			pos += consumed
+		}
+		buf = buf[pos:]
+		offset += int64(pos)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	if inTx {
+		log.Printf("the append only file is truncated, dropping an incomplete transaction of %d commands", len(tx))
+		if err := os.Truncate(config.AOFFileName, txOffset); err != nil {
+			return err
+		}
+	} else if len(buf) > 0 {
+		log.Printf("the append only file is truncated, dropping the last %d bytes of an incomplete command", len(buf))
+		if err := os.Truncate(config.AOFFileName, offset); err != nil {
+			return err
+		}
+	}
+	log.Printf("loaded %d commands from the append only file in %v", loaded, time.Since(start))
+	return nil
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// aofRewriteItemsPerCmd is the max number of members put in a single SADD/ZADD/RPUSH/HSET of the rewritten AOF,
+// same as Redis AOF_REWRITE_ITEMS_PER_CMD
+const aofRewriteItemsPerCmd = 64
+
+// rewriteDataset returns the shortest list of commands, in RESP form, that rebuilds the current dataset
+func rewriteDataset() []byte {
+	var buf []byte
+	emit := func(tokens ...string) {
+		buf = append(buf, Encode(tokens, false)...)
+	}
+
+	dictStore.ForEach(func(key string, obj *data_structure.Obj) {
+		switch getType(obj.TypeEncoding) {
+		case constant.ObjTypeString:
+			emit("SET", key, obj.Value.(string))
+		case constant.ObjTypeSet:
+			members := obj.Value.(data_structure.Set).Members()
+			for len(members) > 0 {
+				n := min(len(members), aofRewriteItemsPerCmd)
+				emit(append([]string{"SADD", key}, members[:n]...)...)
+				members = members[n:]
+			}
+		case constant.ObjTypeList:
+			rpush := []string{"RPUSH", key}
+			obj.Value.(*data_structure.QuickList).ForEach(func(value string) {
+				rpush = append(rpush, value)
+				if len(rpush) == 2+aofRewriteItemsPerCmd {
+					emit(rpush...)
+					rpush = rpush[:2]
+				}
+			})
+			if len(rpush) > 2 {
+				emit(rpush...)
+			}
+		case constant.ObjTypeHash:
+			hset := []string{"HSET", key}
+			obj.Value.(*data_structure.Hash).ForEach(func(field string, value string) {
+				hset = append(hset, field, value)
+				if len(hset) == 2+2*aofRewriteItemsPerCmd {
+					emit(hset...)
+					hset = hset[:2]
+				}
+			})
+			if len(hset) > 2 {
+				emit(hset...)
+			}
+		case constant.ObjTypeZSet:
+			zadd := []string{"ZADD", key}
+			obj.Value.(*data_structure.ZSet).ForEach(func(ele string, score float64) {
+				zadd = append(zadd, strconv.FormatFloat(score, 'g', -1, 64), ele)
+				if len(zadd) == 2+2*aofRewriteItemsPerCmd {
+					emit(zadd...)
+					zadd = zadd[:2]
+				}
+			})
+			if len(zadd) > 2 {
+				emit(zadd...)
+			}
+		case constant.ObjTypeStream:
+			rewriteStream(key, obj.Value.(*data_structure.Stream), emit)
+		case constant.ObjTypeBloom:
+			data, _ := obj.Value.(*data_structure.SBChain).MarshalBinary()
+			emit("BF.LOADCHUNK", key, "1", string(data))
+		case constant.ObjTypeHLL:
+			data, _ := obj.Value.(*data_structure.HyperLogLog).MarshalBinary()
+			emit("PFLOADCHUNK", key, string(data))
+		case constant.ObjTypeCuckoo:
+			data, _ := obj.Value.(*data_structure.CuckooFilter).MarshalBinary()
+			emit("CF.LOADCHUNK", key, "1", string(data))
+		case constant.ObjTypeTopK:
+			data, _ := obj.Value.(*data_structure.TopK).MarshalBinary()
+			emit("TOPK.LOADCHUNK", key, "1", string(data))
+		case constant.ObjTypeTDigest:
+			data, _ := obj.Value.(*data_structure.TDigest).MarshalBinary()
+			emit("TDIGEST.LOADCHUNK", key, "1", string(data))
+		case constant.ObjTypeMorris:
+			data, _ := obj.Value.(*data_structure.MorrisCounter).MarshalBinary()
+			emit("MORRIS.LOADCHUNK", key, "1", string(data))
+		case constant.ObjTypeCMS:
+			data, _ := obj.Value.(*data_structure.CMS).MarshalBinary()
+			emit("CMS.LOADCHUNK", key, "1", string(data))
+		}
+		if exp, ok := dictStore.GetExpiry(obj); ok {
+			emit("PEXPIREAT", key, strconv.FormatUint(exp, 10))
+		}
+	})
+	return buf
+}
+
+/*
+rewriteStream emits the commands that rebuild a stream, like Redis: an XADD per entry, or an XADD trimmed right
+away for an empty stream, XSETID if the last ID is not the ID of the last entry, and for every group XGROUP CREATE,
+XGROUP CREATECONSUMER for its consumers and an XCLAIM per pending entry. The pending entries that were
+deleted from the stream are not kept.
+*/
+func rewriteStream(key string, stream *data_structure.Stream, emit func(tokens ...string)) {
+	entries := stream.Range(data_structure.StreamID{}, data_structure.MaxStreamID, 0, false)
+	for _, e := range entries {
+		emit(append([]string{"XADD", key, e.ID.String()}, e.Fields...)...)
+	}
+	// the ID of the last entry added by the commands
+	top := data_structure.StreamID{Seq: 1}
+	if len(entries) == 0 {
+		emit("XADD", key, "MAXLEN", "0", top.String(), "x", "y")
+	} else {
+		top = entries[len(entries)-1].ID
+	}
+	if top != stream.LastID() {
+		emit("XSETID", key, stream.LastID().String())
+	}
+	for _, g := range stream.Groups() {
+		emit("XGROUP", "CREATE", key, g.Name(), g.LastID().String())
+		for _, c := range g.Consumers() {
+			emit("XGROUP", "CREATECONSUMER", key, g.Name(), c.Name())
+		}
+		g.PendingRange(data_structure.StreamID{}, data_structure.MaxStreamID, nil, func(id data_structure.StreamID, pe *data_structure.PendingEntry) bool {
+			emit("XCLAIM", key, g.Name(), pe.Consumer(), "0", id.String(), "TIME", strconv.FormatUint(pe.DeliveryTime, 10),
+				"RETRYCOUNT", strconv.FormatUint(pe.DeliveryCount, 10), "FORCE", "JUSTID")
+			return true
+		})
+	}
+}
+
+/*
+BGREWRITEAOF
+Replaces the AOF with the shortest list of commands that rebuilds the current dataset.
+Go can't fork, so the dataset is serialized in the event loop: unlike Redis, every client is blocked
+for a time that grows with the size of the dataset. Serializing it across several event loop iterations
+would let commands modify the keys not serialized yet, and replaying them from the rewrite buffer would
+apply them twice. Only writing and syncing the new file runs in the background while the server keeps
+serving commands. Commands executed in the meantime are logged to both the current AOF and a rewrite
+buffer, which is appended to the new file right before it atomically replaces the current one.
+*/
+func cmdBGREWRITEAOF(args []string) []byte {
+	if len(args) != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BGREWRITEAOF' command"), false)
+	}
+	if aof == nil {
+		return Encode(errors.New("(error) ERR append only file is disabled"), false)
+	}
+	aof.mu.Lock()
+	if aof.rewriteBuf != nil {
+		aof.mu.Unlock()
+		return Encode(errors.New("(error) ERR Background append only file rewriting already in progress"), false)
+	}
+	aof.rewriteBuf = []byte{}
+	aof.mu.Unlock()
+
+	go aof.rewrite(rewriteDataset())
+	return Encode("Background append only file rewriting started", true)
+}
+
+func (a *appendOnlyFile) rewrite(payload []byte) {
+	start := time.Now()
+	tmpName := filepath.Join(filepath.Dir(config.AOFFileName), fmt.Sprintf("temp-rewriteaof-bg-%d.aof", os.Getpid()))
+	if err := a.swapRewrittenFile(tmpName, payload); err != nil {
+		log.Println("background append only file rewriting failed:", err)
+		os.Remove(tmpName)
+		return
+	}
+	log.Printf("background append only file rewriting terminated with success in %v", time.Since(start))
+}
+
+func (a *appendOnlyFile) swapRewrittenFile(tmpName string, payload []byte) error {
+	defer func() {
+		a.mu.Lock()
+		a.rewriteBuf = nil
+		a.mu.Unlock()
+	}()
+
+	f, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(payload); err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// no command can be logged from here, so nothing is lost between the rewrite buffer and the swap
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		f.Close()
+		return errors.New("the append only file was closed")
+	}
+	if _, err = f.Write(a.rewriteBuf); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpName, config.AOFFileName)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file.Close()
+	a.file = f
+	// the pending commands are either part of the rewritten dataset or of the rewrite buffer
+	a.buf = a.buf[:0]
+	a.dirty = false
+	return nil
+}
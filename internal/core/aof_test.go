@@ -0,0 +1,367 @@
+package core
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+	"memkv/internal/data_structure"
+)
+
+func resetStores() {
+	dictStore = createDictStore()
+}
+
+func testSet(key string) data_structure.Set {
+	set, _ := getSet(key)
+	return set
+}
+
+func testZSet(key string) *data_structure.ZSet {
+	zset, _ := getZSet(key)
+	return zset
+}
+
+func testList(key string) *data_structure.QuickList {
+	list, _ := getList(key)
+	return list
+}
+
+func testHash(key string) *data_structure.Hash {
+	hash, _ := getHash(key)
+	return hash
+}
+
+func testSBChain(key string) *data_structure.SBChain {
+	sb, _ := getSBChain(key)
+	return sb
+}
+
+func testHyperLogLog(key string) *data_structure.HyperLogLog {
+	hll, _ := getHyperLogLog(key)
+	return hll
+}
+
+func testCuckooFilter(key string) *data_structure.CuckooFilter {
+	cf, _ := getCuckooFilter(key)
+	return cf
+}
+
+func testTopK(key string) *data_structure.TopK {
+	topk, _ := getTopK(key)
+	return topk
+}
+
+func testTDigest(key string) *data_structure.TDigest {
+	td, _ := getTDigest(key)
+	return td
+}
+
+func testMorrisCounter(key string) *data_structure.MorrisCounter {
+	m, _ := getMorrisCounter(key)
+	return m
+}
+
+func testCMS(key string) *data_structure.CMS {
+	cms, _ := getCMS(key)
+	return cms
+}
+
+func testStream(key string) *data_structure.Stream {
+	stream, _ := getStream(key)
+	return stream
+}
+
+func evalCmd(args ...string) {
+	EvalAndResponse(&MemKVCmd{Cmd: args[0], Args: args[1:]}, aofReplayComm{})
+}
+
+func TestAOFLogAndReplay(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	assert.Nil(t, OpenAOF())
+
+	evalCmd("SET", "k", "v", "EX", "100")
+	evalCmd("INCR", "counter")
+	evalCmd("INCR", "counter")
+	evalCmd("SADD", "set", "a", "b", "c")
+	evalCmd("SPOP", "set")
+	evalCmd("SADD", "set2", "a", "b", "c", "d")
+	evalCmd("SMOVE", "set2", "set3", "d")
+	evalCmd("SDIFFSTORE", "set2", "set2", "set")
+	evalCmd("ZADD", "zset", "1.5", "m")
+	evalCmd("RPUSH", "list", "a", "b", "c")
+	evalCmd("BLPOP", "list", "0")
+	evalCmd("BLMOVE", "list", "list", "RIGHT", "LEFT", "0")
+	evalCmd("HSET", "hash", "name", "alice", "score", "1.5")
+	evalCmd("HINCRBYFLOAT", "hash", "score", "0.1")
+	evalCmd("CMS.INITBYDIM", "cms", "10", "2")
+	evalCmd("CMS.INCRBY", "cms", "item", "3")
+	evalCmd("TOPK.RESERVE", "topk", "2", "10", "3", "0.9")
+	evalCmd("TOPK.ADD", "topk", "a", "b", "c")
+	evalCmd("TOPK.INCRBY", "topk", "c", "5")
+	evalCmd("TDIGEST.CREATE", "td", "COMPRESSION", "10")
+	evalCmd("TDIGEST.ADD", "td", "5", "1", "3")
+	evalCmd("TDIGEST.MERGE", "td2", "1", "td")
+	evalCmd("TDIGEST.RESET", "td")
+	evalCmd("MORRIS.CREATE", "morris", "BASE", "1.2")
+	evalCmd("MORRIS.INCRBY", "morris", "100000")
+	evalCmd("MORRIS.MERGE", "morris2", "morris")
+	evalCmd("PFADD", "hll", "a", "b", "c")
+	evalCmd("PFMERGE", "hll2", "hll")
+	evalCmd("CF.RESERVE", "cf", "100", "BUCKETSIZE", "4")
+	evalCmd("CF.INSERT", "cf", "ITEMS", "a", "b")
+	evalCmd("CF.DEL", "cf", "a")
+	evalCmd("XADD", "stream", "*", "f", "1")
+	evalCmd("XADD", "stream", "MAXLEN", "2", "*", "f", "2")
+	evalCmd("XADD", "stream", "MAXLEN", "2", "*", "f", "3")
+	evalCmd("XGROUP", "CREATE", "stream", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "COUNT", "1", "STREAMS", "stream", ">")
+	evalCmd("XREADGROUP", "GROUP", "g", "bob", "NOACK", "STREAMS", "stream", ">")
+	evalCmd("GET", "k")
+	assert.Nil(t, FlushAOF())
+	assert.Nil(t, CloseAOF())
+
+	members := testSet("set").Members()
+	morris, morris2 := *testMorrisCounter("morris"), *testMorrisCounter("morris2")
+	streamLastID := testStream("stream").LastID()
+	firstID, _, _ := testStream("stream").Group("g").PendingBounds()
+	deliveryTime := testStream("stream").Group("g").Pending(firstID).DeliveryTime
+	resetStores()
+	assert.Nil(t, LoadAOF())
+
+	obj := dictStore.Get("k")
+	assert.NotNil(t, obj)
+	assert.EqualValues(t, "v", obj.Value)
+	_, hasExpiry := dictStore.GetExpiry(obj)
+	assert.True(t, hasExpiry)
+	assert.EqualValues(t, "2", dictStore.Get("counter").Value)
+	assert.ElementsMatch(t, members, testSet("set").Members())
+	assert.EqualValues(t, 2, testSet("set").Size())
+	assert.ElementsMatch(t, testSet("set3").Members(), []string{"d"})
+	assert.EqualValues(t, 1, testSet("set2").Size())
+	_, score := testZSet("zset").GetScore("m")
+	assert.EqualValues(t, 1.5, score)
+	assert.EqualValues(t, []string{"c", "b"}, testList("list").Range(0, -1))
+	hashScore, _ := testHash("hash").Get("score")
+	assert.EqualValues(t, "1.6", hashScore)
+	assert.EqualValues(t, 3, testCMS("cms").Count("item"))
+	assert.EqualValues(t, []interface{}{"c", "6", "a", "1"}, evalReply("TOPK.LIST", "topk", "WITHCOUNT"))
+	assert.True(t, math.IsNaN(testTDigest("td").GetMin()))
+	assert.EqualValues(t, 10, testTDigest("td").GetCompression())
+	assert.EqualValues(t, 3, testTDigest("td2").Quantile(0.5))
+	// the increments draw the same random numbers when they are replayed
+	assert.EqualValues(t, morris, *testMorrisCounter("morris"))
+	assert.EqualValues(t, morris2, *testMorrisCounter("morris2"))
+	assert.EqualValues(t, 3, testHyperLogLog("hll2").Count())
+	assert.False(t, testCuckooFilter("cf").Exist("a"))
+	assert.True(t, testCuckooFilter("cf").Exist("b"))
+	assert.EqualValues(t, 4, testCuckooFilter("cf").GetBucketSize())
+	// the IDs generated by XADD and the deliveries to the consumers are replayed as they happened
+	assert.EqualValues(t, 2, testStream("stream").Len())
+	assert.EqualValues(t, streamLastID, testStream("stream").LastID())
+	group := testStream("stream").Group("g")
+	assert.EqualValues(t, streamLastID, group.LastID())
+	assert.EqualValues(t, 1, group.PendingLen())
+	pe := group.Pending(firstID)
+	assert.EqualValues(t, "alice", pe.Consumer())
+	assert.EqualValues(t, deliveryTime, pe.DeliveryTime)
+	assert.EqualValues(t, 1, pe.DeliveryCount)
+}
+
+func TestAOFSkipUnchanged(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	assert.Nil(t, OpenAOF())
+
+	evalCmd("SADD", "set", "a")
+	evalCmd("SREM", "set", "missing")
+	evalCmd("SADD", "set", "a")
+	evalCmd("LPOP", "list")
+	evalCmd("XACK", "stream", "g", "1-1")
+	assert.Nil(t, FlushAOF())
+	assert.Nil(t, CloseAOF())
+
+	data, err := os.ReadFile(config.AOFFileName)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "*3\r\n$4\r\nSADD\r\n$3\r\nset\r\n$1\r\na\r\n", string(data))
+}
+
+func TestAOFLoadTruncated(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	complete := "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"
+	assert.Nil(t, os.WriteFile(config.AOFFileName, []byte(complete+"*3\r\n$3\r\nSET\r\n$1\r\nb"), 0644))
+
+	assert.Nil(t, LoadAOF())
+	assert.EqualValues(t, "1", dictStore.Get("a").Value)
+	assert.Nil(t, dictStore.Get("b"))
+	data, err := os.ReadFile(config.AOFFileName)
+	assert.Nil(t, err)
+	assert.EqualValues(t, complete, string(data))
+}
+
+func TestAOFTransaction(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	assert.Nil(t, OpenAOF())
+
+	comm := &FDComm{Fd: -1}
+	clientReply(comm, "MULTI")
+	clientReply(comm, "SET", "a", "1")
+	clientReply(comm, "SREM", "set", "missing")
+	clientReply(comm, "INCR", "a")
+	clientReply(comm, "EXEC")
+	// a transaction without writes is not logged
+	clientReply(comm, "MULTI")
+	clientReply(comm, "GET", "a")
+	clientReply(comm, "EXEC")
+	assert.Nil(t, FlushAOF())
+	assert.Nil(t, CloseAOF())
+
+	multi := "*1\r\n$5\r\nMULTI\r\n"
+	set := "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"
+	incr := "*2\r\n$4\r\nINCR\r\n$1\r\na\r\n"
+	exec := "*1\r\n$4\r\nEXEC\r\n"
+	data, err := os.ReadFile(config.AOFFileName)
+	assert.Nil(t, err)
+	assert.EqualValues(t, multi+set+incr+exec, string(data))
+
+	resetStores()
+	assert.Nil(t, LoadAOF())
+	assert.EqualValues(t, "2", dictStore.Get("a").Value)
+
+	// the server crashed in the middle of a transaction: the whole transaction is dropped
+	complete := set + multi + incr + exec
+	assert.Nil(t, os.WriteFile(config.AOFFileName, []byte(complete+multi+incr+incr), 0644))
+	resetStores()
+	assert.Nil(t, LoadAOF())
+	assert.EqualValues(t, "2", dictStore.Get("a").Value)
+	data, err = os.ReadFile(config.AOFFileName)
+	assert.Nil(t, err)
+	assert.EqualValues(t, complete, string(data))
+}
+
+func TestAOFLoadBadFormat(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	assert.Nil(t, os.WriteFile(config.AOFFileName, []byte("*1\r\n:12\r\n"), 0644))
+	assert.NotNil(t, LoadAOF())
+}
+
+func waitForRewrite(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		aof.mu.Lock()
+		done := aof.rewriteBuf == nil
+		aof.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("AOF rewrite did not finish")
+}
+
+func TestAOFRewrite(t *testing.T) {
+	resetStores()
+	config.AOFFileName = filepath.Join(t.TempDir(), "test.aof")
+	assert.Nil(t, OpenAOF())
+
+	evalCmd("TDIGEST.CREATE", "td", "COMPRESSION", "10")
+	for i := 0; i < 100; i++ {
+		evalCmd("INCR", "counter")
+		evalCmd("SADD", "set", strconv.Itoa(i))
+		evalCmd("ZADD", "zset", strconv.Itoa(i), strconv.Itoa(i))
+		evalCmd("RPUSH", "list", strconv.Itoa(i))
+		evalCmd("HSET", "hash", strconv.Itoa(i), "v")
+		evalCmd("PFADD", "hll", strconv.Itoa(i))
+		evalCmd("TDIGEST.ADD", "td", strconv.Itoa(i))
+	}
+	evalCmd("SET", "k", "v", "EX", "100")
+	evalCmd("SET", "deleted", "v")
+	evalCmd("DEL", "deleted")
+	evalCmd("BF.MADD", "bf", "a", "b")
+	evalCmd("CF.ADD", "cf", "a")
+	evalCmd("CF.ADD", "cf", "b")
+	evalCmd("CF.DEL", "cf", "a")
+	evalCmd("CMS.INITBYDIM", "cms", "10", "2")
+	evalCmd("CMS.INCRBY", "cms", "item", "3")
+	evalCmd("TOPK.RESERVE", "topk", "2")
+	evalCmd("TOPK.INCRBY", "topk", "a", "3", "b", "1")
+	evalCmd("MORRIS.INCRBY", "morris", "5000")
+	for i := 0; i < 2000; i++ {
+		evalCmd("PFADD", "dense", strconv.Itoa(i))
+	}
+	morrisCount := testMorrisCounter("morris").Estimate()
+	sparseCount, denseCount := testHyperLogLog("hll").Count(), testHyperLogLog("dense").Count()
+	for i := 1; i <= 5; i++ {
+		evalCmd("XADD", "stream", strconv.Itoa(i), "f", strconv.Itoa(i))
+	}
+	evalCmd("XDEL", "stream", "5")
+	evalCmd("XGROUP", "CREATE", "stream", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "stream", ">")
+	evalCmd("XGROUP", "CREATECONSUMER", "stream", "g", "bob")
+	evalCmd("XGROUP", "CREATE", "empty", "g", "$", "MKSTREAM")
+	evalCmd("XADD", "empty", "7", "f", "v")
+	evalCmd("XDEL", "empty", "7")
+	assert.Nil(t, FlushAOF())
+	before, _ := os.Stat(config.AOFFileName)
+
+	res, err := Decode(cmdBGREWRITEAOF([]string{}))
+	assert.Nil(t, err)
+	assert.EqualValues(t, "Background append only file rewriting started", res)
+	// written while the rewrite runs
+	evalCmd("INCR", "counter")
+	waitForRewrite(t)
+	evalCmd("SADD", "set", "after")
+	assert.Nil(t, CloseAOF())
+
+	after, _ := os.Stat(config.AOFFileName)
+	assert.Less(t, after.Size(), before.Size())
+
+	resetStores()
+	assert.Nil(t, LoadAOF())
+	assert.EqualValues(t, "101", dictStore.Get("counter").Value)
+	assert.EqualValues(t, "v", dictStore.Get("k").Value)
+	_, hasExpiry := dictStore.GetExpiry(dictStore.Get("k"))
+	assert.True(t, hasExpiry)
+	assert.Nil(t, dictStore.Get("deleted"))
+	assert.EqualValues(t, 101, testSet("set").Size())
+	assert.EqualValues(t, 100, testZSet("zset").Len())
+	_, score := testZSet("zset").GetScore("42")
+	assert.EqualValues(t, 42, score)
+	assert.EqualValues(t, 100, testList("list").Len())
+	assert.EqualValues(t, []string{"0", "1"}, testList("list").Range(0, 1))
+	assert.EqualValues(t, 100, testHash("hash").Len())
+	assert.True(t, testSBChain("bf").Exist("a"))
+	assert.False(t, testSBChain("bf").Exist("c"))
+	assert.EqualValues(t, 3, testCMS("cms").Count("item"))
+	assert.EqualValues(t, 3, testTopK("topk").Count("a"))
+	assert.True(t, testTopK("topk").Query("b"))
+	assert.EqualValues(t, morrisCount, testMorrisCounter("morris").Estimate())
+	assert.EqualValues(t, 100, testTDigest("td").GetMergedWeight()+testTDigest("td").GetUnmergedWeight())
+	assert.EqualValues(t, 99, testTDigest("td").GetMax())
+	assert.False(t, testCuckooFilter("cf").Exist("a"))
+	assert.True(t, testCuckooFilter("cf").Exist("b"))
+	assert.EqualValues(t, 1, testCuckooFilter("cf").GetDeletedNumber())
+	assert.EqualValues(t, sparseCount, testHyperLogLog("hll").Count())
+	assert.True(t, testHyperLogLog("hll").IsSparse())
+	assert.EqualValues(t, denseCount, testHyperLogLog("dense").Count())
+	assert.False(t, testHyperLogLog("dense").IsSparse())
+	stream := testStream("stream")
+	assert.EqualValues(t, 4, stream.Len())
+	assert.EqualValues(t, "5-0", stream.LastID().String())
+	group := stream.Group("g")
+	assert.EqualValues(t, "2-0", group.LastID().String())
+	assert.EqualValues(t, 2, group.PendingLen())
+	assert.EqualValues(t, 2, len(group.Consumers()))
+	assert.EqualValues(t, 0, testStream("empty").Len())
+	assert.EqualValues(t, "7-0", testStream("empty").LastID().String())
+	assert.NotNil(t, testStream("empty").Group("g"))
+}
@@ -0,0 +1,256 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"strconv"
+	"time"
+)
+
+/*
+Blocking operations, like Redis BLPOP: when the lists of a blocking command are all empty, the client is
+blocked instead of getting a reply. The event loop keeps serving the other clients, and the commands the
+blocked client sends in the meantime are kept until it is unblocked.
+
+A key created while clients are blocked on it is signaled as ready. Right after the command that created it,
+the clients blocked on the ready keys run their blocking command again, in the order they were blocked, until
+the list is empty again. A client that is still blocked when its timeout is reached gets a null reply.
+Blocking commands executed by a transaction, or by the AOF replay, reply at once instead of blocking.
+
+XREAD and XREADGROUP block on streams the same way, and every entry added to a stream signals its key as ready.
+As the entries are not consumed, every client blocked on the stream runs its command again, once, and stays
+blocked if the command still has nothing to read.
+*/
+
+// blockState is the state of a client blocked by a blocking command
+type blockState struct {
+	// cmd is the blocking command, it is executed again when one of its keys is ready. It is nil when
+	// the client is not blocked.
+	cmd  *MemKVCmd
+	keys []string
+	// deadline is when the client times out, zero to wait forever
+	deadline time.Time
+	// deferred are the commands received while the client was blocked
+	deferred []*MemKVCmd
+}
+
+// currentClient is the client whose command is executed, nil for the AOF replay. Blocking commands
+// need it to block the client.
+var currentClient *FDComm
+
+// blockingKeys maps every key to the clients blocked on it, in the order they were blocked
+var blockingKeys = map[string][]*FDComm{}
+
+// blockedClients are the clients waiting for a key, checked for timeouts by the cron
+var blockedClients = map[*FDComm]struct{}{}
+
+// readyKeys are the keys with blocked clients that were created by the current command
+var readyKeys []string
+
+func (bs *blockState) blocked() bool {
+	return bs.cmd != nil
+}
+
+// canBlock reports whether the current command can block its client instead of replying
+func canBlock() bool {
+	return currentClient != nil && !currentClient.tx.inMulti
+}
+
+// parseBlockTimeout parses the timeout of a blocking command, in seconds with decimals, 0 means forever
+func parseBlockTimeout(s string) (time.Duration, error) {
+	timeout, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, errors.New("(error) ERR timeout is not a float or out of range")
+	}
+	if timeout < 0 {
+		return 0, errors.New("(error) ERR timeout is negative")
+	}
+	return time.Duration(timeout * float64(time.Second)), nil
+}
+
+// blockForKeys blocks the current client on keys until one of them is ready, or timeout has passed
+func blockForKeys(cmd *MemKVCmd, keys []string, timeout time.Duration) {
+	comm := currentClient
+	bs := &comm.block
+	bs.cmd = cmd
+	if timeout > 0 {
+		bs.deadline = time.Now().Add(timeout)
+	}
+	for _, key := range keys {
+		if containsKey(bs.keys, key) {
+			continue
+		}
+		bs.keys = append(bs.keys, key)
+		blockingKeys[key] = append(blockingKeys[key], comm)
+	}
+	blockedClients[comm] = struct{}{}
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// unblockClient removes the client from the clients blocked on its keys, its deferred commands are kept
+func unblockClient(comm *FDComm) {
+	bs := &comm.block
+	for _, key := range bs.keys {
+		clients := blockingKeys[key]
+		for i, c := range clients {
+			if c == comm {
+				clients = append(clients[:i], clients[i+1:]...)
+				break
+			}
+		}
+		if len(clients) == 0 {
+			delete(blockingKeys, key)
+		} else {
+			blockingKeys[key] = clients
+		}
+	}
+	delete(blockedClients, comm)
+	bs.cmd, bs.keys, bs.deadline = nil, nil, time.Time{}
+}
+
+// signalKeyAsReady is called when key is created, the clients blocked on it are served after the current command
+func signalKeyAsReady(key string) {
+	if _, ok := blockingKeys[key]; !ok || containsKey(readyKeys, key) {
+		return
+	}
+	readyKeys = append(readyKeys, key)
+}
+
+// handleClientsBlockedOnKeys serves the clients blocked on the keys signaled as ready
+func handleClientsBlockedOnKeys() {
+	var unblocked []*FDComm
+	for len(readyKeys) > 0 {
+		keys := readyKeys
+		readyKeys = nil
+		for _, key := range keys {
+			unblocked = append(unblocked, serveClientsBlockedOnKey(key)...)
+		}
+	}
+	for _, comm := range unblocked {
+		runDeferredCommands(comm)
+	}
+}
+
+// blockedOnStreams reports whether the client waits for entries added to streams, instead of elements pushed to lists
+func blockedOnStreams(comm *FDComm) bool {
+	return comm.block.cmd.Cmd == "XREAD" || comm.block.cmd.Cmd == "XREADGROUP"
+}
+
+// serveClientsBlockedOnKey runs again the commands of the clients blocked on key while its list is not
+// empty, or once for a stream, and returns the clients that were unblocked
+func serveClientsBlockedOnKey(key string) []*FDComm {
+	if obj := dictStore.Get(key); obj != nil && getType(obj.TypeEncoding) == constant.ObjTypeStream {
+		return serveClientsBlockedOnStream(key)
+	}
+	var unblocked []*FDComm
+	for {
+		list, _ := getList(key)
+		if list == nil {
+			// popped by a client that was served before, or replaced by another type
+			break
+		}
+		var comm *FDComm
+		for _, c := range blockingKeys[key] {
+			if !blockedOnStreams(c) {
+				comm = c
+				break
+			}
+		}
+		if comm == nil {
+			break
+		}
+		cmd := comm.block.cmd
+		unblockClient(comm)
+
+		prev := currentClient
+		currentClient = comm
+		res, err := evalCommand(cmd)
+		currentClient = prev
+		if err != nil {
+			res = Encode(err, false)
+		}
+		comm.Write(res)
+		schedulePendingWrite(comm)
+		unblocked = append(unblocked, comm)
+	}
+	return unblocked
+}
+
+// serveClientsBlockedOnStream runs again the commands of the clients blocked on the stream of key, and returns
+// the clients that were unblocked. A command that has still nothing to read replies nil, its client stays blocked.
+func serveClientsBlockedOnStream(key string) []*FDComm {
+	var unblocked []*FDComm
+	clients := append([]*FDComm(nil), blockingKeys[key]...)
+	for _, comm := range clients {
+		if !comm.block.blocked() || !blockedOnStreams(comm) {
+			continue
+		}
+		prev := currentClient
+		currentClient = comm
+		res, err := evalCommand(comm.block.cmd)
+		currentClient = prev
+		if err == nil && res == nil {
+			continue
+		}
+		if err != nil {
+			res = Encode(err, false)
+		}
+		unblockClient(comm)
+		comm.Write(res)
+		schedulePendingWrite(comm)
+		unblocked = append(unblocked, comm)
+	}
+	return unblocked
+}
+
+// runDeferredCommands runs the commands received while the client was blocked, until it blocks again
+func runDeferredCommands(comm *FDComm) {
+	bs := &comm.block
+	for len(bs.deferred) > 0 && !bs.blocked() && !comm.CloseAsap {
+		cmd := bs.deferred[0]
+		bs.deferred = bs.deferred[1:]
+		if err := EvalAndResponse(cmd, comm); err != nil {
+			comm.Write([]byte(fmt.Sprintf("-%s%s", err, CRLF)))
+		}
+	}
+	if len(bs.deferred) == 0 {
+		bs.deferred = nil
+	}
+	schedulePendingWrite(comm)
+}
+
+// blockedTimeoutReply is the reply of a blocked command whose timeout has passed: a null bulk string for BLMOVE,
+// which replies with an element, and a null array for the commands that reply with an array
+func blockedTimeoutReply(cmd *MemKVCmd) []byte {
+	if cmd.Cmd == "BLMOVE" {
+		return constant.RespNil
+	}
+	return constant.RespNilArray
+}
+
+// handleBlockedClientsTimeout replies with a null to the blocked clients whose timeout has passed
+func handleBlockedClientsTimeout() {
+	now := time.Now()
+	var timedOut []*FDComm
+	for comm := range blockedClients {
+		if !comm.block.deadline.IsZero() && !now.Before(comm.block.deadline) {
+			timedOut = append(timedOut, comm)
+		}
+	}
+	for _, comm := range timedOut {
+		cmd := comm.block.cmd
+		unblockClient(comm)
+		comm.Write(blockedTimeoutReply(cmd))
+		runDeferredCommands(comm)
+	}
+}
@@ -1,20 +1,132 @@
 package core
 
-import "syscall"
+import (
+	"log"
+	"memkv/internal/config"
+	"syscall"
+	"time"
+)
+
+// replyBufKeepCap is the largest reply buffer we keep around after it has been drained,
+// bigger buffers are released so that an occasional huge reply doesn't pin memory
+const replyBufKeepCap = 64 * 1024
 
 type MemKVCmd struct {
 	Cmd  string
 	Args []string
 }
 
+// FDComm holds the state of a client connection
 type FDComm struct {
 	Fd int
+	// QueryBuf accumulates bytes read from the socket across readiness events,
+	// until they form complete commands
+	QueryBuf []byte
+	// replyBuf holds replies that have not been written to the socket yet,
+	// replyBuf[:sentLen] has already been sent
+	replyBuf []byte
+	sentLen  int
+	// softLimitReachedAt is when the pending replies grew past the soft limit,
+	// zero if they are below it
+	softLimitReachedAt time.Time
+	// CloseAsap is set when the client must be disconnected, e.g. it is too slow
+	// to read its replies. Replies to a client that is going away are dropped.
+	CloseAsap bool
+	// tx is the MULTI/EXEC state of the client
+	tx txState
+	// pubsub holds the subscriptions of the client
+	pubsub pubsubState
+	// block is the state of the client while a blocking command waits for a key
+	block blockState
+	// pendingWrite is set while the client is in clientsPendingWrite
+	pendingWrite bool
 }
 
-func (f FDComm) Read(data []byte) (int, error) {
+func (f *FDComm) Read(data []byte) (int, error) {
 	return syscall.Read(f.Fd, data)
 }
 
-func (f FDComm) Write(data []byte) (int, error) {
-	return syscall.Write(f.Fd, data)
+// Write appends data to the reply buffer, it is sent to the socket by Flush
+func (f *FDComm) Write(data []byte) (int, error) {
+	if f.CloseAsap {
+		return len(data), nil
+	}
+	f.replyBuf = append(f.replyBuf, data...)
+	f.checkOutputBufferLimits()
+	return len(data), nil
+}
+
+// ConsumeQueryBuf drops the first n bytes of the query buffer, which have been parsed into commands
+func (f *FDComm) ConsumeQueryBuf(n int) {
+	if n == len(f.QueryBuf) {
+		f.QueryBuf = f.QueryBuf[:0]
+		return
+	}
+	remain := copy(f.QueryBuf, f.QueryBuf[n:])
+	f.QueryBuf = f.QueryBuf[:remain]
+}
+
+// PendingReplies returns the number of bytes waiting to be written to the socket
+func (f *FDComm) PendingReplies() int {
+	return len(f.replyBuf) - f.sentLen
+}
+
+// Flush writes as much of the reply buffer as the socket accepts without blocking.
+// It returns true when the buffer has been fully written.
+func (f *FDComm) Flush() (bool, error) {
+	for f.PendingReplies() > 0 {
+		n, err := syscall.Write(f.Fd, f.replyBuf[f.sentLen:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		f.sentLen += n
+	}
+	if f.PendingReplies() > 0 {
+		f.checkOutputBufferLimits()
+		return false, nil
+	}
+	f.sentLen = 0
+	if cap(f.replyBuf) > replyBufKeepCap {
+		f.replyBuf = nil
+	} else {
+		f.replyBuf = f.replyBuf[:0]
+	}
+	f.softLimitReachedAt = time.Time{}
+	return true, nil
+}
+
+/*
+checkOutputBufferLimits marks the client to be closed when its pending replies reach the hard limit,
+or stay above the soft limit for longer than the soft limit period, like Redis client-output-buffer-limit.
+Subscribed clients have their own limits. A limit of 0 means no limit.
+*/
+func (f *FDComm) checkOutputBufferLimits() {
+	pending := f.PendingReplies()
+	hard, soft, softPeriod := config.OutputBufferHardLimit, config.OutputBufferSoftLimit, config.OutputBufferSoftLimitPeriod
+	if f.pubsub.subscriptions() > 0 {
+		hard, soft, softPeriod = config.PubSubOutputBufferHardLimit, config.PubSubOutputBufferSoftLimit, config.PubSubOutputBufferSoftLimitPeriod
+	}
+	if hard > 0 && pending >= hard {
+		log.Printf("client fd=%d scheduled to be closed for reaching the output buffer hard limit (%d bytes)", f.Fd, pending)
+		f.CloseAsap = true
+		return
+	}
+	if soft <= 0 || pending < soft {
+		f.softLimitReachedAt = time.Time{}
+		return
+	}
+	if f.softLimitReachedAt.IsZero() {
+		f.softLimitReachedAt = time.Now()
+		return
+	}
+	if time.Since(f.softLimitReachedAt) >= softPeriod {
+		log.Printf("client fd=%d scheduled to be closed for staying over the output buffer soft limit (%d bytes)", f.Fd, pending)
+		f.CloseAsap = true
+	}
 }
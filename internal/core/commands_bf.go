@@ -21,24 +21,31 @@ func cmdBFRESERVE(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("capacity must be an integer number %s", args[2])), false)
 	}
+	// the negation also rejects NaN
+	if !(errRate > 0 && errRate < 1) {
+		return Encode(errors.New("(error) ERR (0 < error rate range < 1)"), false)
+	}
+	if capacity == 0 {
+		return Encode(errors.New("(error) ERR (capacity should be larger than 0)"), false)
+	}
 	var growthRate uint64 = data_structure.BfDefaultExpansion
 	if len(args) == 5 {
 		if args[3] != "EXPANSION" {
 			return Encode(errors.New("(error) 4th param must be EXPANSION for 'BF.RESERVE' command"), false)
 		}
-		growthRate, err = strconv.ParseUint(args[2], 10, 32)
+		growthRate, err = strconv.ParseUint(args[4], 10, 32)
 		if err != nil {
-			return Encode(errors.New(fmt.Sprintf("growthRate must be an integer number %s", args[2])), false)
+			return Encode(errors.New(fmt.Sprintf("growthRate must be an integer number %s", args[4])), false)
 		}
 		if growthRate < 1 {
 			return Encode(errors.New(fmt.Sprintf("growthRate should be greater or equal to 1 %d", growthRate)), false)
 		}
 	}
-	_, exist := sbStore[key]
-	if exist {
+	if dictStore.Get(key) != nil {
 		return Encode(errors.New(fmt.Sprintf("Bloom filter with key '%s' already exist", key)), false)
 	}
-	sbStore[key] = data_structure.CreateSBChain(capacity, errRate, growthRate)
+	putSBChain(key, data_structure.CreateSBChain(capacity, errRate, growthRate))
+	notifyKeyspaceEvent(NotifyModule, "bf.reserve", key)
 	return constant.RespOk
 }
 
@@ -47,8 +54,11 @@ func cmdBFINFO(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.INFO' command"), false)
 	}
 	key := args[0]
-	sb, exist := sbStore[key]
-	if !exist {
+	sb, err := getSBChain(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if sb == nil {
 		return Encode(errors.New(fmt.Sprintf("Bloom filter with key '%s' does not exist", key)), false)
 	}
 	var res []string
@@ -66,13 +76,15 @@ func cmdBFMADD(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MADD' command"), false)
 	}
 	key := args[0]
-	sb, exist := sbStore[key]
-	var err error
-	if !exist {
+	sb, err := getSBChain(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if sb == nil {
 		sb = data_structure.CreateSBChain(data_structure.BfDefaultInitCapacity,
 			data_structure.BfDefaultErrRate,
 			data_structure.BfDefaultExpansion)
-		sbStore[key] = sb
+		putSBChain(key, sb)
 	}
 	var res []string
 	for i := 1; i < len(args); i++ {
@@ -84,6 +96,7 @@ func cmdBFMADD(args []string) []byte {
 			res = append(res, "1")
 		}
 	}
+	notifyKeyspaceEvent(NotifyModule, "bf.madd", key)
 	return Encode(res, false)
 }
 
@@ -92,8 +105,11 @@ func cmdBFEXISTS(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.EXISTS' command"), false)
 	}
 	key, item := args[0], args[1]
-	sb, exist := sbStore[key]
-	if !exist {
+	sb, err := getSBChain(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if sb == nil {
 		return constant.RespZero
 	}
 	if !sb.Exist(item) {
@@ -107,10 +123,13 @@ func cmdBFMEXISTS(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MEXISTS' command"), false)
 	}
 	key := args[0]
-	sb, exist := sbStore[key]
+	sb, err := getSBChain(key)
+	if err != nil {
+		return Encode(err, false)
+	}
 	var res []string
 	for i := 1; i < len(args); i++ {
-		if !exist {
+		if sb == nil {
 			res = append(res, "0")
 			continue
 		}
@@ -123,3 +142,25 @@ func cmdBFMEXISTS(args []string) []byte {
 	}
 	return Encode(res, false)
 }
+
+/*
+BF.LOADCHUNK key iterator data
+Restores a Bloom filter dumped by the AOF rewrite. MemKV dumps a whole filter in a single chunk,
+so the iterator is always 1.
+*/
+func cmdBFLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	sb := &data_structure.SBChain{}
+	if err := sb.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putSBChain(key, sb)
+	notifyKeyspaceEvent(NotifyModule, "bf.loadchunk", key)
+	return constant.RespOk
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBFReserve(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "OK", evalReply("BF.RESERVE", "bf", "0.01", "100", "EXPANSION", "4"))
+	assert.EqualValues(t, 4, testSBChain("bf").GetGrowthFactor())
+	assert.EqualValues(t, 100, testSBChain("bf").GetCapacity())
+	assert.Contains(t, evalReply("BF.RESERVE", "bf", "0.01", "100"), "already exist")
+
+	assertErrorReply(t, evalReply("BF.RESERVE", "x", "0", "100"))
+	assertErrorReply(t, evalReply("BF.RESERVE", "x", "1", "100"))
+	assertErrorReply(t, evalReply("BF.RESERVE", "x", "-0.5", "100"))
+	assertErrorReply(t, evalReply("BF.RESERVE", "x", "NaN", "100"))
+	assertErrorReply(t, evalReply("BF.RESERVE", "x", "0.01", "0"))
+	assert.Contains(t, evalReply("BF.RESERVE", "x", "0.01", "100", "EXPANSION", "0"), "growthRate")
+	assert.EqualValues(t, 0, evalReply("EXISTS", "x"))
+}
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+// parseCFUint parses a parameter of CF.RESERVE, between 1 and max, 0 too if zero is true
+func parseCFUint(name string, s string, zero bool, max uint64) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || (n == 0 && !zero) || n > max {
+		return 0, errors.New(fmt.Sprintf("(error) ERR Bad %s", name))
+	}
+	return n, nil
+}
+
+/*
+CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]
+Creates a cuckoo filter for capacity items, with bucketsize items per bucket. maxiterations is the number of
+items moved to make room before the filter is declared full, and expansion the growth factor of the filter
+added then, 0 to never grow.
+*/
+func cmdCFRESERVE(args []string) []byte {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.RESERVE' command"), false)
+	}
+	key := args[0]
+	capacity, err := parseCFUint("capacity", args[1], false, math.MaxUint32)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var bucketSize, maxIterations, expansion uint64 = data_structure.CfDefaultBucketSize,
+		data_structure.CfDefaultMaxIterations, data_structure.CfDefaultExpansion
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "BUCKETSIZE":
+			bucketSize, err = parseCFUint("bucket size", args[i+1], false, data_structure.CfMaxBucketSize)
+		case "MAXITERATIONS":
+			maxIterations, err = parseCFUint("maxIterations", args[i+1], false, math.MaxUint16)
+		case "EXPANSION":
+			expansion, err = parseCFUint("expansion", args[i+1], true, math.MaxUint16/2+1)
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		if err != nil {
+			return Encode(err, false)
+		}
+	}
+	if dictStore.Get(key) != nil {
+		return Encode(errors.New("(error) ERR item exists"), false)
+	}
+	putCuckooFilter(key, data_structure.CreateCuckooFilter(capacity, uint8(bucketSize), uint16(maxIterations), uint16(expansion)))
+	notifyKeyspaceEvent(NotifyModule, "cf.reserve", key)
+	return constant.RespOk
+}
+
+// getOrCreateCuckooFilter returns the filter of key, created with capacity if it doesn't exist
+func getOrCreateCuckooFilter(key string, capacity uint64) (*data_structure.CuckooFilter, error) {
+	cf, err := getCuckooFilter(key)
+	if err != nil || cf != nil {
+		return cf, err
+	}
+	cf = data_structure.CreateCuckooFilter(capacity,
+		data_structure.CfDefaultBucketSize,
+		data_structure.CfDefaultMaxIterations,
+		data_structure.CfDefaultExpansion)
+	putCuckooFilter(key, cf)
+	return cf, nil
+}
+
+var errCFFull = errors.New("(error) ERR Filter is full")
+var errCFNotFound = errors.New("(error) ERR not found")
+
+// CF.ADD key item adds item, even if it already exists, to the filter of key, which is created if needed
+func cmdCFADD(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.ADD' command"), false)
+	}
+	key := args[0]
+	cf, err := getOrCreateCuckooFilter(key, data_structure.CfDefaultInitCapacity)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !cf.Add(args[1]) {
+		return Encode(errCFFull, false)
+	}
+	notifyKeyspaceEvent(NotifyModule, "cf.add", key)
+	return constant.RespOne
+}
+
+// CF.ADDNX key item adds item to the filter of key if it doesn't exist yet, and returns 1 if it was added
+func cmdCFADDNX(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.ADDNX' command"), false)
+	}
+	key := args[0]
+	cf, err := getOrCreateCuckooFilter(key, data_structure.CfDefaultInitCapacity)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf.Exist(args[1]) {
+		return constant.RespZero
+	}
+	if !cf.Add(args[1]) {
+		return Encode(errCFFull, false)
+	}
+	notifyKeyspaceEvent(NotifyModule, "cf.add", key)
+	return constant.RespOne
+}
+
+/*
+CF.INSERT key [CAPACITY capacity] [NOCREATE] ITEMS item [item ...]
+Adds the items to the filter of key, which is created with capacity unless NOCREATE is given. Returns for every
+item 1 if it was added, or -1 if the filter is full.
+*/
+func cmdCFINSERT(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.INSERT' command"), false)
+	}
+	key := args[0]
+	var capacity uint64 = data_structure.CfDefaultInitCapacity
+	noCreate := false
+	i := 1
+	for ; i < len(args) && strings.ToUpper(args[i]) != "ITEMS"; i++ {
+		switch strings.ToUpper(args[i]) {
+		case "CAPACITY":
+			if i+1 == len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			n, err := parseCFUint("capacity", args[i+1], false, math.MaxUint32)
+			if err != nil {
+				return Encode(err, false)
+			}
+			capacity = n
+			i++
+		case "NOCREATE":
+			noCreate = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	// i is at ITEMS
+	if i+1 >= len(args) {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.INSERT' command"), false)
+	}
+	cf, err := getCuckooFilter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf == nil {
+		if noCreate {
+			return Encode(errCFNotFound, false)
+		}
+		cf, _ = getOrCreateCuckooFilter(key, capacity)
+	}
+	var res []string
+	for _, item := range args[i+1:] {
+		if cf.Add(item) {
+			res = append(res, "1")
+		} else {
+			res = append(res, "-1")
+		}
+	}
+	notifyKeyspaceEvent(NotifyModule, "cf.insert", key)
+	return Encode(res, false)
+}
+
+func cmdCFEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.EXISTS' command"), false)
+	}
+	key, item := args[0], args[1]
+	cf, err := getCuckooFilter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf == nil || !cf.Exist(item) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func cmdCFMEXISTS(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.MEXISTS' command"), false)
+	}
+	key := args[0]
+	cf, err := getCuckooFilter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var res []string
+	for _, item := range args[1:] {
+		if cf == nil || !cf.Exist(item) {
+			res = append(res, "0")
+			continue
+		}
+		res = append(res, "1")
+	}
+	return Encode(res, false)
+}
+
+// CF.DEL key item deletes one occurrence of item from the filter of key, and returns 1 if it was found
+func cmdCFDEL(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.DEL' command"), false)
+	}
+	key := args[0]
+	cf, err := getCuckooFilter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf == nil {
+		return Encode(errCFNotFound, false)
+	}
+	if !cf.Delete(args[1]) {
+		return constant.RespZero
+	}
+	notifyKeyspaceEvent(NotifyModule, "cf.del", key)
+	return constant.RespOne
+}
+
+// CF.COUNT key item returns how many times item may have been added to the filter of key
+func cmdCFCOUNT(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.COUNT' command"), false)
+	}
+	cf, err := getCuckooFilter(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf == nil {
+		return constant.RespZero
+	}
+	return Encode(int64(cf.Count(args[1])), false)
+}
+
+func cmdCFINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.INFO' command"), false)
+	}
+	key := args[0]
+	cf, err := getCuckooFilter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cf == nil {
+		return Encode(errCFNotFound, false)
+	}
+	var res []string
+	res = append(res, "Size", fmt.Sprintf("%d", cf.GetMemUsage()),
+		"Number of buckets", fmt.Sprintf("%d", cf.GetBucketNumber()),
+		"Number of filters", fmt.Sprintf("%d", cf.GetFilterNumber()),
+		"Number of items inserted", fmt.Sprintf("%d", cf.GetSize()),
+		"Number of items deleted", fmt.Sprintf("%d", cf.GetDeletedNumber()),
+		"Bucket size", fmt.Sprintf("%d", cf.GetBucketSize()),
+		"Expansion rate", fmt.Sprintf("%d", cf.GetExpansion()),
+		"Max iterations", fmt.Sprintf("%d", cf.GetMaxIterations()))
+
+	return Encode(res, false)
+}
+
+/*
+CF.LOADCHUNK key iterator data
+Restores a cuckoo filter dumped by the AOF rewrite, in a single chunk like BF.LOADCHUNK.
+*/
+func cmdCFLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CF.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	cf := &data_structure.CuckooFilter{}
+	if err := cf.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putCuckooFilter(key, cf)
+	notifyKeyspaceEvent(NotifyModule, "cf.loadchunk", key)
+	return constant.RespOk
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCFReserve(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "OK", evalReply("CF.RESERVE", "cf", "1000", "BUCKETSIZE", "4", "MAXITERATIONS", "50", "EXPANSION", "2"))
+	assertErrorReply(t, evalReply("CF.RESERVE", "cf", "1000"))
+	assert.EqualValues(t, "MBbloomCF", evalReply("TYPE", "cf"))
+	info := evalReply("CF.INFO", "cf").([]interface{})
+	assert.EqualValues(t, []interface{}{"Number of buckets", "256"}, info[2:4])
+	assert.EqualValues(t, []interface{}{"Bucket size", "4", "Expansion rate", "2", "Max iterations", "50"}, info[10:])
+
+	assertErrorReply(t, evalReply("CF.RESERVE", "x", "0"))
+	assertErrorReply(t, evalReply("CF.RESERVE", "x", "10", "BUCKETSIZE", "256"))
+	assertErrorReply(t, evalReply("CF.RESERVE", "x", "10", "MAXITERATIONS", "0"))
+	assertErrorReply(t, evalReply("CF.RESERVE", "x", "10", "OTHER", "1"))
+	assertErrorReply(t, evalReply("CF.RESERVE", "x", "10", "EXPANSION"))
+	assertErrorReply(t, evalReply("CF.INFO", "x"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "x"))
+}
+
+func TestCFAddAndDel(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 1, evalReply("CF.ADD", "cf", "a"))
+	assert.EqualValues(t, 1, evalReply("CF.ADD", "cf", "a"))
+	assert.EqualValues(t, 0, evalReply("CF.ADDNX", "cf", "a"))
+	assert.EqualValues(t, 1, evalReply("CF.ADDNX", "cf", "b"))
+	assert.EqualValues(t, 2, evalReply("CF.COUNT", "cf", "a"))
+	assert.EqualValues(t, 1, evalReply("CF.EXISTS", "cf", "b"))
+	assert.EqualValues(t, 0, evalReply("CF.EXISTS", "cf", "c"))
+	assert.EqualValues(t, []interface{}{"1", "1", "0"}, evalReply("CF.MEXISTS", "cf", "a", "b", "c"))
+
+	assert.EqualValues(t, 1, evalReply("CF.DEL", "cf", "a"))
+	assert.EqualValues(t, 1, evalReply("CF.DEL", "cf", "a"))
+	assert.EqualValues(t, 0, evalReply("CF.DEL", "cf", "a"))
+	assert.EqualValues(t, 0, evalReply("CF.EXISTS", "cf", "a"))
+	info := evalReply("CF.INFO", "cf").([]interface{})
+	assert.EqualValues(t, []interface{}{"Number of items inserted", "1", "Number of items deleted", "2"}, info[6:10])
+
+	assert.EqualValues(t, 0, evalReply("CF.EXISTS", "none", "a"))
+	assert.EqualValues(t, 0, evalReply("CF.COUNT", "none", "a"))
+	assert.EqualValues(t, []interface{}{"0"}, evalReply("CF.MEXISTS", "none", "a"))
+	assertErrorReply(t, evalReply("CF.DEL", "none", "a"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("CF.ADD", "str", "a"))
+	assertErrorReply(t, evalReply("CF.EXISTS", "str", "a"))
+}
+
+func TestCFInsert(t *testing.T) {
+	resetStores()
+	assertErrorReply(t, evalReply("CF.INSERT", "cf", "NOCREATE", "ITEMS", "a"))
+	assertErrorReply(t, evalReply("CF.INSERT", "cf", "CAPACITY", "0", "ITEMS", "a"))
+	assertErrorReply(t, evalReply("CF.INSERT", "cf", "ITEMS"))
+	assertErrorReply(t, evalReply("CF.INSERT", "cf", "OTHER", "ITEMS", "a"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "cf"))
+
+	assert.EqualValues(t, []interface{}{"1", "1"}, evalReply("CF.INSERT", "cf", "CAPACITY", "100", "ITEMS", "a", "b"))
+	assert.EqualValues(t, []interface{}{"1"}, evalReply("CF.INSERT", "cf", "NOCREATE", "ITEMS", "c"))
+	assert.EqualValues(t, []interface{}{"1", "1", "1"}, evalReply("CF.MEXISTS", "cf", "a", "b", "c"))
+
+	// a filter that can't grow rejects the items once it is full
+	evalCmd("CF.RESERVE", "small", "4", "EXPANSION", "0")
+	args := []string{"CF.INSERT", "small", "ITEMS"}
+	for i := 0; i < 20; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	res := evalReply(args...).([]interface{})
+	assert.Contains(t, res, "-1")
+	assertErrorReply(t, evalReply("CF.ADD", "small", "x"))
+}
+
+func TestCFCopy(t *testing.T) {
+	resetStores()
+	evalCmd("CF.ADD", "a", "x")
+	assert.EqualValues(t, 1, evalReply("COPY", "a", "b"))
+	evalCmd("CF.DEL", "a", "x")
+	assert.EqualValues(t, 1, evalReply("CF.EXISTS", "b", "x"))
+	assertErrorReply(t, evalReply("CF.LOADCHUNK", "c", "1", "bad"))
+	assertErrorReply(t, evalReply("CF.LOADCHUNK", "c", "2", "bad"))
+}
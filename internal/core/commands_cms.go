@@ -22,11 +22,11 @@ func cmdCMSINITBYDIM(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("height must be a integer number %s", args[1])), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if dictStore.Get(key) != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
-	cmsStore[key] = data_structure.CreateCMS(uint32(width), uint32(height))
+	putCMS(key, data_structure.CreateCMS(uint32(width), uint32(height)))
+	notifyKeyspaceEvent(NotifyModule, "cms.initbydim", key)
 	return constant.RespOk
 }
 
@@ -49,12 +49,12 @@ func cmdCMSINITBYPROB(args []string) []byte {
 	if probability >= 1 || probability <= 0 {
 		return Encode(errors.New("CMS: invalid prob value"), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if dictStore.Get(key) != nil {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
 	w, h := data_structure.CalcCMSDim(errRate, probability)
-	cmsStore[key] = data_structure.CreateCMS(w, h)
+	putCMS(key, data_structure.CreateCMS(w, h))
+	notifyKeyspaceEvent(NotifyModule, "cms.initbyprob", key)
 	return constant.RespOk
 }
 
@@ -63,8 +63,11 @@ func cmdCMSINCRBY(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INCBY' command"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -81,6 +84,7 @@ func cmdCMSINCRBY(args []string) []byte {
 		}
 		res = append(res, fmt.Sprintf("%d", count))
 	}
+	notifyKeyspaceEvent(NotifyModule, "cms.incrby", key)
 	return Encode(res, false)
 }
 
@@ -89,8 +93,11 @@ func cmdCMSQUERY(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.QUERY' command"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -100,3 +107,24 @@ func cmdCMSQUERY(args []string) []byte {
 	}
 	return Encode(res, false)
 }
+
+/*
+CMS.LOADCHUNK key iterator data
+Restores a Count-Min Sketch dumped by the AOF rewrite, in a single chunk like BF.LOADCHUNK.
+*/
+func cmdCMSLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	cms := &data_structure.CMS{}
+	if err := cms.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putCMS(key, cms)
+	notifyKeyspaceEvent(NotifyModule, "cms.loadchunk", key)
+	return constant.RespOk
+}
@@ -26,6 +26,7 @@ func cmdSET(args []string) []byte {
 	}
 
 	dictStore.Put(key, dictStore.NewObj(value, ttlMs, oType, oEnc))
+	notifyKeyspaceEvent(NotifyString, "set", key)
 	return constant.RespOk
 }
 
@@ -44,6 +45,9 @@ func cmdGET(args []string) []byte {
 		return constant.RespNil
 	}
 
+	if err := assertType(obj.TypeEncoding, constant.ObjTypeString); err != nil {
+		return Encode(err, false)
+	}
 	return Encode(obj.Value, false)
 }
 
@@ -75,6 +79,7 @@ func cmdDEL(args []string) []byte {
 
 	for _, key := range args {
 		if ok := dictStore.Del(key); ok {
+			notifyKeyspaceEvent(NotifyGeneric, "del", key)
 			delCount++
 		}
 	}
@@ -97,7 +102,43 @@ func cmdEXPIRE(args []string) []byte {
 		return constant.RespZero
 	}
 
+	if ttlSec <= 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+		return constant.RespOne
+	}
 	dictStore.SetExpiry(obj, ttlSec*1000)
+	notifyKeyspaceEvent(NotifyGeneric, "expire", key)
+	return constant.RespOne
+}
+
+/*
+PEXPIREAT key unix-time-milliseconds
+Same as EXPIRE but the expiry is an absolute unix timestamp, this is how expiries are written to the AOF
+so that they don't restart when the file is replayed.
+*/
+func cmdPEXPIREAT(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PEXPIREAT' command"), false)
+	}
+	key := args[0]
+	expMs, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+	}
+
+	obj := dictStore.Get(key)
+	if obj == nil {
+		return constant.RespZero
+	}
+
+	if expMs <= time.Now().UnixMilli() {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+		return constant.RespOne
+	}
+	dictStore.SetExpiryAt(obj, uint64(expMs))
+	notifyKeyspaceEvent(NotifyGeneric, "expire", key)
 	return constant.RespOne
 }
 
@@ -117,12 +158,13 @@ func cmdINCR(args []string) []byte {
 	}
 
 	if err := assertEncoding(obj.TypeEncoding, constant.ObjEncodingInt); err != nil {
-		return Encode(err, false)
+		return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
 	}
 
 	i, _ := strconv.ParseInt(obj.Value.(string), 10, 64)
 	i++
 	obj.Value = strconv.FormatInt(i, 10)
+	notifyKeyspaceEvent(NotifyString, "incrby", key)
 
 	return Encode(i, false)
 }
@@ -60,16 +60,19 @@ func cmdGEODIST(args []string) []byte {
 		}
 	}
 
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
-	err, score1 := zset.GetScore(mem1)
-	if err != 0 {
+	ret, score1 := zset.GetScore(mem1)
+	if ret != 0 {
 		return constant.RespNil
 	}
-	err, score2 := zset.GetScore(mem2)
-	if err != 0 {
+	ret, score2 := zset.GetScore(mem2)
+	if ret != 0 {
 		return constant.RespNil
 	}
 	score1GeohashBit := data_structure.GeohashBits{
@@ -94,8 +97,11 @@ func cmdGEOHASH(args []string) []byte {
 		return constant.RespEmptyArray
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	var res []string
@@ -156,8 +162,11 @@ func cmdGEOSEARCH(args []string) []byte {
 	} else {
 		return Encode(errors.New("(error) 2nd param must be FROMMEMBER or FROMLONLAT"), false)
 	}
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespEmptyArray
 	}
 	q := data_structure.GeohashCircularSearchQuery{}
@@ -195,8 +204,11 @@ func cmdGEOPOS(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'GEOPOS' command"), false)
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	var res [][]string
@@ -0,0 +1,352 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+// hashForWrite returns the hash of key, created if it doesn't exist
+func hashForWrite(key string) (*data_structure.Hash, error) {
+	hash, err := getHash(key)
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		hash = data_structure.CreateHash()
+		putHash(key, hash)
+	}
+	return hash, nil
+}
+
+// HSET key field value [field value ...] sets the fields and returns the number of fields that were added
+func cmdHSET(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSET' command"), false)
+	}
+	key := args[0]
+	hash, err := hashForWrite(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if hash.Set(args[i], args[i+1]) {
+			added++
+		}
+	}
+	syncHashEncoding(key, hash)
+	notifyKeyspaceEvent(NotifyHash, "hset", key)
+	return Encode(added, false)
+}
+
+// HSETNX key field value sets field only if it doesn't exist, and returns 1 if it was set
+func cmdHSETNX(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSETNX' command"), false)
+	}
+	key := args[0]
+	hash, err := getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash != nil {
+		if _, ok := hash.Get(args[1]); ok {
+			return constant.RespZero
+		}
+	}
+	hash, _ = hashForWrite(key)
+	hash.Set(args[1], args[2])
+	syncHashEncoding(key, hash)
+	notifyKeyspaceEvent(NotifyHash, "hset", key)
+	return constant.RespOne
+}
+
+func cmdHGET(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HGET' command"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespNil
+	}
+	value, ok := hash.Get(args[1])
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(value, false)
+}
+
+// HMGET key field [field ...] returns the values of the fields, nil for the fields that don't exist
+func cmdHMGET(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HMGET' command"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(args)-1)
+	if hash == nil {
+		return Encode(res, false)
+	}
+	for i, field := range args[1:] {
+		if value, ok := hash.Get(field); ok {
+			res[i] = value
+		}
+	}
+	return Encode(res, false)
+}
+
+// HDEL key field [field ...] deletes the fields and returns the number of fields that existed
+func cmdHDEL(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HDEL' command"), false)
+	}
+	key := args[0]
+	hash, err := getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		if hash.Del(field) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		notifyKeyspaceEvent(NotifyHash, "hdel", key)
+	}
+	if hash.Len() == 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+	}
+	return Encode(deleted, false)
+}
+
+func cmdHLEN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HLEN' command"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	return Encode(hash.Len(), false)
+}
+
+func cmdHEXISTS(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HEXISTS' command"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	if _, ok := hash.Get(args[1]); !ok {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+// hashContent returns the fields, the values, or both interleaved, of the hash of key
+func hashContent(name string, args []string, withFields bool, withValues bool) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+name+"' command"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	if hash == nil {
+		return Encode(res, false)
+	}
+	hash.ForEach(func(field string, value string) {
+		if withFields {
+			res = append(res, field)
+		}
+		if withValues {
+			res = append(res, value)
+		}
+	})
+	return Encode(res, false)
+}
+
+// HGETALL key returns the fields and their values
+func cmdHGETALL(args []string) []byte {
+	return hashContent("HGETALL", args, true, true)
+}
+
+func cmdHKEYS(args []string) []byte {
+	return hashContent("HKEYS", args, true, false)
+}
+
+func cmdHVALS(args []string) []byte {
+	return hashContent("HVALS", args, false, true)
+}
+
+// HINCRBY key field increment adds increment to the integer value of field, which is created with 0 if needed
+func cmdHINCRBY(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HINCRBY' command"), false)
+	}
+	key := args[0]
+	incr, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	hash, err := hashForWrite(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if value, ok := hash.Get(args[1]); ok {
+		current, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Encode(errors.New("(error) ERR hash value is not an integer"), false)
+		}
+	}
+	if (incr > 0 && current > math.MaxInt64-incr) || (incr < 0 && current < math.MinInt64-incr) {
+		return Encode(errors.New("(error) ERR increment or decrement would overflow"), false)
+	}
+	current += incr
+	hash.Set(args[1], strconv.FormatInt(current, 10))
+	syncHashEncoding(key, hash)
+	notifyKeyspaceEvent(NotifyHash, "hincrby", key)
+	return Encode(current, false)
+}
+
+/*
+HINCRBYFLOAT key field increment
+Adds increment to the float value of field, which is created with 0 if needed, and returns the new value.
+It is logged to the AOF as a HSET of the new value, so that the replay doesn't depend on float rounding.
+*/
+func cmdHINCRBYFLOAT(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HINCRBYFLOAT' command"), false)
+	}
+	key := args[0]
+	incr, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(incr) || math.IsInf(incr, 0) {
+		return Encode(errors.New("(error) ERR value is not a valid float"), false)
+	}
+	hash, err := hashForWrite(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if value, ok := hash.Get(args[1]); ok {
+		current, err = strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(current) || math.IsInf(current, 0) {
+			return Encode(errors.New("(error) ERR hash value is not a float"), false)
+		}
+	}
+	current += incr
+	if math.IsNaN(current) || math.IsInf(current, 0) {
+		return Encode(errors.New("(error) ERR increment would produce NaN or Infinity"), false)
+	}
+	value := strconv.FormatFloat(current, 'f', -1, 64)
+	hash.Set(args[1], value)
+	syncHashEncoding(key, hash)
+	notifyKeyspaceEvent(NotifyHash, "hincrbyfloat", key)
+	return Encode(value, false)
+}
+
+// parseRandomCount parses the count of HRANDFIELD and SRANDMEMBER, a negative count may repeat elements and
+// is bounded as they are all replied at once
+func parseRandomCount(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if n < -data_structure.MaxRandomCount {
+		return 0, errors.New("(error) ERR value is out of range")
+	}
+	return n, nil
+}
+
+/*
+HRANDFIELD key [count [WITHVALUES]]
+Without count, returns a random field or nil. With count, returns count distinct fields, or all of them if the
+hash is smaller, or -count fields that may repeat if count is negative. WITHVALUES adds the value after every field.
+*/
+func cmdHRANDFIELD(args []string) []byte {
+	if len(args) < 1 || len(args) > 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HRANDFIELD' command"), false)
+	}
+	hasCount := len(args) > 1
+	count := 1
+	if hasCount {
+		var err error
+		if count, err = parseRandomCount(args[1]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	withValues := len(args) == 3
+	if withValues && strings.ToUpper(args[2]) != "WITHVALUES" {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		if hasCount {
+			return constant.RespEmptyArray
+		}
+		return constant.RespNil
+	}
+	fields, values := hash.RandomFields(count)
+	if !hasCount {
+		return Encode(fields[0], false)
+	}
+	if !withValues {
+		return Encode(fields, false)
+	}
+	res := make([]string, 0, 2*len(fields))
+	for i := range fields {
+		res = append(res, fields[i], values[i])
+	}
+	return Encode(res, false)
+}
+
+// HSCAN key cursor [MATCH pattern] [COUNT count] iterates the fields of a hash like SCAN, with their values
+func cmdHSCAN(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'HSCAN' command"), false)
+	}
+	opts, err := parseScanOptions(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	hash, err := getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	elements := make([]string, 0)
+	if hash == nil {
+		return encodeScanReply(0, elements)
+	}
+	next := hash.Scan(opts.cursor, opts.count, func(field string, value string) {
+		if opts.match(field) {
+			elements = append(elements, field, value)
+		}
+	})
+	return encodeScanReply(next, elements)
+}
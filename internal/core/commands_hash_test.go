@@ -0,0 +1,126 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+)
+
+func TestHashCommands(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 2, evalReply("HSET", "user", "name", "alice", "age", "30"))
+	assert.EqualValues(t, 1, evalReply("HSET", "user", "name", "bob", "city", "paris"))
+	assertErrorReply(t, evalReply("HSET", "user", "name"))
+	assert.EqualValues(t, "hash", evalReply("TYPE", "user"))
+	assert.EqualValues(t, "bob", evalReply("HGET", "user", "name"))
+	assert.Nil(t, evalReply("HGET", "user", "missing"))
+	assert.Nil(t, evalReply("HGET", "missing", "name"))
+	assert.EqualValues(t, []interface{}{"bob", nil, "30"}, evalReply("HMGET", "user", "name", "x", "age"))
+	assert.EqualValues(t, []interface{}{nil}, evalReply("HMGET", "missing", "name"))
+	assert.EqualValues(t, 3, evalReply("HLEN", "user"))
+	assert.EqualValues(t, 1, evalReply("HEXISTS", "user", "city"))
+	assert.EqualValues(t, 0, evalReply("HEXISTS", "user", "x"))
+
+	// a small hash keeps the insertion order
+	assert.EqualValues(t, []interface{}{"name", "bob", "age", "30", "city", "paris"}, evalReply("HGETALL", "user"))
+	assert.EqualValues(t, []interface{}{"name", "age", "city"}, evalReply("HKEYS", "user"))
+	assert.EqualValues(t, []interface{}{"bob", "30", "paris"}, evalReply("HVALS", "user"))
+	assert.EqualValues(t, []interface{}{}, evalReply("HGETALL", "missing"))
+
+	assert.EqualValues(t, 0, evalReply("HSETNX", "user", "name", "carol"))
+	assert.EqualValues(t, 1, evalReply("HSETNX", "user", "email", "b@x"))
+	assert.EqualValues(t, 1, evalReply("HSETNX", "other", "f", "v"))
+
+	assert.EqualValues(t, 2, evalReply("HDEL", "user", "email", "city", "x"))
+	assert.EqualValues(t, 2, evalReply("HDEL", "user", "name", "age"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "user"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("HSET", "str", "f", "v"))
+	assertErrorReply(t, evalReply("HGET", "str", "f"))
+}
+
+func TestHashIncr(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 5, evalReply("HINCRBY", "h", "n", "5"))
+	assert.EqualValues(t, 2, evalReply("HINCRBY", "h", "n", "-3"))
+	assertErrorReply(t, evalReply("HINCRBY", "h", "n", "x"))
+	evalCmd("HSET", "h", "s", "abc", "max", strconv.FormatInt(1<<62, 10))
+	assertErrorReply(t, evalReply("HINCRBY", "h", "s", "1"))
+	assertErrorReply(t, evalReply("HINCRBY", "h", "max", strconv.FormatInt(1<<62, 10)))
+
+	assert.EqualValues(t, "10.5", evalReply("HINCRBYFLOAT", "h", "f", "10.5"))
+	assert.EqualValues(t, "10.6", evalReply("HINCRBYFLOAT", "h", "f", "0.1"))
+	assert.EqualValues(t, "7.6", evalReply("HINCRBYFLOAT", "h", "n", "5.6"))
+	assert.EqualValues(t, "5000", evalReply("HINCRBYFLOAT", "h", "e", "5.0e3"))
+	assertErrorReply(t, evalReply("HINCRBYFLOAT", "h", "s", "1"))
+	assertErrorReply(t, evalReply("HINCRBYFLOAT", "h", "f", "inf"))
+	assertErrorReply(t, evalReply("HINCRBYFLOAT", "h", "f", "x"))
+}
+
+func TestHashEncoding(t *testing.T) {
+	resetStores()
+	evalCmd("HSET", "h", "f", "v")
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingListpack, dictStore.Get("h").TypeEncoding)
+	for i := 0; i < config.HashMaxListpackEntries; i++ {
+		evalCmd("HSET", "h", strconv.Itoa(i), "v")
+	}
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingHashTable, dictStore.Get("h").TypeEncoding)
+	assert.EqualValues(t, config.HashMaxListpackEntries+1, evalReply("HLEN", "h"))
+
+	// COPY keeps the encoding
+	evalCmd("COPY", "h", "h2")
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingHashTable, dictStore.Get("h2").TypeEncoding)
+	assert.EqualValues(t, "v", evalReply("HGET", "h2", "42"))
+
+	// a long value converts a small hash
+	evalCmd("HSET", "long", "f", "v")
+	evalCmd("HINCRBYFLOAT", "long", "n", "0.1")
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingListpack, dictStore.Get("long").TypeEncoding)
+	evalCmd("HSETNX", "long", "big", string(make([]byte, config.HashMaxListpackValue+1)))
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingHashTable, dictStore.Get("long").TypeEncoding)
+}
+
+func TestHashRandFieldAndScan(t *testing.T) {
+	resetStores()
+	assert.Nil(t, evalReply("HRANDFIELD", "h"))
+	assert.EqualValues(t, []interface{}{}, evalReply("HRANDFIELD", "h", "3"))
+	for i := 0; i < 300; i++ {
+		evalCmd("HSET", "h", "f"+strconv.Itoa(i), "v"+strconv.Itoa(i))
+	}
+	field := evalReply("HRANDFIELD", "h").(string)
+	assert.EqualValues(t, 1, evalReply("HEXISTS", "h", field))
+
+	res := evalReply("HRANDFIELD", "h", "5", "WITHVALUES").([]interface{})
+	assert.Equal(t, 10, len(res))
+	for i := 0; i < len(res); i += 2 {
+		assert.Equal(t, "v"+res[i].(string)[1:], res[i+1])
+	}
+	assert.Equal(t, 300, len(evalReply("HRANDFIELD", "h", "1000").([]interface{})))
+	assert.Equal(t, 1000, len(evalReply("HRANDFIELD", "h", "-1000").([]interface{})))
+	assertErrorReply(t, evalReply("HRANDFIELD", "h", "1", "WITHSCORES"))
+	assertErrorReply(t, evalReply("HRANDFIELD", "h", "-9223372036854775808"))
+	assertErrorReply(t, evalReply("HRANDFIELD", "h", strconv.Itoa(-data_structure.MaxRandomCount-1)))
+
+	fields := map[string]string{}
+	cursor := "0"
+	for {
+		res := evalReply("HSCAN", "h", cursor, "MATCH", "f1*", "COUNT", "20").([]interface{})
+		elements := res[1].([]interface{})
+		for i := 0; i < len(elements); i += 2 {
+			fields[elements[i].(string)] = elements[i+1].(string)
+		}
+		cursor = res[0].(string)
+		if cursor == "0" {
+			break
+		}
+	}
+	// f1, f10-f19, f100-f199
+	assert.Equal(t, 111, len(fields))
+	assert.Equal(t, "v150", fields["f150"])
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"errors"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+)
+
+/*
+PFADD key [element [element ...]]
+Adds the elements to the HyperLogLog of key, which is created if needed. Returns 1 if the estimated
+cardinality may have changed, or the key was created, 0 otherwise.
+*/
+func cmdPFADD(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PFADD' command"), false)
+	}
+	key := args[0]
+	hll, err := getHyperLogLog(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	updated := false
+	if hll == nil {
+		hll = data_structure.CreateHyperLogLog()
+		putHyperLogLog(key, hll)
+		updated = true
+	}
+	if hll.Add(args[1:]...) {
+		updated = true
+	}
+	if !updated {
+		return constant.RespZero
+	}
+	notifyKeyspaceEvent(NotifyString, "pfadd", key)
+	return constant.RespOne
+}
+
+/*
+PFCOUNT key [key ...]
+Returns the estimated number of distinct elements added to the HyperLogLog of key, or to any of the
+HyperLogLogs of the keys, whose union is estimated without modifying them. Missing keys count as empty.
+*/
+func cmdPFCOUNT(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PFCOUNT' command"), false)
+	}
+	if len(args) == 1 {
+		hll, err := getHyperLogLog(args[0])
+		if err != nil {
+			return Encode(err, false)
+		}
+		if hll == nil {
+			return constant.RespZero
+		}
+		return Encode(int64(hll.Count()), false)
+	}
+	union := data_structure.CreateHyperLogLog()
+	for _, key := range args {
+		hll, err := getHyperLogLog(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if hll != nil {
+			union.Merge(hll)
+		}
+	}
+	return Encode(int64(union.Count()), false)
+}
+
+/*
+PFMERGE destkey [sourcekey [sourcekey ...]]
+Merges the HyperLogLogs of the source keys into the HyperLogLog of destkey, which is created if needed, so that
+it estimates the cardinality of the union of all of them.
+*/
+func cmdPFMERGE(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PFMERGE' command"), false)
+	}
+	dst := args[0]
+	// check every key before modifying destkey
+	sources := make([]*data_structure.HyperLogLog, 0, len(args)-1)
+	for _, key := range args[1:] {
+		hll, err := getHyperLogLog(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if hll != nil {
+			sources = append(sources, hll)
+		}
+	}
+	hll, err := getHyperLogLog(dst)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hll == nil {
+		hll = data_structure.CreateHyperLogLog()
+		putHyperLogLog(dst, hll)
+	}
+	for _, src := range sources {
+		if src != hll {
+			hll.Merge(src)
+		}
+	}
+	notifyKeyspaceEvent(NotifyString, "pfadd", dst)
+	return constant.RespOk
+}
+
+/*
+PFLOADCHUNK key data
+Restores a HyperLogLog dumped by the AOF rewrite, with its encoding, like CMS.LOADCHUNK.
+*/
+func cmdPFLOADCHUNK(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PFLOADCHUNK' command"), false)
+	}
+	hll := &data_structure.HyperLogLog{}
+	if err := hll.UnmarshalBinary([]byte(args[1])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putHyperLogLog(args[0], hll)
+	notifyKeyspaceEvent(NotifyString, "pfadd", args[0])
+	return constant.RespOk
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPFAddAndCount(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 0, evalReply("PFCOUNT", "hll"))
+	assert.EqualValues(t, 1, evalReply("PFADD", "hll"))
+	assert.EqualValues(t, 0, evalReply("PFADD", "hll"))
+	assert.EqualValues(t, 0, evalReply("PFCOUNT", "hll"))
+	assert.EqualValues(t, "hyperloglog", evalReply("TYPE", "hll"))
+
+	assert.EqualValues(t, 1, evalReply("PFADD", "hll", "a", "b", "c"))
+	assert.EqualValues(t, 0, evalReply("PFADD", "hll", "a", "b"))
+	assert.EqualValues(t, 3, evalReply("PFCOUNT", "hll"))
+
+	args := []string{"PFADD", "big"}
+	for i := 0; i < 10000; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	evalCmd(args...)
+	assert.InDelta(t, 10000, evalReply("PFCOUNT", "big"), 400)
+	assert.False(t, testHyperLogLog("big").IsSparse())
+	assert.True(t, testHyperLogLog("hll").IsSparse())
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("PFADD", "str", "a"))
+	assertErrorReply(t, evalReply("PFCOUNT", "str"))
+	assertErrorReply(t, evalReply("PFCOUNT", "hll", "str"))
+}
+
+func TestPFCountUnion(t *testing.T) {
+	resetStores()
+	evalCmd("PFADD", "a", "1", "2", "3")
+	evalCmd("PFADD", "b", "3", "4")
+	assert.EqualValues(t, 4, evalReply("PFCOUNT", "a", "b", "missing"))
+	// the sources are not modified
+	assert.EqualValues(t, 3, evalReply("PFCOUNT", "a"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "missing"))
+}
+
+func TestPFMerge(t *testing.T) {
+	resetStores()
+	evalCmd("PFADD", "a", "1", "2", "3")
+	evalCmd("PFADD", "b", "3", "4")
+	assert.EqualValues(t, "OK", evalReply("PFMERGE", "dst", "a", "b", "missing"))
+	assert.EqualValues(t, 4, evalReply("PFCOUNT", "dst"))
+	assert.EqualValues(t, 3, evalReply("PFCOUNT", "a"))
+
+	// the destination is part of the union
+	evalCmd("PFADD", "c", "5")
+	assert.EqualValues(t, "OK", evalReply("PFMERGE", "dst", "c", "dst"))
+	assert.EqualValues(t, 5, evalReply("PFCOUNT", "dst"))
+	assert.EqualValues(t, "OK", evalReply("PFMERGE", "empty"))
+	assert.EqualValues(t, 0, evalReply("PFCOUNT", "empty"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("PFMERGE", "dst", "str"))
+	assertErrorReply(t, evalReply("PFMERGE", "str", "a"))
+	assert.EqualValues(t, 5, evalReply("PFCOUNT", "dst"))
+}
+
+func TestPFCopy(t *testing.T) {
+	resetStores()
+	evalCmd("PFADD", "a", "1", "2")
+	assert.EqualValues(t, 1, evalReply("COPY", "a", "b"))
+	evalCmd("PFADD", "a", "3")
+	assert.EqualValues(t, 2, evalReply("PFCOUNT", "b"))
+	assertErrorReply(t, evalReply("PFLOADCHUNK", "c", "bad"))
+}
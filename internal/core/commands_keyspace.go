@@ -0,0 +1,347 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"memkv/internal/util"
+	"strconv"
+	"strings"
+)
+
+// typeNames are the names returned by TYPE, the probabilistic types are named like in RedisBloom
+var typeNames = map[uint8]string{
+	constant.ObjTypeString:  "string",
+	constant.ObjTypeSet:     "set",
+	constant.ObjTypeZSet:    "zset",
+	constant.ObjTypeList:    "list",
+	constant.ObjTypeHash:    "hash",
+	constant.ObjTypeStream:  "stream",
+	constant.ObjTypeBloom:   "MBbloom--",
+	constant.ObjTypeCuckoo:  "MBbloomCF",
+	constant.ObjTypeCMS:     "CMSk-TYPE",
+	constant.ObjTypeTopK:    "TopK-TYPE",
+	constant.ObjTypeTDigest: "TDIS-TYPE",
+	constant.ObjTypeMorris:  "morris",
+	constant.ObjTypeHLL:     "hyperloglog",
+}
+
+func cmdTYPE(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TYPE' command"), false)
+	}
+	obj := dictStore.Get(args[0])
+	if obj == nil {
+		return Encode("none", true)
+	}
+	return Encode(typeNames[getType(obj.TypeEncoding)], true)
+}
+
+var encodingNames = map[uint8]string{
+	constant.ObjEncodingRaw:       "raw",
+	constant.ObjEncodingInt:       "int",
+	constant.ObjEncodingHashTable: "hashtable",
+	constant.ObjEncodingSkiplist:  "skiplist",
+	constant.ObjEncodingQuickList: "quicklist",
+	constant.ObjEncodingListpack:  "listpack",
+	constant.ObjEncodingStream:    "stream",
+	constant.ObjEncodingIntset:    "intset",
+}
+
+/*
+OBJECT ENCODING key
+Returns the name of the encoding of the value of key, or nil if the key doesn't exist.
+*/
+func cmdOBJECT(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'OBJECT' command"), false)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "ENCODING":
+		if len(args) != 2 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'OBJECT ENCODING' command"), false)
+		}
+		obj := dictStore.Get(args[1])
+		if obj == nil {
+			return constant.RespNil
+		}
+		return Encode(encodingNames[getEncoding(obj.TypeEncoding)], false)
+	default:
+		return Encode(fmt.Errorf("(error) ERR unknown subcommand '%s'", args[0]), false)
+	}
+}
+
+// EXISTS key [key ...] returns the number of keys that exist, a key given twice is counted twice
+func cmdEXISTS(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'EXISTS' command"), false)
+	}
+	count := 0
+	for _, key := range args {
+		if dictStore.Get(key) != nil {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// UNLINK is DEL: Go frees memory in the background anyway
+func cmdUNLINK(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'UNLINK' command"), false)
+	}
+	return cmdDEL(args)
+}
+
+// renameKey moves the value of src and its expiry to dst, replacing dst
+func renameKey(src string, dst string, obj *data_structure.Obj) {
+	exp, hasExpiry := dictStore.GetExpiry(obj)
+	dictStore.Del(src)
+	dictStore.Put(dst, obj)
+	if hasExpiry {
+		dictStore.SetExpiryAt(obj, exp)
+	}
+	notifyKeyspaceEvent(NotifyGeneric, "rename_from", src)
+	notifyKeyspaceEvent(NotifyGeneric, "rename_to", dst)
+}
+
+/*
+RENAME key newkey
+Renames key to newkey with its TTL, newkey is overwritten if it exists.
+*/
+func cmdRENAME(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'RENAME' command"), false)
+	}
+	src, dst := args[0], args[1]
+	obj := dictStore.Get(src)
+	if obj == nil {
+		return Encode(errors.New("(error) ERR no such key"), false)
+	}
+	if src != dst {
+		renameKey(src, dst, obj)
+	}
+	return constant.RespOk
+}
+
+/*
+RENAMENX key newkey
+Renames key to newkey only if newkey doesn't exist. Returns 1 if key was renamed, 0 otherwise.
+*/
+func cmdRENAMENX(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'RENAMENX' command"), false)
+	}
+	src, dst := args[0], args[1]
+	obj := dictStore.Get(src)
+	if obj == nil {
+		return Encode(errors.New("(error) ERR no such key"), false)
+	}
+	if dictStore.Get(dst) != nil {
+		return constant.RespZero
+	}
+	renameKey(src, dst, obj)
+	return constant.RespOne
+}
+
+// dupValue returns a deep copy of the value of obj, to be stored under key
+func dupValue(obj *data_structure.Obj, key string) interface{} {
+	switch v := obj.Value.(type) {
+	case data_structure.Set:
+		return v.Dup(key)
+	case *data_structure.QuickList:
+		list := data_structure.CreateQuickList()
+		v.ForEach(func(value string) {
+			list.Push(data_structure.ListTail, value)
+		})
+		return list
+	case *data_structure.Hash:
+		return v.Dup()
+	case *data_structure.Stream:
+		return v.Dup()
+	case *data_structure.ZSet:
+		zset := data_structure.CreateZSet()
+		v.ForEach(func(ele string, score float64) {
+			zset.Add(score, ele, 0)
+		})
+		return zset
+	case *data_structure.SBChain:
+		data, _ := v.MarshalBinary()
+		sb := &data_structure.SBChain{}
+		sb.UnmarshalBinary(data)
+		return sb
+	case *data_structure.HyperLogLog:
+		data, _ := v.MarshalBinary()
+		hll := &data_structure.HyperLogLog{}
+		hll.UnmarshalBinary(data)
+		return hll
+	case *data_structure.CuckooFilter:
+		data, _ := v.MarshalBinary()
+		cf := &data_structure.CuckooFilter{}
+		cf.UnmarshalBinary(data)
+		return cf
+	case *data_structure.TopK:
+		data, _ := v.MarshalBinary()
+		topk := &data_structure.TopK{}
+		topk.UnmarshalBinary(data)
+		return topk
+	case *data_structure.TDigest:
+		data, _ := v.MarshalBinary()
+		td := &data_structure.TDigest{}
+		td.UnmarshalBinary(data)
+		return td
+	case *data_structure.MorrisCounter:
+		data, _ := v.MarshalBinary()
+		m := &data_structure.MorrisCounter{}
+		m.UnmarshalBinary(data)
+		return m
+	case *data_structure.CMS:
+		data, _ := v.MarshalBinary()
+		cms := &data_structure.CMS{}
+		cms.UnmarshalBinary(data)
+		return cms
+	}
+	// strings are immutable
+	return obj.Value
+}
+
+/*
+COPY source destination [REPLACE]
+Copies the value of source and its TTL to destination. Returns 1 if source was copied, 0 if it doesn't exist
+or destination exists and REPLACE is not given.
+*/
+func cmdCOPY(args []string) []byte {
+	if len(args) != 2 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'COPY' command"), false)
+	}
+	src, dst := args[0], args[1]
+	replace := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "REPLACE" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		replace = true
+	}
+	if src == dst {
+		return Encode(errors.New("(error) ERR source and destination objects are the same"), false)
+	}
+	obj := dictStore.Get(src)
+	if obj == nil {
+		return constant.RespZero
+	}
+	if !replace && dictStore.Get(dst) != nil {
+		return constant.RespZero
+	}
+	dup := dictStore.NewObj(dupValue(obj, dst), constant.NoExpire, getType(obj.TypeEncoding), getEncoding(obj.TypeEncoding))
+	dictStore.Put(dst, dup)
+	if exp, ok := dictStore.GetExpiry(obj); ok {
+		dictStore.SetExpiryAt(dup, exp)
+	}
+	notifyKeyspaceEvent(NotifyGeneric, "copy_to", dst)
+	// a replaced key is not new, but clients may be blocked on it if it was not a list
+	signalKeyAsReady(dst)
+	return constant.RespOne
+}
+
+// KEYS pattern returns all the keys matching the glob-style pattern
+func cmdKEYS(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'KEYS' command"), false)
+	}
+	keys := make([]string, 0)
+	dictStore.ForEach(func(key string, _ *data_structure.Obj) {
+		if util.GlobMatch(args[0], key) {
+			keys = append(keys, key)
+		}
+	})
+	return Encode(keys, false)
+}
+
+func cmdRANDOMKEY(args []string) []byte {
+	if len(args) != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'RANDOMKEY' command"), false)
+	}
+	key, ok := dictStore.RandomKey()
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(key, false)
+}
+
+// scanOptions are the options of SCAN, SSCAN and ZSCAN
+type scanOptions struct {
+	cursor  uint64
+	pattern string
+	count   int
+	// typeName is the type of the keys returned by SCAN, empty for any type
+	typeName string
+}
+
+// parseScanOptions parses cursor [MATCH pattern] [COUNT count], and [TYPE type] if allowType
+func parseScanOptions(args []string, allowType bool) (*scanOptions, error) {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("(error) ERR invalid cursor")
+	}
+	opts := &scanOptions{cursor: cursor, count: 10}
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, errors.New("(error) ERR syntax error")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			opts.pattern = args[i+1]
+		case "COUNT":
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				return nil, errors.New("(error) ERR syntax error")
+			}
+			opts.count = count
+		case "TYPE":
+			if !allowType {
+				return nil, errors.New("(error) ERR syntax error")
+			}
+			opts.typeName = args[i+1]
+		default:
+			return nil, errors.New("(error) ERR syntax error")
+		}
+	}
+	return opts, nil
+}
+
+// match reports whether s matches the MATCH pattern, if any
+func (opts *scanOptions) match(s string) bool {
+	return opts.pattern == "" || util.GlobMatch(opts.pattern, s)
+}
+
+// encodeScanReply encodes the reply of the SCAN commands: the next cursor and the elements
+func encodeScanReply(cursor uint64, elements []string) []byte {
+	return Encode([]interface{}{strconv.FormatUint(cursor, 10), elements}, false)
+}
+
+/*
+SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+Iterates the keyspace: a full iteration starts with cursor 0 and ends when the returned cursor is 0. Every key
+present during the whole iteration is returned at least once. COUNT is the number of keys visited per call,
+before filtering with MATCH and TYPE.
+*/
+func cmdSCAN(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SCAN' command"), false)
+	}
+	opts, err := parseScanOptions(args, true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys := make([]string, 0)
+	next := dictStore.Scan(opts.cursor, opts.count, func(key string, obj *data_structure.Obj) {
+		if opts.typeName != "" && !strings.EqualFold(typeNames[getType(obj.TypeEncoding)], opts.typeName) {
+			return
+		}
+		if opts.match(key) {
+			keys = append(keys, key)
+		}
+	})
+	return encodeScanReply(next, keys)
+}
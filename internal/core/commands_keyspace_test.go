@@ -0,0 +1,239 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrongType(t *testing.T) {
+	resetStores()
+	evalCmd("SET", "str", "v")
+	evalCmd("SADD", "set", "a")
+
+	assertErrorReply(t, evalReply("SADD", "str", "a"))
+	assertErrorReply(t, evalReply("ZADD", "str", "1", "a"))
+	assertErrorReply(t, evalReply("BF.MADD", "set", "a"))
+	assertErrorReply(t, evalReply("CMS.QUERY", "set", "a"))
+	assert.Contains(t, evalReply("GET", "set"), "WRONGTYPE")
+	assertErrorReply(t, evalReply("INCR", "set"))
+	// the failed commands didn't touch the values
+	assert.EqualValues(t, "v", evalReply("GET", "str"))
+	assert.EqualValues(t, 1, evalReply("SCARD", "set"))
+
+	// SET overwrites any type
+	assert.EqualValues(t, "OK", evalReply("SET", "set", "v"))
+	assert.EqualValues(t, "v", evalReply("GET", "set"))
+}
+
+func TestTypeAndExists(t *testing.T) {
+	resetStores()
+	evalCmd("SET", "str", "v")
+	evalCmd("SADD", "set", "a")
+	evalCmd("ZADD", "zset", "1", "a")
+	evalCmd("BF.MADD", "bf", "a")
+	evalCmd("CMS.INITBYDIM", "cms", "10", "2")
+
+	assert.EqualValues(t, "string", evalReply("TYPE", "str"))
+	assert.EqualValues(t, "set", evalReply("TYPE", "set"))
+	assert.EqualValues(t, "zset", evalReply("TYPE", "zset"))
+	assert.EqualValues(t, "MBbloom--", evalReply("TYPE", "bf"))
+	assert.EqualValues(t, "CMSk-TYPE", evalReply("TYPE", "cms"))
+	assert.EqualValues(t, "none", evalReply("TYPE", "missing"))
+
+	assert.EqualValues(t, 3, evalReply("EXISTS", "str", "set", "missing", "set"))
+	assert.EqualValues(t, 2, evalReply("UNLINK", "set", "zset", "missing"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "set", "zset"))
+	assert.EqualValues(t, 1, evalReply("DEL", "bf"))
+	assert.EqualValues(t, "none", evalReply("TYPE", "bf"))
+}
+
+func TestExpireAnyType(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "set", "a", "b")
+	assert.EqualValues(t, 1, evalReply("EXPIRE", "set", "100"))
+	assert.EqualValues(t, 100, evalReply("TTL", "set"))
+	assert.EqualValues(t, 1, evalReply("EXPIRE", "set", "0"))
+	assert.EqualValues(t, 0, evalReply("SCARD", "set"))
+	assert.EqualValues(t, "none", evalReply("TYPE", "set"))
+
+	// removing the last member deletes the key
+	evalCmd("SADD", "set", "a")
+	evalCmd("SREM", "set", "a")
+	assert.EqualValues(t, 0, evalReply("EXISTS", "set"))
+	evalCmd("ZADD", "zset", "1", "a")
+	evalCmd("ZREM", "zset", "a")
+	assert.EqualValues(t, 0, evalReply("EXISTS", "zset"))
+}
+
+func TestRename(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "set", "a", "b")
+	evalCmd("EXPIRE", "set", "100")
+	evalCmd("SET", "dst", "v")
+
+	assert.EqualValues(t, "OK", evalReply("RENAME", "set", "dst"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "set"))
+	assert.EqualValues(t, "set", evalReply("TYPE", "dst"))
+	assert.EqualValues(t, 2, evalReply("SCARD", "dst"))
+	assert.EqualValues(t, 100, evalReply("TTL", "dst"))
+	assertErrorReply(t, evalReply("RENAME", "set", "dst"))
+	assert.EqualValues(t, "OK", evalReply("RENAME", "dst", "dst"))
+	assert.EqualValues(t, 2, evalReply("SCARD", "dst"))
+
+	evalCmd("SET", "str", "v")
+	assert.EqualValues(t, 0, evalReply("RENAMENX", "str", "dst"))
+	assert.EqualValues(t, 1, evalReply("RENAMENX", "str", "str2"))
+	assert.EqualValues(t, "v", evalReply("GET", "str2"))
+	assert.EqualValues(t, -1, evalReply("TTL", "str2"))
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+}
+
+func TestCopy(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "set", "a", "b")
+	evalCmd("EXPIRE", "set", "100")
+	evalCmd("ZADD", "zset", "1", "a")
+	evalCmd("CMS.INITBYDIM", "cms", "10", "2")
+	evalCmd("CMS.INCRBY", "cms", "item", "3")
+	evalCmd("SET", "str", "v")
+
+	assert.EqualValues(t, 1, evalReply("COPY", "set", "set2"))
+	// TTL rounds down, so a millisecond may have passed
+	assert.InDelta(t, 100, evalReply("TTL", "set2"), 1)
+	// the copy is independent of the source
+	evalCmd("SADD", "set2", "c")
+	assert.EqualValues(t, 2, evalReply("SCARD", "set"))
+	assert.EqualValues(t, 3, evalReply("SCARD", "set2"))
+
+	assert.EqualValues(t, 1, evalReply("COPY", "zset", "zset2"))
+	evalCmd("ZADD", "zset2", "2", "b")
+	assert.EqualValues(t, 1, evalReply("ZCARD", "zset"))
+	assert.EqualValues(t, 2, evalReply("ZCARD", "zset2"))
+
+	assert.EqualValues(t, 1, evalReply("COPY", "cms", "cms2"))
+	evalCmd("CMS.INCRBY", "cms2", "item", "1")
+	assert.EqualValues(t, 3, testCMS("cms").Count("item"))
+	assert.EqualValues(t, 4, testCMS("cms2").Count("item"))
+
+	assert.EqualValues(t, 0, evalReply("COPY", "str", "set2"))
+	assert.EqualValues(t, 0, evalReply("COPY", "missing", "str2"))
+	assert.EqualValues(t, 1, evalReply("COPY", "str", "set2", "REPLACE"))
+	assert.EqualValues(t, "v", evalReply("GET", "set2"))
+	assert.EqualValues(t, -1, evalReply("TTL", "set2"))
+	assertErrorReply(t, evalReply("COPY", "str", "str"))
+	assertErrorReply(t, evalReply("COPY", "str", "x", "FOO"))
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+}
+
+func TestKeys(t *testing.T) {
+	resetStores()
+	for _, key := range []string{"hello", "hallo", "hxllo", "hllo", "heeeello", "h*llo", "user:1", "user:2"} {
+		evalCmd("SET", key, "v")
+	}
+	assert.ElementsMatch(t, []string{"hello", "hallo", "hxllo", "h*llo"}, evalReply("KEYS", "h?llo"))
+	assert.ElementsMatch(t, []string{"hello", "hallo", "hxllo", "hllo", "heeeello", "h*llo"}, evalReply("KEYS", "h*llo"))
+	assert.ElementsMatch(t, []string{"hello", "hallo"}, evalReply("KEYS", "h[ae]llo"))
+	assert.ElementsMatch(t, []string{"hxllo", "h*llo"}, evalReply("KEYS", "h[^ae]llo"))
+	assert.ElementsMatch(t, []string{"hallo"}, evalReply("KEYS", "h[a-b]llo"))
+	assert.ElementsMatch(t, []string{"h*llo"}, evalReply("KEYS", `h\*llo`))
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, evalReply("KEYS", "user:*"))
+	assert.Len(t, evalReply("KEYS", "*"), 8)
+	assert.Len(t, evalReply("KEYS", "nothing*"), 0)
+}
+
+// scanAll runs a full iteration of a SCAN command, cursor being the first argument after args
+func scanAll(t *testing.T, args []string, opts ...string) []string {
+	var res []string
+	cursor := "0"
+	for {
+		reply := evalReply(append(append(append([]string{}, args...), cursor), opts...)...).([]interface{})
+		for _, e := range reply[1].([]interface{}) {
+			res = append(res, e.(string))
+		}
+		cursor = reply[0].(string)
+		if cursor == "0" {
+			return res
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	resetStores()
+	for i := 0; i < 50; i++ {
+		evalCmd("SET", "key:"+strconv.Itoa(i), "v")
+	}
+	evalCmd("SADD", "set", "a")
+	evalCmd("ZADD", "zset", "1", "a")
+
+	keys := scanAll(t, []string{"SCAN"}, "COUNT", "7")
+	assert.Len(t, keys, 52)
+	assert.ElementsMatch(t, []string{"set"}, scanAll(t, []string{"SCAN"}, "TYPE", "set"))
+	assert.Len(t, scanAll(t, []string{"SCAN"}, "MATCH", "key:1*", "TYPE", "string"), 11)
+	assert.Len(t, scanAll(t, []string{"SCAN"}, "TYPE", "zset"), 1)
+
+	assertErrorReply(t, evalReply("SCAN", "abc"))
+	assertErrorReply(t, evalReply("SCAN", "0", "COUNT", "0"))
+	assertErrorReply(t, evalReply("SCAN", "0", "MATCH"))
+	assertErrorReply(t, evalReply("SSCAN", "set", "0", "TYPE", "set"))
+}
+
+func TestRandomKey(t *testing.T) {
+	resetStores()
+	assert.Nil(t, evalReply("RANDOMKEY"))
+	evalCmd("SET", "a", "v")
+	evalCmd("SADD", "b", "v")
+	for i := 0; i < 10; i++ {
+		assert.Contains(t, []string{"a", "b"}, evalReply("RANDOMKEY"))
+	}
+}
+
+func TestSScanZScan(t *testing.T) {
+	resetStores()
+	for i := 0; i < 30; i++ {
+		evalCmd("SADD", "set", "m"+strconv.Itoa(i))
+		evalCmd("ZADD", "zset", strconv.Itoa(i), "m"+strconv.Itoa(i))
+	}
+	evalCmd("SET", "str", "v")
+
+	members := scanAll(t, []string{"SSCAN", "set"}, "COUNT", "4")
+	assert.Len(t, members, 30)
+	assert.ElementsMatch(t, []string{"m1", "m10", "m11", "m12", "m13", "m14", "m15", "m16", "m17", "m18", "m19"},
+		scanAll(t, []string{"SSCAN", "set"}, "MATCH", "m1*"))
+	assert.Len(t, scanAll(t, []string{"SSCAN", "missing"}), 0)
+	assert.Contains(t, evalReply("SSCAN", "str", "0"), "WRONGTYPE")
+
+	elements := scanAll(t, []string{"ZSCAN", "zset"}, "COUNT", "4")
+	assert.Len(t, elements, 60)
+	assert.EqualValues(t, []string{"m7", "7.000000"}, scanAll(t, []string{"ZSCAN", "zset"}, "MATCH", "m7"))
+	assert.Contains(t, evalReply("ZSCAN", "str", "0"), "WRONGTYPE")
+}
+
+func TestObjectEncoding(t *testing.T) {
+	resetStores()
+	evalCmd("SET", "str", "v")
+	evalCmd("SET", "n", "1")
+	evalCmd("INCR", "n")
+	evalCmd("SADD", "set", "1")
+	evalCmd("ZADD", "zset", "1", "a")
+	evalCmd("RPUSH", "list", "a")
+	evalCmd("HSET", "hash", "f", "v")
+	evalCmd("XADD", "stream", "*", "f", "v")
+	evalCmd("PFADD", "hll", "a")
+	for key, encoding := range map[string]string{
+		"str":    "raw",
+		"n":      "int",
+		"set":    "intset",
+		"zset":   "skiplist",
+		"list":   "quicklist",
+		"hash":   "listpack",
+		"stream": "stream",
+		"hll":    "raw",
+	} {
+		assert.EqualValues(t, encoding, evalReply("OBJECT", "ENCODING", key), key)
+	}
+	assert.Nil(t, evalReply("OBJECT", "ENCODING", "missing"))
+	assertErrorReply(t, evalReply("OBJECT", "ENCODING"))
+	assertErrorReply(t, evalReply("OBJECT", "FREQ", "str"))
+}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"errors"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errIndexOutOfRange = errors.New("(error) ERR index out of range")
+
+var errNotInteger = errors.New("(error) ERR value is not an integer or out of range")
+
+// parseListWhere parses LEFT or RIGHT, the end of a list used by LMOVE
+func parseListWhere(s string) (int, bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return data_structure.ListHead, true
+	case "RIGHT":
+		return data_structure.ListTail, true
+	}
+	return 0, false
+}
+
+// listPush pushes values at one end of the list of key, creating it if needed, and returns its new length
+func listPush(key string, where int, values ...string) (int, error) {
+	list, err := getList(key)
+	if err != nil {
+		return 0, err
+	}
+	if list == nil {
+		list = data_structure.CreateQuickList()
+		putList(key, list)
+	}
+	list.Push(where, values...)
+	if where == data_structure.ListHead {
+		notifyKeyspaceEvent(NotifyList, "lpush", key)
+	} else {
+		notifyKeyspaceEvent(NotifyList, "rpush", key)
+	}
+	return list.Len(), nil
+}
+
+// listPop pops count elements from one end of the list of key, which is deleted when it becomes empty
+func listPop(key string, list *data_structure.QuickList, where int, count int) []string {
+	popped := make([]string, 0, min(count, list.Len()))
+	for len(popped) < count {
+		v, ok := list.Pop(where)
+		if !ok {
+			break
+		}
+		popped = append(popped, v)
+	}
+	if len(popped) > 0 {
+		if where == data_structure.ListHead {
+			notifyKeyspaceEvent(NotifyList, "lpop", key)
+		} else {
+			notifyKeyspaceEvent(NotifyList, "rpop", key)
+		}
+	}
+	deleteIfEmptyList(key, list)
+	return popped
+}
+
+func deleteIfEmptyList(key string, list *data_structure.QuickList) {
+	if list.Len() == 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+	}
+}
+
+// LPUSH key element [element ...]
+func cmdLPUSH(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LPUSH' command"), false)
+	}
+	n, err := listPush(args[0], data_structure.ListHead, args[1:]...)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(n, false)
+}
+
+// RPUSH key element [element ...]
+func cmdRPUSH(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'RPUSH' command"), false)
+	}
+	n, err := listPush(args[0], data_structure.ListTail, args[1:]...)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(n, false)
+}
+
+/*
+LPOP key [count] and RPOP key [count]
+Without count, returns the first (last) element or nil. With count, returns an array of up to count elements,
+or a null array if key doesn't exist.
+*/
+func popGeneric(name string, args []string, where int) []byte {
+	if len(args) < 1 || len(args) > 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+name+"' command"), false)
+	}
+	key := args[0]
+	hasCount := len(args) == 2
+	count := 1
+	if hasCount {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
+		}
+		count = n
+	}
+	list, err := getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if hasCount {
+			return constant.RespNilArray
+		}
+		return constant.RespNil
+	}
+	popped := listPop(key, list, where, count)
+	if !hasCount {
+		return Encode(popped[0], false)
+	}
+	return Encode(popped, false)
+}
+
+func cmdLPOP(args []string) []byte {
+	return popGeneric("LPOP", args, data_structure.ListHead)
+}
+
+func cmdRPOP(args []string) []byte {
+	return popGeneric("RPOP", args, data_structure.ListTail)
+}
+
+func cmdLLEN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LLEN' command"), false)
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	return Encode(list.Len(), false)
+}
+
+// LRANGE key start stop returns the elements from start to stop included, negative indexes count from the tail
+func cmdLRANGE(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LRANGE' command"), false)
+	}
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespEmptyArray
+	}
+	return Encode(list.Range(start, stop), false)
+}
+
+// LINDEX key index returns the element at index, negative indexes count from the tail
+func cmdLINDEX(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LINDEX' command"), false)
+	}
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespNil
+	}
+	v, ok := list.Index(index)
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(v, false)
+}
+
+// LSET key index element replaces the element at index
+func cmdLSET(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LSET' command"), false)
+	}
+	key := args[0]
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode(errors.New("(error) ERR no such key"), false)
+	}
+	if !list.Set(index, args[2]) {
+		return Encode(errIndexOutOfRange, false)
+	}
+	notifyKeyspaceEvent(NotifyList, "lset", key)
+	return constant.RespOk
+}
+
+/*
+LREM key count element
+Removes the first count elements equal to element if count > 0, the last -count ones if count < 0,
+or all of them if count is 0. Returns the number of removed elements.
+*/
+func cmdLREM(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LREM' command"), false)
+	}
+	key := args[0]
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	removed := list.Rem(count, args[2])
+	if removed > 0 {
+		notifyKeyspaceEvent(NotifyList, "lrem", key)
+		deleteIfEmptyList(key, list)
+	}
+	return Encode(removed, false)
+}
+
+// LTRIM key start stop keeps only the elements from start to stop included
+func cmdLTRIM(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LTRIM' command"), false)
+	}
+	key := args[0]
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespOk
+	}
+	list.Trim(start, stop)
+	notifyKeyspaceEvent(NotifyList, "ltrim", key)
+	deleteIfEmptyList(key, list)
+	return constant.RespOk
+}
+
+/*
+LINSERT key BEFORE|AFTER pivot element
+Inserts element before or after the first element equal to pivot. Returns the new length of the list,
+-1 if pivot was not found, or 0 if key doesn't exist.
+*/
+func cmdLINSERT(args []string) []byte {
+	if len(args) != 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LINSERT' command"), false)
+	}
+	key := args[0]
+	var after bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+		after = false
+	case "AFTER":
+		after = true
+	default:
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	list, err := getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	n := list.Insert(args[2], args[3], after)
+	if n > 0 {
+		notifyKeyspaceEvent(NotifyList, "linsert", key)
+	}
+	return Encode(n, false)
+}
+
+/*
+lmove pops an element from one end of the list of src and pushes it to one end of the list of dst.
+It returns nil if src doesn't exist.
+*/
+func lmove(src string, dst string, srcList *data_structure.QuickList, from int, to int) ([]byte, error) {
+	dstList, err := getList(dst)
+	if err != nil {
+		return nil, err
+	}
+	v := listPop(src, srcList, from, 1)[0]
+	if dstList == nil || dstList.Len() == 0 {
+		// src and dst are the same list, which was emptied by the pop
+		listPush(dst, to, v)
+	} else {
+		dstList.Push(to, v)
+		if to == data_structure.ListHead {
+			notifyKeyspaceEvent(NotifyList, "lpush", dst)
+		} else {
+			notifyKeyspaceEvent(NotifyList, "rpush", dst)
+		}
+	}
+	return Encode(v, false), nil
+}
+
+/*
+LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+Atomically pops an element from one end of source and pushes it to one end of destination, which may be
+source itself to rotate the list. Returns the element, or nil if source doesn't exist.
+*/
+func cmdLMOVE(args []string) []byte {
+	if len(args) != 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LMOVE' command"), false)
+	}
+	from, ok1 := parseListWhere(args[2])
+	to, ok2 := parseListWhere(args[3])
+	if !ok1 || !ok2 {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespNil
+	}
+	res, err := lmove(args[0], args[1], list, from, to)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return res
+}
+
+/*
+LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+Returns the index of the first element equal to element, or nil. RANK skips the first rank-1 matches, from
+the tail if rank is negative. COUNT returns an array with up to num-matches indexes, 0 for all of them.
+MAXLEN compares only the first len elements.
+*/
+func cmdLPOS(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'LPOS' command"), false)
+	}
+	rank, count, maxLen := 1, 0, 0
+	hasCount := false
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return Encode(errors.New("(error) ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list"), false)
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return Encode(errors.New("(error) ERR COUNT can't be negative"), false)
+			}
+			count, hasCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				return Encode(errors.New("(error) ERR MAXLEN can't be negative"), false)
+			}
+			maxLen = n
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if hasCount {
+			return constant.RespEmptyArray
+		}
+		return constant.RespNil
+	}
+	if !hasCount {
+		res := list.Pos(args[1], rank, 1, maxLen)
+		if len(res) == 0 {
+			return constant.RespNil
+		}
+		return Encode(res[0], false)
+	}
+	positions := list.Pos(args[1], rank, count, maxLen)
+	res := make([]interface{}, len(positions))
+	for i, pos := range positions {
+		res[i] = pos
+	}
+	return Encode(res, false)
+}
+
+/*
+BLPOP key [key ...] timeout and BRPOP key [key ...] timeout
+Pops an element from the first non-empty list and returns the key and the element. If every list is empty,
+the client is blocked until an element is pushed to one of them, or timeout seconds have passed, 0 to wait
+forever. It returns a null array when the timeout is reached.
+*/
+func blockingPopGeneric(name string, args []string, where int) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+name+"' command"), false)
+	}
+	keys := args[:len(args)-1]
+	timeout, err := parseBlockTimeout(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, key := range keys {
+		list, err := getList(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if list != nil {
+			return Encode([]string{key, listPop(key, list, where, 1)[0]}, false)
+		}
+	}
+	if !canBlock() {
+		return constant.RespNilArray
+	}
+	blockForKeys(&MemKVCmd{Cmd: name, Args: args}, keys, timeout)
+	return nil
+}
+
+func cmdBLPOP(args []string) []byte {
+	return blockingPopGeneric("BLPOP", args, data_structure.ListHead)
+}
+
+func cmdBRPOP(args []string) []byte {
+	return blockingPopGeneric("BRPOP", args, data_structure.ListTail)
+}
+
+/*
+BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+The blocking LMOVE: if source is empty, the client is blocked until an element is pushed to it,
+or timeout seconds have passed.
+*/
+func cmdBLMOVE(args []string) []byte {
+	if len(args) != 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BLMOVE' command"), false)
+	}
+	from, ok1 := parseListWhere(args[2])
+	to, ok2 := parseListWhere(args[3])
+	if !ok1 || !ok2 {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	timeout, err := parseBlockTimeout(args[4])
+	if err != nil {
+		return Encode(err, false)
+	}
+	list, err := getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if !canBlock() {
+			return constant.RespNil
+		}
+		blockForKeys(&MemKVCmd{Cmd: "BLMOVE", Args: args}, args[:1], timeout)
+		return nil
+	}
+	res, err := lmove(args[0], args[1], list, from, to)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return res
+}
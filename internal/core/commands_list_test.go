@@ -0,0 +1,221 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/constant"
+)
+
+func TestListPushPop(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 3, evalReply("LPUSH", "list", "a", "b", "c"))
+	assert.EqualValues(t, 5, evalReply("RPUSH", "list", "d", "e"))
+	assert.EqualValues(t, 5, evalReply("LLEN", "list"))
+	assert.EqualValues(t, "list", evalReply("TYPE", "list"))
+	assert.EqualValues(t, []interface{}{"c", "b", "a", "d", "e"}, evalReply("LRANGE", "list", "0", "-1"))
+
+	assert.EqualValues(t, "c", evalReply("LPOP", "list"))
+	assert.EqualValues(t, "e", evalReply("RPOP", "list"))
+	assert.EqualValues(t, []interface{}{"b", "a"}, evalReply("LPOP", "list", "2"))
+	assert.EqualValues(t, []interface{}{}, evalReply("RPOP", "list", "0"))
+	assert.EqualValues(t, []interface{}{"d"}, evalReply("RPOP", "list", "10"))
+	// the empty list is deleted
+	assert.EqualValues(t, 0, evalReply("EXISTS", "list"))
+	assert.Nil(t, evalReply("LPOP", "list"))
+	assert.Nil(t, evalReply("LPOP", "list", "2"))
+	assert.EqualValues(t, 0, evalReply("LLEN", "list"))
+	assertErrorReply(t, evalReply("LPOP", "list", "-1"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("LPUSH", "str", "a"))
+	assertErrorReply(t, evalReply("LRANGE", "str", "0", "-1"))
+}
+
+func TestListIndexes(t *testing.T) {
+	resetStores()
+	evalCmd("RPUSH", "list", "a", "b", "c", "d")
+	assert.EqualValues(t, []interface{}{"b", "c"}, evalReply("LRANGE", "list", "1", "2"))
+	assert.EqualValues(t, []interface{}{"c", "d"}, evalReply("LRANGE", "list", "-2", "100"))
+	assert.EqualValues(t, []interface{}{}, evalReply("LRANGE", "list", "3", "1"))
+	assert.EqualValues(t, []interface{}{}, evalReply("LRANGE", "missing", "0", "-1"))
+	assertErrorReply(t, evalReply("LRANGE", "list", "a", "1"))
+
+	assert.EqualValues(t, "a", evalReply("LINDEX", "list", "0"))
+	assert.EqualValues(t, "d", evalReply("LINDEX", "list", "-1"))
+	assert.Nil(t, evalReply("LINDEX", "list", "4"))
+
+	assert.EqualValues(t, "OK", evalReply("LSET", "list", "-1", "z"))
+	assert.EqualValues(t, "z", evalReply("LINDEX", "list", "3"))
+	assertErrorReply(t, evalReply("LSET", "list", "10", "z"))
+	assertErrorReply(t, evalReply("LSET", "missing", "0", "z"))
+
+	assert.EqualValues(t, "OK", evalReply("LTRIM", "list", "1", "-2"))
+	assert.EqualValues(t, []interface{}{"b", "c"}, evalReply("LRANGE", "list", "0", "-1"))
+	assert.EqualValues(t, "OK", evalReply("LTRIM", "list", "5", "10"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "list"))
+}
+
+func TestListRemInsertPos(t *testing.T) {
+	resetStores()
+	evalCmd("RPUSH", "list", "a", "x", "b", "x", "c", "x")
+	assert.EqualValues(t, 1, evalReply("LPOS", "list", "x"))
+	assert.EqualValues(t, 3, evalReply("LPOS", "list", "x", "RANK", "2"))
+	assert.EqualValues(t, 5, evalReply("LPOS", "list", "x", "RANK", "-1"))
+	assert.EqualValues(t, []interface{}{int64(1), int64(3), int64(5)}, evalReply("LPOS", "list", "x", "COUNT", "0"))
+	assert.EqualValues(t, []interface{}{int64(5), int64(3)}, evalReply("LPOS", "list", "x", "RANK", "-1", "COUNT", "2"))
+	assert.EqualValues(t, []interface{}{int64(1)}, evalReply("LPOS", "list", "x", "COUNT", "0", "MAXLEN", "3"))
+	assert.Nil(t, evalReply("LPOS", "list", "y"))
+	assert.EqualValues(t, []interface{}{}, evalReply("LPOS", "missing", "x", "COUNT", "1"))
+	assertErrorReply(t, evalReply("LPOS", "list", "x", "RANK", "0"))
+	assertErrorReply(t, evalReply("LPOS", "list", "x", "COUNT", "-1"))
+	assertErrorReply(t, evalReply("LPOS", "list", "x", "COUNT"))
+
+	assert.EqualValues(t, 2, evalReply("LREM", "list", "-2", "x"))
+	assert.EqualValues(t, []interface{}{"a", "x", "b", "c"}, evalReply("LRANGE", "list", "0", "-1"))
+	assert.EqualValues(t, 5, evalReply("LINSERT", "list", "BEFORE", "x", "y"))
+	assert.EqualValues(t, 6, evalReply("LINSERT", "list", "after", "c", "z"))
+	assert.EqualValues(t, -1, evalReply("LINSERT", "list", "AFTER", "w", "z"))
+	assert.EqualValues(t, 0, evalReply("LINSERT", "missing", "AFTER", "w", "z"))
+	assertErrorReply(t, evalReply("LINSERT", "list", "NEAR", "c", "z"))
+	assert.EqualValues(t, []interface{}{"a", "y", "x", "b", "c", "z"}, evalReply("LRANGE", "list", "0", "-1"))
+	assert.EqualValues(t, 1, evalReply("LREM", "list", "0", "a"))
+}
+
+func TestListMove(t *testing.T) {
+	resetStores()
+	evalCmd("RPUSH", "src", "a", "b", "c")
+	assert.EqualValues(t, "a", evalReply("LMOVE", "src", "dst", "LEFT", "RIGHT"))
+	assert.EqualValues(t, "c", evalReply("LMOVE", "src", "dst", "RIGHT", "LEFT"))
+	assert.EqualValues(t, []interface{}{"c", "a"}, evalReply("LRANGE", "dst", "0", "-1"))
+	// rotation
+	assert.EqualValues(t, "c", evalReply("LMOVE", "dst", "dst", "LEFT", "RIGHT"))
+	assert.EqualValues(t, []interface{}{"a", "c"}, evalReply("LRANGE", "dst", "0", "-1"))
+	assert.EqualValues(t, "b", evalReply("LMOVE", "src", "src", "LEFT", "LEFT"))
+	assert.EqualValues(t, []interface{}{"b"}, evalReply("LRANGE", "src", "0", "-1"))
+
+	assert.Nil(t, evalReply("LMOVE", "missing", "dst", "LEFT", "RIGHT"))
+	assertErrorReply(t, evalReply("LMOVE", "src", "dst", "UP", "RIGHT"))
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("LMOVE", "src", "str", "LEFT", "RIGHT"))
+	assert.EqualValues(t, 1, evalReply("LLEN", "src"))
+}
+
+func TestBlockingPop(t *testing.T) {
+	resetStores()
+	// served at once when a list is not empty
+	evalCmd("RPUSH", "b", "x")
+	assert.EqualValues(t, []interface{}{"b", "x"}, evalReply("BLPOP", "a", "b", "0"))
+	// without a client, e.g. in the AOF replay, it doesn't block
+	assert.Nil(t, evalReply("BLPOP", "a", "0"))
+	assertErrorReply(t, evalReply("BLPOP", "a", "-1"))
+	assertErrorReply(t, evalReply("BLPOP", "a", "x"))
+
+	c1 := &FDComm{Fd: -1}
+	c2 := &FDComm{Fd: -1}
+	defer FreeClient(c1)
+	defer FreeClient(c2)
+	assert.Empty(t, runClientCmd(c1, "BLPOP", "q1", "q2", "0"))
+	assert.Empty(t, runClientCmd(c2, "BRPOP", "q2", "0"))
+	assert.Contains(t, evalReply("INFO", "clients"), "blocked_clients:2")
+
+	// the commands of a blocked client wait until it is unblocked
+	assert.Empty(t, runClientCmd(c1, "PING"))
+
+	// the first client blocked on q2 is served first, the element pushed first is popped
+	pusher := &FDComm{Fd: -1}
+	assert.EqualValues(t, 2, clientReply(pusher, "RPUSH", "q2", "a", "b"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"q2", "a"}, "PONG"}, clientMessages(c1))
+	assert.EqualValues(t, []interface{}{[]interface{}{"q2", "b"}}, clientMessages(c2))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "q2"))
+	assert.EqualValues(t, 0, len(blockedClients))
+	assert.EqualValues(t, 0, len(blockingKeys))
+	pending := ClientsPendingWrite()
+	assert.Contains(t, pending, c1)
+	assert.Contains(t, pending, c2)
+
+	// a blocked client that disconnects is forgotten
+	runClientCmd(c1, "BLPOP", "q3", "0")
+	FreeClient(c1)
+	assert.EqualValues(t, 0, len(blockingKeys))
+	evalCmd("RPUSH", "q3", "a")
+	assert.EqualValues(t, 1, evalReply("LLEN", "q3"))
+}
+
+func TestBlockingPopTimeout(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+	defer FreeClient(comm)
+	assert.Empty(t, runClientCmd(comm, "BLPOP", "q", "0.05"))
+	handleBlockedClientsTimeout()
+	assert.Empty(t, clientMessages(comm))
+
+	time.Sleep(60 * time.Millisecond)
+	handleBlockedClientsTimeout()
+	assert.EqualValues(t, []interface{}{nil}, clientMessages(comm))
+	assert.False(t, comm.block.blocked())
+	assert.EqualValues(t, 0, len(blockingKeys))
+
+	// BLMOVE replies with a null bulk string, the pops with a null array
+	runClientCmd(comm, "BLMOVE", "q", "d", "LEFT", "LEFT", "0.01")
+	time.Sleep(20 * time.Millisecond)
+	handleBlockedClientsTimeout()
+	assert.EqualValues(t, constant.RespNil, comm.replyBuf)
+	comm.replyBuf = comm.replyBuf[:0]
+	runClientCmd(comm, "BRPOP", "q", "0.01")
+	time.Sleep(20 * time.Millisecond)
+	handleBlockedClientsTimeout()
+	assert.EqualValues(t, constant.RespNilArray, comm.replyBuf)
+}
+
+func TestBlockingPopInMulti(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+	defer FreeClient(comm)
+	clientReply(comm, "MULTI")
+	clientReply(comm, "BLPOP", "q", "0")
+	clientReply(comm, "BLMOVE", "q", "d", "LEFT", "LEFT", "0")
+	assert.EqualValues(t, []interface{}{nil, nil}, clientReply(comm, "EXEC"))
+	assert.False(t, comm.block.blocked())
+
+	// a push in a transaction serves the blocked clients after EXEC
+	blocked := &FDComm{Fd: -1}
+	defer FreeClient(blocked)
+	runClientCmd(blocked, "BLPOP", "q", "0")
+	clientReply(comm, "MULTI")
+	clientReply(comm, "RPUSH", "q", "a", "b")
+	clientReply(comm, "LPOP", "q")
+	assert.EqualValues(t, []interface{}{int64(2), "a"}, clientReply(comm, "EXEC"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"q", "b"}}, clientMessages(blocked))
+}
+
+func TestBlockingMove(t *testing.T) {
+	resetStores()
+	c1 := &FDComm{Fd: -1}
+	c2 := &FDComm{Fd: -1}
+	defer FreeClient(c1)
+	defer FreeClient(c2)
+	runClientCmd(c1, "BLMOVE", "q1", "q2", "LEFT", "RIGHT", "0")
+	runClientCmd(c2, "BLPOP", "q2", "0")
+
+	// the element moved to q2 by the first client wakes up the second one
+	evalCmd("LPUSH", "q1", "job")
+	assert.EqualValues(t, []interface{}{"job"}, clientMessages(c1))
+	assert.EqualValues(t, []interface{}{[]interface{}{"q2", "job"}}, clientMessages(c2))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "q1"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "q2"))
+
+	// RENAME creates the key too
+	runClientCmd(c1, "BLPOP", "q3", "0")
+	evalCmd("RPUSH", "tmp", "v")
+	evalCmd("RENAME", "tmp", "q3")
+	assert.EqualValues(t, []interface{}{[]interface{}{"q3", "v"}}, clientMessages(c1))
+
+	// a key of another type doesn't unblock the client
+	runClientCmd(c1, "BLPOP", "q4", "0")
+	evalCmd("SET", "q4", "v")
+	assert.Empty(t, clientMessages(c1))
+	assert.True(t, c1.block.blocked())
+}
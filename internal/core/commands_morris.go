@@ -0,0 +1,171 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errMorrisNotFound = errors.New("(error) ERR Morris: key does not exist")
+
+// morrisEstimate returns the estimation of the counter as an integer reply
+func morrisEstimate(m *data_structure.MorrisCounter) []byte {
+	return Encode(int64(math.Round(math.Min(m.Estimate(), math.MaxInt64))), false)
+}
+
+/*
+MORRIS.CREATE key [BASE base]
+Creates a Morris counter. The base, greater than 1 and at most 2, trades accuracy for range: the relative
+error is about sqrt((base - 1) / 2), and the counter saturates past about base^255 / (base - 1) events.
+*/
+func cmdMORRISCREATE(args []string) []byte {
+	if len(args) != 1 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.CREATE' command"), false)
+	}
+	key := args[0]
+	base := data_structure.MorrisDefaultBase
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "BASE" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		var err error
+		base, err = strconv.ParseFloat(args[2], 64)
+		if err != nil || !(base > 1 && base <= data_structure.MorrisMaxBase) {
+			return Encode(errors.New(fmt.Sprintf("(error) ERR Morris: base must be greater than 1 and at most %d", data_structure.MorrisMaxBase)), false)
+		}
+	}
+	if dictStore.Get(key) != nil {
+		return Encode(errors.New("(error) ERR Morris: key already exists"), false)
+	}
+	putMorrisCounter(key, data_structure.CreateMorrisCounter(base))
+	notifyKeyspaceEvent(NotifyModule, "morris.create", key)
+	return constant.RespOk
+}
+
+/*
+MORRIS.INCRBY key increment
+Counts increment more events in the counter of key, which is created with the default base if needed.
+Returns the new estimation.
+*/
+func cmdMORRISINCRBY(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.INCRBY' command"), false)
+	}
+	key := args[0]
+	n, err := strconv.ParseUint(args[1], 10, 63)
+	if err != nil || n == 0 {
+		return Encode(errors.New("(error) ERR Morris: increment must be a positive integer"), false)
+	}
+	m, err := getMorrisCounter(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if m == nil {
+		m = data_structure.CreateMorrisCounter(data_structure.MorrisDefaultBase)
+		putMorrisCounter(key, m)
+	}
+	m.IncrBy(n)
+	notifyKeyspaceEvent(NotifyModule, "morris.incrby", key)
+	return morrisEstimate(m)
+}
+
+// MORRIS.GET key returns the estimated number of events counted by key, 0 if it doesn't exist
+func cmdMORRISGET(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.GET' command"), false)
+	}
+	m, err := getMorrisCounter(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if m == nil {
+		return constant.RespZero
+	}
+	return morrisEstimate(m)
+}
+
+/*
+MORRIS.MERGE destkey sourcekey [sourcekey ...]
+Adds the events counted by the source counters to destkey, which is created with the base of the first
+source if needed. Missing sources count no event, like in PFMERGE.
+*/
+func cmdMORRISMERGE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.MERGE' command"), false)
+	}
+	dst := args[0]
+	// check every key before modifying destkey
+	sources := make([]*data_structure.MorrisCounter, 0, len(args)-1)
+	for _, key := range args[1:] {
+		m, err := getMorrisCounter(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if m != nil {
+			sources = append(sources, m)
+		}
+	}
+	m, err := getMorrisCounter(dst)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if m == nil {
+		base := data_structure.MorrisDefaultBase
+		if len(sources) > 0 {
+			base = sources[0].GetBase()
+		}
+		m = data_structure.CreateMorrisCounter(base)
+		putMorrisCounter(dst, m)
+	}
+	for _, src := range sources {
+		if src != m {
+			m.Merge(src)
+		}
+	}
+	notifyKeyspaceEvent(NotifyModule, "morris.merge", dst)
+	return constant.RespOk
+}
+
+func cmdMORRISINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.INFO' command"), false)
+	}
+	m, err := getMorrisCounter(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if m == nil {
+		return Encode(errMorrisNotFound, false)
+	}
+	var res []string
+	res = append(res, "Base", strconv.FormatFloat(m.GetBase(), 'g', -1, 64),
+		"Counter", fmt.Sprintf("%d", m.GetCounter()),
+		"Estimate", strconv.FormatFloat(math.Round(m.Estimate()), 'f', -1, 64))
+
+	return Encode(res, false)
+}
+
+/*
+MORRIS.LOADCHUNK key iterator data
+Restores a Morris counter dumped by the AOF rewrite, in a single chunk like BF.LOADCHUNK.
+*/
+func cmdMORRISLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MORRIS.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	m := &data_structure.MorrisCounter{}
+	if err := m.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putMorrisCounter(key, m)
+	notifyKeyspaceEvent(NotifyModule, "morris.loadchunk", key)
+	return constant.RespOk
+}
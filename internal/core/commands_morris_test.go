@@ -0,0 +1,74 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMorrisCreate(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "OK", evalReply("MORRIS.CREATE", "m"))
+	assertErrorReply(t, evalReply("MORRIS.CREATE", "m"))
+	assert.EqualValues(t, "morris", evalReply("TYPE", "m"))
+	assert.EqualValues(t, []interface{}{"Base", "1.1", "Counter", "0", "Estimate", "0"}, evalReply("MORRIS.INFO", "m"))
+
+	assert.EqualValues(t, "OK", evalReply("MORRIS.CREATE", "m2", "BASE", "2"))
+	assert.EqualValues(t, []interface{}{"Base", "2", "Counter", "0", "Estimate", "0"}, evalReply("MORRIS.INFO", "m2"))
+
+	assertErrorReply(t, evalReply("MORRIS.CREATE", "x", "BASE", "1"))
+	assertErrorReply(t, evalReply("MORRIS.CREATE", "x", "BASE", "2.5"))
+	assertErrorReply(t, evalReply("MORRIS.CREATE", "x", "OTHER", "1.5"))
+	assertErrorReply(t, evalReply("MORRIS.INFO", "x"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "x"))
+}
+
+func TestMorrisIncrBy(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, 0, evalReply("MORRIS.GET", "m"))
+	// the first event is always counted
+	assert.EqualValues(t, 1, evalReply("MORRIS.INCRBY", "m", "1"))
+	estimate := evalReply("MORRIS.INCRBY", "m", "1000000").(int64)
+	assert.InDelta(t, 1000000, estimate, 1000000)
+	assert.EqualValues(t, estimate, evalReply("MORRIS.GET", "m"))
+	info := evalReply("MORRIS.INFO", "m").([]interface{})
+	assert.EqualValues(t, "1.1", info[1])
+
+	assertErrorReply(t, evalReply("MORRIS.INCRBY", "m", "0"))
+	assertErrorReply(t, evalReply("MORRIS.INCRBY", "m", "-1"))
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("MORRIS.INCRBY", "str", "1"))
+	assertErrorReply(t, evalReply("MORRIS.GET", "str"))
+}
+
+func TestMorrisMerge(t *testing.T) {
+	resetStores()
+	evalCmd("MORRIS.CREATE", "a", "BASE", "1.5")
+	evalCmd("MORRIS.INCRBY", "a", "1")
+	evalCmd("MORRIS.INCRBY", "b", "1000")
+	assert.EqualValues(t, "OK", evalReply("MORRIS.MERGE", "dest", "a", "none"))
+	assert.EqualValues(t, 1, evalReply("MORRIS.GET", "dest"))
+	info := evalReply("MORRIS.INFO", "dest").([]interface{})
+	assert.EqualValues(t, "1.5", info[1])
+	// merging a counter into itself changes nothing
+	assert.EqualValues(t, "OK", evalReply("MORRIS.MERGE", "dest", "dest"))
+	assert.EqualValues(t, 1, evalReply("MORRIS.GET", "dest"))
+
+	assert.EqualValues(t, "OK", evalReply("MORRIS.MERGE", "dest", "b"))
+	assert.InDelta(t, 1001, evalReply("MORRIS.GET", "dest"), 1000)
+	assert.EqualValues(t, "1.5", evalReply("MORRIS.INFO", "dest").([]interface{})[1])
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("MORRIS.MERGE", "dest", "str"))
+	assertErrorReply(t, evalReply("MORRIS.MERGE", "str", "a"))
+}
+
+func TestMorrisCopy(t *testing.T) {
+	resetStores()
+	evalCmd("MORRIS.INCRBY", "a", "1")
+	assert.EqualValues(t, 1, evalReply("COPY", "a", "b"))
+	evalCmd("MORRIS.INCRBY", "a", "1000")
+	assert.EqualValues(t, 1, evalReply("MORRIS.GET", "b"))
+	assertErrorReply(t, evalReply("MORRIS.LOADCHUNK", "c", "1", "bad"))
+	assertErrorReply(t, evalReply("MORRIS.LOADCHUNK", "c", "2", "bad"))
+}
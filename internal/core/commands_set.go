@@ -2,35 +2,55 @@ package core
 
 import (
 	"errors"
+	"memkv/internal/constant"
 	"memkv/internal/data_structure"
 	"strconv"
+	"strings"
 )
 
+var setOperator = data_structure.CreateMultiSetOperator()
+
 func cmdSADD(args []string) []byte {
 	if len(args) < 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
 	}
-	key := args[0] // TODO: check key is used by other types or not
-	set, exist := setStore[key]
-	if !exist {
+	key := args[0]
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		set = data_structure.CreateSet(key)
-		setStore[key] = set
+		putSet(key, set)
 	}
 	count := set.Add(args[1:]...)
+	syncSetEncoding(key, set)
+	if count > 0 {
+		notifyKeyspaceEvent(NotifySet, "sadd", key)
+	}
 	return Encode(count, false)
 }
 
 func cmdSREM(args []string) []byte {
 	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SREM' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		set = data_structure.CreateSet(key)
-		setStore[key] = set
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return constant.RespZero
 	}
 	count := set.Rem(args[1:]...)
+	if count > 0 {
+		notifyKeyspaceEvent(NotifySet, "srem", key)
+	}
+	if set.Size() == 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+	}
 	return Encode(count, false)
 }
 
@@ -39,8 +59,11 @@ func cmdSCARD(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SCARD' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(0, false)
 	}
 	return Encode(set.Size(), false)
@@ -51,8 +74,11 @@ func cmdSMEMBERS(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMEMBERS' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(make([]string, 0), false)
 	}
 	return Encode(set.Members(), false)
@@ -63,8 +89,11 @@ func cmdSISMEMBER(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SISMEMBER' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(0, false)
 	}
 	return Encode(set.IsMember(args[1]), false)
@@ -75,66 +104,288 @@ func cmdSMISMEMBER(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMISMEMBER' command"), false)
 	}
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		res := make([]int, len(args)-1)
 		return Encode(res, false)
 	}
 	return Encode(set.MIsMember(args[1:]...), false)
 }
 
+/*
+SPOP key [count]
+Removes and returns a random member, or count random members as an array, all the members if there are fewer.
+*/
 func cmdSPOP(args []string) []byte {
 	if len(args) > 2 {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'SPOP' command"), false)
 	}
 	key := args[0]
 	hasCount := len(args) > 1
-	count := 0
+	count := 1
 	if hasCount {
-		n, err := strconv.ParseInt(args[1], 10, 64)
+		n, err := strconv.Atoi(args[1])
 		if err != nil {
-			return Encode(errors.New("(error) Count must be int"), false)
+			return Encode(errNotInteger, false)
+		}
+		if n < 0 {
+			return Encode(errors.New("(error) ERR value is out of range, must be positive"), false)
 		}
-		count = int(n)
+		count = n
 	}
 
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		if !hasCount {
-			return Encode(nil, false)
+			return constant.RespNil
 		}
-		return Encode(make([]string, 0), false)
+		return constant.RespEmptyArray
+	}
+	popped := set.Pop(count)
+	if len(popped) > 0 {
+		notifyKeyspaceEvent(NotifySet, "spop", key)
+	}
+	if set.Size() == 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
 	}
 	if !hasCount {
-		return Encode(set.Pop(count)[0], false)
+		return Encode(popped[0], false)
 	}
-	return Encode(set.Pop(count), false)
+	return Encode(popped, false)
 }
 
-func cmdSRAND(args []string) []byte {
+/*
+SRANDMEMBER key [count]
+Returns a random member, or count distinct random members as an array, all the members if there are fewer.
+A negative count returns -count members that may repeat, at most data_structure.MaxRandomCount.
+*/
+func cmdSRANDMEMBER(args []string) []byte {
 	if len(args) > 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SRAND' command"), false)
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SRANDMEMBER' command"), false)
 	}
 	key := args[0]
 	hasCount := len(args) > 1
-	count := 0
+	count := 1
 	if hasCount {
-		n, err := strconv.ParseInt(args[1], 10, 64)
-		if err != nil {
-			return Encode(errors.New("(error) Count must be int"), false)
+		var err error
+		if count, err = parseRandomCount(args[1]); err != nil {
+			return Encode(err, false)
 		}
-		count = int(n)
 	}
 
-	set, exist := setStore[key]
-	if !exist {
+	set, err := getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		if !hasCount {
-			return Encode(nil, false)
+			return constant.RespNil
 		}
-		return Encode(make([]string, 0), false)
+		return constant.RespEmptyArray
 	}
 	if !hasCount {
 		return Encode(set.Rand(count)[0], false)
 	}
 	return Encode(set.Rand(count), false)
 }
+
+// SSCAN key cursor [MATCH pattern] [COUNT count] iterates the members of a set like SCAN
+func cmdSSCAN(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SSCAN' command"), false)
+	}
+	opts, err := parseScanOptions(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	set, err := getSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	members := make([]string, 0)
+	if set == nil {
+		return encodeScanReply(0, members)
+	}
+	next := set.Scan(opts.cursor, opts.count, func(member string) {
+		if opts.match(member) {
+			members = append(members, member)
+		}
+	})
+	return encodeScanReply(next, members)
+}
+
+// getSets returns the sets of keys, nil for the missing ones, or errWrongType if a key holds another type
+func getSets(keys []string) ([]data_structure.Set, error) {
+	sets := make([]data_structure.Set, len(keys))
+	for i, key := range keys {
+		set, err := getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// storeSet replaces dest by set, or deletes dest if set is empty, and returns the size of set
+func storeSet(dest string, set data_structure.Set, event string) []byte {
+	if set.Size() == 0 {
+		if dictStore.Del(dest) {
+			notifyKeyspaceEvent(NotifyGeneric, "del", dest)
+		}
+		return constant.RespZero
+	}
+	putSet(dest, set)
+	notifyKeyspaceEvent(NotifySet, event, dest)
+	return Encode(set.Size(), false)
+}
+
+// SINTER key [key ...] returns the members of the intersection of the sets
+func cmdSINTER(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SINTER' command"), false)
+	}
+	sets, err := getSets(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(setOperator.Inter(sets...), false)
+}
+
+/*
+SINTERCARD numkeys key [key ...] [LIMIT limit]
+Returns the size of the intersection of the sets. With a limit other than 0, the computation stops once
+limit members are found, and limit is returned.
+*/
+func cmdSINTERCARD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SINTERCARD' command"), false)
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 {
+		return Encode(errors.New("(error) ERR numkeys should be greater than 0"), false)
+	}
+	if numKeys > len(args)-1 {
+		return Encode(errors.New("(error) ERR Number of keys can't be greater than number of args"), false)
+	}
+	limit := 0
+	for i := 1 + numKeys; i < len(args); i += 2 {
+		if strings.ToUpper(args[i]) != "LIMIT" || i+1 == len(args) {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		if limit, err = strconv.Atoi(args[i+1]); err != nil || limit < 0 {
+			return Encode(errors.New("(error) ERR LIMIT can't be negative"), false)
+		}
+	}
+	sets, err := getSets(args[1 : 1+numKeys])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(setOperator.InterCard(limit, sets...), false)
+}
+
+// SINTERSTORE destination key [key ...] stores the intersection of the sets in destination, and returns its size
+func cmdSINTERSTORE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SINTERSTORE' command"), false)
+	}
+	sets, err := getSets(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return storeSet(args[0], setOperator.InterStore(args[0], sets...), "sinterstore")
+}
+
+// SUNION key [key ...] returns the members of the union of the sets
+func cmdSUNION(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SUNION' command"), false)
+	}
+	sets, err := getSets(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(setOperator.Union(sets...), false)
+}
+
+// SUNIONSTORE destination key [key ...] stores the union of the sets in destination, and returns its size
+func cmdSUNIONSTORE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SUNIONSTORE' command"), false)
+	}
+	sets, err := getSets(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return storeSet(args[0], setOperator.UnionStore(args[0], sets...), "sunionstore")
+}
+
+// SDIFF key [key ...] returns the members of the first set which aren't in any of the other sets
+func cmdSDIFF(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SDIFF' command"), false)
+	}
+	sets, err := getSets(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(setOperator.Diff(sets...), false)
+}
+
+// SDIFFSTORE destination key [key ...] stores the difference of the sets in destination, and returns its size
+func cmdSDIFFSTORE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SDIFFSTORE' command"), false)
+	}
+	sets, err := getSets(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return storeSet(args[0], setOperator.DiffStore(args[0], sets...), "sdiffstore")
+}
+
+/*
+SMOVE source destination member
+Moves member from the set of source to the set of destination, which is created if needed. Returns 1 if
+member was moved, 0 if it isn't in source.
+*/
+func cmdSMOVE(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMOVE' command"), false)
+	}
+	srcKey, destKey, member := args[0], args[1], args[2]
+	src, err := getSet(srcKey)
+	if err != nil {
+		return Encode(err, false)
+	}
+	dest, err := getSet(destKey)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if src == nil || src.IsMember(member) == 0 {
+		return constant.RespZero
+	}
+	if src == dest {
+		return constant.RespOne
+	}
+	if dest == nil {
+		dest = data_structure.CreateSet(destKey)
+		putSet(destKey, dest)
+	}
+	setOperator.Move(src, dest, member)
+	syncSetEncoding(destKey, dest)
+	notifyKeyspaceEvent(NotifySet, "srem", srcKey)
+	if src.Size() == 0 {
+		dictStore.Del(srcKey)
+		notifyKeyspaceEvent(NotifyGeneric, "del", srcKey)
+	}
+	notifyKeyspaceEvent(NotifySet, "sadd", destKey)
+	return constant.RespOne
+}
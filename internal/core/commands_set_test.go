@@ -0,0 +1,147 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+)
+
+func TestSInterUnionDiff(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "a", "1", "2", "3", "4")
+	evalCmd("SADD", "b", "2", "3", "5")
+	evalCmd("SADD", "c", "3", "4", "5")
+
+	assert.ElementsMatch(t, []interface{}{"3"}, evalReply("SINTER", "a", "b", "c"))
+	assert.ElementsMatch(t, []interface{}{"2", "3"}, evalReply("SINTER", "a", "b"))
+	assert.EqualValues(t, []interface{}{}, evalReply("SINTER", "a", "none"))
+	assert.ElementsMatch(t, []interface{}{"1", "2", "3", "4", "5"}, evalReply("SUNION", "a", "b", "none"))
+	assert.ElementsMatch(t, []interface{}{"1"}, evalReply("SDIFF", "a", "b", "c"))
+	assert.ElementsMatch(t, []interface{}{"1", "4"}, evalReply("SDIFF", "a", "b"))
+	assert.EqualValues(t, []interface{}{}, evalReply("SDIFF", "none", "a"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("SINTER", "a", "str"))
+	assertErrorReply(t, evalReply("SUNION", "str"))
+	assertErrorReply(t, evalReply("SDIFF", "a", "str"))
+}
+
+func TestSInterCard(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "a", "1", "2", "3", "4")
+	evalCmd("SADD", "b", "2", "3", "4", "5")
+	assert.EqualValues(t, 3, evalReply("SINTERCARD", "2", "a", "b"))
+	assert.EqualValues(t, 2, evalReply("SINTERCARD", "2", "a", "b", "LIMIT", "2"))
+	assert.EqualValues(t, 3, evalReply("SINTERCARD", "2", "a", "b", "LIMIT", "0"))
+	assert.EqualValues(t, 4, evalReply("SINTERCARD", "1", "a"))
+	assert.EqualValues(t, 0, evalReply("SINTERCARD", "2", "a", "none"))
+
+	assertErrorReply(t, evalReply("SINTERCARD", "0", "a"))
+	assertErrorReply(t, evalReply("SINTERCARD", "3", "a", "b"))
+	assertErrorReply(t, evalReply("SINTERCARD", "2", "a", "b", "LIMIT", "-1"))
+	assertErrorReply(t, evalReply("SINTERCARD", "2", "a", "b", "LIMIT"))
+	assertErrorReply(t, evalReply("SINTERCARD", "1", "a", "b"))
+}
+
+func TestSStore(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "a", "1", "2", "3")
+	evalCmd("SADD", "b", "2", "3", "4")
+
+	assert.EqualValues(t, 2, evalReply("SINTERSTORE", "dest", "a", "b"))
+	assert.ElementsMatch(t, []interface{}{"2", "3"}, evalReply("SMEMBERS", "dest"))
+	assert.EqualValues(t, 4, evalReply("SUNIONSTORE", "dest", "a", "b"))
+	assert.ElementsMatch(t, []interface{}{"1", "2", "3", "4"}, evalReply("SMEMBERS", "dest"))
+	assert.EqualValues(t, 1, evalReply("SDIFFSTORE", "dest", "a", "b"))
+	assert.ElementsMatch(t, []interface{}{"1"}, evalReply("SMEMBERS", "dest"))
+
+	// the destination may be one of the sources
+	assert.EqualValues(t, 4, evalReply("SUNIONSTORE", "a", "a", "dest", "b"))
+	assert.ElementsMatch(t, []interface{}{"1", "2", "3", "4"}, evalReply("SMEMBERS", "a"))
+
+	// an empty result deletes the destination, whatever its type
+	evalCmd("SET", "str", "v")
+	assert.EqualValues(t, 0, evalReply("SINTERSTORE", "str", "a", "none"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "str"))
+	evalCmd("SET", "str", "v", "EX", "100")
+	assert.EqualValues(t, 4, evalReply("SUNIONSTORE", "str", "a"))
+	assert.EqualValues(t, "set", evalReply("TYPE", "str"))
+	assert.EqualValues(t, -1, evalReply("TTL", "str"))
+}
+
+func TestSMove(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "a", "1", "2")
+	assert.EqualValues(t, 1, evalReply("SMOVE", "a", "b", "1"))
+	assert.EqualValues(t, 0, evalReply("SMOVE", "a", "b", "1"))
+	assert.EqualValues(t, []interface{}{"2"}, evalReply("SMEMBERS", "a"))
+	assert.EqualValues(t, []interface{}{"1"}, evalReply("SMEMBERS", "b"))
+	assert.EqualValues(t, 1, evalReply("SMOVE", "a", "a", "2"))
+	assert.EqualValues(t, 1, evalReply("SCARD", "a"))
+
+	// the source is deleted once empty
+	assert.EqualValues(t, 1, evalReply("SMOVE", "a", "b", "2"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "a"))
+	assert.EqualValues(t, 0, evalReply("SMOVE", "none", "b", "1"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("SMOVE", "b", "str", "1"))
+	assertErrorReply(t, evalReply("SMOVE", "str", "b", "1"))
+	assert.EqualValues(t, 2, evalReply("SCARD", "b"))
+}
+
+func TestSetEncoding(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "s", "1", "2")
+	assert.EqualValues(t, constant.ObjTypeSet|constant.ObjEncodingIntset, dictStore.Get("s").TypeEncoding)
+	evalCmd("SADD", "s", "a")
+	assert.EqualValues(t, constant.ObjTypeSet|constant.ObjEncodingListpack, dictStore.Get("s").TypeEncoding)
+	for i := 0; i < config.SetMaxListpackEntries; i++ {
+		evalCmd("SADD", "s", "m"+strconv.Itoa(i))
+	}
+	assert.EqualValues(t, constant.ObjTypeSet|constant.ObjEncodingHashTable, dictStore.Get("s").TypeEncoding)
+
+	evalCmd("SADD", "src", "x")
+	evalCmd("SADD", "dest", "1")
+	evalCmd("SMOVE", "src", "dest", "x")
+	assert.EqualValues(t, constant.ObjTypeSet|constant.ObjEncodingListpack, dictStore.Get("dest").TypeEncoding)
+
+	// the result of a STORE command is encoded for its members
+	evalCmd("SADD", "ints", "1", "2", "3")
+	evalCmd("SINTERSTORE", "inter", "s", "ints")
+	assert.EqualValues(t, constant.ObjTypeSet|constant.ObjEncodingIntset, dictStore.Get("inter").TypeEncoding)
+	assert.EqualValues(t, "intset", evalReply("OBJECT", "ENCODING", "inter"))
+
+	// COPY keeps the encoding
+	evalCmd("SREM", "s", "a")
+	evalCmd("COPY", "s", "copy")
+	assert.EqualValues(t, "hashtable", evalReply("OBJECT", "ENCODING", "copy"))
+}
+
+func TestSRandMemberAndSPop(t *testing.T) {
+	resetStores()
+	evalCmd("SADD", "s", "a", "b", "c")
+	assert.Contains(t, []string{"a", "b", "c"}, evalReply("SRANDMEMBER", "s"))
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, evalReply("SRANDMEMBER", "s", "10"))
+	assert.Len(t, evalReply("SRANDMEMBER", "s", "-10"), 10)
+	assert.Len(t, evalReply("SRANDMEMBER", "s", "2"), 2)
+	assert.Empty(t, evalReply("SRANDMEMBER", "s", "0"))
+	assert.Nil(t, evalReply("SRANDMEMBER", "missing"))
+	assert.Empty(t, evalReply("SRANDMEMBER", "missing", "-2"))
+	assertErrorReply(t, evalReply("SRANDMEMBER", "s", "x"))
+	assertErrorReply(t, evalReply("SRANDMEMBER", "s", "-9223372036854775808"))
+	assertErrorReply(t, evalReply("SRANDMEMBER", "s", strconv.Itoa(-data_structure.MaxRandomCount-1)))
+
+	assertErrorReply(t, evalReply("SPOP", "s", "-1"))
+	assert.Empty(t, evalReply("SPOP", "s", "0"))
+	assert.Len(t, evalReply("SPOP", "s", "1"), 1)
+	// more than the size pops every member and deletes the key
+	assert.Len(t, evalReply("SPOP", "s", "5"), 2)
+	assert.EqualValues(t, 0, evalReply("EXISTS", "s"))
+	assert.Nil(t, evalReply("SPOP", "s"))
+	assert.Empty(t, evalReply("SPOP", "s", "3"))
+}
@@ -36,10 +36,13 @@ func cmdZADD(args []string) []byte {
 		return Encode(errors.New(fmt.Sprintf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs)), false)
 	}
 
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		zset = data_structure.CreateZSet()
-		zsetStore[key] = zset
+		putZSet(key, zset)
 	}
 
 	count := 0
@@ -57,6 +60,9 @@ func cmdZADD(args []string) []byte {
 			count++
 		}
 	}
+	if count > 0 {
+		notifyKeyspaceEvent(NotifyZSet, "zadd", key)
+	}
 	return Encode(count, false)
 }
 
@@ -65,11 +71,37 @@ func cmdZRANK(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
 	}
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	rank, _ := zset.GetRank(member, false)
+	if rank < 0 {
+		return constant.RespNil
+	}
+	return Encode(rank, false)
+}
+
+// ZREVRANK key member returns the rank of member with the scores ordered from high to low, nil if it doesn't exist
+func cmdZREVRANK(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZREVRANK' command"), false)
+	}
+	key, member := args[0], args[1]
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		return constant.RespNil
+	}
+	rank, _ := zset.GetRank(member, true)
+	if rank < 0 {
+		return constant.RespNil
+	}
 	return Encode(rank, false)
 }
 
@@ -78,8 +110,11 @@ func cmdZREM(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZREM' command"), false)
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespZero
 	}
 	deleted := 0
@@ -89,10 +124,16 @@ func cmdZREM(args []string) []byte {
 			deleted++
 		}
 		if zset.Len() == 0 {
-			delete(zsetStore, key)
 			break
 		}
 	}
+	if deleted > 0 {
+		notifyKeyspaceEvent(NotifyZSet, "zrem", key)
+	}
+	if zset.Len() == 0 {
+		dictStore.Del(key)
+		notifyKeyspaceEvent(NotifyGeneric, "del", key)
+	}
 	return Encode(deleted, false)
 }
 
@@ -101,12 +142,15 @@ func cmdZSCORE(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCORE' command"), false)
 	}
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	ret, score := zset.GetScore(member)
-	if ret == 0 {
+	if ret != 0 {
 		return constant.RespNil
 	}
 	return Encode(fmt.Sprintf("%f", score), false)
@@ -117,9 +161,265 @@ func cmdZCARD(args []string) []byte {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZCARD' command"), false)
 	}
 	key := args[0]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespZero
 	}
 	return Encode(zset.Len(), false)
 }
+
+// ZSCAN key cursor [MATCH pattern] [COUNT count] iterates the elements of a sorted set like SCAN,
+// replying with the elements and their scores
+func cmdZSCAN(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCAN' command"), false)
+	}
+	opts, err := parseScanOptions(args[1:], false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, err := getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	if zset == nil {
+		return encodeScanReply(0, res)
+	}
+	next := zset.Scan(opts.cursor, opts.count, func(ele string, score float64) {
+		if opts.match(ele) {
+			res = append(res, ele, fmt.Sprintf("%f", score))
+		}
+	})
+	return encodeScanReply(next, res)
+}
+
+const (
+	zrangeByRank = iota
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec holds the options of the ZRANGE family
+type zrangeSpec struct {
+	by         int
+	reverse    bool
+	withScores bool
+	hasLimit   bool
+	offset     int
+	// count is the limit of elements, -1 if there is none
+	count int
+}
+
+var errZRangeNotFloat = errors.New("(error) ERR min or max is not a float")
+
+/*
+parseZRangeOptions parses the options after the range of ZRANGE. The legacy commands like ZRANGEBYSCORE take
+their kind of range from their name, they only accept LIMIT and WITHSCORES.
+*/
+func parseZRangeOptions(args []string, spec *zrangeSpec, legacy bool, allowWithScores bool) error {
+	spec.count = -1
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "WITHSCORES" && allowWithScores:
+			spec.withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			offset, err1 := strconv.Atoi(args[i+1])
+			count, err2 := strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return errNotInteger
+			}
+			spec.hasLimit, spec.offset, spec.count = true, offset, count
+			i += 2
+		case opt == "BYSCORE" && !legacy:
+			spec.by = zrangeByScore
+		case opt == "BYLEX" && !legacy:
+			spec.by = zrangeByLex
+		case opt == "REV" && !legacy:
+			spec.reverse = true
+		default:
+			return errors.New("(error) ERR syntax error")
+		}
+	}
+	if spec.hasLimit && spec.by == zrangeByRank {
+		return errors.New("(error) ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.by == zrangeByLex {
+		return errors.New("(error) ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	return nil
+}
+
+/*
+zrange calls fn for the elements of zset between start and stop, ranks, scores or elements according to spec.
+With REV, a range by score or lexicographical goes from start, the max, down to stop, the min.
+*/
+func zrange(zset *data_structure.ZSet, start string, stop string, spec zrangeSpec,
+	fn func(ele string, score float64)) error {
+	switch spec.by {
+	case zrangeByRank:
+		startRank, err1 := strconv.Atoi(start)
+		stopRank, err2 := strconv.Atoi(stop)
+		if err1 != nil || err2 != nil {
+			return errNotInteger
+		}
+		if zset == nil {
+			return nil
+		}
+		n := zset.Len()
+		if startRank < 0 {
+			startRank = max(n+startRank, 0)
+		}
+		if stopRank < 0 {
+			stopRank = n + stopRank
+		}
+		stopRank = min(stopRank, n-1)
+		if startRank > stopRank {
+			return nil
+		}
+		zset.RangeByRank(startRank, stopRank, spec.reverse, fn)
+	case zrangeByScore:
+		if spec.reverse {
+			start, stop = stop, start
+		}
+		zr, ok := data_structure.ParseZRange(start, stop)
+		if !ok {
+			return errZRangeNotFloat
+		}
+		if zset != nil && spec.offset >= 0 {
+			zset.RangeByScore(zr, spec.reverse, spec.offset, spec.count, fn)
+		}
+	case zrangeByLex:
+		if spec.reverse {
+			start, stop = stop, start
+		}
+		zr, ok := data_structure.ParseZLexRange(start, stop)
+		if !ok {
+			return errors.New("(error) ERR min or max not valid string range item")
+		}
+		if zset != nil && spec.offset >= 0 {
+			zset.RangeByLex(zr, spec.reverse, spec.offset, spec.count, fn)
+		}
+	}
+	return nil
+}
+
+// zrangeReply replies with the elements of the range of key, and their scores if spec.withScores
+func zrangeReply(key string, start string, stop string, spec zrangeSpec) []byte {
+	zset, err := getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	err = zrange(zset, start, stop, spec, func(ele string, score float64) {
+		res = append(res, ele)
+		if spec.withScores {
+			res = append(res, fmt.Sprintf("%f", score))
+		}
+	})
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(res, false)
+}
+
+/*
+ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+Returns the elements between the ranks start and stop, or with BYSCORE the elements with a score between the
+min start and the max stop, which are excluded if prefixed by "(", and may be -inf and +inf. BYLEX takes the
+elements between "[" or "(" prefixed elements, or "-" and "+", for a sorted set whose elements have the same
+score. REV reverses the order, and then expects the max before the min.
+*/
+func cmdZRANGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANGE' command"), false)
+	}
+	var spec zrangeSpec
+	if err := parseZRangeOptions(args[3:], &spec, false, true); err != nil {
+		return Encode(err, false)
+	}
+	return zrangeReply(args[0], args[1], args[2], spec)
+}
+
+/*
+ZRANGESTORE dst src min max [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+Stores the range of src like ZRANGE into dst, which is deleted if the range is empty. Returns the number of
+elements stored.
+*/
+func cmdZRANGESTORE(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANGESTORE' command"), false)
+	}
+	dst := args[0]
+	var spec zrangeSpec
+	if err := parseZRangeOptions(args[4:], &spec, false, false); err != nil {
+		return Encode(err, false)
+	}
+	src, err := getZSet(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := data_structure.CreateZSet()
+	err = zrange(src, args[2], args[3], spec, func(ele string, score float64) {
+		res.Add(score, ele, 0)
+	})
+	if err != nil {
+		return Encode(err, false)
+	}
+	if res.Len() == 0 {
+		if dictStore.Get(dst) != nil {
+			dictStore.Del(dst)
+			notifyKeyspaceEvent(NotifyGeneric, "del", dst)
+		}
+		return constant.RespZero
+	}
+	putZSet(dst, res)
+	notifyKeyspaceEvent(NotifyZSet, "zrangestore", dst)
+	return Encode(res.Len(), false)
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count] is ZRANGE key min max BYSCORE
+func cmdZRANGEBYSCORE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANGEBYSCORE' command"), false)
+	}
+	spec := zrangeSpec{by: zrangeByScore}
+	if err := parseZRangeOptions(args[3:], &spec, true, true); err != nil {
+		return Encode(err, false)
+	}
+	return zrangeReply(args[0], args[1], args[2], spec)
+}
+
+// ZREVRANGE key start stop [WITHSCORES] is ZRANGE key start stop REV
+func cmdZREVRANGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZREVRANGE' command"), false)
+	}
+	spec := zrangeSpec{reverse: true}
+	if err := parseZRangeOptions(args[3:], &spec, true, true); err != nil {
+		return Encode(err, false)
+	}
+	return zrangeReply(args[0], args[1], args[2], spec)
+}
+
+// ZCOUNT key min max returns the number of elements with a score between min and max, bounds like ZRANGEBYSCORE
+func cmdZCOUNT(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZCOUNT' command"), false)
+	}
+	zset, err := getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	zr, ok := data_structure.ParseZRange(args[1], args[2])
+	if !ok {
+		return Encode(errZRangeNotFloat, false)
+	}
+	if zset == nil {
+		return constant.RespZero
+	}
+	return Encode(zset.Count(zr), false)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZRangeByRank(t *testing.T) {
+	resetStores()
+	evalCmd("ZADD", "z", "1", "a", "2", "b", "3", "c", "4", "d")
+	assert.EqualValues(t, []interface{}{"a", "b", "c", "d"}, evalReply("ZRANGE", "z", "0", "-1"))
+	assert.EqualValues(t, []interface{}{"c", "d"}, evalReply("ZRANGE", "z", "-2", "10"))
+	assert.EqualValues(t, []interface{}{"b", "2.000000"}, evalReply("ZRANGE", "z", "1", "1", "WITHSCORES"))
+	assert.EqualValues(t, []interface{}{"d", "c"}, evalReply("ZRANGE", "z", "0", "1", "REV"))
+	assert.EqualValues(t, []interface{}{"d", "c", "b"}, evalReply("ZREVRANGE", "z", "0", "2"))
+	assert.Empty(t, evalReply("ZRANGE", "z", "3", "1"))
+	assert.Empty(t, evalReply("ZRANGE", "z", "5", "10"))
+	assert.Empty(t, evalReply("ZRANGE", "missing", "0", "-1"))
+	assertErrorReply(t, evalReply("ZRANGE", "z", "a", "1"))
+	assertErrorReply(t, evalReply("ZRANGE", "z", "0", "1", "LIMIT", "0", "1"))
+	assertErrorReply(t, evalReply("ZREVRANGE", "z", "0", "1", "BYSCORE"))
+
+	assert.EqualValues(t, 1, evalReply("ZRANK", "z", "b"))
+	assert.EqualValues(t, 2, evalReply("ZREVRANK", "z", "b"))
+	assert.Nil(t, evalReply("ZRANK", "z", "x"))
+	assert.Nil(t, evalReply("ZREVRANK", "z", "x"))
+	assert.Nil(t, evalReply("ZREVRANK", "missing", "x"))
+}
+
+func TestZRangeByScore(t *testing.T) {
+	resetStores()
+	evalCmd("ZADD", "z", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e")
+	assert.EqualValues(t, []interface{}{"b", "c", "d"}, evalReply("ZRANGE", "z", "2", "4", "BYSCORE"))
+	assert.EqualValues(t, []interface{}{"c", "d"}, evalReply("ZRANGE", "z", "(2", "4", "BYSCORE"))
+	assert.EqualValues(t, []interface{}{"e", "d", "c"}, evalReply("ZRANGE", "z", "+inf", "(2", "BYSCORE", "REV"))
+	assert.EqualValues(t, []interface{}{"b", "c"}, evalReply("ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "2"))
+	assert.EqualValues(t, []interface{}{"b", "c", "d", "e"}, evalReply("ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "-1"))
+	assert.Empty(t, evalReply("ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "-1", "2"))
+	assert.EqualValues(t, []interface{}{"d", "4.000000"}, evalReply("ZRANGEBYSCORE", "z", "4", "(5", "WITHSCORES"))
+	assert.EqualValues(t, []interface{}{"c"}, evalReply("ZRANGEBYSCORE", "z", "2", "5", "LIMIT", "1", "1"))
+	assert.Empty(t, evalReply("ZRANGEBYSCORE", "z", "4", "2"))
+	assertErrorReply(t, evalReply("ZRANGEBYSCORE", "z", "x", "2"))
+	assertErrorReply(t, evalReply("ZRANGEBYSCORE", "z", "1", "2", "REV"))
+	assertErrorReply(t, evalReply("ZRANGEBYSCORE", "z", "1", "2", "LIMIT", "1"))
+
+	assert.EqualValues(t, 5, evalReply("ZCOUNT", "z", "-inf", "+inf"))
+	assert.EqualValues(t, 2, evalReply("ZCOUNT", "z", "(2", "(5"))
+	assert.EqualValues(t, 0, evalReply("ZCOUNT", "z", "6", "7"))
+	assert.EqualValues(t, 0, evalReply("ZCOUNT", "missing", "1", "2"))
+	assertErrorReply(t, evalReply("ZCOUNT", "z", "1", "x"))
+}
+
+func TestZRangeByLex(t *testing.T) {
+	resetStores()
+	evalCmd("ZADD", "z", "0", "a", "0", "b", "0", "c", "0", "d")
+	assert.EqualValues(t, []interface{}{"a", "b", "c", "d"}, evalReply("ZRANGE", "z", "-", "+", "BYLEX"))
+	assert.EqualValues(t, []interface{}{"b", "c"}, evalReply("ZRANGE", "z", "[b", "(d", "BYLEX"))
+	assert.EqualValues(t, []interface{}{"c", "b"}, evalReply("ZRANGE", "z", "(d", "[b", "BYLEX", "REV"))
+	assert.EqualValues(t, []interface{}{"c"}, evalReply("ZRANGE", "z", "-", "+", "BYLEX", "LIMIT", "2", "1"))
+	assertErrorReply(t, evalReply("ZRANGE", "z", "b", "+", "BYLEX"))
+	assertErrorReply(t, evalReply("ZRANGE", "z", "-", "+", "BYLEX", "WITHSCORES"))
+}
+
+func TestZRangeStore(t *testing.T) {
+	resetStores()
+	evalCmd("ZADD", "z", "1", "a", "2", "b", "3", "c")
+	assert.EqualValues(t, 2, evalReply("ZRANGESTORE", "dst", "z", "(1", "+inf", "BYSCORE"))
+	assert.EqualValues(t, []interface{}{"b", "2.000000", "c", "3.000000"}, evalReply("ZRANGE", "dst", "0", "-1", "WITHSCORES"))
+	assert.EqualValues(t, 1, evalReply("ZRANGESTORE", "dst", "z", "0", "0", "REV"))
+	assert.EqualValues(t, []interface{}{"c"}, evalReply("ZRANGE", "dst", "0", "-1"))
+	// an empty range deletes dst
+	assert.EqualValues(t, 0, evalReply("ZRANGESTORE", "dst", "z", "5", "10"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "dst"))
+	assertErrorReply(t, evalReply("ZRANGESTORE", "dst", "z", "0", "1", "WITHSCORES"))
+}
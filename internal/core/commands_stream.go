@@ -0,0 +1,1093 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errStreamID = errors.New("(error) ERR Invalid stream ID specified as stream command argument")
+
+var errNoStreamKey = errors.New("(error) ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+
+func errNoGroup(key string, group string) error {
+	return fmt.Errorf("(error) NOGROUP No such key '%s' or consumer group '%s'", key, group)
+}
+
+func nowMs() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
+
+// parseStreamID parses an ID argument, - and + are the lowest and the greatest IDs, and a missing sequence
+// is missingSeq
+func parseStreamID(s string, missingSeq uint64) (data_structure.StreamID, error) {
+	switch s {
+	case "-":
+		return data_structure.StreamID{}, nil
+	case "+":
+		return data_structure.MaxStreamID, nil
+	}
+	id, ok := data_structure.ParseStreamID(s, missingSeq)
+	if !ok {
+		return id, errStreamID
+	}
+	return id, nil
+}
+
+// parseRangeID parses the start, or the end, of an interval of IDs. It is exclusive when prefixed by (.
+func parseRangeID(s string, isEnd bool) (data_structure.StreamID, error) {
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = math.MaxUint64
+	}
+	if !strings.HasPrefix(s, "(") {
+		return parseStreamID(s, missingSeq)
+	}
+	id, ok := data_structure.ParseStreamID(s[1:], missingSeq)
+	if !ok {
+		return id, errStreamID
+	}
+	if isEnd {
+		id, ok = id.Prev()
+	} else {
+		id, ok = id.Next()
+	}
+	if !ok {
+		if isEnd {
+			return id, errors.New("(error) ERR invalid end ID for the interval")
+		}
+		return id, errors.New("(error) ERR invalid start ID for the interval")
+	}
+	return id, nil
+}
+
+// streamEntriesReply returns the reply of entries: an array of [ID, [field, value, ...]]. A deleted entry
+// has a nil instead of its fields.
+func streamEntriesReply(entries []data_structure.StreamEntry) []interface{} {
+	res := make([]interface{}, len(entries))
+	for i, e := range entries {
+		if e.Fields == nil {
+			res[i] = []interface{}{e.ID.String(), nil}
+		} else {
+			res[i] = []interface{}{e.ID.String(), e.Fields}
+		}
+	}
+	return res
+}
+
+// streamGroup returns the stream of key and its group, or a NOGROUP error if either doesn't exist
+func streamGroup(key string, groupName string) (*data_structure.Stream, *data_structure.ConsumerGroup, error) {
+	stream, err := getStream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stream == nil || stream.Group(groupName) == nil {
+		return nil, nil, errNoGroup(key, groupName)
+	}
+	return stream, stream.Group(groupName), nil
+}
+
+// groupConsumer returns the consumer name of the group, created if it doesn't exist
+func groupConsumer(key string, group *data_structure.ConsumerGroup, name string) *data_structure.Consumer {
+	consumer, created := group.CreateConsumer(name)
+	if created {
+		notifyKeyspaceEvent(NotifyStream, "xgroup-createconsumer", key)
+		alsoPropagate("XGROUP", "CREATECONSUMER", key, group.Name(), name)
+	}
+	return consumer
+}
+
+// pendingIdle returns the ms elapsed since the last delivery of pe
+func pendingIdle(pe *data_structure.PendingEntry, now uint64) uint64 {
+	return now - min(pe.DeliveryTime, now)
+}
+
+// propagateStreamClaim signals key as modified and logs the pending entry of id as an XCLAIM that sets
+// its consumer, its delivery time and count, and the last ID of the group
+func propagateStreamClaim(key string, group *data_structure.ConsumerGroup, id data_structure.StreamID) {
+	signalModifiedKey(key)
+	pe := group.Pending(id)
+	alsoPropagate("XCLAIM", key, group.Name(), pe.Consumer(), "0", id.String(),
+		"TIME", strconv.FormatUint(pe.DeliveryTime, 10), "RETRYCOUNT", strconv.FormatUint(pe.DeliveryCount, 10),
+		"FORCE", "JUSTID", "LASTID", group.LastID().String())
+}
+
+// streamTrim holds the MAXLEN|MINID [=|~] threshold [LIMIT count] options of XADD and XTRIM
+type streamTrim struct {
+	// strategy is MAXLEN, MINID, or empty to not trim
+	strategy string
+	maxLen   int
+	minID    data_structure.StreamID
+	// limit is the max number of entries to delete, 0 for no limit
+	limit int
+}
+
+/*
+parseStreamTrim parses the trim options starting at args[i], and returns the position of the argument after
+them. The trimming is always exact: ~ only allows LIMIT, as an approximate trim is free to keep more entries.
+*/
+func parseStreamTrim(args []string, i int, trim *streamTrim) (int, error) {
+	trim.strategy = strings.ToUpper(args[i])
+	i++
+	approx := false
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		approx = args[i] == "~"
+		i++
+	}
+	if i >= len(args) {
+		return 0, errors.New("(error) ERR syntax error")
+	}
+	if trim.strategy == "MAXLEN" {
+		n, err := strconv.Atoi(args[i])
+		if err != nil {
+			return 0, errNotInteger
+		}
+		if n < 0 {
+			return 0, errors.New("(error) ERR The MAXLEN argument must be >= 0.")
+		}
+		trim.maxLen = n
+	} else {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			return 0, err
+		}
+		trim.minID = id
+	}
+	i++
+	if i+1 < len(args) && strings.ToUpper(args[i]) == "LIMIT" {
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return 0, errNotInteger
+		}
+		if n < 0 {
+			return 0, errors.New("(error) ERR The LIMIT argument must be >= 0.")
+		}
+		if !approx {
+			return 0, errors.New("(error) ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		trim.limit = n
+		i += 2
+	}
+	return i, nil
+}
+
+// apply trims stream and returns the number of deleted entries
+func (t *streamTrim) apply(stream *data_structure.Stream) int {
+	switch t.strategy {
+	case "MAXLEN":
+		return stream.TrimMaxLen(t.maxLen, t.limit)
+	case "MINID":
+		return stream.TrimMinID(t.minID, t.limit)
+	}
+	return 0
+}
+
+// xaddArgs are the parsed arguments of XADD
+type xaddArgs struct {
+	noMkStream bool
+	trim       streamTrim
+	// idIndex is the position of the ID in the arguments, the fields and values follow it
+	idIndex int
+}
+
+func parseXAddArgs(args []string) (*xaddArgs, error) {
+	a := &xaddArgs{}
+	i := 1
+loop:
+	for i < len(args) {
+		switch strings.ToUpper(args[i]) {
+		case "NOMKSTREAM":
+			a.noMkStream = true
+			i++
+		case "MAXLEN", "MINID":
+			var err error
+			if i, err = parseStreamTrim(args, i, &a.trim); err != nil {
+				return nil, err
+			}
+		default:
+			break loop
+		}
+	}
+	fields := len(args) - i - 1
+	if fields <= 0 || fields%2 != 0 {
+		return nil, errors.New("(error) ERR wrong number of arguments for 'XADD' command")
+	}
+	a.idIndex = i
+	return a, nil
+}
+
+// xaddID returns the ID of the entry that XADD adds to stream, from the ID argument: *, <ms>-* or an ID
+func xaddID(stream *data_structure.Stream, arg string) (data_structure.StreamID, error) {
+	errTooSmall := errors.New("(error) ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	last := stream.LastID()
+	if arg == "*" {
+		id, ok := stream.NextID(nowMs())
+		if !ok {
+			return id, errors.New("(error) ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return id, nil
+	}
+	if msPart, ok := strings.CutSuffix(arg, "-*"); ok {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return data_structure.StreamID{}, errStreamID
+		}
+		if ms > last.Ms {
+			return data_structure.StreamID{Ms: ms}, nil
+		}
+		if ms < last.Ms || last.Seq == math.MaxUint64 {
+			return data_structure.StreamID{}, errTooSmall
+		}
+		return data_structure.StreamID{Ms: ms, Seq: last.Seq + 1}, nil
+	}
+	id, ok := data_structure.ParseStreamID(arg, 0)
+	if !ok {
+		return id, errStreamID
+	}
+	if id == (data_structure.StreamID{}) {
+		return id, errors.New("(error) ERR The ID specified in XADD must be greater than 0-0")
+	}
+	if data_structure.CompareStreamIDs(id, last) <= 0 {
+		return id, errTooSmall
+	}
+	return id, nil
+}
+
+/*
+XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+Appends an entry to the stream, created unless NOMKSTREAM is given, and returns its ID. With *, the ID is
+the current time in ms followed by a sequence number, and <ms>-* only generates the sequence number.
+The stream is then trimmed to threshold entries with MAXLEN, or to the entries whose ID is >= threshold with MINID.
+It is logged to the AOF with the ID that was generated.
+*/
+func cmdXADD(args []string) []byte {
+	if len(args) < 4 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XADD' command"), false)
+	}
+	a, err := parseXAddArgs(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	key := args[0]
+	stream, err := getStream(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil && a.noMkStream {
+		return constant.RespNil
+	}
+	created := stream == nil
+	if created {
+		stream = data_structure.CreateStream()
+	}
+	id, err := xaddID(stream, args[a.idIndex])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if created {
+		putStream(key, stream)
+	}
+	stream.Add(id, append([]string(nil), args[a.idIndex+1:]...))
+	notifyKeyspaceEvent(NotifyStream, "xadd", key)
+	if a.trim.apply(stream) > 0 {
+		notifyKeyspaceEvent(NotifyStream, "xtrim", key)
+	}
+	signalKeyAsReady(key)
+	return Encode(id.String(), false)
+}
+
+// XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count] trims the stream like XADD, and returns the number of deleted entries
+func cmdXTRIM(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XTRIM' command"), false)
+	}
+	var trim streamTrim
+	if strategy := strings.ToUpper(args[1]); strategy != "MAXLEN" && strategy != "MINID" {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	next, err := parseStreamTrim(args, 1, &trim)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if next != len(args) {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespZero
+	}
+	deleted := trim.apply(stream)
+	if deleted > 0 {
+		notifyKeyspaceEvent(NotifyStream, "xtrim", args[0])
+	}
+	return Encode(deleted, false)
+}
+
+// XDEL key id [id ...] deletes entries and returns the number of entries that existed
+func cmdXDEL(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XDEL' command"), false)
+	}
+	ids := make([]data_structure.StreamID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, ok := data_structure.ParseStreamID(arg, 0)
+		if !ok {
+			return Encode(errStreamID, false)
+		}
+		ids[i] = id
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespZero
+	}
+	deleted := 0
+	for _, id := range ids {
+		if stream.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		notifyKeyspaceEvent(NotifyStream, "xdel", args[0])
+	}
+	return Encode(deleted, false)
+}
+
+func cmdXLEN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XLEN' command"), false)
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespZero
+	}
+	return Encode(stream.Len(), false)
+}
+
+/*
+XRANGE key start end [COUNT count] and XREVRANGE key end start [COUNT count]
+Return the entries whose IDs are between start and end, in ascending or descending order. - and + are the
+lowest and greatest IDs, a missing sequence number is the lowest for start and the greatest for end, and
+an ID prefixed by ( is excluded from the interval.
+*/
+func xrangeGeneric(name string, args []string, rev bool) []byte {
+	if len(args) != 3 && len(args) != 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for '"+name+"' command"), false)
+	}
+	startArg, endArg := args[1], args[2]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseRangeID(startArg, false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	end, err := parseRangeID(endArg, true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		n, err := strconv.Atoi(args[4])
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if n <= 0 {
+			return constant.RespEmptyArray
+		}
+		count = n
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespEmptyArray
+	}
+	return Encode(streamEntriesReply(stream.Range(start, end, count, rev)), false)
+}
+
+func cmdXRANGE(args []string) []byte {
+	return xrangeGeneric("XRANGE", args, false)
+}
+
+func cmdXREVRANGE(args []string) []byte {
+	return xrangeGeneric("XREVRANGE", args, true)
+}
+
+// streamReadArgs are the options of XREAD and XREADGROUP
+type streamReadArgs struct {
+	// count is the max number of entries returned per stream, 0 for no limit
+	count   int
+	block   bool
+	timeout time.Duration
+	noAck   bool
+	keys    []string
+	ids     []string
+}
+
+// parseStreamReadArgs parses the options of XREAD, or of XREADGROUP if group is true, starting at args[i]
+func parseStreamReadArgs(args []string, i int, group bool) (*streamReadArgs, error) {
+	r := &streamReadArgs{}
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if (opt == "COUNT" || opt == "BLOCK") && i+1 == len(args) {
+			return nil, errors.New("(error) ERR syntax error")
+		}
+		switch opt {
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, errNotInteger
+			}
+			r.count = max(n, 0)
+			i++
+		case "BLOCK":
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, errors.New("(error) ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, errors.New("(error) ERR timeout is negative")
+			}
+			r.block, r.timeout = true, time.Duration(ms)*time.Millisecond
+			i++
+		case "NOACK":
+			if !group {
+				return nil, errors.New("(error) ERR syntax error")
+			}
+			r.noAck = true
+		case "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				if group {
+					return nil, errors.New("(error) ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+				}
+				return nil, errors.New("(error) ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+			}
+			r.keys, r.ids = rest[:len(rest)/2], rest[len(rest)/2:]
+			return r, nil
+		default:
+			return nil, errors.New("(error) ERR syntax error")
+		}
+	}
+	return nil, errors.New("(error) ERR syntax error")
+}
+
+/*
+XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+Returns, for every stream that has some, the entries whose IDs are greater than the ID given for the stream,
+$ being the last ID of the stream. If there are none, it returns a null array, or with BLOCK, blocks the
+client until an entry is added to one of the streams or the timeout is reached, 0 to wait forever.
+*/
+func cmdXREAD(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XREAD' command"), false)
+	}
+	r, err := parseStreamReadArgs(args, 0, false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	streams := make([]*data_structure.Stream, len(r.keys))
+	ids := make([]data_structure.StreamID, len(r.keys))
+	for i, key := range r.keys {
+		stream, err := getStream(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		streams[i] = stream
+		if r.ids[i] == "$" {
+			if stream != nil {
+				ids[i] = stream.LastID()
+			}
+			continue
+		}
+		id, ok := data_structure.ParseStreamID(r.ids[i], 0)
+		if !ok {
+			return Encode(errStreamID, false)
+		}
+		ids[i] = id
+	}
+	res := make([]interface{}, 0)
+	for i, stream := range streams {
+		start, ok := ids[i].Next()
+		if stream == nil || !ok {
+			continue
+		}
+		if entries := stream.Range(start, data_structure.MaxStreamID, r.count, false); len(entries) > 0 {
+			res = append(res, []interface{}{r.keys[i], streamEntriesReply(entries)})
+		}
+	}
+	if len(res) > 0 {
+		return Encode(res, false)
+	}
+	if !r.block || !canBlock() {
+		return constant.RespNilArray
+	}
+	// a blocked client runs its command again when an entry is added, it keeps waiting if there is nothing to read
+	if !currentClient.block.blocked() {
+		// $ means the last ID when the client blocked, the command is run again with the IDs resolved
+		resolved := append([]string(nil), args[:len(args)-len(ids)]...)
+		for _, id := range ids {
+			resolved = append(resolved, id.String())
+		}
+		blockForKeys(&MemKVCmd{Cmd: "XREAD", Args: resolved}, r.keys, r.timeout)
+	}
+	return nil
+}
+
+/*
+XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+Reads the streams as the consumer of a group, which is created if needed. With the ID >, it returns the entries
+never delivered to the group, which become pending for the consumer unless NOACK is given, and blocks like
+XREAD if there are none. With any other ID, it returns the pending entries of the consumer whose IDs are
+greater, and never blocks.
+The deliveries are logged to the AOF as XCLAIMs, as they depend on the time.
+*/
+func cmdXREADGROUP(args []string) []byte {
+	if len(args) < 6 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XREADGROUP' command"), false)
+	}
+	if strings.ToUpper(args[0]) != "GROUP" {
+		return Encode(errors.New("(error) ERR syntax error"), false)
+	}
+	groupName, consumerName := args[1], args[2]
+	r, err := parseStreamReadArgs(args, 3, true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	streams := make([]*data_structure.Stream, len(r.keys))
+	groups := make([]*data_structure.ConsumerGroup, len(r.keys))
+	ids := make([]data_structure.StreamID, len(r.keys))
+	history := false
+	for i, key := range r.keys {
+		streams[i], groups[i], err = streamGroup(key, groupName)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if r.ids[i] == ">" {
+			continue
+		}
+		id, ok := data_structure.ParseStreamID(r.ids[i], 0)
+		if !ok {
+			return Encode(errStreamID, false)
+		}
+		ids[i] = id
+		history = true
+	}
+	now := nowMs()
+	res := make([]interface{}, 0)
+	for i, key := range r.keys {
+		stream, group := streams[i], groups[i]
+		consumer := groupConsumer(key, group, consumerName)
+		if r.ids[i] != ">" {
+			entries := make([]data_structure.StreamEntry, 0)
+			if start, ok := ids[i].Next(); ok {
+				entries = group.ReadPending(stream, consumer, start, r.count, now)
+			}
+			for _, e := range entries {
+				// the delivery of a deleted entry is not logged, XCLAIM would drop it
+				if e.Fields != nil {
+					propagateStreamClaim(key, group, e.ID)
+				}
+			}
+			res = append(res, []interface{}{key, streamEntriesReply(entries)})
+			continue
+		}
+		entries := group.ReadNew(stream, consumer, r.count, now, r.noAck)
+		if len(entries) == 0 {
+			continue
+		}
+		if r.noAck {
+			signalModifiedKey(key)
+			alsoPropagate("XGROUP", "SETID", key, groupName, group.LastID().String())
+		} else {
+			for _, e := range entries {
+				propagateStreamClaim(key, group, e.ID)
+			}
+		}
+		res = append(res, []interface{}{key, streamEntriesReply(entries)})
+	}
+	if len(res) > 0 || history {
+		return Encode(res, false)
+	}
+	if !r.block || !canBlock() {
+		return constant.RespNilArray
+	}
+	if !currentClient.block.blocked() {
+		blockForKeys(&MemKVCmd{Cmd: "XREADGROUP", Args: args}, r.keys, r.timeout)
+	}
+	return nil
+}
+
+// XACK key group id [id ...] removes entries from the pending entries of the group, and returns how many were pending
+func cmdXACK(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XACK' command"), false)
+	}
+	ids := make([]data_structure.StreamID, len(args)-2)
+	for i, arg := range args[2:] {
+		id, ok := data_structure.ParseStreamID(arg, 0)
+		if !ok {
+			return Encode(errStreamID, false)
+		}
+		ids[i] = id
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil || stream.Group(args[1]) == nil {
+		return constant.RespZero
+	}
+	group := stream.Group(args[1])
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	if acked > 0 {
+		signalModifiedKey(args[0])
+	}
+	return Encode(acked, false)
+}
+
+/*
+XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+Without range, returns a summary of the pending entries of the group: their number, the lowest and greatest
+pending IDs, and the number of pending entries of every consumer. With a range, returns up to count pending
+entries, of consumer only if given, idle for at least min-idle-time ms, with their consumer, idle time
+and number of deliveries.
+*/
+func cmdXPENDING(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XPENDING' command"), false)
+	}
+	key, groupName := args[0], args[1]
+	extended := len(args) > 2
+	var minIdle uint64
+	var start, end data_structure.StreamID
+	var count int
+	var consumerName string
+	if extended {
+		i := 2
+		if strings.ToUpper(args[i]) == "IDLE" && i+1 < len(args) {
+			n, err := strconv.ParseUint(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			minIdle = n
+			i += 2
+		}
+		if len(args)-i != 3 && len(args)-i != 4 {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		var err error
+		if start, err = parseRangeID(args[i], false); err != nil {
+			return Encode(err, false)
+		}
+		if end, err = parseRangeID(args[i+1], true); err != nil {
+			return Encode(err, false)
+		}
+		if count, err = strconv.Atoi(args[i+2]); err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if len(args)-i == 4 {
+			consumerName = args[i+3]
+		}
+	}
+	_, group, err := streamGroup(key, groupName)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	if !extended {
+		first, last, ok := group.PendingBounds()
+		if !ok {
+			return []byte("*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n")
+		}
+		consumers := make([]interface{}, 0)
+		for _, c := range group.Consumers() {
+			if c.PendingLen() > 0 {
+				consumers = append(consumers, []string{c.Name(), strconv.Itoa(c.PendingLen())})
+			}
+		}
+		return Encode([]interface{}{group.PendingLen(), first.String(), last.String(), consumers}, false)
+	}
+
+	res := make([]interface{}, 0)
+	var consumer *data_structure.Consumer
+	if consumerName != "" {
+		if consumer = group.Consumer(consumerName); consumer == nil {
+			return constant.RespEmptyArray
+		}
+	}
+	if count <= 0 {
+		return constant.RespEmptyArray
+	}
+	now := nowMs()
+	group.PendingRange(start, end, consumer, func(id data_structure.StreamID, pe *data_structure.PendingEntry) bool {
+		idle := pendingIdle(pe, now)
+		if idle < minIdle {
+			return true
+		}
+		res = append(res, []interface{}{id.String(), pe.Consumer(), int64(idle), int64(pe.DeliveryCount)})
+		return len(res) < count
+	})
+	return Encode(res, false)
+}
+
+// parseMinIdle parses the min-idle-time of XCLAIM and XAUTOCLAIM
+func parseMinIdle(name string, s string) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("(error) ERR Invalid min-idle-time argument for " + name)
+	}
+	return n, nil
+}
+
+/*
+XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-ms] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID id]
+Gives to consumer the pending entries that have been idle for at least min-idle-time ms, and returns them.
+Their delivery time is reset, or set by IDLE or TIME, and their delivery count is incremented, unless JUSTID
+is given, or set by RETRYCOUNT. With FORCE, the entries of the stream that are not pending are claimed
+as well. Pending entries that were deleted from the stream are dropped instead of claimed. LASTID moves the
+last ID of the group forward. JUSTID returns only the IDs.
+The claims are logged to the AOF with their delivery time and count, as they depend on the time.
+*/
+func cmdXCLAIM(args []string) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XCLAIM' command"), false)
+	}
+	key, groupName, consumerName := args[0], args[1], args[2]
+	minIdle, err := parseMinIdle("XCLAIM", args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var ids []data_structure.StreamID
+	i := 4
+	for ; i < len(args); i++ {
+		id, ok := data_structure.ParseStreamID(args[i], 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	now := nowMs()
+	deliveryTime := now
+	var retryCount uint64
+	hasRetryCount, force, justID := false, false, false
+	var lastID *data_structure.StreamID
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		switch opt {
+		case "FORCE":
+			force = true
+			continue
+		case "JUSTID":
+			justID = true
+			continue
+		case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+			if i+1 == len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+		default:
+			return Encode(fmt.Errorf("(error) ERR Unrecognized XCLAIM option '%s'", args[i]), false)
+		}
+		i++
+		if opt == "LASTID" {
+			id, ok := data_structure.ParseStreamID(args[i], 0)
+			if !ok {
+				return Encode(errStreamID, false)
+			}
+			lastID = &id
+			continue
+		}
+		n, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch opt {
+		case "IDLE":
+			deliveryTime = now - min(n, now)
+		case "TIME":
+			deliveryTime = min(n, now)
+		case "RETRYCOUNT":
+			retryCount, hasRetryCount = n, true
+		}
+	}
+	stream, group, err := streamGroup(key, groupName)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if lastID != nil && data_structure.CompareStreamIDs(*lastID, group.LastID()) > 0 {
+		group.SetLastID(*lastID)
+		signalModifiedKey(key)
+		alsoPropagate("XGROUP", "SETID", key, groupName, lastID.String())
+	}
+	consumer := groupConsumer(key, group, consumerName)
+	res := make([]interface{}, 0)
+	for _, id := range ids {
+		pe := group.Pending(id)
+		fields, exists := stream.Get(id)
+		if pe == nil && (!force || !exists) {
+			continue
+		}
+		if pe != nil && pendingIdle(pe, now) < minIdle {
+			continue
+		}
+		if !exists {
+			group.Ack(id)
+			signalModifiedKey(key)
+			alsoPropagate("XACK", key, groupName, id.String())
+			continue
+		}
+		var deliveryCount uint64
+		if pe != nil {
+			deliveryCount = pe.DeliveryCount
+		}
+		if hasRetryCount {
+			deliveryCount = retryCount
+		} else if !justID {
+			deliveryCount++
+		}
+		group.Claim(id, consumer, deliveryTime, deliveryCount)
+		propagateStreamClaim(key, group, id)
+		if justID {
+			res = append(res, id.String())
+		} else {
+			res = append(res, []interface{}{id.String(), fields})
+		}
+	}
+	return Encode(res, false)
+}
+
+/*
+XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+Scans the pending entries of the group from start, and claims like XCLAIM up to count entries, 100 by default,
+idle for at least min-idle-time ms. It returns the ID to start the next call from, 0-0 at the end of the
+pending entries, the claimed entries, and the IDs of the pending entries deleted from the stream, which are
+dropped. At most 10 times count pending entries are scanned by a call.
+*/
+func cmdXAUTOCLAIM(args []string) []byte {
+	if len(args) < 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XAUTOCLAIM' command"), false)
+	}
+	key, groupName, consumerName := args[0], args[1], args[2]
+	minIdle, err := parseMinIdle("XAUTOCLAIM", args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	start, err := parseStreamID(args[4], 0)
+	if err != nil {
+		return Encode(err, false)
+	}
+	count, justID := 100, false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if i+1 == len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 || n > math.MaxInt32 {
+				return Encode(errors.New("(error) ERR COUNT must be > 0"), false)
+			}
+			count = n
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+	stream, group, err := streamGroup(key, groupName)
+	if err != nil {
+		return Encode(err, false)
+	}
+	consumer := groupConsumer(key, group, consumerName)
+
+	now := nowMs()
+	attempts := 10 * count
+	var next data_structure.StreamID
+	var claimed, deleted []data_structure.StreamID
+	group.PendingRange(start, data_structure.MaxStreamID, nil, func(id data_structure.StreamID, pe *data_structure.PendingEntry) bool {
+		if attempts == 0 || len(claimed) == count {
+			next = id
+			return false
+		}
+		attempts--
+		if _, exists := stream.Get(id); !exists {
+			deleted = append(deleted, id)
+		} else if pendingIdle(pe, now) >= minIdle {
+			claimed = append(claimed, id)
+		}
+		return true
+	})
+
+	deletedIDs := make([]string, len(deleted))
+	for i, id := range deleted {
+		group.Ack(id)
+		signalModifiedKey(key)
+		alsoPropagate("XACK", key, groupName, id.String())
+		deletedIDs[i] = id.String()
+	}
+	res := make([]interface{}, len(claimed))
+	for i, id := range claimed {
+		deliveryCount := group.Pending(id).DeliveryCount
+		if !justID {
+			deliveryCount++
+		}
+		group.Claim(id, consumer, now, deliveryCount)
+		propagateStreamClaim(key, group, id)
+		if justID {
+			res[i] = id.String()
+		} else {
+			fields, _ := stream.Get(id)
+			res[i] = []interface{}{id.String(), fields}
+		}
+	}
+	return Encode([]interface{}{next.String(), res, deletedIDs}, false)
+}
+
+// xgroupArity is the number of arguments of every XGROUP subcommand, subcommand included
+var xgroupArity = map[string]int{"CREATE": 4, "SETID": 4, "DESTROY": 3, "CREATECONSUMER": 4, "DELCONSUMER": 4}
+
+/*
+XGROUP CREATE key group id|$ [MKSTREAM]
+XGROUP SETID key group id|$
+XGROUP DESTROY key group
+XGROUP CREATECONSUMER key group consumer
+XGROUP DELCONSUMER key group consumer
+Manage the consumer groups of a stream. CREATE creates a group that delivers the entries after id, $ being the
+last ID of the stream, which is created empty with MKSTREAM. SETID changes the last delivered ID of a group.
+DELCONSUMER deletes a consumer with its pending entries, and returns how many it had.
+*/
+func cmdXGROUP(args []string) []byte {
+	if len(args) < 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XGROUP' command"), false)
+	}
+	sub := strings.ToUpper(args[0])
+	n, ok := xgroupArity[sub]
+	if !ok {
+		return Encode(fmt.Errorf("(error) ERR unknown subcommand '%s'", args[0]), false)
+	}
+	if len(args) != n && !(sub == "CREATE" && len(args) == n+1) {
+		return Encode(fmt.Errorf("(error) ERR wrong number of arguments for 'XGROUP|%s' command", strings.ToLower(sub)), false)
+	}
+	key, groupName := args[1], args[2]
+	stream, err := getStream(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	// groupLastID parses the ID of CREATE and SETID
+	groupLastID := func() (data_structure.StreamID, error) {
+		if args[3] == "$" {
+			if stream == nil {
+				return data_structure.StreamID{}, nil
+			}
+			return stream.LastID(), nil
+		}
+		return parseStreamID(args[3], 0)
+	}
+
+	if sub == "CREATE" {
+		if len(args) == 5 && strings.ToUpper(args[4]) != "MKSTREAM" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		if stream == nil && len(args) != 5 {
+			return Encode(errNoStreamKey, false)
+		}
+		id, err := groupLastID()
+		if err != nil {
+			return Encode(err, false)
+		}
+		if stream == nil {
+			stream = data_structure.CreateStream()
+			putStream(key, stream)
+		}
+		if _, ok := stream.CreateGroup(groupName, id); !ok {
+			return Encode(errors.New("(error) BUSYGROUP Consumer Group name already exists"), false)
+		}
+		notifyKeyspaceEvent(NotifyStream, "xgroup-create", key)
+		return constant.RespOk
+	}
+
+	if stream == nil {
+		return Encode(errNoStreamKey, false)
+	}
+	if sub == "DESTROY" {
+		if !stream.DestroyGroup(groupName) {
+			return constant.RespZero
+		}
+		notifyKeyspaceEvent(NotifyStream, "xgroup-destroy", key)
+		return constant.RespOne
+	}
+	group := stream.Group(groupName)
+	if group == nil {
+		return Encode(errNoGroup(key, groupName), false)
+	}
+	switch sub {
+	case "SETID":
+		id, err := groupLastID()
+		if err != nil {
+			return Encode(err, false)
+		}
+		group.SetLastID(id)
+		notifyKeyspaceEvent(NotifyStream, "xgroup-setid", key)
+		return constant.RespOk
+	case "CREATECONSUMER":
+		if _, created := group.CreateConsumer(args[3]); !created {
+			return constant.RespZero
+		}
+		notifyKeyspaceEvent(NotifyStream, "xgroup-createconsumer", key)
+		return constant.RespOne
+	default:
+		pending, ok := group.DeleteConsumer(args[3])
+		if ok {
+			notifyKeyspaceEvent(NotifyStream, "xgroup-delconsumer", key)
+		}
+		return Encode(pending, false)
+	}
+}
+
+// XSETID key last-id sets the last ID of the stream, which can't be lower than the ID of its last entry
+func cmdXSETID(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'XSETID' command"), false)
+	}
+	id, ok := data_structure.ParseStreamID(args[1], 0)
+	if !ok {
+		return Encode(errStreamID, false)
+	}
+	stream, err := getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return Encode(errors.New("(error) ERR no such key"), false)
+	}
+	if top, ok := stream.LastEntryID(); ok && data_structure.CompareStreamIDs(id, top) < 0 {
+		return Encode(errors.New("(error) ERR The ID specified in XSETID is smaller than the target stream top item"), false)
+	}
+	stream.SetLastID(id)
+	notifyKeyspaceEvent(NotifyStream, "xsetid", args[0])
+	return constant.RespOk
+}
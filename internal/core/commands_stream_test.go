@@ -0,0 +1,307 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(id string, fields ...interface{}) []interface{} {
+	return []interface{}{id, fields}
+}
+
+func TestXAddAndRange(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "1-1", evalReply("XADD", "s", "1-1", "a", "1"))
+	assert.EqualValues(t, "1-2", evalReply("XADD", "s", "1-*", "b", "2"))
+	assert.EqualValues(t, "5-0", evalReply("XADD", "s", "5", "c", "3"))
+	assertErrorReply(t, evalReply("XADD", "s", "5-0", "d", "4"))
+	assertErrorReply(t, evalReply("XADD", "s", "0-0", "d", "4"))
+	assertErrorReply(t, evalReply("XADD", "s", "x", "d", "4"))
+	assertErrorReply(t, evalReply("XADD", "s", "*", "d"))
+	id := evalReply("XADD", "s", "*", "d", "4").(string)
+	assert.NotEqual(t, "5-0", id)
+	assert.EqualValues(t, 4, evalReply("XLEN", "s"))
+	assert.EqualValues(t, "stream", evalReply("TYPE", "s"))
+
+	assert.Nil(t, evalReply("XADD", "none", "NOMKSTREAM", "*", "a", "1"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "none"))
+	assert.EqualValues(t, 0, evalReply("XLEN", "none"))
+
+	assert.EqualValues(t, []interface{}{entry("1-1", "a", "1"), entry("1-2", "b", "2")},
+		evalReply("XRANGE", "s", "-", "+", "COUNT", "2"))
+	assert.EqualValues(t, []interface{}{entry("1-2", "b", "2"), entry("5-0", "c", "3")},
+		evalReply("XRANGE", "s", "(1-1", "5"))
+	assert.EqualValues(t, []interface{}{entry("5-0", "c", "3"), entry("1-2", "b", "2"), entry("1-1", "a", "1")},
+		evalReply("XREVRANGE", "s", "5", "-"))
+	assert.EqualValues(t, []interface{}{entry("1-1", "a", "1"), entry("1-2", "b", "2")},
+		evalReply("XRANGE", "s", "1", "1"))
+	assert.EqualValues(t, []interface{}{}, evalReply("XRANGE", "s", "6", "7"))
+	assertErrorReply(t, evalReply("XRANGE", "s", "x", "+"))
+
+	assert.EqualValues(t, 1, evalReply("XDEL", "s", "1-2", "9-9"))
+	assert.EqualValues(t, 3, evalReply("XLEN", "s"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("XADD", "str", "*", "a", "1"))
+	assertErrorReply(t, evalReply("XLEN", "str"))
+}
+
+func TestXAddTrimAndXTrim(t *testing.T) {
+	resetStores()
+	for i := 1; i <= 5; i++ {
+		evalCmd("XADD", "s", "MAXLEN", "3", "*", "n", "v")
+	}
+	assert.EqualValues(t, 3, evalReply("XLEN", "s"))
+
+	resetStores()
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		evalCmd("XADD", "s", id, "n", id)
+	}
+	assert.EqualValues(t, "6-0", evalReply("XADD", "s", "MINID", "3", "6", "n", "6"))
+	assert.EqualValues(t, []interface{}{entry("3-0", "n", "3")}, evalReply("XRANGE", "s", "-", "+", "COUNT", "1"))
+	assert.EqualValues(t, 2, evalReply("XTRIM", "s", "MAXLEN", "=", "2"))
+	assert.EqualValues(t, 1, evalReply("XTRIM", "s", "MAXLEN", "~", "0", "LIMIT", "1"))
+	assert.EqualValues(t, 1, evalReply("XLEN", "s"))
+	assertErrorReply(t, evalReply("XTRIM", "s", "MAXLEN", "0", "LIMIT", "1"))
+	assertErrorReply(t, evalReply("XTRIM", "s", "SIZE", "1"))
+	assertErrorReply(t, evalReply("XTRIM", "s", "MAXLEN", "-1"))
+
+	// trimming doesn't lower the last ID
+	assert.EqualValues(t, 1, evalReply("XTRIM", "s", "MAXLEN", "0"))
+	assert.EqualValues(t, 1, evalReply("EXISTS", "s"))
+	assertErrorReply(t, evalReply("XADD", "s", "6", "n", "v"))
+}
+
+func TestXRead(t *testing.T) {
+	resetStores()
+	evalCmd("XADD", "a", "1", "x", "1")
+	evalCmd("XADD", "a", "2", "x", "2")
+	evalCmd("XADD", "b", "1", "y", "1")
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"a", []interface{}{entry("2-0", "x", "2")}},
+		[]interface{}{"b", []interface{}{entry("1-0", "y", "1")}},
+	}, evalReply("XREAD", "STREAMS", "a", "b", "1", "0"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"a", []interface{}{entry("1-0", "x", "1")}},
+	}, evalReply("XREAD", "COUNT", "1", "STREAMS", "a", "none", "0", "0"))
+	assert.Nil(t, evalReply("XREAD", "STREAMS", "a", "$"))
+	// without a client it doesn't block
+	assert.Nil(t, evalReply("XREAD", "BLOCK", "0", "STREAMS", "a", "$"))
+	assertErrorReply(t, evalReply("XREAD", "STREAMS", "a", "b", "0"))
+	assertErrorReply(t, evalReply("XREAD", "STREAMS", "a", "x"))
+	assertErrorReply(t, evalReply("XREAD", "BLOCK", "-1", "STREAMS", "a", "0"))
+}
+
+func TestXReadBlocking(t *testing.T) {
+	resetStores()
+	evalCmd("XADD", "s", "1", "x", "1")
+	c1 := &FDComm{Fd: -1}
+	c2 := &FDComm{Fd: -1}
+	defer FreeClient(c1)
+	defer FreeClient(c2)
+	assert.Empty(t, runClientCmd(c1, "XREAD", "BLOCK", "0", "STREAMS", "s", "$"))
+	assert.Empty(t, runClientCmd(c2, "XREAD", "BLOCK", "0", "STREAMS", "other", "s", "$", "$"))
+
+	// a write to another key doesn't unblock them
+	evalCmd("XADD", "unrelated", "*", "x", "1")
+	assert.True(t, c1.block.blocked())
+	assert.True(t, c2.block.blocked())
+
+	// every client reading a stream is served by the same entry
+	evalCmd("XADD", "s", "2", "x", "2")
+	reply := []interface{}{[]interface{}{"s", []interface{}{entry("2-0", "x", "2")}}}
+	assert.EqualValues(t, []interface{}{reply}, clientMessages(c1))
+	assert.EqualValues(t, []interface{}{reply}, clientMessages(c2))
+	assert.EqualValues(t, 0, len(blockedClients))
+	assert.EqualValues(t, 0, len(blockingKeys))
+
+	// $ is the last ID when the client blocked: an entry deleted meanwhile doesn't wake it up with old entries
+	runClientCmd(c1, "XREAD", "BLOCK", "0", "STREAMS", "s", "$")
+	evalCmd("XDEL", "s", "2-0")
+	assert.True(t, c1.block.blocked())
+	evalCmd("XADD", "s", "3", "x", "3")
+	assert.EqualValues(t, []interface{}{[]interface{}{[]interface{}{"s", []interface{}{entry("3-0", "x", "3")}}}},
+		clientMessages(c1))
+
+	assert.Empty(t, runClientCmd(c1, "XREAD", "BLOCK", "50", "STREAMS", "s", "$"))
+	time.Sleep(60 * time.Millisecond)
+	handleBlockedClientsTimeout()
+	assert.EqualValues(t, []interface{}{nil}, clientMessages(c1))
+	assert.False(t, c1.block.blocked())
+
+	// in a transaction it doesn't block
+	clientReply(c1, "MULTI")
+	clientReply(c1, "XREAD", "BLOCK", "0", "STREAMS", "s", "$")
+	assert.EqualValues(t, []interface{}{nil}, clientReply(c1, "EXEC"))
+	assert.False(t, c1.block.blocked())
+}
+
+func TestXGroup(t *testing.T) {
+	resetStores()
+	assertErrorReply(t, evalReply("XGROUP", "CREATE", "s", "g", "$"))
+	assert.EqualValues(t, "OK", evalReply("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM"))
+	assert.EqualValues(t, 0, evalReply("XLEN", "s"))
+	assertErrorReply(t, evalReply("XGROUP", "CREATE", "s", "g", "0"))
+	assertErrorReply(t, evalReply("XGROUP", "CREATE", "s", "g2", "0", "OTHER"))
+	assertErrorReply(t, evalReply("XGROUP", "HELP2"))
+	assertErrorReply(t, evalReply("XGROUP", "DESTROY", "s"))
+
+	assert.EqualValues(t, 1, evalReply("XGROUP", "CREATECONSUMER", "s", "g", "alice"))
+	assert.EqualValues(t, 0, evalReply("XGROUP", "CREATECONSUMER", "s", "g", "alice"))
+	assertErrorReply(t, evalReply("XGROUP", "CREATECONSUMER", "s", "nogroup", "alice"))
+	assert.EqualValues(t, "OK", evalReply("XGROUP", "SETID", "s", "g", "0"))
+	assert.EqualValues(t, 0, evalReply("XGROUP", "DELCONSUMER", "s", "g", "alice"))
+	assert.EqualValues(t, 0, evalReply("XGROUP", "DELCONSUMER", "s", "g", "bob"))
+	assert.EqualValues(t, 1, evalReply("XGROUP", "DESTROY", "s", "g"))
+	assert.EqualValues(t, 0, evalReply("XGROUP", "DESTROY", "s", "g"))
+
+	evalCmd("XADD", "s", "5", "a", "1")
+	assertErrorReply(t, evalReply("XSETID", "s", "4"))
+	assert.EqualValues(t, "OK", evalReply("XSETID", "s", "10"))
+	assertErrorReply(t, evalReply("XADD", "s", "9", "a", "1"))
+	assertErrorReply(t, evalReply("XSETID", "none", "10"))
+}
+
+func TestXReadGroup(t *testing.T) {
+	resetStores()
+	evalCmd("XADD", "s", "1", "a", "1")
+	evalCmd("XADD", "s", "2", "a", "2")
+	evalCmd("XADD", "s", "3", "a", "3")
+	evalCmd("XGROUP", "CREATE", "s", "g", "0")
+	assertErrorReply(t, evalReply("XREADGROUP", "GROUP", "nogroup", "c", "STREAMS", "s", ">"))
+
+	assert.EqualValues(t, []interface{}{[]interface{}{"s", []interface{}{entry("1-0", "a", "1"), entry("2-0", "a", "2")}}},
+		evalReply("XREADGROUP", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "s", ">"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"s", []interface{}{entry("3-0", "a", "3")}}},
+		evalReply("XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"))
+	assert.Nil(t, evalReply("XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"))
+
+	// the history of a consumer is its pending entries, a deleted one is returned without fields
+	evalCmd("XDEL", "s", "2")
+	assert.EqualValues(t, []interface{}{[]interface{}{"s", []interface{}{entry("1-0", "a", "1"), []interface{}{"2-0", nil}}}},
+		evalReply("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "0"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"s", []interface{}{}}},
+		evalReply("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "2"))
+
+	summary := evalReply("XPENDING", "s", "g").([]interface{})
+	assert.EqualValues(t, []interface{}{int64(3), "1-0", "3-0",
+		[]interface{}{[]interface{}{"alice", "2"}, []interface{}{"bob", "1"}}}, summary)
+	pending := evalReply("XPENDING", "s", "g", "-", "+", "10", "alice").([]interface{})
+	assert.EqualValues(t, 2, len(pending))
+	assert.EqualValues(t, "1-0", pending[0].([]interface{})[0])
+	assert.EqualValues(t, "alice", pending[0].([]interface{})[1])
+	// the history read was a second delivery
+	assert.EqualValues(t, 2, pending[0].([]interface{})[3])
+	assert.EqualValues(t, []interface{}{}, evalReply("XPENDING", "s", "g", "IDLE", "100000", "-", "+", "10"))
+	assert.EqualValues(t, []interface{}{}, evalReply("XPENDING", "s", "g", "-", "+", "10", "nobody"))
+
+	assert.EqualValues(t, 2, evalReply("XACK", "s", "g", "1", "2", "9"))
+	assert.EqualValues(t, 0, evalReply("XACK", "s", "nogroup", "3"))
+	assert.EqualValues(t, 1, evalReply("XGROUP", "DELCONSUMER", "s", "g", "bob"))
+	assert.EqualValues(t, []interface{}{int64(0), nil, nil, nil}, evalReply("XPENDING", "s", "g"))
+
+	// with NOACK the entries are not pending
+	evalCmd("XADD", "s", "4", "a", "4")
+	evalReply("XREADGROUP", "GROUP", "g", "alice", "NOACK", "STREAMS", "s", ">")
+	assert.EqualValues(t, 0, evalReply("XPENDING", "s", "g").([]interface{})[0])
+}
+
+func TestXReadGroupBlocking(t *testing.T) {
+	resetStores()
+	evalCmd("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM")
+	c1 := &FDComm{Fd: -1}
+	c2 := &FDComm{Fd: -1}
+	defer FreeClient(c1)
+	defer FreeClient(c2)
+	runClientCmd(c1, "XREADGROUP", "GROUP", "g", "alice", "BLOCK", "0", "STREAMS", "s", ">")
+	runClientCmd(c2, "XREADGROUP", "GROUP", "g", "bob", "BLOCK", "0", "STREAMS", "s", ">")
+
+	// the entry is delivered to the first consumer, the other keeps waiting
+	evalCmd("XADD", "s", "1", "a", "1")
+	assert.EqualValues(t, []interface{}{[]interface{}{[]interface{}{"s", []interface{}{entry("1-0", "a", "1")}}}},
+		clientMessages(c1))
+	assert.Empty(t, clientMessages(c2))
+	assert.True(t, c2.block.blocked())
+	evalCmd("XADD", "s", "2", "a", "2")
+	assert.EqualValues(t, []interface{}{[]interface{}{[]interface{}{"s", []interface{}{entry("2-0", "a", "2")}}}},
+		clientMessages(c2))
+
+	// a destroyed group fails the blocked clients
+	runClientCmd(c1, "XREADGROUP", "GROUP", "g", "alice", "BLOCK", "0", "STREAMS", "s", ">")
+	evalCmd("XGROUP", "DESTROY", "s", "g")
+	evalCmd("XADD", "s", "3", "a", "3")
+	assert.False(t, c1.block.blocked())
+	assertErrorReply(t, clientMessages(c1)[0])
+}
+
+func TestXClaim(t *testing.T) {
+	resetStores()
+	evalCmd("XADD", "s", "1", "a", "1")
+	evalCmd("XADD", "s", "2", "a", "2")
+	evalCmd("XADD", "s", "3", "a", "3")
+	evalCmd("XGROUP", "CREATE", "s", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", ">")
+
+	// not idle long enough
+	assert.EqualValues(t, []interface{}{}, evalReply("XCLAIM", "s", "g", "bob", "100000", "1"))
+	assert.EqualValues(t, []interface{}{entry("1-0", "a", "1")}, evalReply("XCLAIM", "s", "g", "bob", "0", "1"))
+	assert.EqualValues(t, []interface{}{"2-0"}, evalReply("XCLAIM", "s", "g", "bob", "0", "2", "JUSTID", "RETRYCOUNT", "7"))
+	pending := evalReply("XPENDING", "s", "g", "-", "+", "10", "bob").([]interface{})
+	assert.EqualValues(t, []interface{}{"1-0", "bob"}, pending[0].([]interface{})[:2])
+	assert.EqualValues(t, 2, pending[0].([]interface{})[3])
+	assert.EqualValues(t, 7, pending[1].([]interface{})[3])
+	idle := evalReply("XPENDING", "s", "g", "-", "+", "1", "bob").([]interface{})[0].([]interface{})[2].(int64)
+	assert.Less(t, idle, int64(1000))
+	evalCmd("XCLAIM", "s", "g", "bob", "0", "1", "IDLE", "5000")
+	idle = evalReply("XPENDING", "s", "g", "-", "+", "1", "bob").([]interface{})[0].([]interface{})[2].(int64)
+	assert.GreaterOrEqual(t, idle, int64(5000))
+
+	// FORCE claims entries that are not pending, a deleted pending entry is dropped
+	evalCmd("XADD", "s", "4", "a", "4")
+	assert.EqualValues(t, []interface{}{}, evalReply("XCLAIM", "s", "g", "bob", "0", "4"))
+	assert.EqualValues(t, []interface{}{"4-0"}, evalReply("XCLAIM", "s", "g", "bob", "0", "4", "FORCE", "JUSTID"))
+	evalCmd("XDEL", "s", "3")
+	assert.EqualValues(t, []interface{}{}, evalReply("XCLAIM", "s", "g", "bob", "0", "3"))
+	assert.EqualValues(t, 3, evalReply("XPENDING", "s", "g").([]interface{})[0])
+
+	assert.EqualValues(t, []interface{}{}, evalReply("XCLAIM", "s", "g", "bob", "0", "9", "LASTID", "9"))
+	assert.Nil(t, evalReply("XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"))
+	assertErrorReply(t, evalReply("XCLAIM", "s", "g", "bob", "x", "1"))
+	assertErrorReply(t, evalReply("XCLAIM", "s", "g", "bob", "0", "1", "BAD"))
+}
+
+func TestXAutoClaim(t *testing.T) {
+	resetStores()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		evalCmd("XADD", "s", id, "a", id)
+	}
+	evalCmd("XGROUP", "CREATE", "s", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", ">")
+	evalCmd("XDEL", "s", "2")
+
+	// the cursor is the next pending entry, the deleted ones are dropped
+	assert.EqualValues(t, []interface{}{"2-0", []interface{}{entry("1-0", "a", "1")}, []interface{}{}},
+		evalReply("XAUTOCLAIM", "s", "g", "bob", "0", "0", "COUNT", "1"))
+	assert.EqualValues(t, []interface{}{"0-0", []interface{}{"3-0", "4-0"}, []interface{}{"2-0"}},
+		evalReply("XAUTOCLAIM", "s", "g", "bob", "0", "2-0", "JUSTID"))
+	assert.EqualValues(t, []interface{}{int64(3), "1-0", "4-0", []interface{}{[]interface{}{"bob", "3"}}},
+		evalReply("XPENDING", "s", "g"))
+	assert.EqualValues(t, []interface{}{"0-0", []interface{}{}, []interface{}{}},
+		evalReply("XAUTOCLAIM", "s", "g", "alice", "100000", "-"))
+	assertErrorReply(t, evalReply("XAUTOCLAIM", "s", "g", "bob", "0", "0", "COUNT", "0"))
+	assertErrorReply(t, evalReply("XAUTOCLAIM", "s", "nogroup", "bob", "0", "0"))
+}
+
+func TestStreamCopy(t *testing.T) {
+	resetStores()
+	evalCmd("XADD", "s", "1", "a", "1")
+	evalCmd("XGROUP", "CREATE", "s", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", ">")
+	assert.EqualValues(t, 1, evalReply("COPY", "s", "d"))
+	evalCmd("XADD", "s", "2", "a", "2")
+	evalCmd("XACK", "s", "g", "1")
+	assert.EqualValues(t, 1, evalReply("XLEN", "d"))
+	assert.EqualValues(t, 1, evalReply("XPENDING", "d", "g").([]interface{})[0])
+}
@@ -0,0 +1,331 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+var errTDigestNotFound = errors.New("(error) ERR T-Digest: key does not exist")
+
+// parseTDigestCompression parses a compression, a positive integer
+func parseTDigestCompression(s string) (float64, error) {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return 0, errors.New("(error) ERR T-Digest: error parsing compression parameter")
+	}
+	return float64(n), nil
+}
+
+// parseTDigestValues parses the values of a command, which must be finite numbers
+func parseTDigestValues(args []string, name string) ([]float64, error) {
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New(fmt.Sprintf("(error) ERR T-Digest: error parsing %s parameter", name))
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// formatTDigestValue formats an estimation, NaN being the reply of an empty digest
+func formatTDigestValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// getExistingTDigest returns the digest of key, or an error if it doesn't exist
+func getExistingTDigest(key string) (*data_structure.TDigest, error) {
+	td, err := getTDigest(key)
+	if err != nil {
+		return nil, err
+	}
+	if td == nil {
+		return nil, errTDigestNotFound
+	}
+	return td, nil
+}
+
+/*
+TDIGEST.CREATE key [COMPRESSION compression]
+Creates an empty t-digest. The compression trades memory for accuracy, it is about the number of centroids
+kept.
+*/
+func cmdTDIGESTCREATE(args []string) []byte {
+	if len(args) != 1 && len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.CREATE' command"), false)
+	}
+	key := args[0]
+	var compression float64 = data_structure.TDigestDefaultCompression
+	if len(args) == 3 {
+		if strings.ToUpper(args[1]) != "COMPRESSION" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		var err error
+		if compression, err = parseTDigestCompression(args[2]); err != nil {
+			return Encode(err, false)
+		}
+	}
+	if dictStore.Get(key) != nil {
+		return Encode(errors.New("(error) ERR T-Digest: key already exists"), false)
+	}
+	putTDigest(key, data_structure.CreateTDigest(compression))
+	notifyKeyspaceEvent(NotifyModule, "tdigest.create", key)
+	return constant.RespOk
+}
+
+// TDIGEST.ADD key value [value ...] adds the values to the digest of key
+func cmdTDIGESTADD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.ADD' command"), false)
+	}
+	key := args[0]
+	td, err := getExistingTDigest(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:], "val")
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, v := range values {
+		td.Add(v)
+	}
+	notifyKeyspaceEvent(NotifyModule, "tdigest.add", key)
+	return constant.RespOk
+}
+
+// TDIGEST.QUANTILE key quantile [quantile ...] returns the estimated value at every quantile, between 0 and 1
+func cmdTDIGESTQUANTILE(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.QUANTILE' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	quantiles, err := parseTDigestValues(args[1:], "quantile")
+	if err != nil {
+		return Encode(err, false)
+	}
+	var res []string
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			return Encode(errors.New("(error) ERR T-Digest: quantile should be in [0,1]"), false)
+		}
+		res = append(res, formatTDigestValue(td.Quantile(q)))
+	}
+	return Encode(res, false)
+}
+
+// TDIGEST.CDF key value [value ...] returns for every value the estimated fraction of the values lower or equal
+func cmdTDIGESTCDF(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.CDF' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:], "value")
+	if err != nil {
+		return Encode(err, false)
+	}
+	var res []string
+	for _, v := range values {
+		res = append(res, formatTDigestValue(td.CDF(v)))
+	}
+	return Encode(res, false)
+}
+
+/*
+TDIGEST.RANK key value [value ...]
+Returns for every value the estimated number of values lower, plus half the values equal. It is -1 for a value
+lower than the min, and -2 if the digest is empty.
+*/
+func cmdTDIGESTRANK(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.RANK' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values, err := parseTDigestValues(args[1:], "value")
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(values))
+	for i, v := range values {
+		res[i] = td.Rank(v)
+	}
+	return Encode(res, false)
+}
+
+// TDIGEST.MIN key returns the lowest value added, nan if there is none
+func cmdTDIGESTMIN(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.MIN' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(formatTDigestValue(td.GetMin()), false)
+}
+
+// TDIGEST.MAX key returns the greatest value added, nan if there is none
+func cmdTDIGESTMAX(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.MAX' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(formatTDigestValue(td.GetMax()), false)
+}
+
+/*
+TDIGEST.MERGE destination numkeys source [source ...] [COMPRESSION compression] [OVERRIDE]
+Merges the source digests into destination. An existing destination is merged too, unless OVERRIDE is given.
+The compression defaults to the one of the existing destination, otherwise to the greatest of the sources.
+*/
+func cmdTDIGESTMERGE(args []string) []byte {
+	if len(args) < 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.MERGE' command"), false)
+	}
+	dest := args[0]
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys <= 0 {
+		return Encode(errors.New("(error) ERR T-Digest: error parsing numkeys"), false)
+	}
+	if numKeys > len(args)-2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.MERGE' command"), false)
+	}
+	var compression float64
+	override := false
+	for i := 2 + numKeys; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COMPRESSION":
+			if i+1 == len(args) {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			if compression, err = parseTDigestCompression(args[i+1]); err != nil {
+				return Encode(err, false)
+			}
+			i++
+		case "OVERRIDE":
+			override = true
+		default:
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+	}
+
+	var sources []*data_structure.TDigest
+	for _, key := range args[2 : 2+numKeys] {
+		td, err := getExistingTDigest(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		sources = append(sources, td)
+	}
+	destTD, err := getTDigest(dest)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if override {
+		destTD = nil
+	}
+	if compression == 0 {
+		if destTD != nil {
+			compression = destTD.GetCompression()
+		} else {
+			for _, td := range sources {
+				compression = math.Max(compression, td.GetCompression())
+			}
+		}
+	}
+
+	merged := data_structure.CreateTDigest(compression)
+	if destTD != nil {
+		merged.Merge(destTD)
+	}
+	for _, td := range sources {
+		merged.Merge(td)
+	}
+	putTDigest(dest, merged)
+	notifyKeyspaceEvent(NotifyModule, "tdigest.merge", dest)
+	return constant.RespOk
+}
+
+// TDIGEST.RESET key removes all the values of the digest of key, which keeps its compression
+func cmdTDIGESTRESET(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.RESET' command"), false)
+	}
+	key := args[0]
+	td, err := getExistingTDigest(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	td.Reset()
+	notifyKeyspaceEvent(NotifyModule, "tdigest.reset", key)
+	return constant.RespOk
+}
+
+func cmdTDIGESTINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.INFO' command"), false)
+	}
+	td, err := getExistingTDigest(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var res []string
+	res = append(res, "Compression", fmt.Sprintf("%d", int64(td.GetCompression())),
+		"Capacity", fmt.Sprintf("%d", td.GetCapacity()),
+		"Merged nodes", fmt.Sprintf("%d", td.GetMergedNodes()),
+		"Unmerged nodes", fmt.Sprintf("%d", td.GetUnmergedNodes()),
+		"Merged weight", formatTDigestValue(td.GetMergedWeight()),
+		"Unmerged weight", formatTDigestValue(td.GetUnmergedWeight()),
+		"Observations", formatTDigestValue(td.GetMergedWeight()+td.GetUnmergedWeight()),
+		"Total compressions", fmt.Sprintf("%d", td.GetCompressions()),
+		"Memory usage", fmt.Sprintf("%d", td.GetMemUsage()))
+
+	return Encode(res, false)
+}
+
+/*
+TDIGEST.LOADCHUNK key iterator data
+Restores a t-digest dumped by the AOF rewrite, in a single chunk like BF.LOADCHUNK.
+*/
+func cmdTDIGESTLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TDIGEST.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	td := &data_structure.TDigest{}
+	if err := td.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putTDigest(key, td)
+	notifyKeyspaceEvent(NotifyModule, "tdigest.loadchunk", key)
+	return constant.RespOk
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTDigestCreate(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.CREATE", "td"))
+	assertErrorReply(t, evalReply("TDIGEST.CREATE", "td"))
+	assert.EqualValues(t, "TDIS-TYPE", evalReply("TYPE", "td"))
+	info := evalReply("TDIGEST.INFO", "td").([]interface{})
+	assert.EqualValues(t, []interface{}{"Compression", "100", "Capacity", "610"}, info[:4])
+
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.CREATE", "small", "COMPRESSION", "10"))
+	info = evalReply("TDIGEST.INFO", "small").([]interface{})
+	assert.EqualValues(t, []interface{}{"Compression", "10", "Capacity", "70"}, info[:4])
+
+	assertErrorReply(t, evalReply("TDIGEST.CREATE", "x", "COMPRESSION", "0"))
+	assertErrorReply(t, evalReply("TDIGEST.CREATE", "x", "OTHER", "10"))
+	assertErrorReply(t, evalReply("TDIGEST.INFO", "x"))
+	assertErrorReply(t, evalReply("TDIGEST.ADD", "x", "1"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "x"))
+}
+
+func TestTDigestQueries(t *testing.T) {
+	resetStores()
+	evalCmd("TDIGEST.CREATE", "td")
+	assert.EqualValues(t, []interface{}{"nan"}, evalReply("TDIGEST.QUANTILE", "td", "0.5"))
+	assert.EqualValues(t, []interface{}{"nan"}, evalReply("TDIGEST.CDF", "td", "1"))
+	assert.EqualValues(t, []interface{}{int64(-2)}, evalReply("TDIGEST.RANK", "td", "1"))
+	assert.EqualValues(t, "nan", evalReply("TDIGEST.MIN", "td"))
+	assert.EqualValues(t, "nan", evalReply("TDIGEST.MAX", "td"))
+
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.ADD", "td", "1", "2", "3", "4", "5"))
+	assert.EqualValues(t, "1", evalReply("TDIGEST.MIN", "td"))
+	assert.EqualValues(t, "5", evalReply("TDIGEST.MAX", "td"))
+	assert.EqualValues(t, []interface{}{"1", "3", "5"}, evalReply("TDIGEST.QUANTILE", "td", "0", "0.5", "1"))
+	assert.EqualValues(t, []interface{}{"0", "0.5", "1"}, evalReply("TDIGEST.CDF", "td", "0", "3", "6"))
+	assert.EqualValues(t, []interface{}{int64(-1), int64(3), int64(5)}, evalReply("TDIGEST.RANK", "td", "0", "3.5", "10"))
+
+	assertErrorReply(t, evalReply("TDIGEST.ADD", "td", "1", "nan"))
+	assertErrorReply(t, evalReply("TDIGEST.ADD", "td", "abc"))
+	assertErrorReply(t, evalReply("TDIGEST.QUANTILE", "td", "1.5"))
+	assertErrorReply(t, evalReply("TDIGEST.CDF", "td", "abc"))
+	// a failed TDIGEST.ADD adds none of its values
+	assert.EqualValues(t, []interface{}{int64(5)}, evalReply("TDIGEST.RANK", "td", "6"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("TDIGEST.ADD", "str", "1"))
+	assertErrorReply(t, evalReply("TDIGEST.MIN", "str"))
+}
+
+func TestTDigestLatencies(t *testing.T) {
+	resetStores()
+	evalCmd("TDIGEST.CREATE", "latency")
+	args := []string{"TDIGEST.ADD", "latency"}
+	for i := 1; i <= 10000; i++ {
+		args = append(args, strconv.Itoa(i))
+	}
+	evalCmd(args...)
+	res := evalReply("TDIGEST.QUANTILE", "latency", "0.5", "0.99").([]interface{})
+	p50, _ := strconv.ParseFloat(res[0].(string), 64)
+	p99, _ := strconv.ParseFloat(res[1].(string), 64)
+	assert.InDelta(t, 5000, p50, 50)
+	assert.InDelta(t, 9900, p99, 10)
+}
+
+func TestTDigestMergeAndReset(t *testing.T) {
+	resetStores()
+	evalCmd("TDIGEST.CREATE", "a", "COMPRESSION", "50")
+	evalCmd("TDIGEST.CREATE", "b", "COMPRESSION", "200")
+	evalCmd("TDIGEST.ADD", "a", "1", "2")
+	evalCmd("TDIGEST.ADD", "b", "3", "4")
+
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.MERGE", "dest", "2", "a", "b"))
+	assert.EqualValues(t, "1", evalReply("TDIGEST.MIN", "dest"))
+	assert.EqualValues(t, "4", evalReply("TDIGEST.MAX", "dest"))
+	info := evalReply("TDIGEST.INFO", "dest").([]interface{})
+	assert.EqualValues(t, "200", info[1])
+
+	// the existing destination is merged too, unless OVERRIDE is given
+	evalCmd("TDIGEST.ADD", "a", "0")
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.MERGE", "dest", "1", "a"))
+	assert.EqualValues(t, []interface{}{int64(7)}, evalReply("TDIGEST.RANK", "dest", "5"))
+	info = evalReply("TDIGEST.INFO", "dest").([]interface{})
+	assert.EqualValues(t, "200", info[1])
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.MERGE", "dest", "1", "a", "COMPRESSION", "20", "OVERRIDE"))
+	assert.EqualValues(t, []interface{}{int64(3)}, evalReply("TDIGEST.RANK", "dest", "5"))
+	info = evalReply("TDIGEST.INFO", "dest").([]interface{})
+	assert.EqualValues(t, "20", info[1])
+
+	assertErrorReply(t, evalReply("TDIGEST.MERGE", "dest", "2", "a"))
+	assertErrorReply(t, evalReply("TDIGEST.MERGE", "dest", "0", "a"))
+	assertErrorReply(t, evalReply("TDIGEST.MERGE", "dest", "1", "none"))
+	assertErrorReply(t, evalReply("TDIGEST.MERGE", "dest", "1", "a", "OTHER"))
+
+	assert.EqualValues(t, "OK", evalReply("TDIGEST.RESET", "dest"))
+	assert.EqualValues(t, "nan", evalReply("TDIGEST.MIN", "dest"))
+	info = evalReply("TDIGEST.INFO", "dest").([]interface{})
+	assert.EqualValues(t, "20", info[1])
+	assertErrorReply(t, evalReply("TDIGEST.RESET", "none"))
+}
+
+func TestTDigestCopy(t *testing.T) {
+	resetStores()
+	evalCmd("TDIGEST.CREATE", "a")
+	evalCmd("TDIGEST.ADD", "a", "1")
+	assert.EqualValues(t, 1, evalReply("COPY", "a", "b"))
+	evalCmd("TDIGEST.ADD", "a", "0")
+	assert.EqualValues(t, "1", evalReply("TDIGEST.MIN", "b"))
+	assertErrorReply(t, evalReply("TDIGEST.LOADCHUNK", "c", "1", "bad"))
+	assertErrorReply(t, evalReply("TDIGEST.LOADCHUNK", "c", "2", "bad"))
+}
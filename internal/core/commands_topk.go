@@ -0,0 +1,229 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+const topKMaxIncrement = 100000
+
+var errTopKNotFound = errors.New("(error) ERR TopK: key does not exist")
+
+/*
+TOPK.RESERVE key topk [width depth decay]
+Creates a Top-K keeping the topk most frequent items, counted in depth rows of width buckets. decay, between 0
+and 1, is the base of the probability to decrement the count of an item whose bucket is taken by another one.
+*/
+func cmdTOPKRESERVE(args []string) []byte {
+	if len(args) != 2 && len(args) != 5 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.RESERVE' command"), false)
+	}
+	key := args[0]
+	k, err := strconv.ParseUint(args[1], 10, 32)
+	if err != nil || k == 0 {
+		return Encode(errors.New("(error) ERR TopK: invalid k"), false)
+	}
+	var width, depth uint64 = data_structure.TopKDefaultWidth, data_structure.TopKDefaultDepth
+	decay := data_structure.TopKDefaultDecay
+	if len(args) == 5 {
+		if width, err = strconv.ParseUint(args[2], 10, 32); err != nil || width == 0 {
+			return Encode(errors.New("(error) ERR TopK: invalid width"), false)
+		}
+		if depth, err = strconv.ParseUint(args[3], 10, 32); err != nil || depth == 0 || width*depth > math.MaxUint32 {
+			return Encode(errors.New("(error) ERR TopK: invalid depth"), false)
+		}
+		if decay, err = strconv.ParseFloat(args[4], 64); err != nil || decay <= 0 || decay > 1 {
+			return Encode(errors.New("(error) ERR TopK: invalid decay value. must be '<= 1' & '> 0'"), false)
+		}
+	}
+	if dictStore.Get(key) != nil {
+		return Encode(errors.New("(error) ERR TopK: key already exists"), false)
+	}
+	putTopK(key, data_structure.CreateTopK(uint32(k), uint32(width), uint32(depth), decay))
+	notifyKeyspaceEvent(NotifyModule, "topk.reserve", key)
+	return constant.RespOk
+}
+
+// topKIncrBy counts the items, and returns for every item the item it expelled from the top k, or nil
+func topKIncrBy(topk *data_structure.TopK, items []string, increments []uint32) []byte {
+	res := make([]interface{}, len(items))
+	for i, item := range items {
+		if expelled, ok := topk.IncrBy(item, increments[i]); ok {
+			res[i] = expelled
+		}
+	}
+	return Encode(res, false)
+}
+
+/*
+TOPK.ADD key item [item ...]
+Counts the items once more, and returns for every item the item it expelled from the top k, or nil.
+*/
+func cmdTOPKADD(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.ADD' command"), false)
+	}
+	key := args[0]
+	topk, err := getTopK(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	increments := make([]uint32, len(args)-1)
+	for i := range increments {
+		increments[i] = 1
+	}
+	res := topKIncrBy(topk, args[1:], increments)
+	notifyKeyspaceEvent(NotifyModule, "topk.add", key)
+	return res
+}
+
+/*
+TOPK.INCRBY key item increment [item increment ...]
+Counts the items increment more times, and returns for every item the item it expelled from the top k, or nil.
+*/
+func cmdTOPKINCRBY(args []string) []byte {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.INCRBY' command"), false)
+	}
+	key := args[0]
+	topk, err := getTopK(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	var items []string
+	var increments []uint32
+	for i := 1; i < len(args); i += 2 {
+		n, err := strconv.ParseUint(args[i+1], 10, 32)
+		if err != nil || n == 0 || n > topKMaxIncrement {
+			return Encode(errors.New(fmt.Sprintf("(error) ERR TopK: increment must be an integer between 1 and %d", topKMaxIncrement)), false)
+		}
+		items = append(items, args[i])
+		increments = append(increments, uint32(n))
+	}
+	res := topKIncrBy(topk, items, increments)
+	notifyKeyspaceEvent(NotifyModule, "topk.incrby", key)
+	return res
+}
+
+// TOPK.QUERY key item [item ...] returns for every item 1 if it is in the top k, 0 otherwise
+func cmdTOPKQUERY(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.QUERY' command"), false)
+	}
+	topk, err := getTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	var res []string
+	for _, item := range args[1:] {
+		if topk.Query(item) {
+			res = append(res, "1")
+		} else {
+			res = append(res, "0")
+		}
+	}
+	return Encode(res, false)
+}
+
+// TOPK.COUNT key item [item ...] returns the estimated count of every item
+func cmdTOPKCOUNT(args []string) []byte {
+	if len(args) < 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.COUNT' command"), false)
+	}
+	topk, err := getTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	var res []string
+	for _, item := range args[1:] {
+		res = append(res, fmt.Sprintf("%d", topk.Count(item)))
+	}
+	return Encode(res, false)
+}
+
+// TOPK.LIST key [WITHCOUNT] returns the items of the top k from the most frequent, followed by their count with WITHCOUNT
+func cmdTOPKLIST(args []string) []byte {
+	if len(args) != 1 && len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.LIST' command"), false)
+	}
+	withCount := false
+	if len(args) == 2 {
+		if strings.ToUpper(args[1]) != "WITHCOUNT" {
+			return Encode(errors.New("(error) ERR syntax error"), false)
+		}
+		withCount = true
+	}
+	topk, err := getTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	res := make([]string, 0)
+	for _, item := range topk.List() {
+		res = append(res, item.Item)
+		if withCount {
+			res = append(res, fmt.Sprintf("%d", item.Count))
+		}
+	}
+	return Encode(res, false)
+}
+
+func cmdTOPKINFO(args []string) []byte {
+	if len(args) != 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.INFO' command"), false)
+	}
+	topk, err := getTopK(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if topk == nil {
+		return Encode(errTopKNotFound, false)
+	}
+	var res []string
+	res = append(res, "k", fmt.Sprintf("%d", topk.GetK()),
+		"width", fmt.Sprintf("%d", topk.GetWidth()),
+		"depth", fmt.Sprintf("%d", topk.GetDepth()),
+		"decay", strconv.FormatFloat(topk.GetDecay(), 'g', -1, 64))
+
+	return Encode(res, false)
+}
+
+/*
+TOPK.LOADCHUNK key iterator data
+Restores a Top-K dumped by the AOF rewrite, in a single chunk like BF.LOADCHUNK.
+*/
+func cmdTOPKLOADCHUNK(args []string) []byte {
+	if len(args) != 3 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'TOPK.LOADCHUNK' command"), false)
+	}
+	key := args[0]
+	if args[1] != "1" {
+		return Encode(errors.New(fmt.Sprintf("(error) ERR invalid iterator %s", args[1])), false)
+	}
+	topk := &data_structure.TopK{}
+	if err := topk.UnmarshalBinary([]byte(args[2])); err != nil {
+		return Encode(errors.New("(error) ERR received bad data"), false)
+	}
+	putTopK(key, topk)
+	notifyKeyspaceEvent(NotifyModule, "topk.loadchunk", key)
+	return constant.RespOk
+}
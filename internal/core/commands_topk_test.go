@@ -0,0 +1,73 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopKReserve(t *testing.T) {
+	resetStores()
+	assert.EqualValues(t, "OK", evalReply("TOPK.RESERVE", "topk", "3"))
+	assertErrorReply(t, evalReply("TOPK.RESERVE", "topk", "3"))
+	assert.EqualValues(t, "TopK-TYPE", evalReply("TYPE", "topk"))
+	assert.EqualValues(t, []interface{}{"k", "3", "width", "8", "depth", "7", "decay", "0.9"}, evalReply("TOPK.INFO", "topk"))
+
+	assert.EqualValues(t, "OK", evalReply("TOPK.RESERVE", "custom", "5", "100", "4", "0.5"))
+	assert.EqualValues(t, []interface{}{"k", "5", "width", "100", "depth", "4", "decay", "0.5"}, evalReply("TOPK.INFO", "custom"))
+
+	assertErrorReply(t, evalReply("TOPK.RESERVE", "x", "0"))
+	assertErrorReply(t, evalReply("TOPK.RESERVE", "x", "3", "10"))
+	assertErrorReply(t, evalReply("TOPK.RESERVE", "x", "3", "0", "4", "0.9"))
+	assertErrorReply(t, evalReply("TOPK.RESERVE", "x", "3", "10", "4", "1.5"))
+	assertErrorReply(t, evalReply("TOPK.INFO", "x"))
+	assertErrorReply(t, evalReply("TOPK.ADD", "x", "a"))
+	assert.EqualValues(t, 0, evalReply("EXISTS", "x"))
+}
+
+func TestTopKAdd(t *testing.T) {
+	resetStores()
+	evalCmd("TOPK.RESERVE", "topk", "2", "50", "4", "0.9")
+	assert.EqualValues(t, []interface{}{nil, nil}, evalReply("TOPK.ADD", "topk", "a", "b"))
+	assert.EqualValues(t, []interface{}{nil}, evalReply("TOPK.INCRBY", "topk", "b", "4"))
+	// c takes the place of a as soon as it is as frequent
+	assert.EqualValues(t, []interface{}{"a", nil}, evalReply("TOPK.ADD", "topk", "c", "c"))
+	assert.EqualValues(t, []interface{}{"0", "1", "1", "0"}, evalReply("TOPK.QUERY", "topk", "a", "b", "c", "d"))
+	assert.EqualValues(t, []interface{}{"1", "5", "2", "0"}, evalReply("TOPK.COUNT", "topk", "a", "b", "c", "d"))
+	assert.EqualValues(t, []interface{}{"b", "c"}, evalReply("TOPK.LIST", "topk"))
+	assert.EqualValues(t, []interface{}{"b", "5", "c", "2"}, evalReply("TOPK.LIST", "topk", "WITHCOUNT"))
+
+	assertErrorReply(t, evalReply("TOPK.INCRBY", "topk", "a", "0"))
+	assertErrorReply(t, evalReply("TOPK.INCRBY", "topk", "a", "100001"))
+	assertErrorReply(t, evalReply("TOPK.INCRBY", "topk", "a", "1", "b"))
+	assertErrorReply(t, evalReply("TOPK.LIST", "topk", "OTHER"))
+
+	evalCmd("SET", "str", "v")
+	assertErrorReply(t, evalReply("TOPK.ADD", "str", "a"))
+	assertErrorReply(t, evalReply("TOPK.LIST", "str"))
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	resetStores()
+	evalCmd("TOPK.RESERVE", "topk", "3")
+	for i := 0; i < 200; i++ {
+		evalCmd("TOPK.ADD", "topk", "heavy", strconv.Itoa(i))
+		if i%2 == 0 {
+			evalCmd("TOPK.ADD", "topk", "medium")
+		}
+	}
+	list := evalReply("TOPK.LIST", "topk").([]interface{})
+	assert.EqualValues(t, []interface{}{"heavy", "medium"}, list[:2])
+}
+
+func TestTopKCopy(t *testing.T) {
+	resetStores()
+	evalCmd("TOPK.RESERVE", "a", "2")
+	evalCmd("TOPK.ADD", "a", "x")
+	assert.EqualValues(t, 1, evalReply("COPY", "a", "b"))
+	evalCmd("TOPK.INCRBY", "a", "x", "10")
+	assert.EqualValues(t, []interface{}{"1"}, evalReply("TOPK.COUNT", "b", "x"))
+	assertErrorReply(t, evalReply("TOPK.LOADCHUNK", "c", "1", "bad"))
+	assertErrorReply(t, evalReply("TOPK.LOADCHUNK", "c", "2", "bad"))
+}
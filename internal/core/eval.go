@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"memkv/internal/constant"
 )
 
 func cmdPING(args []string) []byte {
@@ -22,8 +23,69 @@ func cmdPING(args []string) []byte {
 	return buf
 }
 
+func isErrorReply(res []byte) bool {
+	return len(res) > 0 && res[0] == '-'
+}
+
 func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 	var res []byte
+	var err error
+
+	// the transaction and the subscriptions are kept by the clients connected to the server
+	comm, _ := c.(*FDComm)
+	inMulti := comm != nil && comm.tx.inMulti
+
+	// a blocked client runs its next commands once it is unblocked
+	if comm != nil && comm.block.blocked() {
+		comm.block.deferred = append(comm.block.deferred, cmd)
+		return nil
+	}
+	prevClient := currentClient
+	currentClient = comm
+	defer func() {
+		currentClient = prevClient
+	}()
+
+	if comm != nil && comm.pubsub.subscriptions() > 0 {
+		if res, ok := subscribedModeReply(cmd); ok {
+			_, err := c.Write(res)
+			return err
+		}
+	}
+
+	// the replayed AOF is loaded whole, like Redis does
+	if !aofLoading && !performEvictions() && isDenyOOMCommand(cmd.Cmd) {
+		if inMulti {
+			comm.tx.aborted = true
+		}
+		_, err := c.Write(Encode(errors.New("(error) OOM command not allowed when used memory > 'maxmemory'"), false))
+		return err
+	}
+
+	switch {
+	case inMulti && !isTxCommand(cmd.Cmd):
+		res = queueCommand(comm, cmd)
+	case isClientCommand(cmd.Cmd):
+		res = evalClientCommand(cmd, c)
+	default:
+		res, err = evalCommand(cmd)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = c.Write(res); err != nil {
+		return err
+	}
+	if len(readyKeys) > 0 {
+		handleClientsBlockedOnKeys()
+	}
+	return nil
+}
+
+// evalCommand executes cmd and returns its reply, the write commands are propagated to the AOF
+func evalCommand(cmd *MemKVCmd) ([]byte, error) {
+	var res []byte
+	dirtyBefore := dirty
 
 	switch cmd.Cmd {
 	case "PING":
@@ -38,8 +100,122 @@ func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 		res = cmdDEL(cmd.Args)
 	case "EXPIRE":
 		res = cmdEXPIRE(cmd.Args)
+	case "PEXPIREAT":
+		res = cmdPEXPIREAT(cmd.Args)
 	case "INCR":
 		res = cmdINCR(cmd.Args)
+	// Keyspace
+	case "TYPE":
+		res = cmdTYPE(cmd.Args)
+	case "EXISTS":
+		res = cmdEXISTS(cmd.Args)
+	case "UNLINK":
+		res = cmdUNLINK(cmd.Args)
+	case "RENAME":
+		res = cmdRENAME(cmd.Args)
+	case "RENAMENX":
+		res = cmdRENAMENX(cmd.Args)
+	case "COPY":
+		res = cmdCOPY(cmd.Args)
+	case "KEYS":
+		res = cmdKEYS(cmd.Args)
+	case "SCAN":
+		res = cmdSCAN(cmd.Args)
+	case "RANDOMKEY":
+		res = cmdRANDOMKEY(cmd.Args)
+	case "OBJECT":
+		res = cmdOBJECT(cmd.Args)
+	// List
+	case "LPUSH":
+		res = cmdLPUSH(cmd.Args)
+	case "RPUSH":
+		res = cmdRPUSH(cmd.Args)
+	case "LPOP":
+		res = cmdLPOP(cmd.Args)
+	case "RPOP":
+		res = cmdRPOP(cmd.Args)
+	case "LLEN":
+		res = cmdLLEN(cmd.Args)
+	case "LRANGE":
+		res = cmdLRANGE(cmd.Args)
+	case "LINDEX":
+		res = cmdLINDEX(cmd.Args)
+	case "LSET":
+		res = cmdLSET(cmd.Args)
+	case "LREM":
+		res = cmdLREM(cmd.Args)
+	case "LTRIM":
+		res = cmdLTRIM(cmd.Args)
+	case "LINSERT":
+		res = cmdLINSERT(cmd.Args)
+	case "LMOVE":
+		res = cmdLMOVE(cmd.Args)
+	case "LPOS":
+		res = cmdLPOS(cmd.Args)
+	case "BLPOP":
+		res = cmdBLPOP(cmd.Args)
+	case "BRPOP":
+		res = cmdBRPOP(cmd.Args)
+	case "BLMOVE":
+		res = cmdBLMOVE(cmd.Args)
+	// Hash
+	case "HSET":
+		res = cmdHSET(cmd.Args)
+	case "HSETNX":
+		res = cmdHSETNX(cmd.Args)
+	case "HGET":
+		res = cmdHGET(cmd.Args)
+	case "HMGET":
+		res = cmdHMGET(cmd.Args)
+	case "HDEL":
+		res = cmdHDEL(cmd.Args)
+	case "HLEN":
+		res = cmdHLEN(cmd.Args)
+	case "HEXISTS":
+		res = cmdHEXISTS(cmd.Args)
+	case "HGETALL":
+		res = cmdHGETALL(cmd.Args)
+	case "HKEYS":
+		res = cmdHKEYS(cmd.Args)
+	case "HVALS":
+		res = cmdHVALS(cmd.Args)
+	case "HINCRBY":
+		res = cmdHINCRBY(cmd.Args)
+	case "HINCRBYFLOAT":
+		res = cmdHINCRBYFLOAT(cmd.Args)
+	case "HRANDFIELD":
+		res = cmdHRANDFIELD(cmd.Args)
+	case "HSCAN":
+		res = cmdHSCAN(cmd.Args)
+	// Stream
+	case "XADD":
+		res = cmdXADD(cmd.Args)
+	case "XTRIM":
+		res = cmdXTRIM(cmd.Args)
+	case "XDEL":
+		res = cmdXDEL(cmd.Args)
+	case "XLEN":
+		res = cmdXLEN(cmd.Args)
+	case "XRANGE":
+		res = cmdXRANGE(cmd.Args)
+	case "XREVRANGE":
+		res = cmdXREVRANGE(cmd.Args)
+	case "XREAD":
+		res = cmdXREAD(cmd.Args)
+	case "XREADGROUP":
+		res = cmdXREADGROUP(cmd.Args)
+	case "XGROUP":
+		res = cmdXGROUP(cmd.Args)
+	case "XACK":
+		res = cmdXACK(cmd.Args)
+	case "XPENDING":
+		res = cmdXPENDING(cmd.Args)
+	case "XCLAIM":
+		res = cmdXCLAIM(cmd.Args)
+	case "XAUTOCLAIM":
+		res = cmdXAUTOCLAIM(cmd.Args)
+	case "XSETID":
+		res = cmdXSETID(cmd.Args)
 	// Set
 	case "SADD":
 		res = cmdSADD(cmd.Args)
@@ -53,10 +229,28 @@ func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 		res = cmdSISMEMBER(cmd.Args)
 	case "SMISMEMBER":
 		res = cmdSMISMEMBER(cmd.Args)
-	case "SRAND":
-		res = cmdSRAND(cmd.Args)
+	case "SRANDMEMBER", "SRAND":
+		res = cmdSRANDMEMBER(cmd.Args)
+	case "SSCAN":
+		res = cmdSSCAN(cmd.Args)
 	case "SPOP":
 		res = cmdSPOP(cmd.Args)
+	case "SINTER":
+		res = cmdSINTER(cmd.Args)
+	case "SINTERCARD":
+		res = cmdSINTERCARD(cmd.Args)
+	case "SINTERSTORE":
+		res = cmdSINTERSTORE(cmd.Args)
+	case "SUNION":
+		res = cmdSUNION(cmd.Args)
+	case "SUNIONSTORE":
+		res = cmdSUNIONSTORE(cmd.Args)
+	case "SDIFF":
+		res = cmdSDIFF(cmd.Args)
+	case "SDIFFSTORE":
+		res = cmdSDIFFSTORE(cmd.Args)
+	case "SMOVE":
+		res = cmdSMOVE(cmd.Args)
 	// Sorted set
 	case "ZADD":
 		res = cmdZADD(cmd.Args)
@@ -68,6 +262,20 @@ func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 		res = cmdZSCORE(cmd.Args)
 	case "ZCARD":
 		res = cmdZCARD(cmd.Args)
+	case "ZSCAN":
+		res = cmdZSCAN(cmd.Args)
+	case "ZREVRANK":
+		res = cmdZREVRANK(cmd.Args)
+	case "ZRANGE":
+		res = cmdZRANGE(cmd.Args)
+	case "ZRANGESTORE":
+		res = cmdZRANGESTORE(cmd.Args)
+	case "ZRANGEBYSCORE":
+		res = cmdZRANGEBYSCORE(cmd.Args)
+	case "ZREVRANGE":
+		res = cmdZREVRANGE(cmd.Args)
+	case "ZCOUNT":
+		res = cmdZCOUNT(cmd.Args)
 	// Geo Hash
 	case "GEOADD":
 		res = cmdGEOADD(cmd.Args)
@@ -90,6 +298,55 @@ func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 		res = cmdBFEXISTS(cmd.Args)
 	case "BF.MEXISTS":
 		res = cmdBFMEXISTS(cmd.Args)
+	case "BF.LOADCHUNK":
+		res = cmdBFLOADCHUNK(cmd.Args)
+	// Top-K
+	case "TOPK.RESERVE":
+		res = cmdTOPKRESERVE(cmd.Args)
+	case "TOPK.ADD":
+		res = cmdTOPKADD(cmd.Args)
+	case "TOPK.INCRBY":
+		res = cmdTOPKINCRBY(cmd.Args)
+	case "TOPK.QUERY":
+		res = cmdTOPKQUERY(cmd.Args)
+	case "TOPK.COUNT":
+		res = cmdTOPKCOUNT(cmd.Args)
+	case "TOPK.LIST":
+		res = cmdTOPKLIST(cmd.Args)
+	case "TOPK.INFO":
+		res = cmdTOPKINFO(cmd.Args)
+	case "TOPK.LOADCHUNK":
+		res = cmdTOPKLOADCHUNK(cmd.Args)
+	// HyperLogLog
+	case "PFADD":
+		res = cmdPFADD(cmd.Args)
+	case "PFCOUNT":
+		res = cmdPFCOUNT(cmd.Args)
+	case "PFMERGE":
+		res = cmdPFMERGE(cmd.Args)
+	case "PFLOADCHUNK":
+		res = cmdPFLOADCHUNK(cmd.Args)
+	// Cuckoo filter
+	case "CF.RESERVE":
+		res = cmdCFRESERVE(cmd.Args)
+	case "CF.ADD":
+		res = cmdCFADD(cmd.Args)
+	case "CF.ADDNX":
+		res = cmdCFADDNX(cmd.Args)
+	case "CF.INSERT":
+		res = cmdCFINSERT(cmd.Args)
+	case "CF.EXISTS":
+		res = cmdCFEXISTS(cmd.Args)
+	case "CF.MEXISTS":
+		res = cmdCFMEXISTS(cmd.Args)
+	case "CF.DEL":
+		res = cmdCFDEL(cmd.Args)
+	case "CF.COUNT":
+		res = cmdCFCOUNT(cmd.Args)
+	case "CF.INFO":
+		res = cmdCFINFO(cmd.Args)
+	case "CF.LOADCHUNK":
+		res = cmdCFLOADCHUNK(cmd.Args)
 	// Count-Min Sketch
 	case "CMS.INITBYDIM":
 		res = cmdCMSINITBYDIM(cmd.Args)
@@ -99,9 +356,75 @@ func EvalAndResponse(cmd *MemKVCmd, c io.ReadWriter) error {
 		res = cmdCMSINCRBY(cmd.Args)
 	case "CMS.QUERY":
 		res = cmdCMSQUERY(cmd.Args)
+	case "CMS.LOADCHUNK":
+		res = cmdCMSLOADCHUNK(cmd.Args)
+	// T-digest
+	case "TDIGEST.CREATE":
+		res = cmdTDIGESTCREATE(cmd.Args)
+	case "TDIGEST.ADD":
+		res = cmdTDIGESTADD(cmd.Args)
+	case "TDIGEST.QUANTILE":
+		res = cmdTDIGESTQUANTILE(cmd.Args)
+	case "TDIGEST.CDF":
+		res = cmdTDIGESTCDF(cmd.Args)
+	case "TDIGEST.RANK":
+		res = cmdTDIGESTRANK(cmd.Args)
+	case "TDIGEST.MIN":
+		res = cmdTDIGESTMIN(cmd.Args)
+	case "TDIGEST.MAX":
+		res = cmdTDIGESTMAX(cmd.Args)
+	case "TDIGEST.MERGE":
+		res = cmdTDIGESTMERGE(cmd.Args)
+	case "TDIGEST.RESET":
+		res = cmdTDIGESTRESET(cmd.Args)
+	case "TDIGEST.INFO":
+		res = cmdTDIGESTINFO(cmd.Args)
+	case "TDIGEST.LOADCHUNK":
+		res = cmdTDIGESTLOADCHUNK(cmd.Args)
+	// Morris counter
+	case "MORRIS.CREATE":
+		res = cmdMORRISCREATE(cmd.Args)
+	case "MORRIS.INCRBY":
+		res = cmdMORRISINCRBY(cmd.Args)
+	case "MORRIS.GET":
+		res = cmdMORRISGET(cmd.Args)
+	case "MORRIS.MERGE":
+		res = cmdMORRISMERGE(cmd.Args)
+	case "MORRIS.INFO":
+		res = cmdMORRISINFO(cmd.Args)
+	case "MORRIS.LOADCHUNK":
+		res = cmdMORRISLOADCHUNK(cmd.Args)
+	// Persistence
+	case "BGREWRITEAOF":
+		res = cmdBGREWRITEAOF(cmd.Args)
+	case "SAVE":
+		res = cmdSAVE(cmd.Args)
+	case "BGSAVE":
+		res = cmdBGSAVE(cmd.Args)
+	// Memory
+	case "MEMORY":
+		res = cmdMEMORY(cmd.Args)
+	case "INFO":
+		res = cmdINFO(cmd.Args)
+	// Pub/Sub
+	case "PUBLISH":
+		res = cmdPUBLISH(cmd.Args)
+	case "PUBSUB":
+		res = cmdPUBSUB(cmd.Args)
+	// Transaction
+	case "UNWATCH":
+		// queued in a transaction, EXEC unwatches all the keys anyway
+		res = constant.RespOk
 	default:
-		return errors.New(fmt.Sprintf("command not found: %s", cmd.Cmd))
+		return nil, errors.New(fmt.Sprintf("command not found: %s", cmd.Cmd))
+	}
+	if isWriteCommand(cmd.Cmd) {
+		keys := commandKeys(cmd)
+		updateKeysMemUsage(keys)
+		// a blocked command has no reply yet, it is propagated when the client is served
+		if res != nil && !isErrorReply(res) && dirty != dirtyBefore {
+			propagate(cmd, res)
+		}
 	}
-	_, err := c.Write(res)
-	return err
+	return res, nil
 }
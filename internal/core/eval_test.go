@@ -12,12 +12,8 @@ import (
 	"memkv/internal/data_structure"
 )
 
-func resetSetStore() {
-	setStore = make(map[string]data_structure.Set)
-}
-
 func TestCmdSADD(t *testing.T) {
-	resetSetStore()
+	resetStores()
 	res, err := Decode(cmdSADD([]string{"set", "adele"}))
 	assert.Nil(t, err)
 	assert.EqualValues(t, 1, res)
@@ -28,7 +24,7 @@ func TestCmdSADD(t *testing.T) {
 }
 
 func TestCmdSREM(t *testing.T) {
-	resetSetStore()
+	resetStores()
 	res, err := Decode(cmdSREM([]string{"set", "adele"}))
 	assert.Nil(t, err)
 	assert.EqualValues(t, 0, res)
@@ -40,7 +36,7 @@ func TestCmdSREM(t *testing.T) {
 }
 
 func TestCmdSCARD(t *testing.T) {
-	resetSetStore()
+	resetStores()
 
 	cmdSADD([]string{"set", "a", "b", "c"})
 	res, err := Decode(cmdSCARD([]string{"set"}))
@@ -49,7 +45,7 @@ func TestCmdSCARD(t *testing.T) {
 }
 
 func TestCmdSMEMBERS(t *testing.T) {
-	resetSetStore()
+	resetStores()
 
 	cmdSADD([]string{"set", "a", "b", "c"})
 	res, err := Decode(cmdSMEMBERS([]string{"set"}))
@@ -58,7 +54,7 @@ func TestCmdSMEMBERS(t *testing.T) {
 }
 
 func TestCmdSMISMEMBER(t *testing.T) {
-	resetSetStore()
+	resetStores()
 
 	cmdSADD([]string{"set", "a", "b", "c"})
 	res, err := Decode(cmdSMISMEMBER([]string{"set", "a", "d"}))
@@ -66,11 +62,11 @@ func TestCmdSMISMEMBER(t *testing.T) {
 	assert.ElementsMatch(t, []int{1, 0}, res)
 }
 
-func TestCmdSRAND(t *testing.T) {
-	resetSetStore()
+func TestCmdSRANDMEMBER(t *testing.T) {
+	resetStores()
 
 	cmdSADD([]string{"set", "a", "b", "c"})
-	res, err := Decode(cmdSRAND([]string{"set", "2"}))
+	res, err := Decode(cmdSRANDMEMBER([]string{"set", "2"}))
 
 	assert.Nil(t, err)
 	m := make(map[string]struct{})
@@ -87,7 +83,7 @@ func TestCmdSRAND(t *testing.T) {
 }
 
 func TestCmdSPOP(t *testing.T) {
-	resetSetStore()
+	resetStores()
 
 	cmdSADD([]string{"set", "a", "b", "c"})
 	res, err := Decode(cmdSPOP([]string{"set", "2"}))
@@ -111,7 +107,7 @@ func TestCmdSPOP(t *testing.T) {
 }
 
 func TestCmdGEOADD(t *testing.T) {
-	delete(zsetStore, "vn")
+	dictStore.Del("vn")
 	res, err := Decode(cmdGEOADD([]string{"vn", "10", "20", "p1"}))
 	assert.Nil(t, err)
 	assert.EqualValues(t, res, 1)
@@ -128,8 +124,8 @@ func TestCmdGEOADD(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, res, 2)
 
-	zset, exist := zsetStore["vn"]
-	assert.True(t, exist)
+	zset := testZSet("vn")
+	assert.NotNil(t, zset)
 	assert.EqualValues(t, 3, zset.Len())
 
 	res, err = Decode(cmdGEOADD([]string{"vn"}))
@@ -139,7 +135,7 @@ func TestCmdGEOADD(t *testing.T) {
 }
 
 func TestCmdGEODIST(t *testing.T) {
-	delete(zsetStore, "vn")
+	dictStore.Del("vn")
 	cmdGEOADD([]string{"vn", "20", "10", "p1"})
 	cmdGEOADD([]string{"vn", "40", "30", "p2"})
 	cmdGEOADD([]string{"vn", "10", "85", "p3"})
@@ -172,7 +168,7 @@ func TestCmdGEODIST(t *testing.T) {
 }
 
 func TestCmdGeoHash(t *testing.T) {
-	delete(zsetStore, "vn")
+	dictStore.Del("vn")
 	cmdGEOADD([]string{"vn", "13.361389", "38.115556", "p1"})
 	cmdGEOADD([]string{"vn", "15.087269", "37.502669", "p2"})
 	cmdGEOADD([]string{"vn", "100", "80", "p3"})
@@ -190,7 +186,7 @@ func TestCmdGeoHash(t *testing.T) {
 }
 
 func TestSimpleEvalGEOSEARCH(t *testing.T) {
-	delete(zsetStore, "nyc")
+	dictStore.Del("nyc")
 	cmdGEOADD([]string{"nyc", "-73.9733487", "40.7648057", "central park"})
 	cmdGEOADD([]string{"nyc", "-73.9903085", "40.7362513", "union square"})
 	cmdGEOADD([]string{"nyc", "-74.0131604", "40.7126674", "wtc one"})
@@ -213,7 +209,7 @@ func randFloat(min, max float64) float64 {
 }
 
 func TestRandomEvalGEOSEARCH(t *testing.T) {
-	delete(zsetStore, "nyc")
+	dictStore.Del("nyc")
 	targetLon := -73.9798091
 	targetLat := 40.7598464
 	for round := 0; round < 10; round++ {
@@ -244,7 +240,7 @@ func TestRandomEvalGEOSEARCH(t *testing.T) {
 }
 
 func TestCmdGEOPOS(t *testing.T) {
-	delete(zsetStore, "nyc")
+	dictStore.Del("nyc")
 	cmdGEOADD([]string{"nyc", "-73.9733487", "40.7648057", "central park"})
 	cmdGEOADD([]string{"nyc", "-73.9375699", "40.7498929", "q4"})
 	ret, err := Decode(cmdGEOPOS([]string{"nyc", "x"}))
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"memkv/internal/config"
+	"time"
+)
+
+// Expiration of the keys with a TTL. An expired key is deleted lazily the next time it is accessed,
+// and actively by the cycle below, so that keys which are never accessed again don't use memory forever.
+
+// expiredKeys is the number of keys deleted because they expired
+var expiredKeys uint64 = 0
+
+// expiredStalePerc is an estimate of the percentage of keys with an expiry that are expired but not deleted yet
+var expiredStalePerc float64 = 0
+
+// expireCycleTimeCapReached is the number of cycles that stopped because they ran out of time
+var expireCycleTimeCapReached uint64 = 0
+
+// expireCycleTime is the total time spent in the active expire cycle
+var expireCycleTime time.Duration = 0
+
+func onKeyExpired(key string) {
+	expiredKeys++
+	touchWatchedKey(key)
+	notifyKeyspaceEvent(NotifyExpired, "expired", key)
+}
+
+/*
+activeExpireCycle deletes expired keys by sampling the keys with an expiry, like Redis does.
+A batch of keys is checked, and when more than ActiveExpireAcceptableStalePerc percent of them
+were expired there are probably many more, so another batch is checked. The cycle stops after
+ActiveExpireCycleTimePerc percent of the cron period, so that it never blocks the clients for long.
+*/
+func activeExpireCycle() {
+	start := time.Now()
+	timeLimit := time.Second / time.Duration(config.Hz) * time.Duration(config.ActiveExpireCycleTimePerc) / 100
+	totalSampled, totalExpired := 0, 0
+	for {
+		sampled, expired := dictStore.ExpireSample(config.ActiveExpireKeysPerLoop)
+		totalSampled += sampled
+		totalExpired += expired
+		if sampled == 0 || expired*100 <= sampled*config.ActiveExpireAcceptableStalePerc {
+			break
+		}
+		if time.Since(start) > timeLimit {
+			expireCycleTimeCapReached++
+			break
+		}
+	}
+	expireCycleTime += time.Since(start)
+
+	currentPerc := 0.0
+	if totalSampled > 0 {
+		currentPerc = float64(totalExpired) / float64(totalSampled)
+	}
+	// a moving average, so that one cycle doesn't change the estimate too much
+	expiredStalePerc = currentPerc*0.05 + expiredStalePerc*0.95
+}
+
+// ServerCron runs the background tasks, the event loop calls it config.Hz times per second
+func ServerCron() {
+	activeExpireCycle()
+	handleBlockedClientsTimeout()
+}
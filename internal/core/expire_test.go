@@ -0,0 +1,59 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActiveExpireCycle(t *testing.T) {
+	resetStores()
+	expired := expiredKeys
+	expMs := strconv.FormatInt(time.Now().UnixMilli()+20, 10)
+	for i := 0; i < 1000; i++ {
+		evalCmd("SET", strconv.Itoa(i), "v")
+		evalCmd("PEXPIREAT", strconv.Itoa(i), expMs)
+	}
+	evalCmd("SET", "persistent", "v")
+	evalCmd("SET", "volatile", "v", "EX", "100")
+	time.Sleep(30 * time.Millisecond)
+
+	// the keys are deleted without being accessed
+	for i := 0; i < 100 && dictStore.Len() > 2; i++ {
+		ServerCron()
+	}
+	assert.EqualValues(t, 2, dictStore.Len())
+	assert.EqualValues(t, 1, dictStore.VolatileLen())
+	assert.EqualValues(t, expired+1000, expiredKeys)
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+}
+
+func TestLazyExpireStats(t *testing.T) {
+	resetStores()
+	expired := expiredKeys
+	evalCmd("SET", "k", "v")
+	evalCmd("PEXPIREAT", "k", strconv.FormatInt(time.Now().UnixMilli()+10, 10))
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, evalReply("GET", "k"))
+	assert.EqualValues(t, expired+1, expiredKeys)
+}
+
+func TestInfo(t *testing.T) {
+	resetStores()
+	evalCmd("SET", "k", "v", "EX", "100")
+	evalCmd("SADD", "set", "a")
+
+	info := evalReply("INFO").(string)
+	assert.Contains(t, info, "# Memory\r\nused_memory:")
+	assert.Contains(t, info, "expired_keys:")
+	assert.Contains(t, info, "evicted_keys:")
+	assert.Contains(t, info, "db0:keys=2,expires=1\r\n")
+
+	stats := evalReply("INFO", "STATS").(string)
+	assert.True(t, strings.HasPrefix(stats, "# Stats\r\n"))
+	assert.NotContains(t, stats, "# Keyspace")
+	assertErrorReply(t, evalReply("INFO", "a", "b"))
+}
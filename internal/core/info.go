@@ -0,0 +1,73 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"memkv/internal/config"
+	"strings"
+)
+
+// infoSections are the sections of INFO, in the order they are printed
+var infoSections = []struct {
+	name  string
+	title string
+	gen   func(b *strings.Builder)
+}{
+	{"clients", "Clients", genClientsInfo},
+	{"memory", "Memory", genMemoryInfo},
+	{"stats", "Stats", genStatsInfo},
+	{"keyspace", "Keyspace", genKeyspaceInfo},
+}
+
+func genClientsInfo(b *strings.Builder) {
+	fmt.Fprintf(b, "blocked_clients:%d\r\n", len(blockedClients))
+}
+
+func genMemoryInfo(b *strings.Builder) {
+	fmt.Fprintf(b, "used_memory:%d\r\n", UsedMemory())
+	fmt.Fprintf(b, "maxmemory:%d\r\n", config.MaxMemory)
+	fmt.Fprintf(b, "maxmemory_policy:%s\r\n", config.MaxMemoryPolicy)
+}
+
+func genStatsInfo(b *strings.Builder) {
+	fmt.Fprintf(b, "expired_keys:%d\r\n", expiredKeys)
+	fmt.Fprintf(b, "expired_stale_perc:%.2f\r\n", expiredStalePerc*100)
+	fmt.Fprintf(b, "expired_time_cap_reached_count:%d\r\n", expireCycleTimeCapReached)
+	fmt.Fprintf(b, "expire_cycle_cpu_milliseconds:%d\r\n", expireCycleTime.Milliseconds())
+	fmt.Fprintf(b, "evicted_keys:%d\r\n", evictedKeys)
+	fmt.Fprintf(b, "pubsub_channels:%d\r\n", len(pubsubChannels))
+	fmt.Fprintf(b, "pubsub_patterns:%d\r\n", len(pubsubPatterns))
+}
+
+func genKeyspaceInfo(b *strings.Builder) {
+	keys := dictStore.Len()
+	if keys > 0 {
+		fmt.Fprintf(b, "db0:keys=%d,expires=%d\r\n", keys, dictStore.VolatileLen())
+	}
+}
+
+/*
+INFO [section]
+Returns information and statistics about the server, for every section or only the given one.
+*/
+func cmdINFO(args []string) []byte {
+	if len(args) > 1 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'INFO' command"), false)
+	}
+	section := "all"
+	if len(args) == 1 {
+		section = strings.ToLower(args[0])
+	}
+	var b strings.Builder
+	for _, s := range infoSections {
+		if section != "all" && section != "default" && section != s.name {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", s.title)
+		s.gen(&b)
+	}
+	return Encode(b.String(), false)
+}
@@ -6,12 +6,16 @@ import (
 	"log"
 	"memkv/internal/config"
 	"syscall"
+	"time"
 )
 
 type Epoll struct {
 	fd            int
 	epollEvents   []syscall.EpollEvent
 	genericEvents []Event
+	// interests keeps the event mask registered for each FD, because epoll
+	// needs the whole mask whenever we add or remove a single operation
+	interests map[int]uint32
 }
 
 func CreateIOMultiplexer() (*Epoll, error) {
@@ -22,27 +26,66 @@ func CreateIOMultiplexer() (*Epoll, error) {
 	}
 
 	return &Epoll{
-		fd:            epollFD,
-		epollEvents:   make([]syscall.EpollEvent, config.MaxConnection),
-		genericEvents: make([]Event, config.MaxConnection),
+		fd:          epollFD,
+		epollEvents: make([]syscall.EpollEvent, config.MaxConnection),
+		// a FD can be readable and writable at the same time, which produces 2 generic events
+		genericEvents: make([]Event, 0, 2*config.MaxConnection),
+		interests:     make(map[int]uint32),
 	}, nil
 }
 
 func (ep *Epoll) Monitor(event Event) error {
 	epollEvent := event.toNative()
-	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_ADD, event.Fd, &epollEvent)
+	cur, exist := ep.interests[event.Fd]
+	if !exist {
+		if err := syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_ADD, event.Fd, &epollEvent); err != nil {
+			return err
+		}
+		ep.interests[event.Fd] = epollEvent.Events
+		return nil
+	}
+	if cur&epollEvent.Events == epollEvent.Events {
+		return nil
+	}
+	epollEvent.Events |= cur
+	if err := syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &epollEvent); err != nil {
+		return err
+	}
+	ep.interests[event.Fd] = epollEvent.Events
+	return nil
 }
 
-func (ep *Epoll) Check() ([]Event, error) {
-	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, -1)
+func (ep *Epoll) Unmonitor(event Event) error {
+	epollEvent := event.toNative()
+	cur, exist := ep.interests[event.Fd]
+	if !exist || cur&epollEvent.Events == 0 {
+		return nil
+	}
+	epollEvent.Events = cur &^ epollEvent.Events
+	if epollEvent.Events == 0 {
+		delete(ep.interests, event.Fd)
+		return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_DEL, event.Fd, nil)
+	}
+	ep.interests[event.Fd] = epollEvent.Events
+	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &epollEvent)
+}
+
+func (ep *Epoll) Check(timeout time.Duration) ([]Event, error) {
+	msec := -1
+	if timeout >= 0 {
+		// round up, so that we don't wake up right before the deadline
+		msec = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, msec)
 	if err != nil {
 		return nil, err
 	}
+	ep.genericEvents = ep.genericEvents[:0]
 	for i := 0; i < n; i++ {
-		ep.genericEvents[i] = createEvent(ep.epollEvents[i])
+		ep.genericEvents = appendEvents(ep.genericEvents, ep.epollEvents[i])
 	}
 
-	return ep.genericEvents[:n], nil
+	return ep.genericEvents, nil
 }
 
 func (ep *Epoll) Close() error {
@@ -1,5 +1,7 @@
 package io_multiplexing
 
+import "time"
+
 const OpRead = 0
 const OpWrite = 1
 
@@ -11,7 +13,11 @@ type Event struct {
 }
 
 type IOMultiplexer interface {
+	// Monitor starts watching the FD for the operation, a FD can be watched for both reading and writing
 	Monitor(event Event) error
-	Check() ([]Event, error)
+	// Unmonitor stops watching the FD for the operation
+	Unmonitor(event Event) error
+	// Check waits until some FDs are ready or the timeout elapses, a negative timeout waits forever
+	Check(timeout time.Duration) ([]Event, error)
 	Close() error
 }
@@ -6,12 +6,16 @@ import (
 	"log"
 	"memkv/internal/config"
 	"syscall"
+	"time"
 )
 
 type KQueue struct {
 	fd            int
 	kqEvents      []syscall.Kevent_t
 	genericEvents []Event
+	// filters keeps the operations registered for each FD, one bit per operation, so that
+	// registering or deleting a filter that is already in this state doesn't make a syscall
+	filters map[int]uint8
 }
 
 func CreateIOMultiplexer() (*KQueue, error) {
@@ -25,17 +29,47 @@ func CreateIOMultiplexer() (*KQueue, error) {
 		fd:            epollFD,
 		kqEvents:      make([]syscall.Kevent_t, config.MaxConnection),
 		genericEvents: make([]Event, config.MaxConnection),
+		filters:       make(map[int]uint8),
 	}, nil
 }
 
 func (kq *KQueue) Monitor(event Event) error {
+	bit := uint8(1) << event.Op
+	cur := kq.filters[event.Fd]
+	if cur&bit != 0 {
+		return nil
+	}
 	kqEvent := event.toNative(syscall.EV_ADD)
+	if _, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{kqEvent}, nil, nil); err != nil {
+		return err
+	}
+	kq.filters[event.Fd] = cur | bit
+	return nil
+}
+
+func (kq *KQueue) Unmonitor(event Event) error {
+	bit := uint8(1) << event.Op
+	cur := kq.filters[event.Fd]
+	if cur&bit == 0 {
+		return nil
+	}
+	if cur &^= bit; cur == 0 {
+		delete(kq.filters, event.Fd)
+	} else {
+		kq.filters[event.Fd] = cur
+	}
+	kqEvent := event.toNative(syscall.EV_DELETE)
 	_, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{kqEvent}, nil, nil)
 	return err
 }
 
-func (kq *KQueue) Check() ([]Event, error) {
-	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, nil)
+func (kq *KQueue) Check(timeout time.Duration) ([]Event, error) {
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(int64(timeout))
+		ts = &t
+	}
+	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, ts)
 	if err != nil {
 		return nil, err
 	}
@@ -15,13 +15,21 @@ func (e Event) toNative() syscall.EpollEvent {
 	}
 }
 
-func createEvent(ep syscall.EpollEvent) Event {
-	var op Operation = OpRead
-	if ep.Events == syscall.EPOLLOUT {
-		op = OpWrite
+// appendEvents converts an epoll event to generic events. A FD that is both readable
+// and writable produces one event per operation. Errors and hang-ups are reported
+// as readable, so that the next read on the FD surfaces them.
+func appendEvents(events []Event, ep syscall.EpollEvent) []Event {
+	if ep.Events&(syscall.EPOLLIN|syscall.EPOLLERR|syscall.EPOLLHUP) != 0 {
+		events = append(events, Event{
+			Fd: int(ep.Fd),
+			Op: OpRead,
+		})
 	}
-	return Event{
-		Fd: int(ep.Fd),
-		Op: op,
+	if ep.Events&syscall.EPOLLOUT != 0 {
+		events = append(events, Event{
+			Fd: int(ep.Fd),
+			Op: OpWrite,
+		})
 	}
+	return events
 }
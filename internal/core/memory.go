@@ -0,0 +1,229 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"strconv"
+	"strings"
+)
+
+// Memory accounting and maxmemory eviction. The memory of every key is accounted by dictStore when the key
+// is put, and accounted again after each write command as values like sets are modified in place.
+
+var evictionPool data_structure.EvictionPool
+
+// evictedKeys is the number of keys evicted because of maxmemory or of the key number limit
+var evictedKeys uint64 = 0
+
+// denyOOMCommands may use more memory, they are rejected when the memory usage can't be brought below maxmemory
+var denyOOMCommands = map[string]struct{}{
+	"SET":            {},
+	"INCR":           {},
+	"COPY":           {},
+	"LPUSH":          {},
+	"RPUSH":          {},
+	"LSET":           {},
+	"LINSERT":        {},
+	"LMOVE":          {},
+	"BLMOVE":         {},
+	"HSET":           {},
+	"HSETNX":         {},
+	"HINCRBY":        {},
+	"HINCRBYFLOAT":   {},
+	"XADD":           {},
+	"SADD":           {},
+	"SINTERSTORE":    {},
+	"SUNIONSTORE":    {},
+	"SDIFFSTORE":     {},
+	"ZADD":           {},
+	"ZRANGESTORE":    {},
+	"GEOADD":         {},
+	"BF.RESERVE":     {},
+	"BF.MADD":        {},
+	"BF.LOADCHUNK":   {},
+	"TOPK.RESERVE":   {},
+	"TOPK.ADD":       {},
+	"TOPK.INCRBY":    {},
+	"TOPK.LOADCHUNK": {},
+	"PFADD":          {},
+	"PFMERGE":        {},
+	"PFLOADCHUNK":    {},
+	"CF.RESERVE":     {},
+	"CF.ADD":         {},
+	"CF.ADDNX":       {},
+	"CF.INSERT":      {},
+	"CF.LOADCHUNK":   {},
+	"CMS.INITBYDIM":  {},
+	"CMS.INITBYPROB": {},
+	"CMS.LOADCHUNK":  {},
+
+	"TDIGEST.CREATE":    {},
+	"TDIGEST.ADD":       {},
+	"TDIGEST.MERGE":     {},
+	"TDIGEST.LOADCHUNK": {},
+	"MORRIS.CREATE":     {},
+	"MORRIS.INCRBY":     {},
+	"MORRIS.MERGE":      {},
+	"MORRIS.LOADCHUNK":  {},
+}
+
+func isDenyOOMCommand(cmd string) bool {
+	_, ok := denyOOMCommands[cmd]
+	return ok
+}
+
+// UsedMemory returns the estimated memory used by the dataset
+func UsedMemory() uint64 {
+	return dictStore.MemUsage()
+}
+
+// commandKeys returns the keys accessed by cmd
+func commandKeys(cmd *MemKVCmd) []string {
+	switch cmd.Cmd {
+	case "PING", "BGREWRITEAOF", "SAVE", "BGSAVE", "MEMORY", "INFO":
+		return nil
+	case "DEL", "UNLINK", "EXISTS", "PFCOUNT", "PFMERGE":
+		return cmd.Args
+	case "RENAME", "RENAMENX", "COPY", "LMOVE", "BLMOVE", "SMOVE", "ZRANGESTORE":
+		return cmd.Args[:min(len(cmd.Args), 2)]
+	case "BLPOP", "BRPOP":
+		// the last argument is the timeout
+		return cmd.Args[:max(len(cmd.Args)-1, 0)]
+	case "XGROUP":
+		// the subcommand comes first
+		return cmd.Args[min(len(cmd.Args), 1):min(len(cmd.Args), 2)]
+	case "XREAD":
+		return streamsKeys(cmd.Args, 0)
+	case "XREADGROUP":
+		// after GROUP group consumer
+		return streamsKeys(cmd.Args, 3)
+	}
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	return cmd.Args[:1]
+}
+
+// streamsKeys returns the keys of XREAD and XREADGROUP: the first half of the arguments after STREAMS,
+// which is looked for from args[from]
+func streamsKeys(args []string, from int) []string {
+	for i := from; i < len(args); i++ {
+		if strings.ToUpper(args[i]) == "STREAMS" {
+			rest := args[i+1:]
+			return rest[:len(rest)/2]
+		}
+	}
+	return nil
+}
+
+// updateKeysMemUsage accounts again for the keys of a write command that has just run
+func updateKeysMemUsage(keys []string) {
+	for _, key := range keys {
+		dictStore.UpdateMemUsage(key)
+	}
+}
+
+// onKeyEvicted is called for every evicted key, the eviction is logged to the AOF as a DEL
+func onKeyEvicted(key string) {
+	evictedKeys++
+	touchWatchedKey(key)
+	notifyKeyspaceEvent(NotifyEvicted, "evicted", key)
+	if aof != nil && !aofLoading {
+		feedAOF("DEL", key)
+	}
+}
+
+// sampleEvictionPool adds a sample of keys to the eviction pool according to the maxmemory policy,
+// and returns the number of sampled keys
+func sampleEvictionPool() int {
+	sampled := 0
+	switch config.MaxMemoryPolicy {
+	case config.MaxMemoryAllKeysLRU:
+		dictStore.SampleKeys(config.EvictionSamples, func(key string, obj *data_structure.Obj) {
+			evictionPool.Add(key, dictStore.EvictionScore(obj))
+			sampled++
+		})
+	case config.MaxMemoryVolatileTTL:
+		dictStore.SampleVolatileKeys(config.EvictionSamples, func(key string, expMs uint64) {
+			// the nearest expiry is the best candidate
+			evictionPool.Add(key, math.MaxUint64-expMs)
+			sampled++
+		})
+	}
+	return sampled
+}
+
+// nextEvictionKey returns the best key to evict, or false if the policy has no key to evict
+func nextEvictionKey() (string, bool) {
+	for {
+		sampled := sampleEvictionPool()
+		for {
+			key, ok := evictionPool.Pop()
+			if !ok {
+				break
+			}
+			if dictStore.Get(key) != nil {
+				return key, true
+			}
+		}
+		if sampled == 0 {
+			return "", false
+		}
+	}
+}
+
+// performEvictions evicts keys until the memory usage is below maxmemory, it returns false if it can't
+func performEvictions() bool {
+	if config.MaxMemory == 0 {
+		return true
+	}
+	for UsedMemory() > config.MaxMemory {
+		if config.MaxMemoryPolicy == config.MaxMemoryNoEviction {
+			return false
+		}
+		key, ok := nextEvictionKey()
+		if !ok {
+			return false
+		}
+		dictStore.Del(key)
+		onKeyEvicted(key)
+	}
+	return true
+}
+
+/*
+MEMORY USAGE key [SAMPLES count]
+Returns the estimated number of bytes used by key and its value, or nil if the key doesn't exist.
+SAMPLES is accepted for compatibility, the usage of every value is always tracked exactly.
+*/
+func cmdMEMORY(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'MEMORY' command"), false)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "USAGE":
+		if len(args) != 2 && len(args) != 4 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'MEMORY USAGE' command"), false)
+		}
+		if len(args) == 4 {
+			if strings.ToUpper(args[2]) != "SAMPLES" {
+				return Encode(errors.New("(error) ERR syntax error"), false)
+			}
+			if _, err := strconv.ParseInt(args[3], 10, 64); err != nil {
+				return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
+			}
+		}
+		key := args[1]
+		usage, exist := dictStore.KeyMemUsage(key)
+		if !exist {
+			return constant.RespNil
+		}
+		return Encode(int64(usage), false)
+	default:
+		return Encode(fmt.Errorf("(error) ERR unknown subcommand '%s'", args[0]), false)
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+	"memkv/internal/data_structure"
+)
+
+func evalReply(args ...string) interface{} {
+	var buf bytes.Buffer
+	EvalAndResponse(&MemKVCmd{Cmd: args[0], Args: args[1:]}, &buf)
+	res, _ := Decode(buf.Bytes())
+	return res
+}
+
+func assertErrorReply(t *testing.T, res interface{}) {
+	assert.Contains(t, res, "(error)")
+}
+
+func withMaxMemory(t *testing.T, maxMemory uint64, policy string) {
+	oldMax, oldPolicy := config.MaxMemory, config.MaxMemoryPolicy
+	config.MaxMemory, config.MaxMemoryPolicy = maxMemory, policy
+	t.Cleanup(func() {
+		config.MaxMemory, config.MaxMemoryPolicy = oldMax, oldPolicy
+	})
+}
+
+// recountUsedMemory sums the usage of every key from scratch
+func recountUsedMemory() uint64 {
+	var total uint64 = 0
+	dictStore.ForEach(func(key string, _ *data_structure.Obj) {
+		usage, _ := dictStore.KeyMemUsage(key)
+		total += usage
+	})
+	return total
+}
+
+func TestMemoryUsage(t *testing.T) {
+	resetStores()
+	assert.Nil(t, evalReply("MEMORY", "USAGE", "missing"))
+
+	evalCmd("SET", "short", "v")
+	evalCmd("SET", "long", string(make([]byte, 1000)))
+	short := evalReply("MEMORY", "USAGE", "short").(int64)
+	long := evalReply("MEMORY", "USAGE", "long").(int64)
+	assert.Greater(t, short, int64(0))
+	// 1000 bytes against 1, with a key shorter by 1
+	assert.EqualValues(t, 998, long-short)
+
+	evalCmd("SADD", "set", "a")
+	small := evalReply("MEMORY", "USAGE", "set", "SAMPLES", "5").(int64)
+	for i := 0; i < 100; i++ {
+		evalCmd("SADD", "set", strconv.Itoa(i))
+	}
+	assert.Greater(t, evalReply("MEMORY", "USAGE", "set").(int64), small+100*16)
+
+	evalCmd("BF.RESERVE", "bf", "0.01", "10000")
+	assert.Greater(t, evalReply("MEMORY", "USAGE", "bf").(int64), int64(10000))
+	evalCmd("CMS.INITBYDIM", "cms", "1000", "5")
+	assert.Greater(t, evalReply("MEMORY", "USAGE", "cms").(int64), int64(4*1000*5))
+
+	assertErrorReply(t, evalReply("MEMORY", "USAGE"))
+	assertErrorReply(t, evalReply("MEMORY", "DOCTOR"))
+}
+
+func TestMemoryAccounting(t *testing.T) {
+	resetStores()
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		evalCmd("SET", key, key, "EX", "100")
+		evalCmd("INCR", "counter")
+		evalCmd("SADD", "set", key)
+		evalCmd("ZADD", "zset", key, key)
+		evalCmd("BF.MADD", "bf", key)
+		evalCmd("CMS.INITBYDIM", "cms"+key, "10", "2")
+	}
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+
+	evalCmd("SPOP", "set")
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		evalCmd("SET", key, "overwritten")
+		evalCmd("DEL", "cms"+key)
+		evalCmd("SREM", "set", key)
+		evalCmd("ZREM", "zset", key)
+	}
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+
+	for i := 0; i < 100; i++ {
+		evalCmd("DEL", strconv.Itoa(i))
+	}
+	evalCmd("DEL", "counter")
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+}
+
+// like Redis, the memory usage is checked before running a command, so the command that goes above
+// maxmemory succeeds and the next one evicts or fails
+func TestMaxMemoryNoEviction(t *testing.T) {
+	resetStores()
+	evalCmd("SET", "a", "1")
+	withMaxMemory(t, UsedMemory(), config.MaxMemoryNoEviction)
+
+	assert.EqualValues(t, "OK", evalReply("SET", "b", string(make([]byte, 100))))
+	res := evalReply("SET", "c", "1")
+	assert.EqualValues(t, "(error) OOM command not allowed when used memory > 'maxmemory'", res)
+	assertErrorReply(t, evalReply("SADD", "set", "a"))
+	assert.Nil(t, dictStore.Get("c"))
+	// reads and deletions are still allowed
+	assert.EqualValues(t, "1", evalReply("GET", "a"))
+	assert.EqualValues(t, 1, evalReply("DEL", "b"))
+	assert.EqualValues(t, "OK", evalReply("SET", "c", "1"))
+}
+
+func TestMaxMemoryAllKeysLRU(t *testing.T) {
+	resetStores()
+	withMaxMemory(t, 0, config.MaxMemoryAllKeysLRU)
+	evalCmd("SADD", "set", "a", "b")
+	evalCmd("SET", "first", "v")
+	config.MaxMemory = 20 * 1024
+	evicted := evictedKeys
+
+	for i := 0; i < 1000; i++ {
+		assert.EqualValues(t, "OK", evalReply("SET", strconv.Itoa(i), strconv.Itoa(i)))
+		assert.LessOrEqual(t, UsedMemory(), config.MaxMemory+1024)
+	}
+	assert.Greater(t, evictedKeys, evicted)
+	keys := 0
+	dictStore.ForEach(func(string, *data_structure.Obj) { keys++ })
+	assert.Less(t, keys, 1000)
+	assert.EqualValues(t, recountUsedMemory(), UsedMemory())
+}
+
+func TestMaxMemoryVolatileTTL(t *testing.T) {
+	resetStores()
+	withMaxMemory(t, 0, config.MaxMemoryVolatileTTL)
+	evalCmd("SET", "persistent", "v")
+	evalCmd("SET", "later", "v", "EX", "1000")
+	evalCmd("SET", "sooner", "v", "EX", "10")
+	config.MaxMemory = UsedMemory()
+	evalCmd("SET", "new1", "v")
+
+	// the key with the nearest expiry is evicted first
+	assert.EqualValues(t, "OK", evalReply("SET", "new2", "v"))
+	assert.Nil(t, dictStore.Get("sooner"))
+	assert.NotNil(t, dictStore.Get("later"))
+	assert.EqualValues(t, "OK", evalReply("SET", "new3", "v"))
+	assert.Nil(t, dictStore.Get("later"))
+
+	// only the keys with an expiry can be evicted
+	assertErrorReply(t, evalReply("SET", "new4", "v"))
+	assert.NotNil(t, dictStore.Get("persistent"))
+}
@@ -0,0 +1,426 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"memkv/internal/constant"
+	"strings"
+)
+
+/*
+Transactions, like Redis MULTI/EXEC: after MULTI the commands of a client are checked and queued instead of
+being executed, and EXEC runs them back-to-back. As the server is single-threaded no other client can run a
+command in the middle of a transaction.
+
+Optimistic locking: WATCH records the version of keys, which is incremented by every modification of a
+watched key, including its expiration and its eviction. EXEC aborts the transaction if any of the versions
+the client recorded has changed.
+*/
+
+// txState is the transaction state of a client
+type txState struct {
+	inMulti bool
+	queue   []*MemKVCmd
+	// aborted is set when a command could not be queued, EXEC then discards the transaction
+	aborted bool
+	// watched maps the keys watched by the client to their version when they were watched
+	watched map[string]uint64
+}
+
+// watchedKey is the version of a key watched by at least one client
+type watchedKey struct {
+	version uint64
+	clients int
+}
+
+// watchedKeys holds the versions of the keys watched by the clients. Only the watched keys are tracked,
+// the entry of a key is deleted when its last watcher unwatches it.
+var watchedKeys = map[string]*watchedKey{}
+
+/*
+commandArity is the number of arguments of every command, command name included, like in the Redis
+command table: N means exactly N, -N means at least N. It is used to reject a bad command when it is queued.
+*/
+var commandArity = map[string]int{
+	"PING":           -1,
+	"SET":            -3,
+	"GET":            2,
+	"TTL":            2,
+	"DEL":            -2,
+	"EXPIRE":         -3,
+	"PEXPIREAT":      3,
+	"INCR":           2,
+	"TYPE":           2,
+	"EXISTS":         -2,
+	"UNLINK":         -2,
+	"RENAME":         3,
+	"RENAMENX":       3,
+	"COPY":           -3,
+	"KEYS":           2,
+	"SCAN":           -2,
+	"RANDOMKEY":      1,
+	"OBJECT":         -2,
+	"LPUSH":          -3,
+	"RPUSH":          -3,
+	"LPOP":           -2,
+	"RPOP":           -2,
+	"LLEN":           2,
+	"LRANGE":         4,
+	"LINDEX":         3,
+	"LSET":           4,
+	"LREM":           4,
+	"LTRIM":          4,
+	"LINSERT":        5,
+	"LMOVE":          5,
+	"LPOS":           -3,
+	"BLPOP":          -3,
+	"BRPOP":          -3,
+	"BLMOVE":         6,
+	"HSET":           -4,
+	"HSETNX":         4,
+	"HGET":           3,
+	"HMGET":          -3,
+	"HDEL":           -3,
+	"HLEN":           2,
+	"HEXISTS":        3,
+	"HGETALL":        2,
+	"HKEYS":          2,
+	"HVALS":          2,
+	"HINCRBY":        4,
+	"HINCRBYFLOAT":   4,
+	"HRANDFIELD":     -2,
+	"HSCAN":          -3,
+	"XADD":           -5,
+	"XTRIM":          -4,
+	"XDEL":           -3,
+	"XLEN":           2,
+	"XRANGE":         -4,
+	"XREVRANGE":      -4,
+	"XREAD":          -4,
+	"XREADGROUP":     -7,
+	"XGROUP":         -2,
+	"XACK":           -4,
+	"XPENDING":       -3,
+	"XCLAIM":         -6,
+	"XAUTOCLAIM":     -6,
+	"XSETID":         3,
+	"SADD":           -3,
+	"SREM":           -3,
+	"SCARD":          2,
+	"SMEMBERS":       2,
+	"SISMEMBER":      3,
+	"SMISMEMBER":     -3,
+	"SRANDMEMBER":    -2,
+	"SRAND":          -2,
+	"SSCAN":          -3,
+	"SPOP":           -2,
+	"SINTER":         -2,
+	"SINTERCARD":     -3,
+	"SINTERSTORE":    -3,
+	"SUNION":         -2,
+	"SUNIONSTORE":    -3,
+	"SDIFF":          -2,
+	"SDIFFSTORE":     -3,
+	"SMOVE":          4,
+	"ZADD":           -4,
+	"ZRANK":          3,
+	"ZREM":           -3,
+	"ZSCORE":         3,
+	"ZCARD":          2,
+	"ZSCAN":          -3,
+	"ZREVRANK":       3,
+	"ZRANGE":         -4,
+	"ZRANGESTORE":    -5,
+	"ZRANGEBYSCORE":  -4,
+	"ZREVRANGE":      -4,
+	"ZCOUNT":         4,
+	"GEOADD":         -5,
+	"GEODIST":        -4,
+	"GEOHASH":        -2,
+	"GEOSEARCH":      -5,
+	"GEOPOS":         -3,
+	"BF.RESERVE":     -4,
+	"BF.INFO":        2,
+	"BF.MADD":        -3,
+	"BF.EXISTS":      3,
+	"BF.MEXISTS":     -3,
+	"BF.LOADCHUNK":   4,
+	"TOPK.RESERVE":   -3,
+	"TOPK.ADD":       -3,
+	"TOPK.INCRBY":    -4,
+	"TOPK.QUERY":     -3,
+	"TOPK.COUNT":     -3,
+	"TOPK.LIST":      -2,
+	"TOPK.INFO":      2,
+	"TOPK.LOADCHUNK": 4,
+	"PFADD":          -2,
+	"PFCOUNT":        -2,
+	"PFMERGE":        -2,
+	"PFLOADCHUNK":    3,
+	"CF.RESERVE":     -3,
+	"CF.ADD":         3,
+	"CF.ADDNX":       3,
+	"CF.INSERT":      -4,
+	"CF.EXISTS":      3,
+	"CF.MEXISTS":     -3,
+	"CF.DEL":         3,
+	"CF.COUNT":       3,
+	"CF.INFO":        2,
+	"CF.LOADCHUNK":   4,
+	"CMS.INITBYDIM":  4,
+	"CMS.INITBYPROB": 4,
+	"CMS.INCRBY":     -4,
+	"CMS.QUERY":      -3,
+	"CMS.LOADCHUNK":  4,
+	"BGREWRITEAOF":   1,
+	"SAVE":           1,
+	"BGSAVE":         1,
+	"MEMORY":         -2,
+	"INFO":           -1,
+	"MULTI":          1,
+	"EXEC":           1,
+	"DISCARD":        1,
+	"WATCH":          -2,
+	"UNWATCH":        1,
+	"SUBSCRIBE":      -2,
+	"UNSUBSCRIBE":    -1,
+	"PSUBSCRIBE":     -2,
+	"PUNSUBSCRIBE":   -1,
+	"PUBLISH":        3,
+	"PUBSUB":         -2,
+
+	"TDIGEST.CREATE":    -2,
+	"TDIGEST.ADD":       -3,
+	"TDIGEST.QUANTILE":  -3,
+	"TDIGEST.CDF":       -3,
+	"TDIGEST.RANK":      -3,
+	"TDIGEST.MIN":       2,
+	"TDIGEST.MAX":       2,
+	"TDIGEST.MERGE":     -4,
+	"TDIGEST.RESET":     2,
+	"TDIGEST.INFO":      2,
+	"TDIGEST.LOADCHUNK": 4,
+	"MORRIS.CREATE":     -2,
+	"MORRIS.INCRBY":     3,
+	"MORRIS.GET":        2,
+	"MORRIS.MERGE":      -3,
+	"MORRIS.INFO":       2,
+	"MORRIS.LOADCHUNK":  4,
+}
+
+// checkCommand returns the error of an unknown command or of a command with a wrong number of arguments
+func checkCommand(cmd *MemKVCmd) error {
+	arity, ok := commandArity[cmd.Cmd]
+	if !ok {
+		return fmt.Errorf("(error) ERR unknown command '%s'", cmd.Cmd)
+	}
+	n := len(cmd.Args) + 1
+	if (arity > 0 && n != arity) || (arity < 0 && n < -arity) {
+		return fmt.Errorf("(error) ERR wrong number of arguments for '%s' command", strings.ToLower(cmd.Cmd))
+	}
+	return nil
+}
+
+// isTxCommand reports whether cmd controls the transaction, these commands are executed even after MULTI
+func isTxCommand(cmd string) bool {
+	switch cmd {
+	case "MULTI", "EXEC", "DISCARD", "WATCH":
+		return true
+	}
+	return false
+}
+
+// isClientCommand reports whether cmd works on the state of the client, rather than on the dataset
+func isClientCommand(cmd string) bool {
+	return isTxCommand(cmd) || isPubSubCommand(cmd) || cmd == "UNWATCH"
+}
+
+// touchWatchedKey increments the version of key if it is watched, failing the transactions watching it
+func touchWatchedKey(key string) {
+	if wk, ok := watchedKeys[key]; ok {
+		wk.version++
+	}
+}
+
+// dirty counts the changes made to the dataset by the commands, a write command is propagated and touches
+// the watched keys only if it changed something
+var dirty uint64
+
+// signalModifiedKey is called for every key modified by a command
+func signalModifiedKey(key string) {
+	dirty++
+	touchWatchedKey(key)
+}
+
+func (tx *txState) watch(key string) {
+	if _, ok := tx.watched[key]; ok {
+		return
+	}
+	wk, ok := watchedKeys[key]
+	if !ok {
+		wk = &watchedKey{}
+		watchedKeys[key] = wk
+	}
+	wk.clients++
+	if tx.watched == nil {
+		tx.watched = map[string]uint64{}
+	}
+	tx.watched[key] = wk.version
+}
+
+func (tx *txState) unwatchAll() {
+	for key := range tx.watched {
+		wk := watchedKeys[key]
+		wk.clients--
+		if wk.clients == 0 {
+			delete(watchedKeys, key)
+		}
+	}
+	tx.watched = nil
+}
+
+// watchedKeysModified reports whether a key watched by the client was modified since it was watched
+func (tx *txState) watchedKeysModified() bool {
+	for key := range tx.watched {
+		// a watched key that has expired is deleted now, which modifies it
+		dictStore.Get(key)
+	}
+	for key, version := range tx.watched {
+		if watchedKeys[key].version != version {
+			return true
+		}
+	}
+	return false
+}
+
+// reset ends the transaction of the client and unwatches its keys
+func (tx *txState) reset() {
+	tx.inMulti = false
+	tx.queue = nil
+	tx.aborted = false
+	tx.unwatchAll()
+}
+
+// FreeClient releases the state of a client that is disconnected
+func FreeClient(comm *FDComm) {
+	comm.tx.reset()
+	comm.pubsub.unsubscribeAll(comm)
+	if comm.block.blocked() {
+		unblockClient(comm)
+	}
+	comm.block.deferred = nil
+}
+
+// queueCommand queues cmd in the transaction of the client, or aborts the transaction if cmd is invalid
+func queueCommand(comm *FDComm, cmd *MemKVCmd) []byte {
+	if err := checkCommand(cmd); err != nil {
+		comm.tx.aborted = true
+		return Encode(err, false)
+	}
+	if isPubSubCommand(cmd.Cmd) {
+		comm.tx.aborted = true
+		return Encode(fmt.Errorf("(error) ERR %s inside MULTI is not allowed", cmd.Cmd), false)
+	}
+	comm.tx.queue = append(comm.tx.queue, cmd)
+	return Encode("QUEUED", true)
+}
+
+// evalClientCommand executes a command working on the state of the client, like the transaction
+// or the subscriptions
+func evalClientCommand(cmd *MemKVCmd, c io.ReadWriter) []byte {
+	comm, ok := c.(*FDComm)
+	if !ok {
+		return Encode(fmt.Errorf("(error) ERR %s is not allowed here", cmd.Cmd), false)
+	}
+	if err := checkCommand(cmd); err != nil {
+		if comm.tx.inMulti {
+			comm.tx.aborted = true
+		}
+		return Encode(err, false)
+	}
+	switch cmd.Cmd {
+	case "MULTI":
+		return cmdMULTI(comm)
+	case "EXEC":
+		return cmdEXEC(comm)
+	case "DISCARD":
+		return cmdDISCARD(comm)
+	case "WATCH":
+		return cmdWATCH(comm, cmd.Args)
+	case "UNWATCH":
+		return cmdUNWATCH(comm)
+	case "SUBSCRIBE":
+		return cmdSUBSCRIBE(comm, cmd.Args)
+	case "UNSUBSCRIBE":
+		return cmdUNSUBSCRIBE(comm, cmd.Args)
+	case "PSUBSCRIBE":
+		return cmdPSUBSCRIBE(comm, cmd.Args)
+	case "PUNSUBSCRIBE":
+		return cmdPUNSUBSCRIBE(comm, cmd.Args)
+	}
+	return nil
+}
+
+func cmdMULTI(comm *FDComm) []byte {
+	if comm.tx.inMulti {
+		return Encode(errors.New("(error) ERR MULTI calls can not be nested"), false)
+	}
+	comm.tx.inMulti = true
+	return Encode("OK", true)
+}
+
+/*
+EXEC runs the queued commands and replies with the array of their replies. It replies with a null array
+when a watched key was modified, and with an error when a command could not be queued.
+*/
+func cmdEXEC(comm *FDComm) []byte {
+	if !comm.tx.inMulti {
+		return Encode(errors.New("(error) ERR EXEC without MULTI"), false)
+	}
+	defer comm.tx.reset()
+	if comm.tx.aborted {
+		return Encode(errors.New("(error) EXECABORT Transaction discarded because of previous errors."), false)
+	}
+	if comm.tx.watchedKeysModified() {
+		return constant.RespNilArray
+	}
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("*%d%s", len(comm.tx.queue), CRLF))
+	beginAOFExec()
+	for _, cmd := range comm.tx.queue {
+		res, err := evalCommand(cmd)
+		if err != nil {
+			res = Encode(err, false)
+		}
+		buf.Write(res)
+	}
+	endAOFExec()
+	return buf.Bytes()
+}
+
+func cmdDISCARD(comm *FDComm) []byte {
+	if !comm.tx.inMulti {
+		return Encode(errors.New("(error) ERR DISCARD without MULTI"), false)
+	}
+	comm.tx.reset()
+	return Encode("OK", true)
+}
+
+// WATCH key [key ...] marks the keys to be watched for the conditional execution of the next transaction
+func cmdWATCH(comm *FDComm, args []string) []byte {
+	if comm.tx.inMulti {
+		comm.tx.aborted = true
+		return Encode(errors.New("(error) ERR WATCH inside MULTI is not allowed"), false)
+	}
+	for _, key := range args {
+		comm.tx.watch(key)
+	}
+	return Encode("OK", true)
+}
+
+func cmdUNWATCH(comm *FDComm) []byte {
+	comm.tx.unwatchAll()
+	return Encode("OK", true)
+}
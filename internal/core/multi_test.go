@@ -0,0 +1,150 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clientReply runs a command for the client comm and returns its decoded reply
+func clientReply(comm *FDComm, args ...string) interface{} {
+	EvalAndResponse(&MemKVCmd{Cmd: args[0], Args: args[1:]}, comm)
+	res, _ := Decode(comm.replyBuf)
+	comm.replyBuf = comm.replyBuf[:0]
+	return res
+}
+
+func TestMultiExec(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+	evalCmd("SADD", "pending", "alice", "bob")
+
+	assert.EqualValues(t, "OK", clientReply(comm, "MULTI"))
+	assert.EqualValues(t, "QUEUED", clientReply(comm, "SREM", "pending", "alice"))
+	assert.EqualValues(t, "QUEUED", clientReply(comm, "ZADD", "leaderboard", "10", "alice"))
+	assert.EqualValues(t, "QUEUED", clientReply(comm, "GET", "pending"))
+	// the commands are not executed before EXEC
+	assert.EqualValues(t, 2, evalReply("SCARD", "pending"))
+
+	res := clientReply(comm, "EXEC").([]interface{})
+	assert.Len(t, res, 3)
+	assert.EqualValues(t, 1, res[0])
+	assert.EqualValues(t, 1, res[1])
+	// an error of a command doesn't stop the others
+	assert.Contains(t, res[2], "WRONGTYPE")
+	assert.EqualValues(t, 1, evalReply("SCARD", "pending"))
+	assert.EqualValues(t, 1, evalReply("ZCARD", "leaderboard"))
+
+	// the client is back to normal
+	assert.EqualValues(t, 1, clientReply(comm, "SCARD", "pending"))
+	assertErrorReply(t, clientReply(comm, "EXEC"))
+	assertErrorReply(t, clientReply(comm, "DISCARD"))
+}
+
+func TestMultiDiscardAndErrors(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+
+	clientReply(comm, "MULTI")
+	assertErrorReply(t, clientReply(comm, "MULTI"))
+	clientReply(comm, "SET", "k", "v")
+	assert.EqualValues(t, "OK", clientReply(comm, "DISCARD"))
+	assert.Nil(t, evalReply("GET", "k"))
+
+	// unknown commands and wrong arities abort the transaction
+	clientReply(comm, "MULTI")
+	clientReply(comm, "SET", "k", "v")
+	assertErrorReply(t, clientReply(comm, "GET"))
+	assert.Contains(t, clientReply(comm, "EXEC"), "EXECABORT")
+	assert.Nil(t, evalReply("GET", "k"))
+
+	clientReply(comm, "MULTI")
+	assertErrorReply(t, clientReply(comm, "NOTACOMMAND", "x"))
+	clientReply(comm, "SET", "k", "v")
+	assert.Contains(t, clientReply(comm, "EXEC"), "EXECABORT")
+
+	clientReply(comm, "MULTI")
+	assertErrorReply(t, clientReply(comm, "WATCH", "k"))
+	assert.Contains(t, clientReply(comm, "EXEC"), "EXECABORT")
+
+	// an empty transaction
+	clientReply(comm, "MULTI")
+	assert.EqualValues(t, []interface{}{}, clientReply(comm, "EXEC"))
+}
+
+func TestWatch(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+	evalCmd("SET", "balance", "10")
+
+	// no modification: the transaction runs
+	assert.EqualValues(t, "OK", clientReply(comm, "WATCH", "balance", "other"))
+	clientReply(comm, "MULTI")
+	clientReply(comm, "INCR", "balance")
+	assert.EqualValues(t, []interface{}{int64(11)}, clientReply(comm, "EXEC"))
+	assert.Empty(t, watchedKeys)
+
+	// another client modifies the key: the transaction is aborted
+	clientReply(comm, "WATCH", "balance")
+	evalCmd("INCR", "balance")
+	clientReply(comm, "MULTI")
+	clientReply(comm, "INCR", "balance")
+	assert.Nil(t, clientReply(comm, "EXEC"))
+	assert.EqualValues(t, "12", evalReply("GET", "balance"))
+
+	// any type of value and deleting the key
+	evalCmd("SADD", "set", "a")
+	clientReply(comm, "WATCH", "set")
+	evalCmd("DEL", "set")
+	clientReply(comm, "MULTI")
+	assert.Nil(t, clientReply(comm, "EXEC"))
+
+	// UNWATCH
+	clientReply(comm, "WATCH", "balance")
+	evalCmd("INCR", "balance")
+	assert.EqualValues(t, "OK", clientReply(comm, "UNWATCH"))
+	clientReply(comm, "MULTI")
+	clientReply(comm, "INCR", "balance")
+	assert.EqualValues(t, []interface{}{int64(14)}, clientReply(comm, "EXEC"))
+
+	// a write command that changes nothing doesn't abort the transaction
+	evalCmd("SADD", "set", "a")
+	clientReply(comm, "WATCH", "set")
+	evalCmd("SREM", "set", "missing")
+	evalCmd("SADD", "set", "a")
+	clientReply(comm, "MULTI")
+	clientReply(comm, "SCARD", "set")
+	assert.EqualValues(t, []interface{}{int64(1)}, clientReply(comm, "EXEC"))
+
+	// the stream commands that only change the consumer groups
+	evalCmd("XADD", "stream", "1-1", "f", "v")
+	evalCmd("XGROUP", "CREATE", "stream", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "stream", ">")
+	clientReply(comm, "WATCH", "stream")
+	evalCmd("XACK", "stream", "g", "1-2")
+	clientReply(comm, "MULTI")
+	assert.EqualValues(t, []interface{}{}, clientReply(comm, "EXEC"))
+	clientReply(comm, "WATCH", "stream")
+	evalCmd("XACK", "stream", "g", "1-1")
+	clientReply(comm, "MULTI")
+	assert.Nil(t, clientReply(comm, "EXEC"))
+
+	// a key that expires
+	evalCmd("PEXPIREAT", "balance", strconv.FormatInt(time.Now().UnixMilli()+20, 10))
+	clientReply(comm, "WATCH", "balance")
+	time.Sleep(30 * time.Millisecond)
+	clientReply(comm, "MULTI")
+	clientReply(comm, "SET", "balance", "0")
+	assert.Nil(t, clientReply(comm, "EXEC"))
+
+	// disconnecting unwatches the keys
+	clientReply(comm, "WATCH", "balance")
+	other := &FDComm{Fd: -1}
+	clientReply(other, "WATCH", "balance")
+	FreeClient(comm)
+	assert.EqualValues(t, 1, watchedKeys["balance"].clients)
+	FreeClient(other)
+	assert.Empty(t, watchedKeys)
+}
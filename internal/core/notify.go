@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"memkv/internal/config"
+)
+
+/*
+Keyspace notifications, like Redis: every modification of a key publishes a keyspace event,
+with the event as message, to the channel __keyspace@0__:<key>, and a keyevent event, with the key as
+message, to the channel __keyevent@0__:<event>.
+The events that are published are chosen with config.NotifyKeyspaceEvents, see ParseNotifyKeyspaceEvents.
+*/
+
+// The classes of the notifications
+const (
+	NotifyKeyspace = 1 << iota // K
+	NotifyKeyevent             // E
+	NotifyGeneric              // g
+	NotifyString               // $
+	NotifyList                 // l
+	NotifySet                  // s
+	NotifyHash                 // h
+	NotifyZSet                 // z
+	NotifyExpired              // x
+	NotifyEvicted              // e
+	NotifyStream               // t
+	NotifyKeyMiss              // m
+	NotifyModule               // d
+	NotifyNew                  // n
+	// NotifyAll is the class A, m and n are not included
+	NotifyAll = NotifyGeneric | NotifyString | NotifyList | NotifySet | NotifyHash | NotifyZSet |
+		NotifyExpired | NotifyEvicted | NotifyStream | NotifyModule
+)
+
+var notifyClassChars = map[byte]int{
+	'K': NotifyKeyspace,
+	'E': NotifyKeyevent,
+	'g': NotifyGeneric,
+	'$': NotifyString,
+	'l': NotifyList,
+	's': NotifySet,
+	'h': NotifyHash,
+	'z': NotifyZSet,
+	'x': NotifyExpired,
+	'e': NotifyEvicted,
+	't': NotifyStream,
+	'm': NotifyKeyMiss,
+	'd': NotifyModule,
+	'n': NotifyNew,
+	'A': NotifyAll,
+}
+
+/*
+ParseNotifyKeyspaceEvents parses the notify-keyspace-events class string of Redis: K and E select the
+keyspace and keyevent channels, and at least one of them is needed for anything to be published. The other
+characters select the events: g generic commands like DEL or EXPIRE, $ strings, s sets, z sorted sets,
+l lists, h hashes, t streams, d probabilistic types, x expired keys, e evicted keys, n new keys,
+and A is an alias for "g$lshzxetd". m is accepted for compatibility, key misses are not notified.
+*/
+func ParseNotifyKeyspaceEvents(classes string) (int, error) {
+	flags := 0
+	for i := 0; i < len(classes); i++ {
+		class, ok := notifyClassChars[classes[i]]
+		if !ok {
+			return 0, fmt.Errorf("invalid keyspace event class '%c'", classes[i])
+		}
+		flags |= class
+	}
+	return flags, nil
+}
+
+/*
+notifyKeyspaceEvent publishes event on key, if the class of the event is enabled. The commands emit an event
+for every key they modify, so it also signals the key as modified, except for the keys deleted by the expire
+cycle or the eviction, which are not changes made by the command being executed.
+*/
+func notifyKeyspaceEvent(class int, event string, key string) {
+	if class&(NotifyExpired|NotifyEvicted) == 0 {
+		signalModifiedKey(key)
+	}
+	flags := config.NotifyKeyspaceEvents
+	if flags&class == 0 {
+		return
+	}
+	if flags&NotifyKeyspace != 0 {
+		publish("__keyspace@0__:"+key, event)
+	}
+	if flags&NotifyKeyevent != 0 {
+		publish("__keyevent@0__:"+event, key)
+	}
+}
+
+// onNewKey is called for every key added to the keyspace
+func onNewKey(key string) {
+	signalKeyAsReady(key)
+	notifyKeyspaceEvent(NotifyNew, "new", key)
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+)
+
+func withNotifyKeyspaceEvents(t *testing.T, classes string) {
+	flags, err := ParseNotifyKeyspaceEvents(classes)
+	assert.Nil(t, err)
+	old := config.NotifyKeyspaceEvents
+	config.NotifyKeyspaceEvents = flags
+	t.Cleanup(func() {
+		config.NotifyKeyspaceEvents = old
+	})
+}
+
+// subscribeNotifications returns a client subscribed to every keyspace and keyevent channel
+func subscribeNotifications(t *testing.T) *FDComm {
+	comm := &FDComm{Fd: -1}
+	runClientCmd(comm, "PSUBSCRIBE", "__key*__:*")
+	t.Cleanup(func() {
+		FreeClient(comm)
+		ClientsPendingWrite()
+	})
+	return comm
+}
+
+// notifications returns the channel and message of every notification received by comm
+func notifications(comm *FDComm) [][2]string {
+	var res [][2]string
+	for _, msg := range clientMessages(comm) {
+		m := msg.([]interface{})
+		res = append(res, [2]string{m[2].(string), m[3].(string)})
+	}
+	return res
+}
+
+func TestParseNotifyKeyspaceEvents(t *testing.T) {
+	flags, err := ParseNotifyKeyspaceEvents("KEA")
+	assert.Nil(t, err)
+	assert.EqualValues(t, NotifyKeyspace|NotifyKeyevent|NotifyAll, flags)
+	assert.Zero(t, flags&NotifyNew)
+	flags, err = ParseNotifyKeyspaceEvents("Ex")
+	assert.Nil(t, err)
+	assert.EqualValues(t, NotifyKeyevent|NotifyExpired, flags)
+	_, err = ParseNotifyKeyspaceEvents("K?")
+	assert.NotNil(t, err)
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	resetStores()
+	withNotifyKeyspaceEvents(t, "KEA")
+	comm := subscribeNotifications(t)
+
+	evalCmd("SET", "k", "v")
+	assert.EqualValues(t, [][2]string{
+		{"__keyspace@0__:k", "set"},
+		{"__keyevent@0__:set", "k"},
+	}, notifications(comm))
+
+	evalCmd("SADD", "s", "a", "b")
+	evalCmd("SADD", "s", "a")
+	evalCmd("SREM", "s", "a", "b")
+	assert.EqualValues(t, [][2]string{
+		{"__keyspace@0__:s", "sadd"},
+		{"__keyevent@0__:sadd", "s"},
+		{"__keyspace@0__:s", "srem"},
+		{"__keyevent@0__:srem", "s"},
+		{"__keyspace@0__:s", "del"},
+		{"__keyevent@0__:del", "s"},
+	}, notifications(comm))
+
+	evalCmd("ZADD", "z", "1", "a")
+	evalCmd("BF.MADD", "bf", "a")
+	evalCmd("EXPIRE", "z", "100")
+	evalCmd("RENAME", "z", "z2")
+	evalCmd("DEL", "bf", "missing")
+	assert.EqualValues(t, [][2]string{
+		{"__keyspace@0__:z", "zadd"},
+		{"__keyevent@0__:zadd", "z"},
+		{"__keyspace@0__:bf", "bf.madd"},
+		{"__keyevent@0__:bf.madd", "bf"},
+		{"__keyspace@0__:z", "expire"},
+		{"__keyevent@0__:expire", "z"},
+		{"__keyspace@0__:z", "rename_from"},
+		{"__keyevent@0__:rename_from", "z"},
+		{"__keyspace@0__:z2", "rename_to"},
+		{"__keyevent@0__:rename_to", "z2"},
+		{"__keyspace@0__:bf", "del"},
+		{"__keyevent@0__:del", "bf"},
+	}, notifications(comm))
+
+	// failed commands and reads don't notify
+	evalCmd("SADD", "k", "a")
+	evalCmd("GET", "k")
+	assert.Empty(t, notifications(comm))
+}
+
+func TestKeyspaceNotificationClasses(t *testing.T) {
+	resetStores()
+	// only the keyevent channel of expired keys and new keys
+	withNotifyKeyspaceEvents(t, "Exn")
+	comm := subscribeNotifications(t)
+
+	evalCmd("SET", "k", "v")
+	evalCmd("SET", "k", "v2")
+	evalCmd("PEXPIREAT", "k", strconv.FormatInt(time.Now().UnixMilli()+10, 10))
+	time.Sleep(20 * time.Millisecond)
+	// lazy expiration
+	assert.Nil(t, evalReply("GET", "k"))
+	assert.EqualValues(t, [][2]string{
+		{"__keyevent@0__:new", "k"},
+		{"__keyevent@0__:expired", "k"},
+	}, notifications(comm))
+
+	// nothing is published without K or E
+	withNotifyKeyspaceEvents(t, "A")
+	evalCmd("SET", "k", "v")
+	assert.Empty(t, notifications(comm))
+}
+
+func TestEvictionNotification(t *testing.T) {
+	resetStores()
+	withNotifyKeyspaceEvents(t, "Ee")
+	evalCmd("SET", "k", "v")
+	comm := subscribeNotifications(t)
+	withMaxMemory(t, 1, config.MaxMemoryAllKeysLRU)
+
+	evalCmd("PING")
+	assert.EqualValues(t, [][2]string{
+		{"__keyevent@0__:evicted", "k"},
+	}, notifications(comm))
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"memkv/internal/util"
+	"sort"
+	"strings"
+)
+
+/*
+Publish/Subscribe, like Redis: PUBLISH sends a message to the clients subscribed to a channel, and to the
+clients subscribed to a glob-style pattern matching it. A subscribed client can only run the subscription
+commands and PING.
+
+Messages are appended to the reply buffer of the subscribers, which are then added to clientsPendingWrite
+so that the event loop writes them to their sockets. A subscriber that doesn't read its messages is
+disconnected by the pubsub output buffer limits, it never blocks the event loop.
+*/
+
+// pubsubState holds the subscriptions of a client
+type pubsubState struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+// pubsubChannels maps every channel to its subscribers
+var pubsubChannels = map[string]map[*FDComm]struct{}{}
+
+// pubsubPatterns maps every pattern to its subscribers
+var pubsubPatterns = map[string]map[*FDComm]struct{}{}
+
+// clientsPendingWrite are the clients that received messages, or were unblocked, since the last call
+// to ClientsPendingWrite
+var clientsPendingWrite []*FDComm
+
+// ClientsPendingWrite returns the clients that received messages published by other clients, or the reply
+// of a blocking command, their replies have to be flushed
+func ClientsPendingWrite() []*FDComm {
+	pending := clientsPendingWrite
+	clientsPendingWrite = nil
+	for _, comm := range pending {
+		comm.pendingWrite = false
+	}
+	return pending
+}
+
+// isPubSubCommand reports whether cmd manages the subscriptions of the client
+func isPubSubCommand(cmd string) bool {
+	switch cmd {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE":
+		return true
+	}
+	return false
+}
+
+// subscriptions returns the number of channels and patterns the client is subscribed to
+func (ps *pubsubState) subscriptions() int {
+	return len(ps.channels) + len(ps.patterns)
+}
+
+// subscribedModeReply returns the reply of cmd if it is not allowed, or behaves differently, when the
+// client is subscribed
+func subscribedModeReply(cmd *MemKVCmd) ([]byte, bool) {
+	if isPubSubCommand(cmd.Cmd) {
+		return nil, false
+	}
+	if cmd.Cmd == "PING" && len(cmd.Args) <= 1 {
+		msg := ""
+		if len(cmd.Args) == 1 {
+			msg = cmd.Args[0]
+		}
+		return Encode([]string{"pong", msg}, false), true
+	}
+	return Encode(fmt.Errorf("(error) ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING are allowed in this context",
+		strings.ToLower(cmd.Cmd)), false), true
+}
+
+// sendMessage writes a message to a subscriber and schedules the flush of its socket
+func sendMessage(comm *FDComm, message []interface{}) {
+	comm.Write(Encode(message, false))
+	schedulePendingWrite(comm)
+}
+
+// schedulePendingWrite makes the event loop flush the replies written to a client outside of its own commands
+func schedulePendingWrite(comm *FDComm) {
+	if !comm.pendingWrite {
+		comm.pendingWrite = true
+		clientsPendingWrite = append(clientsPendingWrite, comm)
+	}
+}
+
+// subscriptionReply encodes the confirmation of a (un)subscription, channel is nil when the client had
+// no subscription to remove
+func subscriptionReply(kind string, channel interface{}, count int) []byte {
+	return Encode([]interface{}{kind, channel, count}, false)
+}
+
+func subscribe(subscribers map[string]map[*FDComm]struct{}, comm *FDComm, channel string) {
+	clients, ok := subscribers[channel]
+	if !ok {
+		clients = map[*FDComm]struct{}{}
+		subscribers[channel] = clients
+	}
+	clients[comm] = struct{}{}
+}
+
+func unsubscribe(subscribers map[string]map[*FDComm]struct{}, comm *FDComm, channel string) {
+	clients := subscribers[channel]
+	delete(clients, comm)
+	if len(clients) == 0 {
+		delete(subscribers, channel)
+	}
+}
+
+// SUBSCRIBE channel [channel ...]
+func cmdSUBSCRIBE(comm *FDComm, args []string) []byte {
+	var res []byte
+	ps := &comm.pubsub
+	if ps.channels == nil {
+		ps.channels = map[string]struct{}{}
+	}
+	for _, channel := range args {
+		if _, ok := ps.channels[channel]; !ok {
+			ps.channels[channel] = struct{}{}
+			subscribe(pubsubChannels, comm, channel)
+		}
+		res = append(res, subscriptionReply("subscribe", channel, ps.subscriptions())...)
+	}
+	return res
+}
+
+// UNSUBSCRIBE [channel ...] unsubscribes from the channels, or from all the channels if none is given
+func cmdUNSUBSCRIBE(comm *FDComm, args []string) []byte {
+	var res []byte
+	ps := &comm.pubsub
+	if len(args) == 0 {
+		if len(ps.channels) == 0 {
+			return subscriptionReply("unsubscribe", nil, ps.subscriptions())
+		}
+		for channel := range ps.channels {
+			args = append(args, channel)
+		}
+		sort.Strings(args)
+	}
+	for _, channel := range args {
+		if _, ok := ps.channels[channel]; ok {
+			delete(ps.channels, channel)
+			unsubscribe(pubsubChannels, comm, channel)
+		}
+		res = append(res, subscriptionReply("unsubscribe", channel, ps.subscriptions())...)
+	}
+	return res
+}
+
+// PSUBSCRIBE pattern [pattern ...] subscribes to the channels matching the glob-style patterns
+func cmdPSUBSCRIBE(comm *FDComm, args []string) []byte {
+	var res []byte
+	ps := &comm.pubsub
+	if ps.patterns == nil {
+		ps.patterns = map[string]struct{}{}
+	}
+	for _, pattern := range args {
+		if _, ok := ps.patterns[pattern]; !ok {
+			ps.patterns[pattern] = struct{}{}
+			subscribe(pubsubPatterns, comm, pattern)
+		}
+		res = append(res, subscriptionReply("psubscribe", pattern, ps.subscriptions())...)
+	}
+	return res
+}
+
+// PUNSUBSCRIBE [pattern ...] unsubscribes from the patterns, or from all the patterns if none is given
+func cmdPUNSUBSCRIBE(comm *FDComm, args []string) []byte {
+	var res []byte
+	ps := &comm.pubsub
+	if len(args) == 0 {
+		if len(ps.patterns) == 0 {
+			return subscriptionReply("punsubscribe", nil, ps.subscriptions())
+		}
+		for pattern := range ps.patterns {
+			args = append(args, pattern)
+		}
+		sort.Strings(args)
+	}
+	for _, pattern := range args {
+		if _, ok := ps.patterns[pattern]; ok {
+			delete(ps.patterns, pattern)
+			unsubscribe(pubsubPatterns, comm, pattern)
+		}
+		res = append(res, subscriptionReply("punsubscribe", pattern, ps.subscriptions())...)
+	}
+	return res
+}
+
+// unsubscribeAll removes all the subscriptions of a client that is disconnected
+func (ps *pubsubState) unsubscribeAll(comm *FDComm) {
+	for channel := range ps.channels {
+		unsubscribe(pubsubChannels, comm, channel)
+	}
+	for pattern := range ps.patterns {
+		unsubscribe(pubsubPatterns, comm, pattern)
+	}
+	ps.channels, ps.patterns = nil, nil
+}
+
+// publish sends message to the subscribers of channel and of the patterns matching it, and returns the
+// number of clients that received it
+func publish(channel string, message string) int {
+	receivers := 0
+	for comm := range pubsubChannels[channel] {
+		sendMessage(comm, []interface{}{"message", channel, message})
+		receivers++
+	}
+	for pattern, clients := range pubsubPatterns {
+		if !util.GlobMatch(pattern, channel) {
+			continue
+		}
+		for comm := range clients {
+			sendMessage(comm, []interface{}{"pmessage", pattern, channel, message})
+			receivers++
+		}
+	}
+	return receivers
+}
+
+// PUBLISH channel message returns the number of clients that received the message
+func cmdPUBLISH(args []string) []byte {
+	if len(args) != 2 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PUBLISH' command"), false)
+	}
+	return Encode(publish(args[0], args[1]), false)
+}
+
+/*
+PUBSUB CHANNELS [pattern] returns the channels with at least one subscriber, matching pattern if given.
+PUBSUB NUMSUB [channel ...] returns the number of subscribers of every channel.
+PUBSUB NUMPAT returns the number of patterns subscribed to by the clients.
+*/
+func cmdPUBSUB(args []string) []byte {
+	if len(args) == 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'PUBSUB' command"), false)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "CHANNELS":
+		if len(args) > 2 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'PUBSUB CHANNELS' command"), false)
+		}
+		channels := make([]string, 0)
+		for channel := range pubsubChannels {
+			if len(args) == 1 || util.GlobMatch(args[1], channel) {
+				channels = append(channels, channel)
+			}
+		}
+		return Encode(channels, false)
+	case "NUMSUB":
+		res := make([]interface{}, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			res = append(res, channel, len(pubsubChannels[channel]))
+		}
+		return Encode(res, false)
+	case "NUMPAT":
+		if len(args) != 1 {
+			return Encode(errors.New("(error) ERR wrong number of arguments for 'PUBSUB NUMPAT' command"), false)
+		}
+		return Encode(len(pubsubPatterns), false)
+	}
+	return Encode(errors.New("(error) ERR unknown subcommand '"+args[0]+"'. Try CHANNELS, NUMSUB or NUMPAT."), false)
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+)
+
+func withPubSubHardLimit(t *testing.T, limit int) {
+	old := config.PubSubOutputBufferHardLimit
+	config.PubSubOutputBufferHardLimit = limit
+	t.Cleanup(func() {
+		config.PubSubOutputBufferHardLimit = old
+	})
+}
+
+// clientMessages decodes every reply pending in the buffer of comm
+func clientMessages(comm *FDComm) []interface{} {
+	var res []interface{}
+	data := comm.replyBuf
+	for len(data) > 0 {
+		msg, n, err := DecodeOne(data)
+		if err != nil {
+			break
+		}
+		res = append(res, msg)
+		data = data[n:]
+	}
+	comm.replyBuf = comm.replyBuf[:0]
+	return res
+}
+
+func runClientCmd(comm *FDComm, args ...string) []interface{} {
+	EvalAndResponse(&MemKVCmd{Cmd: args[0], Args: args[1:]}, comm)
+	return clientMessages(comm)
+}
+
+func TestPubSub(t *testing.T) {
+	sub := &FDComm{Fd: -1}
+	psub := &FDComm{Fd: -1}
+	t.Cleanup(func() {
+		FreeClient(sub)
+		FreeClient(psub)
+		ClientsPendingWrite()
+	})
+
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"subscribe", "news", int64(1)},
+		[]interface{}{"subscribe", "sport", int64(2)},
+	}, runClientCmd(sub, "SUBSCRIBE", "news", "sport"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"psubscribe", "n*", int64(1)},
+	}, runClientCmd(psub, "PSUBSCRIBE", "n*"))
+
+	assert.EqualValues(t, 2, evalReply("PUBLISH", "news", "hello"))
+	assert.EqualValues(t, 1, evalReply("PUBLISH", "sport", "goal"))
+	assert.EqualValues(t, 0, evalReply("PUBLISH", "weather", "rain"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"message", "news", "hello"},
+		[]interface{}{"message", "sport", "goal"},
+	}, clientMessages(sub))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"pmessage", "n*", "news", "hello"},
+	}, clientMessages(psub))
+	// both subscribers have to be flushed, once
+	assert.ElementsMatch(t, []*FDComm{sub, psub}, ClientsPendingWrite())
+	assert.Empty(t, ClientsPendingWrite())
+
+	assert.ElementsMatch(t, []interface{}{"news", "sport"}, evalReply("PUBSUB", "CHANNELS"))
+	assert.ElementsMatch(t, []interface{}{"sport"}, evalReply("PUBSUB", "CHANNELS", "s*"))
+	assert.EqualValues(t, []interface{}{"news", int64(1), "other", int64(0)}, evalReply("PUBSUB", "NUMSUB", "news", "other"))
+	assert.EqualValues(t, 1, evalReply("PUBSUB", "NUMPAT"))
+
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"unsubscribe", "news", int64(1)},
+		[]interface{}{"unsubscribe", "sport", int64(0)},
+	}, runClientCmd(sub, "UNSUBSCRIBE"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"unsubscribe", nil, int64(0)},
+	}, runClientCmd(sub, "UNSUBSCRIBE"))
+	assert.EqualValues(t, 1, evalReply("PUBLISH", "news", "hello"))
+
+	// disconnecting removes the subscriptions
+	FreeClient(psub)
+	assert.EqualValues(t, 0, evalReply("PUBLISH", "news", "hello"))
+	assert.EqualValues(t, 0, evalReply("PUBSUB", "NUMPAT"))
+}
+
+func TestSubscribedMode(t *testing.T) {
+	resetStores()
+	comm := &FDComm{Fd: -1}
+	t.Cleanup(func() { FreeClient(comm) })
+
+	runClientCmd(comm, "SUBSCRIBE", "news")
+	res := runClientCmd(comm, "GET", "k")
+	assert.Contains(t, res[0], "only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING")
+	assert.EqualValues(t, []interface{}{[]interface{}{"pong", ""}}, runClientCmd(comm, "PING"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"pong", "hi"}}, runClientCmd(comm, "PING", "hi"))
+
+	// back to normal once unsubscribed from everything
+	runClientCmd(comm, "UNSUBSCRIBE", "news")
+	assert.EqualValues(t, []interface{}{"PONG"}, runClientCmd(comm, "PING"))
+	assert.EqualValues(t, []interface{}{nil}, runClientCmd(comm, "GET", "k"))
+
+	// subscriptions can't be queued in a transaction
+	runClientCmd(comm, "MULTI")
+	assertErrorReply(t, runClientCmd(comm, "SUBSCRIBE", "news")[0])
+	assert.Contains(t, runClientCmd(comm, "EXEC")[0], "EXECABORT")
+}
+
+func TestPubSubSlowSubscriber(t *testing.T) {
+	comm := &FDComm{Fd: -1}
+	t.Cleanup(func() {
+		FreeClient(comm)
+		ClientsPendingWrite()
+	})
+	withPubSubHardLimit(t, 1024)
+
+	runClientCmd(comm, "SUBSCRIBE", "news")
+	message := string(make([]byte, 100))
+	for i := 0; i < 20; i++ {
+		evalReply("PUBLISH", "news", message)
+	}
+	// the messages are never read: the subscriber is disconnected instead of buffering them all
+	assert.True(t, comm.CloseAsap)
+	assert.Less(t, len(comm.replyBuf), 2*1024)
+}
@@ -11,24 +11,58 @@ import (
 
 const CRLF string = "\r\n"
 
+// ErrIncomplete is returned by the decoder when data ends before a full RESP value,
+// the caller should wait for more bytes from the client and try again.
+var ErrIncomplete = errors.New("incomplete RESP data")
+
+// MaxBulkLength is the largest bulk string we accept from a client, same as Redis proto-max-bulk-len
+const MaxBulkLength = 512 * 1024 * 1024
+
+// MaxMultibulkLength is the largest number of elements we accept in an array from a client, like Redis
+const MaxMultibulkLength = 1024 * 1024
+
+// findCRLF returns the position of the first '\r' of a "\r\n" starting from pos,
+// or -1 if the line is not complete yet
+func findCRLF(data []byte, pos int) int {
+	i := bytes.Index(data[pos:], []byte(CRLF))
+	if i < 0 {
+		return -1
+	}
+	return pos + i
+}
+
 // +OK\r\n => OK, 5
 func readSimpleString(data []byte) (string, int, error) {
-	pos := 1
-	for data[pos] != '\r' {
-		pos++
+	end := findCRLF(data, 1)
+	if end < 0 {
+		return "", 0, ErrIncomplete
 	}
-	return string(data[1:pos]), pos + 2, nil
+	return string(data[1:end]), end + 2, nil
 }
 
 // :123\r\n => 123
 func readInt64(data []byte) (int64, int, error) {
-	var res int64 = 0
+	end := findCRLF(data, 1)
+	if end < 0 {
+		return 0, 0, ErrIncomplete
+	}
 	pos := 1
-	for data[pos] != '\r' {
-		res = res*10 + int64(data[pos]-'0')
+	sign := int64(1)
+	if pos < end && data[pos] == '-' {
+		sign = -1
 		pos++
 	}
-	return res, pos + 2, nil
+	if pos == end {
+		return 0, 0, errors.New("ERR Protocol error: invalid integer")
+	}
+	var res int64 = 0
+	for ; pos < end; pos++ {
+		if data[pos] < '0' || data[pos] > '9' {
+			return 0, 0, errors.New("ERR Protocol error: invalid integer")
+		}
+		res = res*10 + int64(data[pos]-'0')
+	}
+	return sign * res, end + 2, nil
 }
 
 func readError(data []byte) (string, int, error) {
@@ -36,28 +70,51 @@ func readError(data []byte) (string, int, error) {
 }
 
 // $5\r\nhello\r\n => 5, 4
-func readLen(data []byte) (int, int) {
-	res, pos, _ := readInt64(data)
-	return int(res), pos
+func readLen(data []byte) (int, int, error) {
+	res, pos, err := readInt64(data)
+	return int(res), pos, err
 }
 
 // $5\r\nhello\r\n => "hello"
-func readBulkString(data []byte) (string, int, error) {
-	length, pos := readLen(data)
+func readBulkString(data []byte) (interface{}, int, error) {
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		// $-1\r\n is the null bulk string
+		return nil, pos, nil
+	}
+	if length > MaxBulkLength {
+		return nil, 0, errors.New("ERR Protocol error: invalid bulk length")
+	}
+	if len(data) < pos+length+2 {
+		return nil, 0, ErrIncomplete
+	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
-	length, pos := readLen(data)
-	var res []interface{} = make([]interface{}, length)
-
-	for i := range res {
+	length, pos, err := readLen(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 0 {
+		return nil, pos, nil
+	}
+	if length > MaxMultibulkLength {
+		return nil, 0, errors.New("ERR Protocol error: invalid multibulk length")
+	}
+	// the elements may not have arrived yet, so the array grows as they are decoded rather than being
+	// allocated at the declared length
+	res := make([]interface{}, 0, min(length, 16))
+	for len(res) < length {
 		elem, delta, err := DecodeOne(data[pos:])
 		if err != nil {
 			return nil, 0, err
 		}
-		res[i] = elem
+		res = append(res, elem)
 		pos += delta
 	}
 	return res, pos, nil
@@ -79,9 +136,11 @@ func readIntArray(data []byte) (interface{}, int, error) {
 	return res, pos, nil
 }
 
+// DecodeOne decodes the first RESP value in data and returns it with the number of bytes it occupies.
+// It returns ErrIncomplete if data holds only a prefix of a value.
 func DecodeOne(data []byte) (interface{}, int, error) {
 	if len(data) == 0 {
-		return nil, 0, errors.New("no data")
+		return nil, 0, ErrIncomplete
 	}
 	switch data[0] {
 	case '+':
@@ -97,7 +156,7 @@ func DecodeOne(data []byte) (interface{}, int, error) {
 	case '@':
 		return readIntArray(data)
 	}
-	return nil, 0, nil
+	return nil, 0, fmt.Errorf("ERR Protocol error: unexpected byte '%c'", data[0])
 }
 
 func Decode(data []byte) (interface{}, error) {
@@ -157,17 +216,80 @@ func Encode(value interface{}, isSimpleString bool) []byte {
 	}
 }
 
+// readInlineCmd parses the inline protocol used by telnet-like clients: "PING\r\n"
+func readInlineCmd(data []byte) ([]string, int, error) {
+	end := bytes.IndexByte(data, '\n')
+	if end < 0 {
+		if len(data) > MaxBulkLength {
+			return nil, 0, errors.New("ERR Protocol error: too big inline request")
+		}
+		return nil, 0, ErrIncomplete
+	}
+	return strings.Fields(string(data[:end])), end + 1, nil
+}
+
+// parseOneCmd decodes the first command in data and returns the number of bytes it occupies.
+// An empty inline line yields a nil command.
+func parseOneCmd(data []byte) (*MemKVCmd, int, error) {
+	var tokens []string
+	var pos int
+	if len(data) > 0 && data[0] != '*' {
+		var err error
+		tokens, pos, err = readInlineCmd(data)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		value, n, err := DecodeOne(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		pos = n
+		array, _ := value.([]interface{})
+		tokens = make([]string, len(array))
+		for i := range tokens {
+			token, ok := array[i].(string)
+			if !ok {
+				return nil, 0, errors.New("ERR Protocol error: expected bulk string")
+			}
+			tokens[i] = token
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, pos, nil
+	}
+	return &MemKVCmd{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, pos, nil
+}
+
 func ParseCmd(data []byte) (*MemKVCmd, error) {
-	value, err := Decode(data)
+	cmd, _, err := parseOneCmd(data)
 	if err != nil {
 		return nil, err
 	}
+	if cmd == nil {
+		return nil, errors.New("ERR Protocol error: empty command")
+	}
+	return cmd, nil
+}
 
-	array := value.([]interface{})
-	tokens := make([]string, len(array))
-	for i := range tokens {
-		tokens[i] = array[i].(string)
+// ParseCmds decodes every complete command at the head of data, in order.
+// It returns the commands and the number of bytes they occupy, the remaining bytes
+// are the beginning of a command that has not fully arrived yet.
+func ParseCmds(data []byte) ([]*MemKVCmd, int, error) {
+	var cmds []*MemKVCmd
+	pos := 0
+	for pos < len(data) {
+		cmd, n, err := parseOneCmd(data[pos:])
+		if err == ErrIncomplete {
+			break
+		}
+		if err != nil {
+			return cmds, pos, err
+		}
+		pos += n
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 	}
-	res := &MemKVCmd{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
-	return res, nil
+	return cmds, pos, nil
 }
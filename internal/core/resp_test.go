@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"memkv/internal/core"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +127,91 @@ func TestParseCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeIncomplete(t *testing.T) {
+	cases := []string{
+		"",
+		"+OK",
+		":100\r",
+		"$5\r\nhel",
+		"$5\r\nhello\r",
+		"*2\r\n$5\r\nhello\r\n",
+		"*2\r\n$5\r\nhello\r\n$5\r\nwor",
+	}
+	for _, c := range cases {
+		_, err := core.Decode([]byte(c))
+		assert.Equal(t, core.ErrIncomplete, err, c)
+	}
+}
+
+func TestDecodeNull(t *testing.T) {
+	value, err := core.Decode([]byte("$-1\r\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	value, err = core.Decode([]byte(":-42\r\n"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, -42, value)
+}
+
+func TestParseCmdsPipeline(t *testing.T) {
+	data := []byte("*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n" +
+		"*2\r\n$3\r\nget\r\n$1\r\na\r\n" +
+		"*2\r\n$3\r\nGET\r\n$1\r")
+	cmds, n, err := core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(cmds))
+	assert.EqualValues(t, "SET", cmds[0].Cmd)
+	assert.EqualValues(t, []string{"a", "1"}, cmds[0].Args)
+	assert.EqualValues(t, "GET", cmds[1].Cmd)
+	assert.EqualValues(t, []string{"a"}, cmds[1].Args)
+	assert.EqualValues(t, "*2\r\n$3\r\nGET\r\n$1\r", string(data[n:]))
+
+	// the rest of the last command arrives
+	data = append(data[n:], []byte("\nb\r\n")...)
+	cmds, n, err = core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(cmds))
+	assert.EqualValues(t, []string{"b"}, cmds[0].Args)
+	assert.EqualValues(t, len(data), n)
+}
+
+func TestParseCmdsLargeValue(t *testing.T) {
+	value := strings.Repeat("x", 100000)
+	data := []byte(fmt.Sprintf("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$%d\r\n%s\r\n", len(value), value))
+	for split := 1; split < len(data); split += 4096 {
+		cmds, n, err := core.ParseCmds(data[:split])
+		assert.Nil(t, err)
+		assert.EqualValues(t, 0, len(cmds))
+		assert.EqualValues(t, 0, n)
+	}
+	cmds, n, err := core.ParseCmds(data)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(cmds))
+	assert.EqualValues(t, value, cmds[0].Args[1])
+	assert.EqualValues(t, len(data), n)
+}
+
+func TestParseCmdsInline(t *testing.T) {
+	cmds, n, err := core.ParseCmds([]byte("ping\r\n\r\nset a  b\r\nget"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(cmds))
+	assert.EqualValues(t, "PING", cmds[0].Cmd)
+	assert.EqualValues(t, "SET", cmds[1].Cmd)
+	assert.EqualValues(t, []string{"a", "b"}, cmds[1].Args)
+	assert.EqualValues(t, 18, n)
+}
+
+func TestParseCmdsProtocolError(t *testing.T) {
+	cmds, n, err := core.ParseCmds([]byte("*1\r\n$4\r\nPING\r\n*1\r\n:12\r\n"))
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, len(cmds))
+	assert.EqualValues(t, 14, n)
+}
+
+func TestParseCmdMultibulkLength(t *testing.T) {
+	_, err := core.ParseCmd([]byte("*50000000\r\n$3\r\nSET\r\n"))
+	assert.EqualError(t, err, "ERR Protocol error: invalid multibulk length")
+	_, err = core.ParseCmd([]byte(fmt.Sprintf("*%d\r\n$3\r\nSET\r\n", core.MaxMultibulkLength)))
+	assert.Equal(t, core.ErrIncomplete, err)
+}
@@ -0,0 +1,525 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"log"
+	"math"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"memkv/internal/data_structure"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Binary snapshot of the whole dataset, written by SAVE and BGSAVE and loaded at startup.
+
+	+-------+---------+--------------------+-----+------------+-------+
+	| MEMKV | version | record | record... | EOF | CRC64 (LE) |
+	+-------+---------+--------------------+-----+------------+-------+
+	  5 bytes  uint16
+
+A record is an opcode followed by its payload. Lengths and counts are unsigned varints,
+strings are a length followed by the bytes, numbers are little-endian:
+  - EXPIRE_MS:  uint64 unix time in ms, the expiry of the key in the next record
+  - STRING:     key, value
+  - SET:        key, number of members, members
+  - LIST:       key, number of elements, elements from the head
+  - HASH:       key, number of fields, (field, value)...
+  - ZSET:       key, number of elements, (member, float64 score)...
+  - BLOOM, CUCKOO, CMS, TOPK, TDIGEST, MORRIS, STREAM, HLL: key, the structure encoded by its MarshalBinary
+The CRC64 (ECMA) covers every byte before it.
+*/
+
+const snapshotMagic = "MEMKV"
+
+// snapshotVersion is bumped on every incompatible change of the format, we refuse to load newer versions
+const snapshotVersion uint16 = 1
+
+const (
+	snapshotOpString   byte = 0
+	snapshotOpSet      byte = 1
+	snapshotOpZSet     byte = 2
+	snapshotOpBloom    byte = 3
+	snapshotOpCMS      byte = 4
+	snapshotOpList     byte = 5
+	snapshotOpHash     byte = 6
+	snapshotOpStream   byte = 7
+	snapshotOpHLL      byte = 8
+	snapshotOpCuckoo   byte = 9
+	snapshotOpTopK     byte = 10
+	snapshotOpTDigest  byte = 11
+	snapshotOpMorris   byte = 12
+	snapshotOpExpireMs byte = 0xfc
+	snapshotOpEOF      byte = 0xff
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// bgsaveInProgress is 1 while a BGSAVE is writing its file
+var bgsaveInProgress int32 = 0
+
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash64
+	buf []byte
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	crc := crc64.New(crc64Table)
+	return &snapshotWriter{
+		w:   bufio.NewWriter(io.MultiWriter(w, crc)),
+		crc: crc,
+	}
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	sw.w.Write(b)
+}
+
+func (sw *snapshotWriter) writeByte(b byte) {
+	sw.w.WriteByte(b)
+}
+
+func (sw *snapshotWriter) writeUvarint(v uint64) {
+	sw.buf = binary.AppendUvarint(sw.buf[:0], v)
+	sw.w.Write(sw.buf)
+}
+
+func (sw *snapshotWriter) writeUint64(v uint64) {
+	sw.buf = binary.LittleEndian.AppendUint64(sw.buf[:0], v)
+	sw.w.Write(sw.buf)
+}
+
+func (sw *snapshotWriter) writeString(s string) {
+	sw.writeUvarint(uint64(len(s)))
+	sw.w.WriteString(s)
+}
+
+// finish writes the EOF opcode and the checksum of everything written before
+func (sw *snapshotWriter) finish() error {
+	sw.writeByte(snapshotOpEOF)
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	sw.buf = binary.LittleEndian.AppendUint64(sw.buf[:0], sw.crc.Sum64())
+	_, err := sw.w.Write(sw.buf)
+	if err != nil {
+		return err
+	}
+	return sw.w.Flush()
+}
+
+// writeValue writes the record of a key
+func (sw *snapshotWriter) writeValue(key string, obj *data_structure.Obj) {
+	switch getType(obj.TypeEncoding) {
+	case constant.ObjTypeString:
+		sw.writeByte(snapshotOpString)
+		sw.writeString(key)
+		sw.writeString(obj.Value.(string))
+	case constant.ObjTypeSet:
+		set := obj.Value.(data_structure.Set)
+		sw.writeByte(snapshotOpSet)
+		sw.writeString(key)
+		sw.writeUvarint(uint64(set.Size()))
+		for _, m := range set.Members() {
+			sw.writeString(m)
+		}
+	case constant.ObjTypeList:
+		list := obj.Value.(*data_structure.QuickList)
+		sw.writeByte(snapshotOpList)
+		sw.writeString(key)
+		sw.writeUvarint(uint64(list.Len()))
+		list.ForEach(func(value string) {
+			sw.writeString(value)
+		})
+	case constant.ObjTypeHash:
+		hash := obj.Value.(*data_structure.Hash)
+		sw.writeByte(snapshotOpHash)
+		sw.writeString(key)
+		sw.writeUvarint(uint64(hash.Len()))
+		hash.ForEach(func(field string, value string) {
+			sw.writeString(field)
+			sw.writeString(value)
+		})
+	case constant.ObjTypeZSet:
+		zset := obj.Value.(*data_structure.ZSet)
+		sw.writeByte(snapshotOpZSet)
+		sw.writeString(key)
+		sw.writeUvarint(uint64(zset.Len()))
+		zset.ForEach(func(ele string, score float64) {
+			sw.writeString(ele)
+			sw.writeUint64(math.Float64bits(score))
+		})
+	case constant.ObjTypeStream:
+		data, _ := obj.Value.(*data_structure.Stream).MarshalBinary()
+		sw.writeByte(snapshotOpStream)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeBloom:
+		data, _ := obj.Value.(*data_structure.SBChain).MarshalBinary()
+		sw.writeByte(snapshotOpBloom)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeHLL:
+		data, _ := obj.Value.(*data_structure.HyperLogLog).MarshalBinary()
+		sw.writeByte(snapshotOpHLL)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeCuckoo:
+		data, _ := obj.Value.(*data_structure.CuckooFilter).MarshalBinary()
+		sw.writeByte(snapshotOpCuckoo)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeTopK:
+		data, _ := obj.Value.(*data_structure.TopK).MarshalBinary()
+		sw.writeByte(snapshotOpTopK)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeTDigest:
+		data, _ := obj.Value.(*data_structure.TDigest).MarshalBinary()
+		sw.writeByte(snapshotOpTDigest)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeMorris:
+		data, _ := obj.Value.(*data_structure.MorrisCounter).MarshalBinary()
+		sw.writeByte(snapshotOpMorris)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	case constant.ObjTypeCMS:
+		data, _ := obj.Value.(*data_structure.CMS).MarshalBinary()
+		sw.writeByte(snapshotOpCMS)
+		sw.writeString(key)
+		sw.writeString(string(data))
+	}
+}
+
+// writeSnapshot encodes the whole dataset to w
+func writeSnapshot(w io.Writer) error {
+	sw := newSnapshotWriter(w)
+	sw.write([]byte(snapshotMagic))
+	sw.write(binary.LittleEndian.AppendUint16(nil, snapshotVersion))
+
+	dictStore.ForEach(func(key string, obj *data_structure.Obj) {
+		if exp, ok := dictStore.GetExpiry(obj); ok {
+			sw.writeByte(snapshotOpExpireMs)
+			sw.writeUint64(exp)
+		}
+		sw.writeValue(key, obj)
+	})
+	return sw.finish()
+}
+
+var errBadSnapshot = errors.New("bad snapshot format")
+
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash64
+	err error
+	one [1]byte
+}
+
+func (sr *snapshotReader) read(n uint64) []byte {
+	if sr.err != nil {
+		return nil
+	}
+	// don't trust a corrupted length to allocate a huge buffer
+	if n > MaxBulkLength {
+		sr.err = errBadSnapshot
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		sr.err = errBadSnapshot
+		return nil
+	}
+	sr.crc.Write(b)
+	return b
+}
+
+func (sr *snapshotReader) readByte() byte {
+	if sr.err != nil {
+		return snapshotOpEOF
+	}
+	b, err := sr.r.ReadByte()
+	if err != nil {
+		sr.err = errBadSnapshot
+		return snapshotOpEOF
+	}
+	sr.one[0] = b
+	sr.crc.Write(sr.one[:])
+	return b
+}
+
+func (sr *snapshotReader) readUvarint() uint64 {
+	var v uint64
+	for shift := 0; shift < 64 && sr.err == nil; shift += 7 {
+		b := sr.readByte()
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+	sr.err = errBadSnapshot
+	return 0
+}
+
+func (sr *snapshotReader) readUint64() uint64 {
+	b := sr.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (sr *snapshotReader) readString() string {
+	return string(sr.read(sr.readUvarint()))
+}
+
+// readValue reads the value of a record of type op
+func (sr *snapshotReader) readValue(op byte, key string) (*data_structure.Obj, error) {
+	switch op {
+	case snapshotOpString:
+		value := sr.readString()
+		oType, oEnc := deduceTypeString(value)
+		return dictStore.NewObj(value, constant.NoExpire, oType, oEnc), nil
+	case snapshotOpSet:
+		set := data_structure.CreateSet(key)
+		n := sr.readUvarint()
+		for i := uint64(0); i < n && sr.err == nil; i++ {
+			set.Add(sr.readString())
+		}
+		return dictStore.NewObj(set, constant.NoExpire, constant.ObjTypeSet, set.Encoding()), nil
+	case snapshotOpList:
+		list := data_structure.CreateQuickList()
+		n := sr.readUvarint()
+		for i := uint64(0); i < n && sr.err == nil; i++ {
+			list.Push(data_structure.ListTail, sr.readString())
+		}
+		return dictStore.NewObj(list, constant.NoExpire, constant.ObjTypeList, constant.ObjEncodingQuickList), nil
+	case snapshotOpHash:
+		hash := data_structure.CreateHash()
+		n := sr.readUvarint()
+		for i := uint64(0); i < n && sr.err == nil; i++ {
+			field := sr.readString()
+			hash.Set(field, sr.readString())
+		}
+		return dictStore.NewObj(hash, constant.NoExpire, constant.ObjTypeHash, hash.Encoding()), nil
+	case snapshotOpZSet:
+		zset := data_structure.CreateZSet()
+		n := sr.readUvarint()
+		for i := uint64(0); i < n && sr.err == nil; i++ {
+			ele := sr.readString()
+			zset.Add(math.Float64frombits(sr.readUint64()), ele, 0)
+		}
+		return dictStore.NewObj(zset, constant.NoExpire, constant.ObjTypeZSet, constant.ObjEncodingSkiplist), nil
+	case snapshotOpStream:
+		stream := &data_structure.Stream{}
+		if err := stream.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(stream, constant.NoExpire, constant.ObjTypeStream, constant.ObjEncodingStream), nil
+	case snapshotOpBloom:
+		sb := &data_structure.SBChain{}
+		if err := sb.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(sb, constant.NoExpire, constant.ObjTypeBloom, constant.ObjEncodingRaw), nil
+	case snapshotOpHLL:
+		hll := &data_structure.HyperLogLog{}
+		if err := hll.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(hll, constant.NoExpire, constant.ObjTypeHLL, constant.ObjEncodingRaw), nil
+	case snapshotOpCuckoo:
+		cf := &data_structure.CuckooFilter{}
+		if err := cf.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(cf, constant.NoExpire, constant.ObjTypeCuckoo, constant.ObjEncodingRaw), nil
+	case snapshotOpTopK:
+		topk := &data_structure.TopK{}
+		if err := topk.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(topk, constant.NoExpire, constant.ObjTypeTopK, constant.ObjEncodingRaw), nil
+	case snapshotOpTDigest:
+		td := &data_structure.TDigest{}
+		if err := td.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(td, constant.NoExpire, constant.ObjTypeTDigest, constant.ObjEncodingRaw), nil
+	case snapshotOpMorris:
+		m := &data_structure.MorrisCounter{}
+		if err := m.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(m, constant.NoExpire, constant.ObjTypeMorris, constant.ObjEncodingRaw), nil
+	case snapshotOpCMS:
+		cms := &data_structure.CMS{}
+		if err := cms.UnmarshalBinary(sr.read(sr.readUvarint())); err != nil && sr.err == nil {
+			sr.err = err
+		}
+		return dictStore.NewObj(cms, constant.NoExpire, constant.ObjTypeCMS, constant.ObjEncodingRaw), nil
+	}
+	return nil, fmt.Errorf("unknown snapshot opcode %d", op)
+}
+
+// readSnapshot loads the dataset encoded by writeSnapshot into dictStore. Keys that expired
+// since the snapshot was taken are skipped.
+func readSnapshot(r io.Reader) (int, error) {
+	sr := &snapshotReader{
+		r:   bufio.NewReader(r),
+		crc: crc64.New(crc64Table),
+	}
+	if string(sr.read(uint64(len(snapshotMagic)))) != snapshotMagic {
+		return 0, errBadSnapshot
+	}
+	version := binary.LittleEndian.Uint16(append(sr.read(2), 0, 0))
+	if sr.err == nil && version > snapshotVersion {
+		return 0, fmt.Errorf("can't load snapshot version %d, the newest supported version is %d", version, snapshotVersion)
+	}
+
+	now := uint64(time.Now().UnixMilli())
+	loaded := 0
+	var expMs uint64 = 0
+	for sr.err == nil {
+		op := sr.readByte()
+		if op == snapshotOpEOF {
+			break
+		}
+		if op == snapshotOpExpireMs {
+			expMs = sr.readUint64()
+			continue
+		}
+		key := sr.readString()
+		obj, err := sr.readValue(op, key)
+		if err != nil {
+			return loaded, err
+		}
+		if sr.err != nil {
+			break
+		}
+		if expMs != 0 && expMs <= now {
+			// expired since the snapshot was taken
+			expMs = 0
+			continue
+		}
+		if expMs != 0 {
+			dictStore.SetExpiryAt(obj, expMs)
+		}
+		dictStore.Put(key, obj)
+		expMs = 0
+		loaded++
+	}
+	if sr.err != nil {
+		return loaded, sr.err
+	}
+	expected := sr.crc.Sum64()
+	var checksum [8]byte
+	if _, err := io.ReadFull(sr.r, checksum[:]); err != nil {
+		return loaded, errBadSnapshot
+	}
+	if binary.LittleEndian.Uint64(checksum[:]) != expected {
+		return loaded, errors.New("wrong snapshot checksum")
+	}
+	return loaded, nil
+}
+
+// saveSnapshot writes data to a temporary file, which atomically replaces the snapshot once it's on disk
+func saveSnapshot(write func(f *os.File) error) error {
+	tmpName := filepath.Join(filepath.Dir(config.SnapshotFileName), fmt.Sprintf("temp-%d.snapshot", os.Getpid()))
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, config.SnapshotFileName)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
+
+// LoadSnapshot loads the snapshot file into the dataset, a missing file means an empty dataset
+func LoadSnapshot() error {
+	f, err := os.Open(config.SnapshotFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	start := time.Now()
+	loaded, err := readSnapshot(f)
+	if err != nil {
+		return fmt.Errorf("can't load the snapshot %s: %v", config.SnapshotFileName, err)
+	}
+	log.Printf("loaded %d keys from the snapshot in %v", loaded, time.Since(start))
+	return nil
+}
+
+// SAVE writes the snapshot synchronously, blocking every client until it's done
+func cmdSAVE(args []string) []byte {
+	if len(args) != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'SAVE' command"), false)
+	}
+	if atomic.LoadInt32(&bgsaveInProgress) == 1 {
+		return Encode(errors.New("(error) ERR Background save already in progress"), false)
+	}
+	err := saveSnapshot(func(f *os.File) error {
+		return writeSnapshot(f)
+	})
+	if err != nil {
+		log.Println("can't save the snapshot:", err)
+		return Encode(fmt.Errorf("(error) ERR %v", err), false)
+	}
+	return constant.RespOk
+}
+
+/*
+BGSAVE writes the snapshot in the background. Like BGREWRITEAOF, the dataset is encoded in memory by the
+event loop, which blocks every client for a time that grows with the size of the dataset, then a goroutine
+writes it to disk while the server keeps serving commands.
+*/
+func cmdBGSAVE(args []string) []byte {
+	if len(args) != 0 {
+		return Encode(errors.New("(error) ERR wrong number of arguments for 'BGSAVE' command"), false)
+	}
+	if !atomic.CompareAndSwapInt32(&bgsaveInProgress, 0, 1) {
+		return Encode(errors.New("(error) ERR Background save already in progress"), false)
+	}
+	var buf bytes.Buffer
+	writeSnapshot(&buf)
+	go func() {
+		defer atomic.StoreInt32(&bgsaveInProgress, 0)
+		start := time.Now()
+		err := saveSnapshot(func(f *os.File) error {
+			_, err := buf.WriteTo(f)
+			return err
+		})
+		if err != nil {
+			log.Println("background saving failed:", err)
+			return
+		}
+		log.Printf("background saving terminated with success in %v", time.Since(start))
+	}()
+	return Encode("Background saving started", true)
+}
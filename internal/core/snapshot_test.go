@@ -0,0 +1,120 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+	"memkv/internal/constant"
+)
+
+func TestSnapshotSaveAndLoad(t *testing.T) {
+	resetStores()
+	config.SnapshotFileName = filepath.Join(t.TempDir(), "test.snapshot")
+
+	for i := 0; i < 100; i++ {
+		evalCmd("SADD", "set", strconv.Itoa(i))
+		evalCmd("ZADD", "zset", strconv.Itoa(i)+".5", strconv.Itoa(i))
+		evalCmd("RPUSH", "list", strconv.Itoa(i))
+	}
+	evalCmd("HSET", "user:1", "name", "alice", "age", "30")
+	evalCmd("EXPIRE", "set", "100")
+	evalCmd("SET", "k", "v", "EX", "100")
+	evalCmd("SET", "counter", "42")
+	evalCmd("SET", "expired", "v")
+	evalCmd("PEXPIREAT", "expired", strconv.FormatInt(time.Now().UnixMilli()+50, 10))
+	evalCmd("BF.MADD", "bf", "a", "b")
+	evalCmd("CMS.INITBYDIM", "cms", "10", "2")
+	evalCmd("CMS.INCRBY", "cms", "item", "3")
+	evalCmd("PFADD", "hll", "a", "b", "c")
+	evalCmd("CF.ADD", "cf", "a")
+	evalCmd("TOPK.RESERVE", "topk", "3")
+	evalCmd("TOPK.INCRBY", "topk", "a", "2")
+	evalCmd("TDIGEST.CREATE", "td")
+	evalCmd("TDIGEST.ADD", "td", "1", "2", "3")
+	evalCmd("MORRIS.CREATE", "morris", "BASE", "2")
+	evalCmd("MORRIS.INCRBY", "morris", "1")
+	evalCmd("XADD", "stream", "1", "f", "v")
+	evalCmd("XGROUP", "CREATE", "stream", "g", "0")
+	evalCmd("XREADGROUP", "GROUP", "g", "alice", "STREAMS", "stream", ">")
+	assert.EqualValues(t, constant.RespOk, cmdSAVE([]string{}))
+	time.Sleep(60 * time.Millisecond)
+
+	resetStores()
+	assert.Nil(t, LoadSnapshot())
+	obj := dictStore.Get("k")
+	assert.EqualValues(t, "v", obj.Value)
+	_, hasExpiry := dictStore.GetExpiry(obj)
+	assert.True(t, hasExpiry)
+	assert.EqualValues(t, "42", dictStore.Get("counter").Value)
+	assert.Nil(t, dictStore.Get("expired"))
+	assert.EqualValues(t, 100, testSet("set").Size())
+	_, hasExpiry = dictStore.GetExpiry(dictStore.Get("set"))
+	assert.True(t, hasExpiry)
+	assert.EqualValues(t, 100, testZSet("zset").Len())
+	_, score := testZSet("zset").GetScore("42")
+	assert.EqualValues(t, 42.5, score)
+	assert.EqualValues(t, 100, testList("list").Len())
+	assert.EqualValues(t, []string{"98", "99"}, testList("list").Range(-2, -1))
+	name, _ := testHash("user:1").Get("name")
+	assert.EqualValues(t, "alice", name)
+	assert.EqualValues(t, constant.ObjTypeHash|constant.ObjEncodingListpack, dictStore.Get("user:1").TypeEncoding)
+	assert.True(t, testSBChain("bf").Exist("a"))
+	assert.False(t, testSBChain("bf").Exist("c"))
+	assert.EqualValues(t, 3, testCMS("cms").Count("item"))
+	assert.EqualValues(t, 3, testHyperLogLog("hll").Count())
+	assert.True(t, testCuckooFilter("cf").Exist("a"))
+	assert.EqualValues(t, 2, testTopK("topk").Count("a"))
+	assert.EqualValues(t, 3, testTopK("topk").GetK())
+	assert.EqualValues(t, 2, testTDigest("td").Quantile(0.5))
+	assert.EqualValues(t, 1, testMorrisCounter("morris").Estimate())
+	assert.EqualValues(t, 2, testMorrisCounter("morris").GetBase())
+	assert.EqualValues(t, 1, testStream("stream").Len())
+	assert.EqualValues(t, 1, testStream("stream").Group("g").PendingLen())
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	resetStores()
+	config.SnapshotFileName = filepath.Join(t.TempDir(), "test.snapshot")
+	evalCmd("SET", "key", "value")
+	assert.EqualValues(t, constant.RespOk, cmdSAVE([]string{}))
+
+	data, err := os.ReadFile(config.SnapshotFileName)
+	assert.Nil(t, err)
+	data[len(data)-12] ^= 0xff
+	assert.Nil(t, os.WriteFile(config.SnapshotFileName, data, 0644))
+	resetStores()
+	assert.NotNil(t, LoadSnapshot())
+
+	// truncated file
+	assert.Nil(t, os.WriteFile(config.SnapshotFileName, data[:len(data)/2], 0644))
+	resetStores()
+	assert.NotNil(t, LoadSnapshot())
+}
+
+func TestBGSAVE(t *testing.T) {
+	resetStores()
+	config.SnapshotFileName = filepath.Join(t.TempDir(), "test.snapshot")
+	evalCmd("SET", "key", "value")
+
+	res, err := Decode(cmdBGSAVE([]string{}))
+	assert.Nil(t, err)
+	assert.EqualValues(t, "Background saving started", res)
+	// not part of the snapshot
+	evalCmd("SET", "after", "value")
+	for i := 0; i < 100 && atomic.LoadInt32(&bgsaveInProgress) == 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.EqualValues(t, 0, atomic.LoadInt32(&bgsaveInProgress))
+
+	resetStores()
+	assert.Nil(t, LoadSnapshot())
+	assert.EqualValues(t, "value", dictStore.Get("key").Value)
+	assert.Nil(t, dictStore.Get("after"))
+}
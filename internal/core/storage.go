@@ -1,19 +1,199 @@
 package core
 
 import (
+	"errors"
+	"memkv/internal/constant"
 	"memkv/internal/data_structure"
 )
 
-var zsetStore map[string]*data_structure.ZSet
-var setStore map[string]data_structure.Set
+// dictStore is the keyspace: every value is an Obj whose TypeEncoding tells its type
 var dictStore *data_structure.Dict
-var sbStore map[string]*data_structure.SBChain
-var cmsStore map[string]*data_structure.CMS
 
 func init() {
-	zsetStore = make(map[string]*data_structure.ZSet)
-	setStore = make(map[string]data_structure.Set)
-	dictStore = data_structure.CreateDict()
-	sbStore = make(map[string]*data_structure.SBChain)
-	cmsStore = make(map[string]*data_structure.CMS)
+	dictStore = createDictStore()
+}
+
+func createDictStore() *data_structure.Dict {
+	d := data_structure.CreateDict()
+	d.OnEvict = onKeyEvicted
+	d.OnExpire = onKeyExpired
+	d.OnNewKey = onNewKey
+	return d
+}
+
+var errWrongType = errors.New("(error) WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// lookupKey returns the obj of key, nil if the key doesn't exist, or errWrongType if it holds another type
+func lookupKey(key string, oType uint8) (*data_structure.Obj, error) {
+	obj := dictStore.Get(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if getType(obj.TypeEncoding) != oType {
+		return nil, errWrongType
+	}
+	return obj, nil
+}
+
+func getSet(key string) (data_structure.Set, error) {
+	obj, err := lookupKey(key, constant.ObjTypeSet)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(data_structure.Set), nil
+}
+
+func getZSet(key string) (*data_structure.ZSet, error) {
+	obj, err := lookupKey(key, constant.ObjTypeZSet)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.ZSet), nil
+}
+
+func getList(key string) (*data_structure.QuickList, error) {
+	obj, err := lookupKey(key, constant.ObjTypeList)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.QuickList), nil
+}
+
+func getHash(key string) (*data_structure.Hash, error) {
+	obj, err := lookupKey(key, constant.ObjTypeHash)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Hash), nil
+}
+
+func getStream(key string) (*data_structure.Stream, error) {
+	obj, err := lookupKey(key, constant.ObjTypeStream)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Stream), nil
+}
+
+func getSBChain(key string) (*data_structure.SBChain, error) {
+	obj, err := lookupKey(key, constant.ObjTypeBloom)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.SBChain), nil
+}
+
+func getHyperLogLog(key string) (*data_structure.HyperLogLog, error) {
+	obj, err := lookupKey(key, constant.ObjTypeHLL)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.HyperLogLog), nil
+}
+
+func getCuckooFilter(key string) (*data_structure.CuckooFilter, error) {
+	obj, err := lookupKey(key, constant.ObjTypeCuckoo)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.CuckooFilter), nil
+}
+
+func getTopK(key string) (*data_structure.TopK, error) {
+	obj, err := lookupKey(key, constant.ObjTypeTopK)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.TopK), nil
+}
+
+func getTDigest(key string) (*data_structure.TDigest, error) {
+	obj, err := lookupKey(key, constant.ObjTypeTDigest)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.TDigest), nil
+}
+
+func getMorrisCounter(key string) (*data_structure.MorrisCounter, error) {
+	obj, err := lookupKey(key, constant.ObjTypeMorris)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.MorrisCounter), nil
+}
+
+func getCMS(key string) (*data_structure.CMS, error) {
+	obj, err := lookupKey(key, constant.ObjTypeCMS)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.CMS), nil
+}
+
+// putValue stores value under key, replacing the previous value of any type and its expiry
+func putValue(key string, value interface{}, oType uint8, oEnc uint8) {
+	dictStore.Put(key, dictStore.NewObj(value, constant.NoExpire, oType, oEnc))
+}
+
+func putSet(key string, set data_structure.Set) {
+	putValue(key, set, constant.ObjTypeSet, set.Encoding())
+}
+
+// syncSetEncoding records the encoding of the set of key, which changes when the set grows
+func syncSetEncoding(key string, set data_structure.Set) {
+	if obj := dictStore.Get(key); obj != nil {
+		obj.TypeEncoding = constant.ObjTypeSet | set.Encoding()
+	}
+}
+
+func putZSet(key string, zset *data_structure.ZSet) {
+	putValue(key, zset, constant.ObjTypeZSet, constant.ObjEncodingSkiplist)
+}
+
+func putList(key string, list *data_structure.QuickList) {
+	putValue(key, list, constant.ObjTypeList, constant.ObjEncodingQuickList)
+}
+
+func putHash(key string, hash *data_structure.Hash) {
+	putValue(key, hash, constant.ObjTypeHash, hash.Encoding())
+}
+
+// syncHashEncoding records the encoding of the hash of key, which changes when the hash grows
+func syncHashEncoding(key string, hash *data_structure.Hash) {
+	if obj := dictStore.Get(key); obj != nil {
+		obj.TypeEncoding = constant.ObjTypeHash | hash.Encoding()
+	}
+}
+
+func putStream(key string, stream *data_structure.Stream) {
+	putValue(key, stream, constant.ObjTypeStream, constant.ObjEncodingStream)
+}
+
+func putSBChain(key string, sb *data_structure.SBChain) {
+	putValue(key, sb, constant.ObjTypeBloom, constant.ObjEncodingRaw)
+}
+
+func putHyperLogLog(key string, hll *data_structure.HyperLogLog) {
+	putValue(key, hll, constant.ObjTypeHLL, constant.ObjEncodingRaw)
+}
+
+func putCuckooFilter(key string, cf *data_structure.CuckooFilter) {
+	putValue(key, cf, constant.ObjTypeCuckoo, constant.ObjEncodingRaw)
+}
+
+func putTopK(key string, topk *data_structure.TopK) {
+	putValue(key, topk, constant.ObjTypeTopK, constant.ObjEncodingRaw)
+}
+
+func putTDigest(key string, td *data_structure.TDigest) {
+	putValue(key, td, constant.ObjTypeTDigest, constant.ObjEncodingRaw)
+}
+
+func putMorrisCounter(key string, m *data_structure.MorrisCounter) {
+	putValue(key, m, constant.ObjTypeMorris, constant.ObjEncodingRaw)
+}
+
+func putCMS(key string, cms *data_structure.CMS) {
+	putValue(key, cms, constant.ObjTypeCMS, constant.ObjEncodingRaw)
 }
@@ -15,7 +15,7 @@ func getEncoding(te uint8) uint8 {
 
 func assertType(te uint8, t uint8) error {
 	if getType(te) != t {
-		return errors.New(fmt.Sprintf("operation is not permitted on type %d", getType(te)))
+		return errWrongType
 	}
 	return nil
 }
@@ -0,0 +1,88 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Helpers for the MarshalBinary/UnmarshalBinary methods of the data structures.
+// All fields are written in little-endian order.
+
+var ErrCorruptedPayload = errors.New("corrupted payload")
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendFloat64(b []byte, v float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+// appendString appends the length of s as an uint32, followed by its bytes
+func appendString(b []byte, s string) []byte {
+	b = appendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// payloadReader reads the fields back in the order they were appended. Once a read runs past the end
+// of the payload every following read returns zero, and err reports the corruption.
+type payloadReader struct {
+	data []byte
+	err  error
+}
+
+func (r *payloadReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.data) < n {
+		r.err = ErrCorruptedPayload
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *payloadReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *payloadReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *payloadReader) float64() float64 {
+	return math.Float64frombits(r.uint64())
+}
+
+// bytes returns a copy of the next n bytes
+func (r *payloadReader) bytes(n uint64) []byte {
+	if n > uint64(len(r.data)) {
+		r.err = ErrCorruptedPayload
+		return nil
+	}
+	return append([]byte(nil), r.next(int(n))...)
+}
+
+func (r *payloadReader) string() string {
+	return string(r.bytes(uint64(r.uint32())))
+}
+
+// done returns the first error met, or an error if some bytes were not consumed
+func (r *payloadReader) done() error {
+	if r.err == nil && len(r.data) > 0 {
+		return ErrCorruptedPayload
+	}
+	return r.err
+}
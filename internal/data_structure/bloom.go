@@ -40,19 +40,26 @@ func CreateBloomFilter(entries uint64, errorRate float64) *Bloom {
 		Entries: entries,
 		Error:   errorRate,
 	}
-	bloom.bitPerEntry = calcBpe(errorRate)
-	bits := uint64(float64(entries) * bloom.bitPerEntry)
-	if bits%64 != 0 {
-		bloom.bytes = ((bits / 64) + 1) * 8
-	} else {
-		bloom.bytes = bits / 8
-	}
+	bloom.bitPerEntry, bloom.bytes, bloom.Hashes = bloomParams(entries, errorRate)
 	bloom.bits = bloom.bytes * 8
-	bloom.Hashes = int(math.Ceil(Ln2 * bloom.bitPerEntry))
 	bloom.bf = make([]uint8, bloom.bytes)
 	return &bloom
 }
 
+// bloomParams returns the bits per entry, the size in bytes, rounded up to a multiple of 8 and at least 8,
+// and the number of hashes of a filter for entries items with errorRate
+func bloomParams(entries uint64, errorRate float64) (float64, uint64, int) {
+	bitPerEntry := calcBpe(errorRate)
+	bits := uint64(float64(entries) * bitPerEntry)
+	var bytes uint64
+	if bits%64 != 0 || bits == 0 {
+		bytes = ((bits / 64) + 1) * 8
+	} else {
+		bytes = bits / 8
+	}
+	return bitPerEntry, bytes, int(math.Ceil(Ln2 * bitPerEntry))
+}
+
 func (b *Bloom) CalcHash(entry string) HashValue {
 	hasher := murmur3.New128WithSeed(ABigSeed)
 	hasher.Write([]byte(entry))
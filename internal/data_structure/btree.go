@@ -0,0 +1,352 @@
+package data_structure
+
+/*
+BTree is an ordered map, a B-tree whose nodes hold between bTreeMinItems and bTreeMaxItems items, except the
+root. Lookups, inserts and deletes are O(log n) and the items of a node are contiguous, which makes it compact
+and fast to iterate in both directions from any key. Keys are ordered by the compare function of the tree.
+The insert and delete algorithms are the usual single pass ones: full nodes are split on the way down an
+insert, and nodes with the minimum number of items are grown, from a sibling or by merging, on the way
+down a delete, so that no node ever needs to be fixed on the way back up.
+*/
+
+const (
+	bTreeDegree   = 16
+	bTreeMaxItems = 2*bTreeDegree - 1
+	bTreeMinItems = bTreeDegree - 1
+)
+
+type bTreeItem[K any, V any] struct {
+	key   K
+	value V
+}
+
+type bTreeNode[K any, V any] struct {
+	items []bTreeItem[K, V]
+	// children is empty for a leaf, otherwise it has len(items)+1 nodes: children[i] holds the keys
+	// lower than items[i].key
+	children []*bTreeNode[K, V]
+}
+
+type BTree[K any, V any] struct {
+	root    *bTreeNode[K, V]
+	length  int
+	compare func(a, b K) int
+}
+
+// CreateBTree returns an empty tree ordered by compare, which returns a negative number if a < b,
+// 0 if a == b and a positive number if a > b
+func CreateBTree[K any, V any](compare func(a, b K) int) *BTree[K, V] {
+	return &BTree[K, V]{compare: compare}
+}
+
+func (t *BTree[K, V]) Len() int {
+	return t.length
+}
+
+// find returns the position of the first item of n whose key is >= key, and whether it is equal
+func (n *bTreeNode[K, V]) find(key K, compare func(a, b K) int) (int, bool) {
+	lo, hi := 0, len(n.items)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compare(n.items[mid].key, key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.items) && compare(n.items[lo].key, key) == 0
+}
+
+func (n *bTreeNode[K, V]) leaf() bool {
+	return len(n.children) == 0
+}
+
+// split moves the items after position i, and their children, to a new node, and returns the item at i
+// and the new node
+func (n *bTreeNode[K, V]) split(i int) (bTreeItem[K, V], *bTreeNode[K, V]) {
+	item := n.items[i]
+	next := &bTreeNode[K, V]{items: append([]bTreeItem[K, V](nil), n.items[i+1:]...)}
+	clear(n.items[i:])
+	n.items = n.items[:i]
+	if !n.leaf() {
+		next.children = append([]*bTreeNode[K, V](nil), n.children[i+1:]...)
+		clear(n.children[i+1:])
+		n.children = n.children[:i+1]
+	}
+	return item, next
+}
+
+func (t *BTree[K, V]) Get(key K) (V, bool) {
+	n := t.root
+	for n != nil {
+		i, found := n.find(key, t.compare)
+		if found {
+			return n.items[i].value, true
+		}
+		if n.leaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	var zero V
+	return zero, false
+}
+
+// Set sets the value of key, and returns true if the key is new
+func (t *BTree[K, V]) Set(key K, value V) bool {
+	item := bTreeItem[K, V]{key: key, value: value}
+	if t.root == nil {
+		t.root = &bTreeNode[K, V]{items: []bTreeItem[K, V]{item}}
+		t.length++
+		return true
+	}
+	if len(t.root.items) >= bTreeMaxItems {
+		mid, next := t.root.split(bTreeMaxItems / 2)
+		t.root = &bTreeNode[K, V]{
+			items:    []bTreeItem[K, V]{mid},
+			children: []*bTreeNode[K, V]{t.root, next},
+		}
+	}
+	added := t.root.insert(item, t.compare)
+	if added {
+		t.length++
+	}
+	return added
+}
+
+// insert adds item to the subtree of n, which is not full, or replaces the value of its key
+func (n *bTreeNode[K, V]) insert(item bTreeItem[K, V], compare func(a, b K) int) bool {
+	i, found := n.find(item.key, compare)
+	if found {
+		n.items[i].value = item.value
+		return false
+	}
+	if n.leaf() {
+		n.items = insertAt(n.items, i, item)
+		return true
+	}
+	if len(n.children[i].items) >= bTreeMaxItems {
+		mid, next := n.children[i].split(bTreeMaxItems / 2)
+		n.items = insertAt(n.items, i, mid)
+		n.children = insertAt(n.children, i+1, next)
+		switch c := compare(item.key, mid.key); {
+		case c == 0:
+			n.items[i].value = item.value
+			return false
+		case c > 0:
+			i++
+		}
+	}
+	return n.children[i].insert(item, compare)
+}
+
+// Delete deletes key and returns its value, and true if it existed
+func (t *BTree[K, V]) Delete(key K) (V, bool) {
+	var zero V
+	if t.root == nil {
+		return zero, false
+	}
+	item, ok := t.root.remove(key, false, t.compare)
+	if len(t.root.items) == 0 {
+		if t.root.leaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+	if !ok {
+		return zero, false
+	}
+	t.length--
+	return item.value, true
+}
+
+// remove deletes key, or the greatest key if greatest is true, from the subtree of n, whose nodes other than
+// the root have more than bTreeMinItems items
+func (n *bTreeNode[K, V]) remove(key K, greatest bool, compare func(a, b K) int) (bTreeItem[K, V], bool) {
+	var i int
+	var found bool
+	if greatest {
+		i = len(n.items)
+		if n.leaf() {
+			item := n.items[i-1]
+			n.items = removeAt(n.items, i-1)
+			return item, true
+		}
+	} else {
+		i, found = n.find(key, compare)
+		if n.leaf() {
+			if !found {
+				return bTreeItem[K, V]{}, false
+			}
+			item := n.items[i]
+			n.items = removeAt(n.items, i)
+			return item, true
+		}
+	}
+	if len(n.children[i].items) <= bTreeMinItems {
+		n.growChild(i)
+		// the items moved, search again
+		return n.remove(key, greatest, compare)
+	}
+	if found {
+		// replace the item by its predecessor, the greatest key of the child before it
+		item := n.items[i]
+		n.items[i], _ = n.children[i].remove(key, true, compare)
+		return item, true
+	}
+	return n.children[i].remove(key, greatest, compare)
+}
+
+// growChild gives one more item to children[i], taken from a sibling through the parent item between them,
+// or merges it with a sibling when both have the minimum number of items
+func (n *bTreeNode[K, V]) growChild(i int) {
+	child := n.children[i]
+	switch {
+	case i > 0 && len(n.children[i-1].items) > bTreeMinItems:
+		left := n.children[i-1]
+		child.items = insertAt(child.items, 0, n.items[i-1])
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items = removeAt(left.items, len(left.items)-1)
+		if !left.leaf() {
+			child.children = insertAt(child.children, 0, left.children[len(left.children)-1])
+			left.children = removeAt(left.children, len(left.children)-1)
+		}
+	case i < len(n.items) && len(n.children[i+1].items) > bTreeMinItems:
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = removeAt(right.items, 0)
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = removeAt(right.children, 0)
+		}
+	default:
+		if i == len(n.items) {
+			i--
+			child = n.children[i]
+		}
+		// merge children[i], the item at i and children[i+1]
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		child.items = append(child.items, right.items...)
+		child.children = append(child.children, right.children...)
+		n.items = removeAt(n.items, i)
+		n.children = removeAt(n.children, i+1)
+	}
+}
+
+// insertAt inserts v at position i of s
+func insertAt[T any](s []T, i int, v T) []T {
+	var zero T
+	s = append(s, zero)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+// removeAt removes the element at position i of s, and clears the slot it frees so that it can be collected
+func removeAt[T any](s []T, i int) []T {
+	copy(s[i:], s[i+1:])
+	var zero T
+	s[len(s)-1] = zero
+	return s[:len(s)-1]
+}
+
+// Min returns the lowest key and its value, false if the tree is empty
+func (t *BTree[K, V]) Min() (K, V, bool) {
+	n := t.root
+	if n == nil {
+		var key K
+		var value V
+		return key, value, false
+	}
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.items[0].key, n.items[0].value, true
+}
+
+// Max returns the greatest key and its value, false if the tree is empty
+func (t *BTree[K, V]) Max() (K, V, bool) {
+	n := t.root
+	if n == nil {
+		var key K
+		var value V
+		return key, value, false
+	}
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	item := n.items[len(n.items)-1]
+	return item.key, item.value, true
+}
+
+// Ascend calls fn for the keys >= from in ascending order, until fn returns false
+func (t *BTree[K, V]) Ascend(from K, fn func(key K, value V) bool) {
+	if t.root != nil {
+		t.root.ascend(from, t.compare, fn)
+	}
+}
+
+func (n *bTreeNode[K, V]) ascend(from K, compare func(a, b K) int, fn func(key K, value V) bool) bool {
+	i, _ := n.find(from, compare)
+	for ; i < len(n.items); i++ {
+		if !n.leaf() && !n.children[i].ascend(from, compare, fn) {
+			return false
+		}
+		if !fn(n.items[i].key, n.items[i].value) {
+			return false
+		}
+	}
+	if n.leaf() {
+		return true
+	}
+	return n.children[len(n.items)].ascend(from, compare, fn)
+}
+
+// Descend calls fn for the keys <= from in descending order, until fn returns false
+func (t *BTree[K, V]) Descend(from K, fn func(key K, value V) bool) {
+	if t.root != nil {
+		t.root.descend(from, t.compare, fn)
+	}
+}
+
+func (n *bTreeNode[K, V]) descend(from K, compare func(a, b K) int, fn func(key K, value V) bool) bool {
+	// i is the number of items <= from, children[i] holds keys between items[i-1] and items[i]
+	i, found := n.find(from, compare)
+	if found {
+		i++
+	}
+	if !n.leaf() && !n.children[i].descend(from, compare, fn) {
+		return false
+	}
+	for i--; i >= 0; i-- {
+		if !fn(n.items[i].key, n.items[i].value) {
+			return false
+		}
+		if !n.leaf() && !n.children[i].descend(from, compare, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// ForEach calls fn for every key in ascending order
+func (t *BTree[K, V]) ForEach(fn func(key K, value V)) {
+	if t.root != nil {
+		t.root.forEach(fn)
+	}
+}
+
+func (n *bTreeNode[K, V]) forEach(fn func(key K, value V)) {
+	for i, item := range n.items {
+		if !n.leaf() {
+			n.children[i].forEach(fn)
+		}
+		fn(item.key, item.value)
+	}
+	if !n.leaf() {
+		n.children[len(n.items)].forEach(fn)
+	}
+}
@@ -0,0 +1,154 @@
+package data_structure
+
+import (
+	"cmp"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkBTree checks the invariants of the nodes: ordered keys, number of items and same depth of the leaves
+func checkBTree(t *testing.T, tree *BTree[int, int]) {
+	if tree.root == nil {
+		assert.Equal(t, 0, tree.length)
+		return
+	}
+	leafDepth := -1
+	count := 0
+	var check func(n *bTreeNode[int, int], depth int, lo int, hi int)
+	check = func(n *bTreeNode[int, int], depth int, lo int, hi int) {
+		count += len(n.items)
+		assert.LessOrEqual(t, len(n.items), bTreeMaxItems)
+		if n != tree.root {
+			assert.GreaterOrEqual(t, len(n.items), bTreeMinItems)
+		}
+		for i, item := range n.items {
+			assert.True(t, item.key > lo && item.key < hi)
+			if i > 0 {
+				assert.Less(t, n.items[i-1].key, item.key)
+			}
+		}
+		if n.leaf() {
+			if leafDepth < 0 {
+				leafDepth = depth
+			}
+			assert.Equal(t, leafDepth, depth)
+			return
+		}
+		assert.Equal(t, len(n.items)+1, len(n.children))
+		for i, child := range n.children {
+			childLo, childHi := lo, hi
+			if i > 0 {
+				childLo = n.items[i-1].key
+			}
+			if i < len(n.items) {
+				childHi = n.items[i].key
+			}
+			check(child, depth+1, childLo, childHi)
+		}
+	}
+	check(tree.root, 0, -1<<62, 1<<62)
+	assert.Equal(t, tree.length, count)
+}
+
+func TestBTreeSetGetDelete(t *testing.T) {
+	tree := CreateBTree[int, int](cmp.Compare[int])
+	_, ok := tree.Get(1)
+	assert.False(t, ok)
+	_, ok = tree.Delete(1)
+	assert.False(t, ok)
+
+	ref := map[int]int{}
+	for i := 0; i < 20000; i++ {
+		key := rand.Intn(5000)
+		if rand.Intn(3) == 0 {
+			value, ok := tree.Delete(key)
+			refValue, refOk := ref[key]
+			assert.Equal(t, refOk, ok)
+			assert.Equal(t, refValue, value)
+			delete(ref, key)
+		} else {
+			_, exists := ref[key]
+			assert.Equal(t, !exists, tree.Set(key, i))
+			ref[key] = i
+		}
+	}
+	checkBTree(t, tree)
+	assert.Equal(t, len(ref), tree.Len())
+	for key, value := range ref {
+		v, ok := tree.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, value, v)
+	}
+	for key := range ref {
+		tree.Delete(key)
+	}
+	checkBTree(t, tree)
+	assert.Equal(t, 0, tree.Len())
+	assert.Nil(t, tree.root)
+}
+
+func TestBTreeAppendAndTrim(t *testing.T) {
+	// the access pattern of a stream: keys added in order and deleted from the lowest
+	tree := CreateBTree[int, int](cmp.Compare[int])
+	for i := 0; i < 10000; i++ {
+		tree.Set(i, i)
+	}
+	checkBTree(t, tree)
+	for i := 0; i < 9000; i++ {
+		key, _, ok := tree.Min()
+		assert.True(t, ok)
+		assert.Equal(t, i, key)
+		tree.Delete(key)
+	}
+	checkBTree(t, tree)
+	key, _, _ := tree.Min()
+	assert.Equal(t, 9000, key)
+	key, _, _ = tree.Max()
+	assert.Equal(t, 9999, key)
+}
+
+func TestBTreeIterate(t *testing.T) {
+	tree := CreateBTree[int, int](cmp.Compare[int])
+	var keys []int
+	for len(keys) < 1000 {
+		key := rand.Intn(100000)
+		if tree.Set(key, -key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Ints(keys)
+
+	var all []int
+	tree.ForEach(func(key int, value int) {
+		assert.Equal(t, -key, value)
+		all = append(all, key)
+	})
+	assert.Equal(t, keys, all)
+
+	for i := 0; i < 100; i++ {
+		from := rand.Intn(100000)
+		start := sort.SearchInts(keys, from)
+		// not nil, like keys[start:] when from is after every key
+		got := []int{}
+		tree.Ascend(from, func(key int, value int) bool {
+			got = append(got, key)
+			return len(got) < 10
+		})
+		assert.Equal(t, keys[start:min(start+10, len(keys))], got)
+
+		// the keys <= from, in reverse
+		end := sort.SearchInts(keys, from+1)
+		var want []int
+		for j := end - 1; j >= 0 && len(want) < 10; j-- {
+			want = append(want, keys[j])
+		}
+		got = nil
+		tree.Descend(from, func(key int, value int) bool {
+			got = append(got, key)
+			return len(got) < 10
+		})
+		assert.Equal(t, want, got)
+	}
+}
@@ -3,6 +3,7 @@ package data_structure
 import (
 	"github.com/spaolacci/murmur3"
 	"math"
+	"reflect"
 )
 
 // Implementation of Count-Min Sketch data structure
@@ -35,7 +36,8 @@ func CalcCMSDim(errRate float64, errProb float64) (uint32, uint32) {
 	return w, d
 }
 
-func (c *CMS) calcHash(item string, seed uint32) uint32 {
+// calcHash returns the 32 bits murmur3 hash of item, the seed selects one of a family of hash functions
+func calcHash(item string, seed uint32) uint32 {
 	hasher := murmur3.New32WithSeed(seed)
 	hasher.Write([]byte(item))
 	return hasher.Sum32()
@@ -46,7 +48,7 @@ func (c *CMS) IncrBy(item string, value uint32) uint32 {
 	var minCount uint32 = math.MaxUint32
 
 	for i = 0; i < c.depth; i++ {
-		hash = c.calcHash(item, i)
+		hash = calcHash(item, i)
 		id = (hash % c.width) + i*c.width
 		if math.MaxUint32-c.counter[id] < value {
 			c.counter[id] = math.MaxUint32
@@ -67,7 +69,7 @@ func (c *CMS) Count(item string) uint32 {
 	var i, id, hash uint32
 
 	for i = 0; i < c.depth; i++ {
-		hash = c.calcHash(item, i)
+		hash = calcHash(item, i)
 		id = (hash % c.width) + i*c.width
 		if c.counter[id] < minCount {
 			minCount = c.counter[id]
@@ -75,3 +77,34 @@ func (c *CMS) Count(item string) uint32 {
 	}
 	return minCount
 }
+
+func (c *CMS) GetMemUsage() uint64 {
+	return uint64(reflect.TypeOf(*c).Size()) + 4*uint64(len(c.counter))
+}
+
+// MarshalBinary encodes the dimensions and the whole counter matrix
+func (c *CMS) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 16+4*len(c.counter))
+	b = appendUint32(b, c.width)
+	b = appendUint32(b, c.depth)
+	b = appendUint64(b, c.totalCount)
+	for _, v := range c.counter {
+		b = appendUint32(b, v)
+	}
+	return b, nil
+}
+
+func (c *CMS) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	c.width = r.uint32()
+	c.depth = r.uint32()
+	c.totalCount = r.uint64()
+	if r.err != nil || c.width == 0 || c.depth == 0 || uint64(len(r.data)) != 4*uint64(c.width)*uint64(c.depth) {
+		return ErrCorruptedPayload
+	}
+	c.counter = make([]uint32, c.width*c.depth)
+	for i := range c.counter {
+		c.counter[i] = r.uint32()
+	}
+	return r.done()
+}
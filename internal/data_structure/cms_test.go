@@ -28,3 +28,20 @@ func TestCMS_IncrBy(t *testing.T) {
 	cms.IncrBy("b", 30)
 	assert.EqualValues(t, 30, cms.Count("b"))
 }
+
+func TestCMS_MarshalBinary(t *testing.T) {
+	cms := CreateCMS(10, 20)
+	cms.IncrBy("a", 10)
+	cms.IncrBy("b", 30)
+	data, err := cms.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &CMS{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, 10, loaded.width)
+	assert.EqualValues(t, 20, loaded.depth)
+	assert.EqualValues(t, 40, loaded.totalCount)
+	assert.EqualValues(t, 10, loaded.Count("a"))
+	assert.EqualValues(t, 30, loaded.Count("b"))
+	assert.Equal(t, ErrCorruptedPayload, loaded.UnmarshalBinary(data[:len(data)-1]))
+}
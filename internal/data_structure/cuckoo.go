@@ -0,0 +1,303 @@
+package data_structure
+
+import (
+	"github.com/spaolacci/murmur3"
+	"math/bits"
+	"reflect"
+)
+
+// Implementation of Scalable Cuckoo Filter data structure
+// https://www.cs.cmu.edu/~dga/papers/cuckoo-conext2014.pdf
+
+const CfDefaultBucketSize = 2
+const CfDefaultMaxIterations = 20
+const CfDefaultExpansion = 1
+const CfDefaultInitCapacity = 1024
+const CfMaxBucketSize = 255
+
+/*
+cuckooLink is a cuckoo filter: an array of buckets of bucketSize fingerprints. A fingerprint is a byte of the
+hash of an item, never 0 which marks an empty slot, and it is stored in one of the two buckets of the item.
+The second bucket is the first one xor a hash of the fingerprint, so that a fingerprint can be moved to its
+other bucket without the item. The number of buckets is a power of two for the xor to stay in range.
+*/
+type cuckooLink struct {
+	numBuckets uint64
+	data       []uint8
+}
+
+func createCuckooLink(numBuckets uint64, bucketSize uint8) cuckooLink {
+	return cuckooLink{
+		numBuckets: numBuckets,
+		data:       make([]uint8, numBuckets*uint64(bucketSize)),
+	}
+}
+
+// CuckooFilter A chain of cuckoo filters, a new one is added when the last one is full
+type CuckooFilter struct {
+	filters       []cuckooLink
+	numItems      uint64
+	numDeletes    uint64
+	bucketSize    uint8
+	maxIterations uint16
+	expansion     uint16
+}
+
+type cuckooHash struct {
+	fp   uint8
+	hash uint64
+}
+
+func CreateCuckooFilter(capacity uint64, bucketSize uint8, maxIterations uint16, expansion uint16) *CuckooFilter {
+	if capacity == 0 || bucketSize == 0 || maxIterations == 0 {
+		return nil
+	}
+	if expansion > 0 {
+		// keeps the number of buckets of every filter a power of two
+		expansion = uint16(nextPowerOfTwo(uint64(expansion)))
+	}
+	cf := &CuckooFilter{
+		bucketSize:    bucketSize,
+		maxIterations: maxIterations,
+		expansion:     expansion,
+	}
+	numBuckets := nextPowerOfTwo((capacity + uint64(bucketSize) - 1) / uint64(bucketSize))
+	cf.filters = []cuckooLink{createCuckooLink(numBuckets, bucketSize)}
+	return cf
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(n-1)
+}
+
+func cuckooCalcHash(item string) cuckooHash {
+	hash := murmur3.Sum64WithSeed([]byte(item), ABigSeed)
+	return cuckooHash{
+		fp:   uint8(hash%255 + 1),
+		hash: hash,
+	}
+}
+
+// altIndex returns the other bucket of the fingerprint fp stored in bucket i
+func (l *cuckooLink) altIndex(fp uint8, i uint64) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (l.numBuckets - 1)
+}
+
+func (l *cuckooLink) indexes(h cuckooHash) (uint64, uint64) {
+	i1 := h.hash & (l.numBuckets - 1)
+	return i1, l.altIndex(h.fp, i1)
+}
+
+func (l *cuckooLink) bucket(i uint64, bucketSize uint8) []uint8 {
+	return l.data[i*uint64(bucketSize) : (i+1)*uint64(bucketSize)]
+}
+
+// insertInBucket stores fp in a free slot of the bucket, it returns false if the bucket is full
+func insertInBucket(bucket []uint8, fp uint8) bool {
+	for i, slot := range bucket {
+		if slot == 0 {
+			bucket[i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+func countInBucket(bucket []uint8, fp uint8) uint64 {
+	var n uint64
+	for _, slot := range bucket {
+		if slot == fp {
+			n++
+		}
+	}
+	return n
+}
+
+func deleteFromBucket(bucket []uint8, fp uint8) bool {
+	for i, slot := range bucket {
+		if slot == fp {
+			bucket[i] = 0
+			return true
+		}
+	}
+	return false
+}
+
+/*
+insert stores h in the filter. When both buckets are full, fingerprints are moved to their other bucket to
+make room, up to maxIterations times. If that fails, the moves are undone so that nothing is lost, and false
+is returned. The slot whose fingerprint is moved is chosen deterministically, so that replaying the same
+commands rebuilds the same filter.
+*/
+func (l *cuckooLink) insert(h cuckooHash, bucketSize uint8, maxIterations uint16) bool {
+	i1, i2 := l.indexes(h)
+	if insertInBucket(l.bucket(i1, bucketSize), h.fp) || insertInBucket(l.bucket(i2, bucketSize), h.fp) {
+		return true
+	}
+	type move struct {
+		bucket uint64
+		slot   uint8
+	}
+	moves := make([]move, 0, maxIterations)
+	fp, i := h.fp, i2
+	for n := uint16(0); n < maxIterations; n++ {
+		slot := uint8(n % uint16(bucketSize))
+		bucket := l.bucket(i, bucketSize)
+		fp, bucket[slot] = bucket[slot], fp
+		moves = append(moves, move{bucket: i, slot: slot})
+		i = l.altIndex(fp, i)
+		if insertInBucket(l.bucket(i, bucketSize), fp) {
+			return true
+		}
+	}
+	for n := len(moves) - 1; n >= 0; n-- {
+		bucket := l.bucket(moves[n].bucket, bucketSize)
+		fp, bucket[moves[n].slot] = bucket[moves[n].slot], fp
+	}
+	return false
+}
+
+// Add adds item, even if it may already exist, and returns false if the filter is full and can't grow
+func (cf *CuckooFilter) Add(item string) bool {
+	h := cuckooCalcHash(item)
+	last := &cf.filters[len(cf.filters)-1]
+	if !last.insert(h, cf.bucketSize, cf.maxIterations) {
+		if cf.expansion == 0 {
+			return false
+		}
+		cf.filters = append(cf.filters, createCuckooLink(last.numBuckets*uint64(cf.expansion), cf.bucketSize))
+		if !cf.filters[len(cf.filters)-1].insert(h, cf.bucketSize, cf.maxIterations) {
+			return false
+		}
+	}
+	cf.numItems++
+	return true
+}
+
+func (cf *CuckooFilter) Exist(item string) bool {
+	h := cuckooCalcHash(item)
+	for i := len(cf.filters) - 1; i >= 0; i-- {
+		i1, i2 := cf.filters[i].indexes(h)
+		if countInBucket(cf.filters[i].bucket(i1, cf.bucketSize), h.fp) > 0 ||
+			countInBucket(cf.filters[i].bucket(i2, cf.bucketSize), h.fp) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns how many times item may have been added, it can be more when other items have the same fingerprint
+func (cf *CuckooFilter) Count(item string) uint64 {
+	h := cuckooCalcHash(item)
+	var n uint64
+	for i := range cf.filters {
+		i1, i2 := cf.filters[i].indexes(h)
+		n += countInBucket(cf.filters[i].bucket(i1, cf.bucketSize), h.fp)
+		if i2 != i1 {
+			n += countInBucket(cf.filters[i].bucket(i2, cf.bucketSize), h.fp)
+		}
+	}
+	return n
+}
+
+// Delete removes one occurrence of item, it returns false if it was not found. Deleting an item that was
+// never added may delete another item with the same fingerprint.
+func (cf *CuckooFilter) Delete(item string) bool {
+	h := cuckooCalcHash(item)
+	for i := len(cf.filters) - 1; i >= 0; i-- {
+		i1, i2 := cf.filters[i].indexes(h)
+		if deleteFromBucket(cf.filters[i].bucket(i1, cf.bucketSize), h.fp) ||
+			deleteFromBucket(cf.filters[i].bucket(i2, cf.bucketSize), h.fp) {
+			cf.numItems--
+			cf.numDeletes++
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *CuckooFilter) GetSize() uint64 {
+	return cf.numItems
+}
+
+func (cf *CuckooFilter) GetDeletedNumber() uint64 {
+	return cf.numDeletes
+}
+
+func (cf *CuckooFilter) GetFilterNumber() int {
+	return len(cf.filters)
+}
+
+// GetBucketNumber returns the number of buckets of the first filter
+func (cf *CuckooFilter) GetBucketNumber() uint64 {
+	return cf.filters[0].numBuckets
+}
+
+func (cf *CuckooFilter) GetBucketSize() uint8 {
+	return cf.bucketSize
+}
+
+func (cf *CuckooFilter) GetMaxIterations() uint16 {
+	return cf.maxIterations
+}
+
+func (cf *CuckooFilter) GetExpansion() uint16 {
+	return cf.expansion
+}
+
+func (cf *CuckooFilter) GetMemUsage() uint64 {
+	res := uint64(reflect.TypeOf(*cf).Size())
+	for i := 0; i < len(cf.filters); i++ {
+		res += uint64(reflect.TypeOf(cf.filters[i]).Size()) + uint64(len(cf.filters[i].data))
+	}
+	return res
+}
+
+// MarshalBinary encodes the parameters and the buckets of every filter
+func (cf *CuckooFilter) MarshalBinary() ([]byte, error) {
+	var b []byte
+	b = appendUint64(b, cf.numItems)
+	b = appendUint64(b, cf.numDeletes)
+	b = append(b, cf.bucketSize)
+	b = appendUint32(b, uint32(cf.maxIterations))
+	b = appendUint32(b, uint32(cf.expansion))
+	b = appendUint32(b, uint32(len(cf.filters)))
+	for _, link := range cf.filters {
+		b = appendUint64(b, link.numBuckets)
+		b = append(b, link.data...)
+	}
+	return b, nil
+}
+
+func (cf *CuckooFilter) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	cf.numItems = r.uint64()
+	cf.numDeletes = r.uint64()
+	if b := r.next(1); b != nil {
+		cf.bucketSize = b[0]
+	}
+	cf.maxIterations = uint16(r.uint32())
+	cf.expansion = uint16(r.uint32())
+	n := r.uint32()
+	cf.filters = nil
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		numBuckets := r.uint64()
+		if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 || cf.bucketSize == 0 {
+			return ErrCorruptedPayload
+		}
+		cf.filters = append(cf.filters, cuckooLink{
+			numBuckets: numBuckets,
+			data:       r.bytes(numBuckets * uint64(cf.bucketSize)),
+		})
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	if len(cf.filters) == 0 || cf.maxIterations == 0 {
+		return ErrCorruptedPayload
+	}
+	return nil
+}
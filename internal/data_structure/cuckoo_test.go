@@ -0,0 +1,106 @@
+package data_structure
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreateCuckooFilter(t *testing.T) {
+	cf := CreateCuckooFilter(1000, 4, 20, 3)
+	assert.EqualValues(t, 1, len(cf.filters))
+	// 250 buckets rounded up to a power of two
+	assert.EqualValues(t, 256, cf.filters[0].numBuckets)
+	assert.EqualValues(t, 1024, len(cf.filters[0].data))
+	assert.EqualValues(t, 4, cf.expansion)
+	assert.EqualValues(t, 0, CreateCuckooFilter(1000, 4, 20, 0).expansion)
+	assert.Nil(t, CreateCuckooFilter(0, 4, 20, 1))
+	assert.Nil(t, CreateCuckooFilter(10, 0, 20, 1))
+}
+
+func TestCuckooFilter_AltIndex(t *testing.T) {
+	l := createCuckooLink(64, 2)
+	for fp := 1; fp < 256; fp++ {
+		for i := uint64(0); i < l.numBuckets; i++ {
+			assert.EqualValues(t, i, l.altIndex(uint8(fp), l.altIndex(uint8(fp), i)))
+		}
+	}
+}
+
+func TestCuckooFilter_AddDelete(t *testing.T) {
+	cf := CreateCuckooFilter(100, 2, 20, 1)
+	assert.True(t, cf.Add("a"))
+	assert.True(t, cf.Add("a"))
+	assert.True(t, cf.Exist("a"))
+	assert.False(t, cf.Exist("b"))
+	assert.EqualValues(t, 2, cf.Count("a"))
+	assert.EqualValues(t, 2, cf.GetSize())
+
+	assert.True(t, cf.Delete("a"))
+	assert.EqualValues(t, 1, cf.Count("a"))
+	assert.True(t, cf.Delete("a"))
+	assert.False(t, cf.Exist("a"))
+	assert.False(t, cf.Delete("a"))
+	assert.EqualValues(t, 0, cf.GetSize())
+	assert.EqualValues(t, 2, cf.GetDeletedNumber())
+}
+
+func TestCuckooFilter_Grow(t *testing.T) {
+	cf := CreateCuckooFilter(64, 2, 20, 2)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, cf.Add(fmt.Sprintf("%d", i)))
+	}
+	assert.Greater(t, len(cf.filters), 1)
+	assert.EqualValues(t, 2*cf.filters[0].numBuckets, cf.filters[1].numBuckets)
+	// no false negatives, the items moved while making room are still found
+	for i := 0; i < 1000; i++ {
+		assert.True(t, cf.Exist(fmt.Sprintf("%d", i)))
+	}
+	falsePositives := 0
+	for i := 1000; i < 2000; i++ {
+		if cf.Exist(fmt.Sprintf("%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 100)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, cf.Delete(fmt.Sprintf("%d", i)))
+	}
+	assert.EqualValues(t, 0, cf.GetSize())
+}
+
+func TestCuckooFilter_Full(t *testing.T) {
+	cf := CreateCuckooFilter(8, 2, 10, 0)
+	added := 0
+	for i := 0; i < 100; i++ {
+		if cf.Add(fmt.Sprintf("%d", i)) {
+			added++
+		}
+	}
+	assert.EqualValues(t, 1, len(cf.filters))
+	assert.LessOrEqual(t, added, 8)
+	assert.EqualValues(t, added, cf.GetSize())
+	// a failed insertion doesn't lose the items already stored
+	for i := 0; i < 100; i++ {
+		if cf.Exist(fmt.Sprintf("%d", i)) {
+			added--
+		}
+	}
+	assert.LessOrEqual(t, added, 0)
+}
+
+func TestCuckooFilter_MarshalBinary(t *testing.T) {
+	cf := CreateCuckooFilter(64, 4, 20, 2)
+	for i := 0; i < 500; i++ {
+		cf.Add(fmt.Sprintf("%d", i))
+	}
+	cf.Delete("0")
+	data, err := cf.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &CuckooFilter{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, cf, loaded)
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.NotNil(t, loaded.UnmarshalBinary(nil))
+}
@@ -1,6 +1,7 @@
 package data_structure
 
 import (
+	"math/rand"
 	"memkv/internal/config"
 	"time"
 )
@@ -10,11 +11,33 @@ type Obj struct {
 	TypeEncoding uint8
 	// type    | encoding
 	// [][][][]|[][][][]
+	// access is the LRU clock or the LFU counter of the obj, see evict.go
+	access uint32
+	// key is the key of the obj once it is put in the dict
+	key string
+	// memUsage is the memory accounted for the obj in the dict
+	memUsage uint64
+	// pos is the position of the obj in the keys of the dict
+	pos int
 }
 
+// expiryEntryMemUsage is the memory used by an entry of expiredDictStore
+const expiryEntryMemUsage = MapEntryOverhead + pointerSize + 8
+
 type Dict struct {
 	dictStore        map[string]*Obj
 	expiredDictStore map[*Obj]uint64
+	// keys holds the objs of dictStore densely, for Scan and RandomKey
+	keys []*Obj
+	// evictionPool holds the best keys to evict found by the previous samplings
+	evictionPool EvictionPool
+	usedMemory   uint64
+	// OnEvict is called with the key of every key evicted because of the key number limit
+	OnEvict func(key string)
+	// OnExpire is called with the key of every expired key deleted, lazily or by ExpireSample
+	OnExpire func(key string)
+	// OnNewKey is called with every key put that didn't exist
+	OnNewKey func(key string)
 }
 
 func CreateDict() *Dict {
@@ -30,6 +53,7 @@ func (d *Dict) NewObj(value interface{}, ttlMs int64, oType uint8, oEnc uint8) *
 		Value:        value,
 		TypeEncoding: oType | oEnc,
 	}
+	initAccess(obj)
 	if ttlMs > 0 {
 		d.SetExpiry(obj, ttlMs)
 	}
@@ -50,39 +74,153 @@ func (d *Dict) GetExpiry(obj *Obj) (uint64, bool) {
 }
 
 func (d *Dict) SetExpiry(obj *Obj, ttlMs int64) {
-	d.expiredDictStore[obj] = uint64(time.Now().UnixMilli()) + uint64(ttlMs)
+	d.SetExpiryAt(obj, uint64(time.Now().UnixMilli())+uint64(ttlMs))
+}
+
+// SetExpiryAt sets the expiry of obj to an absolute unix time in milliseconds
+func (d *Dict) SetExpiryAt(obj *Obj, expMs uint64) {
+	if _, exist := d.expiredDictStore[obj]; !exist {
+		d.usedMemory += expiryEntryMemUsage
+	}
+	d.expiredDictStore[obj] = expMs
+}
+
+func (d *Dict) removeExpiry(obj *Obj) {
+	if _, exist := d.expiredDictStore[obj]; exist {
+		delete(d.expiredDictStore, obj)
+		d.usedMemory -= expiryEntryMemUsage
+	}
 }
 
 func (d *Dict) Get(k string) *Obj {
 	v := d.dictStore[k]
 	if v != nil {
 		if d.HasExpired(v) {
-			d.Del(k)
+			d.deleteExpired(k)
 			return nil
 		}
+		v.Touch()
 	}
 	return v
 }
 
 func (d *Dict) Put(k string, obj *Obj) {
-	if len(d.dictStore) >= config.KeyNumberLimit {
+	old, exist := d.dictStore[k]
+	if !exist && len(d.dictStore) >= config.KeyNumberLimit {
 		d.evict()
 	}
+	if exist {
+		d.usedMemory -= old.memUsage
+		if old != obj {
+			d.removeExpiry(old)
+		}
+	}
+	obj.key = k
+	obj.memUsage = objMemUsage(k, obj)
+	d.usedMemory += obj.memUsage
 	d.dictStore[k] = obj
+	if exist {
+		obj.pos = old.pos
+		d.keys[obj.pos] = obj
+	} else {
+		obj.pos = len(d.keys)
+		d.keys = append(d.keys, obj)
+		if d.OnNewKey != nil {
+			d.OnNewKey(k)
+		}
+	}
 }
 
 func (d *Dict) Del(k string) bool {
 	if obj, exist := d.dictStore[k]; exist {
 		delete(d.dictStore, k)
-		delete(d.expiredDictStore, obj)
+		d.removeExpiry(obj)
+		d.usedMemory -= obj.memUsage
+		last := d.keys[len(d.keys)-1]
+		last.pos = obj.pos
+		d.keys[obj.pos] = last
+		d.keys[len(d.keys)-1] = nil
+		d.keys = d.keys[:len(d.keys)-1]
 		return true
 	}
 	return false
 }
 
+func (d *Dict) deleteExpired(k string) {
+	d.Del(k)
+	if d.OnExpire != nil {
+		d.OnExpire(k)
+	}
+}
+
+// ExpireSample checks up to n keys with an expiry and deletes the expired ones. It returns the number
+// of keys checked and deleted.
+func (d *Dict) ExpireSample(n int) (sampled int, expired int) {
+	now := uint64(time.Now().UnixMilli())
+	for obj, exp := range d.expiredDictStore {
+		if sampled == n {
+			break
+		}
+		sampled++
+		if exp > now {
+			continue
+		}
+		if d.dictStore[obj.key] != obj {
+			// the obj was never put in the dict
+			d.removeExpiry(obj)
+			continue
+		}
+		d.deleteExpired(obj.key)
+		expired++
+	}
+	return sampled, expired
+}
+
+// Len returns the number of keys, expired keys not yet deleted included
+func (d *Dict) Len() int {
+	return len(d.dictStore)
+}
+
+// VolatileLen returns the number of keys with an expiry
+func (d *Dict) VolatileLen() int {
+	return len(d.expiredDictStore)
+}
+
+// UpdateMemUsage accounts again for the obj of key k, after its value was modified in place
+func (d *Dict) UpdateMemUsage(k string) {
+	obj, exist := d.dictStore[k]
+	if !exist {
+		return
+	}
+	d.usedMemory -= obj.memUsage
+	obj.memUsage = objMemUsage(k, obj)
+	d.usedMemory += obj.memUsage
+}
+
+// MemUsage returns the estimated memory used by all the keys of the dict
+func (d *Dict) MemUsage() uint64 {
+	return d.usedMemory
+}
+
+// KeyMemUsage returns the estimated memory used by key k and its value, without counting it as an access
+func (d *Dict) KeyMemUsage(k string) (uint64, bool) {
+	obj, exist := d.dictStore[k]
+	if !exist || d.HasExpired(obj) {
+		return 0, false
+	}
+	usage := obj.memUsage
+	if _, volatile := d.expiredDictStore[obj]; volatile {
+		usage += expiryEntryMemUsage
+	}
+	return usage, true
+}
+
 func (d *Dict) evictFirst() {
 	for k := range d.dictStore {
 		d.Del(k)
+		if d.OnEvict != nil {
+			d.OnEvict(k)
+		}
 		return
 	}
 }
@@ -91,7 +229,46 @@ func (d *Dict) evict() {
 	switch config.EvictStrategy {
 	case config.EvictFirst:
 		d.evictFirst()
+	case config.LRU, config.LFU:
+		d.evictSampled()
 	default:
 		d.evictFirst()
 	}
 }
+
+// ForEach calls fn for every key that has not expired
+func (d *Dict) ForEach(fn func(key string, obj *Obj)) {
+	for k, v := range d.dictStore {
+		if d.HasExpired(v) {
+			continue
+		}
+		fn(k, v)
+	}
+}
+
+// Scan calls fn for up to count keys that have not expired, starting at cursor, and returns the cursor
+// of the next call. See scan.go for the guarantees.
+func (d *Dict) Scan(cursor uint64, count int, fn func(key string, obj *Obj)) uint64 {
+	start, end, next := scanRange(len(d.keys), cursor, count)
+	for i := end - 1; i >= start; i-- {
+		obj := d.keys[i]
+		if d.HasExpired(obj) {
+			continue
+		}
+		fn(obj.key, obj)
+	}
+	return next
+}
+
+// RandomKey returns a random key that has not expired, deleting the expired keys it picks
+func (d *Dict) RandomKey() (string, bool) {
+	for len(d.keys) > 0 {
+		obj := d.keys[rand.Intn(len(d.keys))]
+		if d.HasExpired(obj) {
+			d.deleteExpired(obj.key)
+			continue
+		}
+		return obj.key, true
+	}
+	return "", false
+}
@@ -0,0 +1,183 @@
+package data_structure
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"memkv/internal/config"
+)
+
+func withEviction(t *testing.T, strategy int, limit int) {
+	oldStrategy, oldLimit, oldSamples := config.EvictStrategy, config.KeyNumberLimit, config.EvictionSamples
+	config.EvictStrategy, config.KeyNumberLimit, config.EvictionSamples = strategy, limit, 10
+	t.Cleanup(func() {
+		config.EvictStrategy, config.KeyNumberLimit, config.EvictionSamples = oldStrategy, oldLimit, oldSamples
+	})
+}
+
+func TestDictPutOverwriteDoesNotEvict(t *testing.T) {
+	withEviction(t, config.EvictFirst, 2)
+	d := CreateDict()
+	d.Put("a", d.NewObj("1", -1, 0, 0))
+	d.Put("b", d.NewObj("2", -1, 0, 0))
+	d.Put("b", d.NewObj("3", -1, 0, 0))
+	assert.NotNil(t, d.Get("a"))
+	assert.EqualValues(t, "3", d.Get("b").Value)
+}
+
+func TestDictLRUEviction(t *testing.T) {
+	withEviction(t, config.LRU, 100)
+	d := CreateDict()
+	now := lruClock()
+	for i := 0; i < 100; i++ {
+		obj := d.NewObj(strconv.Itoa(i), -1, 0, 0)
+		d.Put(strconv.Itoa(i), obj)
+		// keys 0-49 are old, the others were accessed recently
+		if i < 50 {
+			obj.access = now - 1000 + uint32(i)
+		}
+	}
+	for i := 100; i < 120; i++ {
+		d.Put(strconv.Itoa(i), d.NewObj(strconv.Itoa(i), -1, 0, 0))
+	}
+	assert.EqualValues(t, 100, len(d.dictStore))
+	// the eviction is approximated, almost all the evicted keys must be old ones
+	assert.GreaterOrEqual(t, countEvicted(d, 0, 50), 18)
+}
+
+func countEvicted(d *Dict, from int, to int) int {
+	evicted := 0
+	for i := from; i < to; i++ {
+		if d.dictStore[strconv.Itoa(i)] == nil {
+			evicted++
+		}
+	}
+	return evicted
+}
+
+func TestDictLFUEviction(t *testing.T) {
+	withEviction(t, config.LFU, 100)
+	d := CreateDict()
+	for i := 0; i < 100; i++ {
+		d.Put(strconv.Itoa(i), d.NewObj(strconv.Itoa(i), -1, 0, 0))
+	}
+	// keys 50-99 are hot
+	for n := 0; n < 100; n++ {
+		for i := 50; i < 100; i++ {
+			d.Get(strconv.Itoa(i))
+		}
+	}
+	for i := 100; i < 120; i++ {
+		d.Put(strconv.Itoa(i), d.NewObj(strconv.Itoa(i), -1, 0, 0))
+	}
+	assert.EqualValues(t, 100, len(d.dictStore))
+	assert.LessOrEqual(t, countEvicted(d, 50, 100), 2)
+}
+
+func TestLFULogIncr(t *testing.T) {
+	var counter uint8 = lfuInitVal
+	for i := 0; i < 1000; i++ {
+		counter = lfuLogIncr(counter)
+	}
+	// the counter grows logarithmically
+	assert.Greater(t, counter, uint8(lfuInitVal+5))
+	assert.Less(t, counter, uint8(100))
+
+	counter = 255
+	assert.EqualValues(t, 255, lfuLogIncr(counter))
+}
+
+func TestLFUDecay(t *testing.T) {
+	withEviction(t, config.LFU, 100)
+	obj := &Obj{}
+	ldt := (lfuTimeInMinutes() - 3) & 0xFFFF
+	obj.access = ldt<<8 | 10
+	assert.EqualValues(t, 7, lfuDecr(obj))
+	obj.access = ldt<<8 | 2
+	assert.EqualValues(t, 0, lfuDecr(obj))
+}
+
+func TestDictPutReplacesExpiry(t *testing.T) {
+	d := CreateDict()
+	d.Put("a", d.NewObj("1", 100000, 0, 0))
+	assert.EqualValues(t, 1, d.VolatileLen())
+	d.Put("a", d.NewObj("2", -1, 0, 0))
+	assert.EqualValues(t, 0, d.VolatileLen())
+	_, hasExpiry := d.GetExpiry(d.Get("a"))
+	assert.False(t, hasExpiry)
+}
+
+func TestDictExpireSample(t *testing.T) {
+	d := CreateDict()
+	var expired []string
+	d.OnExpire = func(key string) {
+		expired = append(expired, key)
+	}
+	now := uint64(time.Now().UnixMilli())
+	for i := 0; i < 10; i++ {
+		obj := d.NewObj(strconv.Itoa(i), -1, 0, 0)
+		d.Put(strconv.Itoa(i), obj)
+		if i < 5 {
+			d.SetExpiryAt(obj, now-1)
+		} else {
+			d.SetExpiryAt(obj, now+100000)
+		}
+	}
+	d.Put("persistent", d.NewObj("v", -1, 0, 0))
+
+	sampled, deleted := d.ExpireSample(100)
+	assert.EqualValues(t, 10, sampled)
+	assert.EqualValues(t, 5, deleted)
+	assert.ElementsMatch(t, []string{"0", "1", "2", "3", "4"}, expired)
+	assert.EqualValues(t, 6, d.Len())
+	assert.EqualValues(t, 5, d.VolatileLen())
+
+	sampled, _ = d.ExpireSample(2)
+	assert.EqualValues(t, 2, sampled)
+}
+
+func TestDictScan(t *testing.T) {
+	d := CreateDict()
+	for i := 0; i < 100; i++ {
+		d.Put(strconv.Itoa(i), d.NewObj("v", -1, 0, 0))
+	}
+	seen := map[string]int{}
+	var cursor uint64 = 0
+	next := 100
+	for {
+		cursor = d.Scan(cursor, 7, func(key string, _ *Obj) {
+			seen[key]++
+		})
+		// the keyspace changes during the scan: keys 0-49 are deleted and new keys are added
+		d.Del(strconv.Itoa(len(seen) % 50))
+		for i := 0; i < 3; i++ {
+			d.Put(strconv.Itoa(next), d.NewObj("v", -1, 0, 0))
+			next++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 50; i < 100; i++ {
+		assert.GreaterOrEqual(t, seen[strconv.Itoa(i)], 1, "key %d was not returned", i)
+	}
+}
+
+func TestDictRandomKey(t *testing.T) {
+	d := CreateDict()
+	_, ok := d.RandomKey()
+	assert.False(t, ok)
+	d.Put("a", d.NewObj("v", -1, 0, 0))
+	expired := d.NewObj("v", -1, 0, 0)
+	d.Put("b", expired)
+	d.SetExpiryAt(expired, uint64(time.Now().UnixMilli())-1)
+	for i := 0; i < 10; i++ {
+		key, ok := d.RandomKey()
+		assert.True(t, ok)
+		assert.EqualValues(t, "a", key)
+	}
+	assert.EqualValues(t, 1, d.Len())
+}
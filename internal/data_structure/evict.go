@@ -0,0 +1,206 @@
+package data_structure
+
+import (
+	"math"
+	"math/rand"
+	"memkv/internal/config"
+	"sort"
+	"time"
+)
+
+/*
+Approximated LRU and LFU eviction, the same way Redis does it: instead of keeping every key in a list
+ordered by access, each Obj stores a small access field and eviction samples a few keys, keeping
+the best candidates across evictions in a pool.
+
+The access field of an Obj holds:
+  - LRU: the unix time in seconds of the last access
+  - LFU: the time of the last decrement in minutes (16 bits) followed by a logarithmic counter (8 bits)
+*/
+
+const evictionPoolSize = 16
+
+// lfuInitVal is the counter of a new key, so it is not evicted before having a chance to be accessed again
+const lfuInitVal = 5
+
+type evictionCandidate struct {
+	key string
+	// idle is higher for keys that are better to evict
+	idle uint64
+}
+
+func lruClock() uint32 {
+	return uint32(time.Now().Unix())
+}
+
+// lfuTimeInMinutes returns the current time in minutes, on 16 bits
+func lfuTimeInMinutes() uint32 {
+	return uint32(time.Now().Unix()/60) & 0xFFFF
+}
+
+// lfuTimeElapsed returns the minutes elapsed since ldt, handling the wrap around of the 16 bit clock
+func lfuTimeElapsed(ldt uint32) uint32 {
+	now := lfuTimeInMinutes()
+	if now >= ldt {
+		return now - ldt
+	}
+	return 0xFFFF - ldt + now
+}
+
+/*
+lfuLogIncr increments the counter with a probability that decreases as the counter grows, so that
+8 bits can tell apart keys accessed a few times from keys accessed millions of times.
+*/
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return 255
+	}
+	baseVal := float64(counter) - lfuInitVal
+	if baseVal < 0 {
+		baseVal = 0
+	}
+	p := 1.0 / (baseVal*float64(config.LFULogFactor) + 1)
+	if rand.Float64() < p {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecr returns the counter of obj decremented by the number of decay periods elapsed since its last decrement
+func lfuDecr(obj *Obj) uint8 {
+	ldt := obj.access >> 8
+	counter := obj.access & 0xFF
+	if config.LFUDecayTime > 0 {
+		periods := lfuTimeElapsed(ldt) / uint32(config.LFUDecayTime)
+		if periods >= counter {
+			counter = 0
+		} else {
+			counter -= periods
+		}
+	}
+	return uint8(counter)
+}
+
+// initAccess sets the access field of a new obj
+func initAccess(obj *Obj) {
+	if config.EvictStrategy == config.LFU {
+		obj.access = lfuTimeInMinutes()<<8 | lfuInitVal
+	} else {
+		obj.access = lruClock()
+	}
+}
+
+// Touch records an access to the obj
+func (o *Obj) Touch() {
+	if config.EvictStrategy == config.LFU {
+		counter := lfuLogIncr(lfuDecr(o))
+		o.access = lfuTimeInMinutes()<<8 | uint32(counter)
+	} else {
+		o.access = lruClock()
+	}
+}
+
+// IdleScore returns how good the obj is as an eviction candidate: the seconds since its last access
+// with LRU, the complement of its access counter with LFU
+func (o *Obj) IdleScore() uint64 {
+	if config.EvictStrategy == config.LFU {
+		return uint64(255 - lfuDecr(o))
+	}
+	now := lruClock()
+	if now < o.access {
+		return 0
+	}
+	return uint64(now - o.access)
+}
+
+// EvictionPool keeps the best eviction candidates across samplings, sorted by ascending idle score
+type EvictionPool struct {
+	candidates []evictionCandidate
+}
+
+// Add adds key to the pool if it is better than the worst candidate, or if the pool is not full
+func (p *EvictionPool) Add(key string, idle uint64) {
+	if len(p.candidates) == evictionPoolSize && idle <= p.candidates[0].idle {
+		return
+	}
+	pos := sort.Search(len(p.candidates), func(i int) bool {
+		return p.candidates[i].idle >= idle
+	})
+	if pos < len(p.candidates) && p.candidates[pos].key == key {
+		return
+	}
+	if len(p.candidates) == evictionPoolSize {
+		// drop the worst candidate to make room
+		copy(p.candidates[:pos-1], p.candidates[1:pos])
+		pos--
+	} else {
+		p.candidates = append(p.candidates, evictionCandidate{})
+		copy(p.candidates[pos+1:], p.candidates[pos:])
+	}
+	p.candidates[pos] = evictionCandidate{key: key, idle: idle}
+}
+
+// Pop removes and returns the best candidate. It may not exist anymore, the caller must check it.
+func (p *EvictionPool) Pop() (string, bool) {
+	if len(p.candidates) == 0 {
+		return "", false
+	}
+	last := len(p.candidates) - 1
+	key := p.candidates[last].key
+	p.candidates = p.candidates[:last]
+	return key, true
+}
+
+/*
+SampleKeys calls fn for up to n keys, expired keys included.
+Go maps are iterated from a random position, so the first keys of an iteration are a cheap random sample.
+*/
+func (d *Dict) SampleKeys(n int, fn func(key string, obj *Obj)) {
+	for k, obj := range d.dictStore {
+		if n == 0 {
+			return
+		}
+		n--
+		fn(k, obj)
+	}
+}
+
+// SampleVolatileKeys calls fn for up to n keys that have an expiry
+func (d *Dict) SampleVolatileKeys(n int, fn func(key string, expMs uint64)) {
+	for obj, exp := range d.expiredDictStore {
+		if n == 0 {
+			return
+		}
+		n--
+		fn(obj.key, exp)
+	}
+}
+
+// EvictionScore returns the idle score of obj, expired keys are the best candidates
+func (d *Dict) EvictionScore(obj *Obj) uint64 {
+	if d.HasExpired(obj) {
+		return math.MaxUint64
+	}
+	return obj.IdleScore()
+}
+
+// evictSampled deletes the best candidate of the pool that still exists
+func (d *Dict) evictSampled() {
+	for len(d.dictStore) > 0 {
+		d.SampleKeys(config.EvictionSamples, func(key string, obj *Obj) {
+			d.evictionPool.Add(key, d.EvictionScore(obj))
+		})
+		for {
+			key, ok := d.evictionPool.Pop()
+			if !ok {
+				break
+			}
+			if d.Del(key) {
+				if d.OnEvict != nil {
+					d.OnEvict(key)
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package data_structure
+
+import (
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"reflect"
+)
+
+/*
+Hash maps fields to values. Its fields are stored densely in entries, for Scan and random fields.
+A small hash uses the listpack encoding, like Redis: the fields are found by a linear search of entries,
+which is fast for a few short fields and saves the memory of a map. When the hash grows past
+config.HashMaxListpackEntries fields, or gets a field or value longer than config.HashMaxListpackValue,
+it is converted to the hashtable encoding, which indexes the fields with a map. It is never converted back.
+*/
+type Hash struct {
+	entries []hashEntry
+	// index maps every field to its position in entries, nil for the listpack encoding
+	index map[string]int
+	// entriesMemUsage is the memory used by the entries and their index
+	entriesMemUsage uint64
+}
+
+type hashEntry struct {
+	field string
+	value string
+}
+
+var hashSize = uint64(reflect.TypeOf(Hash{}).Size())
+
+func CreateHash() *Hash {
+	return &Hash{}
+}
+
+func (h *Hash) entryMemUsage(e hashEntry) uint64 {
+	usage := StringMemUsage(e.field) + StringMemUsage(e.value)
+	if h.index != nil {
+		usage += MapEntryOverhead + StringHeaderSize + 8
+	}
+	return usage
+}
+
+// Encoding returns constant.ObjEncodingListpack or constant.ObjEncodingHashTable
+func (h *Hash) Encoding() uint8 {
+	if h.index == nil {
+		return constant.ObjEncodingListpack
+	}
+	return constant.ObjEncodingHashTable
+}
+
+// convert switches to the hashtable encoding
+func (h *Hash) convert() {
+	h.index = make(map[string]int, len(h.entries))
+	h.entriesMemUsage = 0
+	for i, e := range h.entries {
+		h.index[e.field] = i
+		h.entriesMemUsage += h.entryMemUsage(e)
+	}
+}
+
+// find returns the position of field in entries, -1 if it doesn't exist
+func (h *Hash) find(field string) int {
+	if h.index != nil {
+		if i, ok := h.index[field]; ok {
+			return i
+		}
+		return -1
+	}
+	for i, e := range h.entries {
+		if e.field == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Hash) Len() int {
+	return len(h.entries)
+}
+
+func (h *Hash) Get(field string) (string, bool) {
+	i := h.find(field)
+	if i < 0 {
+		return "", false
+	}
+	return h.entries[i].value, true
+}
+
+// Set sets the value of field, and returns true if the field is new
+func (h *Hash) Set(field string, value string) bool {
+	i := h.find(field)
+	if h.index == nil && (len(field) > config.HashMaxListpackValue || len(value) > config.HashMaxListpackValue ||
+		(i < 0 && len(h.entries) >= config.HashMaxListpackEntries)) {
+		h.convert()
+	}
+	if i >= 0 {
+		h.entriesMemUsage -= h.entryMemUsage(h.entries[i])
+		h.entries[i].value = value
+		h.entriesMemUsage += h.entryMemUsage(h.entries[i])
+		return false
+	}
+	e := hashEntry{field: field, value: value}
+	if h.index != nil {
+		h.index[field] = len(h.entries)
+	}
+	h.entries = append(h.entries, e)
+	h.entriesMemUsage += h.entryMemUsage(e)
+	return true
+}
+
+/*
+Del deletes field and returns true if it existed. The listpack encoding keeps the order of the other
+fields, the hashtable encoding moves the last field into the position of the deleted one.
+*/
+func (h *Hash) Del(field string) bool {
+	i := h.find(field)
+	if i < 0 {
+		return false
+	}
+	h.entriesMemUsage -= h.entryMemUsage(h.entries[i])
+	last := len(h.entries) - 1
+	if h.index == nil {
+		copy(h.entries[i:], h.entries[i+1:])
+	} else {
+		h.entries[i] = h.entries[last]
+		h.index[h.entries[i].field] = i
+		delete(h.index, field)
+	}
+	h.entries[last] = hashEntry{}
+	h.entries = h.entries[:last]
+	return true
+}
+
+// Dup returns a copy of the hash with the same encoding
+func (h *Hash) Dup() *Hash {
+	dup := &Hash{
+		entries:         append([]hashEntry(nil), h.entries...),
+		entriesMemUsage: h.entriesMemUsage,
+	}
+	if h.index != nil {
+		dup.index = make(map[string]int, len(h.index))
+		for field, i := range h.index {
+			dup.index[field] = i
+		}
+	}
+	return dup
+}
+
+// ForEach calls fn for every field and its value
+func (h *Hash) ForEach(fn func(field string, value string)) {
+	for _, e := range h.entries {
+		fn(e.field, e.value)
+	}
+}
+
+/*
+Scan calls fn for up to count fields starting at cursor, and returns the cursor of the next call, 0 once
+every field present during the whole scan was returned. A hash with the listpack encoding is returned
+in a single call, like Redis does.
+*/
+func (h *Hash) Scan(cursor uint64, count int, fn func(field string, value string)) uint64 {
+	if h.index == nil {
+		count = len(h.entries)
+	}
+	start, end, next := scanRange(len(h.entries), cursor, count)
+	for i := end - 1; i >= start; i-- {
+		fn(h.entries[i].field, h.entries[i].value)
+	}
+	return next
+}
+
+/*
+RandomFields returns random fields and their values, like HRANDFIELD: count distinct fields if count > 0,
+or all the fields if there are fewer, and -count fields that may repeat if count < 0, at most MaxRandomCount.
+*/
+func (h *Hash) RandomFields(count int) ([]string, []string) {
+	positions := randomPositions(len(h.entries), count)
+	fields := make([]string, len(positions))
+	values := make([]string, len(positions))
+	for i, pos := range positions {
+		fields[i], values[i] = h.entries[pos].field, h.entries[pos].value
+	}
+	return fields, values
+}
+
+func (h *Hash) GetMemUsage() uint64 {
+	return hashSize + h.entriesMemUsage
+}
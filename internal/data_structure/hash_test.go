@@ -0,0 +1,114 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHash_SetGetDel(t *testing.T) {
+	h := CreateHash()
+	assert.True(t, h.Set("a", "1"))
+	assert.True(t, h.Set("b", "2"))
+	assert.False(t, h.Set("a", "3"))
+	v, ok := h.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "3", v)
+	_, ok = h.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 2, h.Len())
+
+	assert.True(t, h.Del("a"))
+	assert.False(t, h.Del("a"))
+	assert.Equal(t, 1, h.Len())
+	h.Del("b")
+	assert.EqualValues(t, hashSize, h.GetMemUsage())
+}
+
+func TestHash_Encoding(t *testing.T) {
+	h := CreateHash()
+	for i := 0; i < config.HashMaxListpackEntries; i++ {
+		h.Set("f"+strconv.Itoa(i), "v")
+	}
+	assert.EqualValues(t, constant.ObjEncodingListpack, h.Encoding())
+	small := h.GetMemUsage()
+	h.Set("one more", "v")
+	assert.EqualValues(t, constant.ObjEncodingHashTable, h.Encoding())
+	assert.Greater(t, h.GetMemUsage(), small)
+
+	// the fields stay reachable after the conversion, and after deletions
+	for i := 0; i < config.HashMaxListpackEntries; i += 2 {
+		assert.True(t, h.Del("f"+strconv.Itoa(i)))
+	}
+	for i := 1; i < config.HashMaxListpackEntries; i += 2 {
+		v, ok := h.Get("f" + strconv.Itoa(i))
+		assert.True(t, ok)
+		assert.Equal(t, "v", v)
+	}
+	assert.EqualValues(t, constant.ObjEncodingHashTable, h.Encoding())
+
+	h = CreateHash()
+	h.Set("f", strings.Repeat("x", config.HashMaxListpackValue+1))
+	assert.EqualValues(t, constant.ObjEncodingHashTable, h.Encoding())
+
+	// a long value converts when it overwrites a field too
+	h = CreateHash()
+	h.Set("f", "v")
+	h.Set("f", strings.Repeat("x", config.HashMaxListpackValue+1))
+	assert.EqualValues(t, constant.ObjEncodingHashTable, h.Encoding())
+	assert.EqualValues(t, 1, h.Len())
+}
+
+func TestHash_Scan(t *testing.T) {
+	h := CreateHash()
+	h.Set("a", "1")
+	h.Set("b", "2")
+	seen := map[string]string{}
+	// a small hash is returned at once
+	next := h.Scan(0, 1, func(field string, value string) {
+		seen[field] = value
+	})
+	assert.EqualValues(t, 0, next)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, seen)
+
+	for i := 0; i < 1000; i++ {
+		h.Set(strconv.Itoa(i), "v")
+	}
+	seen = map[string]string{}
+	cursor := uint64(0)
+	for {
+		cursor = h.Scan(cursor, 10, func(field string, value string) {
+			seen[field] = value
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 1002, len(seen))
+}
+
+func TestHash_RandomFields(t *testing.T) {
+	h := CreateHash()
+	for i := 0; i < 10; i++ {
+		h.Set(strconv.Itoa(i), "v"+strconv.Itoa(i))
+	}
+	for _, count := range []int{1, 3, 5, 10, 20} {
+		fields, values := h.RandomFields(count)
+		assert.Equal(t, min(count, 10), len(fields))
+		seen := map[string]struct{}{}
+		for i, f := range fields {
+			seen[f] = struct{}{}
+			assert.Equal(t, "v"+f, values[i])
+		}
+		assert.Equal(t, len(fields), len(seen))
+	}
+	fields, _ := h.RandomFields(-30)
+	assert.Equal(t, 30, len(fields))
+	// the fields that may repeat are bounded
+	fields, _ = h.RandomFields(math.MinInt)
+	assert.Equal(t, MaxRandomCount, len(fields))
+}
@@ -0,0 +1,286 @@
+package data_structure
+
+import (
+	"github.com/spaolacci/murmur3"
+	"math"
+	"memkv/internal/config"
+	"reflect"
+	"sort"
+)
+
+/*
+HyperLogLog estimates the number of distinct elements added to it, with a standard error of 0.81%, in at most
+12KB. Like Redis, it uses 2^14 registers of 6 bits: an element is hashed to 64 bits, the low 14 bits select a
+register and the register keeps the greatest position of the first set bit seen in the remaining 50 bits.
+The cardinality is estimated from the histogram of the registers with the estimator of Otmar Ertl,
+https://arxiv.org/abs/1702.01284, which needs no bias correction for small or large cardinalities.
+
+A new HyperLogLog uses the sparse encoding: only the registers that are not 0 are stored, as a sorted list of
+register index and value, 4 bytes each. Most of the registers are 0 as long as few elements were added, which keeps
+small counters cheap. Once the list grows past config.HllSparseMaxBytes it is converted to the dense encoding,
+which packs all the registers in 12KB. It is never converted back.
+*/
+
+const (
+	hllP         = 14
+	hllRegisters = 1 << hllP
+	// hllQ is the number of hash bits left to count the zeros from
+	hllQ        = 64 - hllP
+	hllBits     = 6
+	hllRegMax   = 1<<hllBits - 1
+	hllDenseLen = hllRegisters * hllBits / 8
+	// hllAlphaInf is the asymptotic constant of the estimator, 1/(2 ln 2)
+	hllAlphaInf = 0.721347520444481703680
+)
+
+type HyperLogLog struct {
+	// sparse holds index<<hllBits | value for every register that is not 0, sorted by index. It is nil
+	// for the dense encoding.
+	sparse []uint32
+	// dense packs the registers, little-endian, hllBits each
+	dense []byte
+	// card caches the estimation until a register changes, when cardValid is false
+	card      uint64
+	cardValid bool
+}
+
+var hllSize = uint64(reflect.TypeOf(HyperLogLog{}).Size())
+
+func CreateHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{sparse: make([]uint32, 0)}
+}
+
+func (h *HyperLogLog) IsSparse() bool {
+	return h.dense == nil
+}
+
+// hllHash returns the register of element and the value it sets, the position of the first set bit
+func hllHash(element string) (uint32, uint8) {
+	hash := murmur3.Sum64WithSeed([]byte(element), ABigSeed)
+	index := uint32(hash & (hllRegisters - 1))
+	hash >>= hllP
+	// the bit after the Q bits ends the count if they are all 0
+	hash |= 1 << hllQ
+	count := uint8(1)
+	for hash&1 == 0 {
+		count++
+		hash >>= 1
+	}
+	return index, count
+}
+
+func (h *HyperLogLog) denseGet(index uint32) uint8 {
+	bit := index * hllBits
+	b, shift := bit/8, bit%8
+	v := uint16(h.dense[b]) >> shift
+	if shift > 8-hllBits {
+		v |= uint16(h.dense[b+1]) << (8 - shift)
+	}
+	return uint8(v & hllRegMax)
+}
+
+func (h *HyperLogLog) denseSet(index uint32, value uint8) {
+	bit := index * hllBits
+	b, shift := bit/8, bit%8
+	h.dense[b] &^= byte(hllRegMax << shift)
+	h.dense[b] |= byte(uint16(value) << shift)
+	if shift > 8-hllBits {
+		h.dense[b+1] &^= byte(hllRegMax >> (8 - shift))
+		h.dense[b+1] |= value >> (8 - shift)
+	}
+}
+
+// get returns the value of a register
+func (h *HyperLogLog) get(index uint32) uint8 {
+	if !h.IsSparse() {
+		return h.denseGet(index)
+	}
+	i := sort.Search(len(h.sparse), func(i int) bool { return h.sparse[i]>>hllBits >= index })
+	if i < len(h.sparse) && h.sparse[i]>>hllBits == index {
+		return uint8(h.sparse[i] & hllRegMax)
+	}
+	return 0
+}
+
+// update raises a register to value, and returns true if it changed
+func (h *HyperLogLog) update(index uint32, value uint8) bool {
+	if !h.IsSparse() {
+		if h.denseGet(index) >= value {
+			return false
+		}
+		h.denseSet(index, value)
+		h.cardValid = false
+		return true
+	}
+	entry := index<<hllBits | uint32(value)
+	i := sort.Search(len(h.sparse), func(i int) bool { return h.sparse[i]>>hllBits >= index })
+	if i < len(h.sparse) && h.sparse[i]>>hllBits == index {
+		if uint8(h.sparse[i]&hllRegMax) >= value {
+			return false
+		}
+		h.sparse[i] = entry
+	} else {
+		h.sparse = insertAt(h.sparse, i, entry)
+		if 4*len(h.sparse) > config.HllSparseMaxBytes {
+			h.convert()
+		}
+	}
+	h.cardValid = false
+	return true
+}
+
+// convert switches to the dense encoding
+func (h *HyperLogLog) convert() {
+	h.dense = make([]byte, hllDenseLen)
+	for _, entry := range h.sparse {
+		h.denseSet(entry>>hllBits, uint8(entry&hllRegMax))
+	}
+	h.sparse = nil
+}
+
+// Add adds elements, and returns true if a register changed, i.e. the estimation may have changed
+func (h *HyperLogLog) Add(elements ...string) bool {
+	updated := false
+	for _, element := range elements {
+		if h.update(hllHash(element)) {
+			updated = true
+		}
+	}
+	return updated
+}
+
+// forEachRegister calls fn for every register that is not 0
+func (h *HyperLogLog) forEachRegister(fn func(index uint32, value uint8)) {
+	if h.IsSparse() {
+		for _, entry := range h.sparse {
+			fn(entry>>hllBits, uint8(entry&hllRegMax))
+		}
+		return
+	}
+	for index := uint32(0); index < hllRegisters; index++ {
+		if v := h.denseGet(index); v != 0 {
+			fn(index, v)
+		}
+	}
+}
+
+// Merge sets every register to the greatest of its value and the value in other, h then estimates the
+// cardinality of the union of both
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	other.forEachRegister(func(index uint32, value uint8) {
+		h.update(index, value)
+	})
+}
+
+// Count returns the estimated number of distinct elements added
+func (h *HyperLogLog) Count() uint64 {
+	if h.cardValid {
+		return h.card
+	}
+	var histogram [hllQ + 2]int
+	histogram[0] = hllRegisters
+	h.forEachRegister(func(index uint32, value uint8) {
+		histogram[0]--
+		histogram[value]++
+	})
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	h.card = uint64(math.Round(hllAlphaInf * m * m / z))
+	h.cardValid = true
+	return h.card
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+func (h *HyperLogLog) GetMemUsage() uint64 {
+	return hllSize + 4*uint64(cap(h.sparse)) + uint64(len(h.dense))
+}
+
+// MarshalBinary encodes the encoding, 0 for sparse and 1 for dense, and the registers in that encoding
+func (h *HyperLogLog) MarshalBinary() ([]byte, error) {
+	if h.IsSparse() {
+		b := make([]byte, 0, 5+4*len(h.sparse))
+		b = append(b, 0)
+		b = appendUint32(b, uint32(len(h.sparse)))
+		for _, entry := range h.sparse {
+			b = appendUint32(b, entry)
+		}
+		return b, nil
+	}
+	return append([]byte{1}, h.dense...), nil
+}
+
+func (h *HyperLogLog) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	encoding := r.next(1)
+	switch {
+	case encoding == nil:
+	case encoding[0] == 0:
+		n := r.uint32()
+		if uint64(n)*4 != uint64(len(r.data)) {
+			return ErrCorruptedPayload
+		}
+		h.sparse = make([]uint32, n)
+		h.dense = nil
+		for i := range h.sparse {
+			h.sparse[i] = r.uint32()
+			index, value := h.sparse[i]>>hllBits, h.sparse[i]&hllRegMax
+			if index >= hllRegisters || value == 0 || value > hllQ+1 || (i > 0 && index <= h.sparse[i-1]>>hllBits) {
+				return ErrCorruptedPayload
+			}
+		}
+	case encoding[0] == 1:
+		h.dense = r.bytes(hllDenseLen)
+		h.sparse = nil
+		if h.dense == nil {
+			break
+		}
+		for index := uint32(0); index < hllRegisters; index++ {
+			if h.denseGet(index) > hllQ+1 {
+				h.dense = nil
+				return ErrCorruptedPayload
+			}
+		}
+	default:
+		return ErrCorruptedPayload
+	}
+	h.cardValid = false
+	return r.done()
+}
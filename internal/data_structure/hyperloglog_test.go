@@ -0,0 +1,110 @@
+package data_structure
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"memkv/internal/config"
+	"strconv"
+	"testing"
+)
+
+func assertHLLEstimate(t *testing.T, expected int, h *HyperLogLog) {
+	// 4 standard errors
+	assert.InDelta(t, expected, h.Count(), 4*0.0081*float64(expected)+1)
+}
+
+func TestHyperLogLog_Registers(t *testing.T) {
+	h := CreateHyperLogLog()
+	h.convert()
+	for index := uint32(0); index < hllRegisters; index++ {
+		h.denseSet(index, uint8(index%(hllQ+2)))
+	}
+	for index := uint32(0); index < hllRegisters; index++ {
+		assert.EqualValues(t, index%(hllQ+2), h.denseGet(index))
+	}
+	h.denseSet(5, 0)
+	assert.EqualValues(t, 0, h.denseGet(5))
+	assert.EqualValues(t, 4, h.denseGet(4))
+	assert.EqualValues(t, 6, h.denseGet(6))
+}
+
+func TestHyperLogLog_Add(t *testing.T) {
+	h := CreateHyperLogLog()
+	assert.EqualValues(t, 0, h.Count())
+	assert.True(t, h.Add("a", "b", "c"))
+	assert.False(t, h.Add("a"))
+	assert.EqualValues(t, 3, h.Count())
+	assert.True(t, h.IsSparse())
+
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		h := CreateHyperLogLog()
+		for i := 0; i < n; i++ {
+			h.Add(strconv.Itoa(i))
+		}
+		assertHLLEstimate(t, n, h)
+	}
+}
+
+func TestHyperLogLog_Encodings(t *testing.T) {
+	sparse := CreateHyperLogLog()
+	dense := CreateHyperLogLog()
+	dense.convert()
+	for i := 0; i < 500; i++ {
+		sparse.Add(strconv.Itoa(i))
+		dense.Add(strconv.Itoa(i))
+	}
+	assert.True(t, sparse.IsSparse())
+	assert.EqualValues(t, dense.Count(), sparse.Count())
+	assert.Less(t, sparse.GetMemUsage(), dense.GetMemUsage())
+
+	// converted once the registers that are not 0 take more than config.HllSparseMaxBytes
+	i := 500
+	for ; sparse.IsSparse(); i++ {
+		sparse.Add(strconv.Itoa(i))
+		dense.Add(strconv.Itoa(i))
+	}
+	assert.Greater(t, i, config.HllSparseMaxBytes/4)
+	assert.EqualValues(t, dense.dense, sparse.dense)
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a := CreateHyperLogLog()
+	b := CreateHyperLogLog()
+	for i := 0; i < 20000; i++ {
+		a.Add(strconv.Itoa(i))
+	}
+	for i := 10000; i < 30000; i++ {
+		b.Add(strconv.Itoa(i))
+	}
+	// a dense HyperLogLog merged into a sparse one converts it
+	union := CreateHyperLogLog()
+	union.Add("0")
+	union.Merge(a)
+	union.Merge(b)
+	assert.False(t, union.IsSparse())
+	assertHLLEstimate(t, 30000, union)
+	assert.GreaterOrEqual(t, union.Count(), a.Count())
+}
+
+func TestHyperLogLog_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 10, 5000} {
+		h := CreateHyperLogLog()
+		for i := 0; i < n; i++ {
+			h.Add(strconv.Itoa(i))
+		}
+		data, err := h.MarshalBinary()
+		assert.Nil(t, err)
+
+		loaded := &HyperLogLog{}
+		assert.Nil(t, loaded.UnmarshalBinary(data))
+		assert.EqualValues(t, h.IsSparse(), loaded.IsSparse())
+		assert.EqualValues(t, h.Count(), loaded.Count())
+		assert.NotNil(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	}
+	assert.NotNil(t, (&HyperLogLog{}).UnmarshalBinary([]byte{2}))
+	// registers out of order
+	assert.NotNil(t, (&HyperLogLog{}).UnmarshalBinary([]byte{0, 2, 0, 0, 0, 0x41, 0, 0, 0, 0x01, 0, 0, 0}))
+	// dense registers greater than hllQ+1
+	dense := append([]byte{1}, bytes.Repeat([]byte{0xff}, hllDenseLen)...)
+	assert.NotNil(t, (&HyperLogLog{}).UnmarshalBinary(dense))
+}
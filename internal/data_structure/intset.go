@@ -0,0 +1,57 @@
+package data_structure
+
+import (
+	"sort"
+	"strconv"
+)
+
+// intset holds sorted integers, like the intset of Redis: a member is found by a binary search, and every
+// member only takes 8 bytes
+type intset []int64
+
+// parseSetInt returns the integer of s if s is the canonical decimal form of an int64, which can be
+// formatted back to s
+func parseSetInt(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != s {
+		return 0, false
+	}
+	return v, true
+}
+
+// search returns the position of v, or the position where it would be inserted and false
+func (is intset) search(v int64) (int, bool) {
+	i := sort.Search(len(is), func(i int) bool {
+		return is[i] >= v
+	})
+	return i, i < len(is) && is[i] == v
+}
+
+// add inserts v and returns true if it wasn't a member
+func (is *intset) add(v int64) bool {
+	i, found := is.search(v)
+	if found {
+		return false
+	}
+	*is = append(*is, 0)
+	copy((*is)[i+1:], (*is)[i:])
+	(*is)[i] = v
+	return true
+}
+
+// remove deletes v and returns true if it was a member
+func (is *intset) remove(v int64) bool {
+	i, found := is.search(v)
+	if !found {
+		return false
+	}
+	*is = append((*is)[:i], (*is)[i+1:]...)
+	return true
+}
+
+func (is intset) get(i int) string {
+	return strconv.FormatInt(is[i], 10)
+}
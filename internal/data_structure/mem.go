@@ -0,0 +1,45 @@
+package data_structure
+
+import "reflect"
+
+/*
+Estimated memory usage of the data structures, reported by their GetMemUsage methods. The sizes of
+the Go runtime structures are approximations for 64-bit platforms: they don't need to be exact, only
+to grow with the data so that maxmemory evicts before the process runs out of memory.
+*/
+
+const (
+	// StringHeaderSize is the size of a string header, the bytes are counted apart
+	StringHeaderSize = 16
+	// MapEntryOverhead is the average cost of a map entry besides its key and value, including
+	// the hash bucket slack
+	MapEntryOverhead = 16
+	pointerSize      = 8
+)
+
+var objSize = uint64(reflect.TypeOf(Obj{}).Size())
+
+type memUsager interface {
+	GetMemUsage() uint64
+}
+
+// StringMemUsage returns the memory used by s
+func StringMemUsage(s string) uint64 {
+	return StringHeaderSize + uint64(len(s))
+}
+
+// valueMemUsage returns the memory used by the value of an Obj
+func valueMemUsage(v interface{}) uint64 {
+	switch v := v.(type) {
+	case string:
+		return StringMemUsage(v)
+	case memUsager:
+		return v.GetMemUsage()
+	}
+	return 0
+}
+
+// objMemUsage returns the memory used by the entry of obj in the dict and its slot in the keys, key included
+func objMemUsage(key string, obj *Obj) uint64 {
+	return MapEntryOverhead + StringMemUsage(key) + 2*pointerSize + objSize + valueMemUsage(obj.Value)
+}
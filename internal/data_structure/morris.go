@@ -0,0 +1,119 @@
+package data_structure
+
+import (
+	"math"
+	"reflect"
+)
+
+// Implementation of the Morris approximate counter
+// https://www.inf.ed.ac.uk/teaching/courses/exc/reading/morris.pdf
+
+// MorrisDefaultBase gives a relative error of about 22%, and counts up to about 3.6e11
+const MorrisDefaultBase = 1.1
+
+// MorrisMaxBase is the base of the original counter of Morris
+const MorrisMaxBase = 2
+
+/*
+MorrisCounter counts events in a single byte. The counter c estimates (base^c - 1) / (base - 1) events, and an
+event increments it with a probability of base^-c. The estimation is unbiased, with a relative standard error
+of about sqrt((base - 1) / 2): a smaller base is more accurate, but the counter saturates at 255 sooner.
+Like TopK, the increments draw numbers from a pseudo-random generator stored in the counter, so that the AOF
+rebuilds the same counter.
+*/
+type MorrisCounter struct {
+	base    float64
+	counter uint8
+	rng     uint64
+}
+
+func CreateMorrisCounter(base float64) *MorrisCounter {
+	return &MorrisCounter{
+		base: base,
+		rng:  uint64(ABigSeed),
+	}
+}
+
+// random returns a pseudo-random number in [0, 1), from a xorshift64* generator
+func (m *MorrisCounter) random() float64 {
+	m.rng ^= m.rng >> 12
+	m.rng ^= m.rng << 25
+	m.rng ^= m.rng >> 27
+	return float64((m.rng*2685821657736338717)>>11) / (1 << 53)
+}
+
+/*
+IncrBy counts n more events. Rather than trying every event, it draws the number of events before the next
+increment of the counter from a geometric distribution, so that n events only cost one draw per increment.
+*/
+func (m *MorrisCounter) IncrBy(n uint64) {
+	for n > 0 && m.counter < math.MaxUint8 {
+		p := math.Pow(m.base, -float64(m.counter))
+		trials := uint64(1)
+		if p < 1 {
+			// the number of events until one increments the counter, with u in (0, 1]
+			u := 1 - m.random()
+			t := math.Floor(math.Log(u)/math.Log1p(-p)) + 1
+			if t > float64(n) {
+				return
+			}
+			trials = uint64(t)
+		}
+		n -= trials
+		m.counter++
+	}
+}
+
+// Estimate returns the estimated number of events
+func (m *MorrisCounter) Estimate() float64 {
+	return (math.Pow(m.base, float64(m.counter)) - 1) / (m.base - 1)
+}
+
+/*
+Merge counts the events of other too. The counter with the greatest estimation is kept, and the estimation of
+the other one is added to it, which keeps the estimation unbiased.
+*/
+func (m *MorrisCounter) Merge(other *MorrisCounter) {
+	added := other.Estimate()
+	if added > m.Estimate() {
+		// with the same base, the greatest counter can be taken as is
+		if m.base == other.base {
+			added = m.Estimate()
+			m.counter = other.counter
+		}
+	}
+	m.IncrBy(uint64(math.Round(math.Min(added, 1<<63))))
+}
+
+func (m *MorrisCounter) GetBase() float64 {
+	return m.base
+}
+
+func (m *MorrisCounter) GetCounter() uint8 {
+	return m.counter
+}
+
+func (m *MorrisCounter) GetMemUsage() uint64 {
+	return uint64(reflect.TypeOf(*m).Size())
+}
+
+func (m *MorrisCounter) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 17)
+	b = appendFloat64(b, m.base)
+	b = append(b, m.counter)
+	b = appendUint64(b, m.rng)
+	return b, nil
+}
+
+func (m *MorrisCounter) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	m.base = r.float64()
+	if counter := r.next(1); counter != nil {
+		m.counter = counter[0]
+	}
+	m.rng = r.uint64()
+	if r.err == nil && !(m.base > 1 && m.base <= MorrisMaxBase) {
+		return ErrCorruptedPayload
+	}
+	return r.done()
+}
@@ -0,0 +1,72 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMorrisCounter_IncrBy(t *testing.T) {
+	m := CreateMorrisCounter(MorrisDefaultBase)
+	assert.EqualValues(t, 0, m.Estimate())
+	// the first event always increments the counter
+	m.IncrBy(1)
+	assert.EqualValues(t, 1, m.GetCounter())
+	assert.InDelta(t, 1, m.Estimate(), 1e-9)
+
+	// the average of many counters is close to the number of events
+	for _, n := range []uint64{10, 1000, 1000000} {
+		var sum float64
+		for i := 0; i < 200; i++ {
+			m := CreateMorrisCounter(MorrisDefaultBase)
+			m.rng += uint64(i)
+			m.IncrBy(n)
+			sum += m.Estimate()
+		}
+		assert.InDelta(t, float64(n), sum/200, float64(n)*0.05, "n %d", n)
+	}
+}
+
+func TestMorrisCounter_Saturation(t *testing.T) {
+	m := CreateMorrisCounter(MorrisMaxBase)
+	m.IncrBy(1 << 62)
+	assert.InDelta(t, 62, m.GetCounter(), 5)
+	m.counter = 255
+	m.IncrBy(1000)
+	assert.EqualValues(t, 255, m.GetCounter())
+}
+
+func TestMorrisCounter_Merge(t *testing.T) {
+	var sum float64
+	for i := 0; i < 200; i++ {
+		a := CreateMorrisCounter(MorrisDefaultBase)
+		b := CreateMorrisCounter(MorrisDefaultBase)
+		a.rng += uint64(i)
+		b.rng += uint64(1000 + i)
+		a.IncrBy(1000)
+		b.IncrBy(100000)
+		a.Merge(b)
+		sum += a.Estimate()
+	}
+	assert.InDelta(t, 101000, sum/200, 101000*0.05)
+
+	// counters with different bases are merged by their estimations
+	a := CreateMorrisCounter(1.5)
+	b := CreateMorrisCounter(MorrisDefaultBase)
+	b.IncrBy(1)
+	a.Merge(b)
+	assert.EqualValues(t, 1, a.Estimate())
+}
+
+func TestMorrisCounter_MarshalBinary(t *testing.T) {
+	m := CreateMorrisCounter(1.2)
+	m.IncrBy(12345)
+	data, err := m.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &MorrisCounter{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, m, loaded)
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.NotNil(t, loaded.UnmarshalBinary(append(data, 0)))
+	assert.NotNil(t, loaded.UnmarshalBinary(make([]byte, 17)))
+}
@@ -0,0 +1,433 @@
+package data_structure
+
+import "reflect"
+
+/*
+QuickList is a deque of strings, like the Redis quicklist: a doubly linked list of nodes that each hold a
+small array of elements. Pushing and popping at both ends is O(1), and the arrays use much less memory
+than a linked list of single elements. A node is full when it holds quickListNodeMaxEntries elements or
+quickListNodeMaxBytes bytes, like list-max-listpack-size -2 in Redis.
+*/
+
+const (
+	quickListNodeMaxEntries = 128
+	quickListNodeMaxBytes   = 8 * 1024
+)
+
+const (
+	ListHead = 0
+	ListTail = 1
+)
+
+type quickListNode struct {
+	prev    *quickListNode
+	next    *quickListNode
+	entries []string
+	// bytes is the total length of entries
+	bytes int
+}
+
+type QuickList struct {
+	head   *quickListNode
+	tail   *quickListNode
+	length int
+	nodes  int
+	// entriesMemUsage is the memory used by the elements
+	entriesMemUsage uint64
+}
+
+var quickListSize = uint64(reflect.TypeOf(QuickList{}).Size())
+var quickListNodeSize = uint64(reflect.TypeOf(quickListNode{}).Size())
+
+func CreateQuickList() *QuickList {
+	return &QuickList{}
+}
+
+func (n *quickListNode) canAdd(value string) bool {
+	if len(n.entries) == 0 {
+		return true
+	}
+	return len(n.entries) < quickListNodeMaxEntries && n.bytes+len(value) <= quickListNodeMaxBytes
+}
+
+func (q *QuickList) Len() int {
+	return q.length
+}
+
+// linkAfter inserts the new node n after prev, or at the head if prev is nil
+func (q *QuickList) linkAfter(prev *quickListNode, n *quickListNode) {
+	n.prev = prev
+	if prev == nil {
+		n.next = q.head
+		q.head = n
+	} else {
+		n.next = prev.next
+		prev.next = n
+	}
+	if n.next == nil {
+		q.tail = n
+	} else {
+		n.next.prev = n
+	}
+	q.nodes++
+}
+
+func (q *QuickList) unlink(n *quickListNode) {
+	if n.prev == nil {
+		q.head = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.next == nil {
+		q.tail = n.prev
+	} else {
+		n.next.prev = n.prev
+	}
+	n.prev, n.next = nil, nil
+	q.nodes--
+}
+
+// insertAt inserts value at offset off of node n, n is nil when the list is empty
+func (q *QuickList) insertAt(n *quickListNode, off int, value string) {
+	q.length++
+	q.entriesMemUsage += StringMemUsage(value)
+	switch {
+	case n == nil:
+		n = &quickListNode{}
+		q.linkAfter(q.tail, n)
+	case n.canAdd(value):
+	case off == 0 && n.prev != nil && n.prev.canAdd(value):
+		n, off = n.prev, len(n.prev.entries)
+	case off == len(n.entries) && n.next != nil && n.next.canAdd(value):
+		n, off = n.next, 0
+	default:
+		// the node is full: the elements after off move to a new node, and value goes at the end
+		// of the first part if it fits, or in a node of its own between the two parts
+		if off > 0 && off < len(n.entries) {
+			right := &quickListNode{entries: append([]string(nil), n.entries[off:]...)}
+			for _, v := range right.entries {
+				right.bytes += len(v)
+			}
+			clear(n.entries[off:])
+			n.entries = n.entries[:off]
+			n.bytes -= right.bytes
+			q.linkAfter(n, right)
+		}
+		if off == 0 || !n.canAdd(value) {
+			single := &quickListNode{}
+			if off == 0 {
+				q.linkAfter(n.prev, single)
+			} else {
+				q.linkAfter(n, single)
+			}
+			n, off = single, 0
+		}
+	}
+	n.entries = append(n.entries, "")
+	copy(n.entries[off+1:], n.entries[off:])
+	n.entries[off] = value
+	n.bytes += len(value)
+}
+
+// deleteAt removes the element at offset off of node n, and the node if it becomes empty
+func (q *QuickList) deleteAt(n *quickListNode, off int) string {
+	value := n.entries[off]
+	switch off {
+	case 0:
+		n.entries[0] = ""
+		n.entries = n.entries[1:]
+	default:
+		copy(n.entries[off:], n.entries[off+1:])
+		n.entries[len(n.entries)-1] = ""
+		n.entries = n.entries[:len(n.entries)-1]
+	}
+	n.bytes -= len(value)
+	if len(n.entries) == 0 {
+		q.unlink(n)
+	}
+	q.length--
+	q.entriesMemUsage -= StringMemUsage(value)
+	return value
+}
+
+// locate returns the node holding the element at index i, which must be in range, and its offset in the node
+func (q *QuickList) locate(i int) (*quickListNode, int) {
+	if i < q.length/2 {
+		n := q.head
+		for i >= len(n.entries) {
+			i -= len(n.entries)
+			n = n.next
+		}
+		return n, i
+	}
+	i = q.length - 1 - i
+	n := q.tail
+	for i >= len(n.entries) {
+		i -= len(n.entries)
+		n = n.prev
+	}
+	return n, len(n.entries) - 1 - i
+}
+
+// normalizeIndex turns a negative index, counted from the tail, into an index from the head,
+// and returns false if it is out of range
+func (q *QuickList) normalizeIndex(i int) (int, bool) {
+	if i < 0 {
+		i += q.length
+	}
+	return i, i >= 0 && i < q.length
+}
+
+// Push adds the values one after the other at the head or at the tail of the list, so that pushing
+// a b c at the head gives c b a
+func (q *QuickList) Push(where int, values ...string) {
+	for _, v := range values {
+		if where == ListHead {
+			q.insertAt(q.head, 0, v)
+		} else if q.tail == nil {
+			q.insertAt(nil, 0, v)
+		} else {
+			q.insertAt(q.tail, len(q.tail.entries), v)
+		}
+	}
+}
+
+// Pop removes and returns the element at the head or at the tail, false if the list is empty
+func (q *QuickList) Pop(where int) (string, bool) {
+	if q.length == 0 {
+		return "", false
+	}
+	if where == ListHead {
+		return q.deleteAt(q.head, 0), true
+	}
+	return q.deleteAt(q.tail, len(q.tail.entries)-1), true
+}
+
+// Index returns the element at index i, negative indexes count from the tail: -1 is the last element
+func (q *QuickList) Index(i int) (string, bool) {
+	i, ok := q.normalizeIndex(i)
+	if !ok {
+		return "", false
+	}
+	n, off := q.locate(i)
+	return n.entries[off], true
+}
+
+// Set replaces the element at index i, it returns false if i is out of range
+func (q *QuickList) Set(i int, value string) bool {
+	i, ok := q.normalizeIndex(i)
+	if !ok {
+		return false
+	}
+	n, off := q.locate(i)
+	old := n.entries[off]
+	n.entries[off] = value
+	n.bytes += len(value) - len(old)
+	q.entriesMemUsage += StringMemUsage(value)
+	q.entriesMemUsage -= StringMemUsage(old)
+	return true
+}
+
+/*
+rangeIndexes converts the inclusive range start..stop, where negative indexes count from the tail,
+to indexes from the head clamped to the list, like LRANGE. It returns false if the range is empty.
+*/
+func (q *QuickList) rangeIndexes(start int, stop int) (int, int, bool) {
+	if start < 0 {
+		start = max(start+q.length, 0)
+	}
+	if stop < 0 {
+		stop += q.length
+	}
+	stop = min(stop, q.length-1)
+	if start > stop {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// Range returns the elements from start to stop included, negative indexes count from the tail
+func (q *QuickList) Range(start int, stop int) []string {
+	start, stop, ok := q.rangeIndexes(start, stop)
+	if !ok {
+		return []string{}
+	}
+	res := make([]string, 0, stop-start+1)
+	n, off := q.locate(start)
+	for len(res) < cap(res) {
+		if off == len(n.entries) {
+			n, off = n.next, 0
+			continue
+		}
+		res = append(res, n.entries[off])
+		off++
+	}
+	return res
+}
+
+// Trim keeps only the elements from start to stop included, negative indexes count from the tail
+func (q *QuickList) Trim(start int, stop int) {
+	start, stop, ok := q.rangeIndexes(start, stop)
+	if !ok {
+		start, stop = q.length, q.length-1
+	}
+	q.deleteHead(start)
+	q.deleteTail(q.length - (stop - start + 1))
+}
+
+// deleteHead removes the first count elements, dropping whole nodes when possible
+func (q *QuickList) deleteHead(count int) {
+	for count > 0 {
+		n := q.head
+		if count < len(n.entries) {
+			for ; count > 0; count-- {
+				q.deleteAt(n, 0)
+			}
+			return
+		}
+		count -= len(n.entries)
+		q.dropNode(n)
+	}
+}
+
+// deleteTail removes the last count elements, dropping whole nodes when possible
+func (q *QuickList) deleteTail(count int) {
+	for count > 0 {
+		n := q.tail
+		if count < len(n.entries) {
+			for ; count > 0; count-- {
+				q.deleteAt(n, len(n.entries)-1)
+			}
+			return
+		}
+		count -= len(n.entries)
+		q.dropNode(n)
+	}
+}
+
+func (q *QuickList) dropNode(n *quickListNode) {
+	for _, v := range n.entries {
+		q.entriesMemUsage -= StringMemUsage(v)
+	}
+	q.length -= len(n.entries)
+	q.unlink(n)
+}
+
+/*
+Rem removes the elements equal to value: the first count ones from the head if count > 0, the last
+-count ones from the tail if count < 0, or all of them if count is 0. It returns the number of removed elements.
+*/
+func (q *QuickList) Rem(count int, value string) int {
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := 0
+	if count >= 0 {
+		for n := q.head; n != nil && (limit == 0 || removed < limit); {
+			next := n.next
+			for i := 0; i < len(n.entries) && (limit == 0 || removed < limit); {
+				if n.entries[i] == value {
+					q.deleteAt(n, i)
+					removed++
+					continue
+				}
+				i++
+			}
+			n = next
+		}
+		return removed
+	}
+	for n := q.tail; n != nil && removed < limit; {
+		prev := n.prev
+		for i := len(n.entries) - 1; i >= 0 && removed < limit; i-- {
+			if n.entries[i] == value {
+				q.deleteAt(n, i)
+				removed++
+			}
+		}
+		n = prev
+	}
+	return removed
+}
+
+// Insert inserts value before or after the first element equal to pivot, and returns the new length
+// of the list, or -1 if pivot was not found
+func (q *QuickList) Insert(pivot string, value string, after bool) int {
+	for n := q.head; n != nil; n = n.next {
+		for off, v := range n.entries {
+			if v != pivot {
+				continue
+			}
+			if after {
+				off++
+			}
+			q.insertAt(n, off, value)
+			return q.length
+		}
+	}
+	return -1
+}
+
+/*
+Pos returns the indexes of the elements equal to value, like LPOS. The matches are searched from the head,
+or from the tail if rank is negative, and the first |rank|-1 matches are skipped. At most count indexes are
+returned, and only the first maxLen elements are compared, 0 means no limit for both.
+*/
+func (q *QuickList) Pos(value string, rank int, count int, maxLen int) []int {
+	res := make([]int, 0)
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	compared := 0
+	match := func(i int, v string) bool {
+		if maxLen > 0 && compared == maxLen {
+			return false
+		}
+		compared++
+		if v == value {
+			if skip > 0 {
+				skip--
+			} else {
+				res = append(res, i)
+			}
+		}
+		return count == 0 || len(res) < count
+	}
+	if rank > 0 {
+		i := 0
+		for n := q.head; n != nil; n = n.next {
+			for _, v := range n.entries {
+				if !match(i, v) {
+					return res
+				}
+				i++
+			}
+		}
+		return res
+	}
+	i := q.length - 1
+	for n := q.tail; n != nil; n = n.prev {
+		for off := len(n.entries) - 1; off >= 0; off-- {
+			if !match(i, n.entries[off]) {
+				return res
+			}
+			i--
+		}
+	}
+	return res
+}
+
+// ForEach calls fn for every element, from the head to the tail
+func (q *QuickList) ForEach(fn func(value string)) {
+	for n := q.head; n != nil; n = n.next {
+		for _, v := range n.entries {
+			fn(v)
+		}
+	}
+}
+
+func (q *QuickList) GetMemUsage() uint64 {
+	return quickListSize + uint64(q.nodes)*quickListNodeSize + q.entriesMemUsage
+}
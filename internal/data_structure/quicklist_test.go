@@ -0,0 +1,191 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func quickListValues(q *QuickList) []string {
+	res := make([]string, 0)
+	q.ForEach(func(v string) {
+		res = append(res, v)
+	})
+	return res
+}
+
+// checkQuickList verifies the links, the counters and the node limits of q
+func checkQuickList(t *testing.T, q *QuickList) {
+	length, nodes := 0, 0
+	var prev *quickListNode
+	for n := q.head; n != nil; n = n.next {
+		assert.Equal(t, prev, n.prev)
+		assert.NotEmpty(t, n.entries)
+		assert.LessOrEqual(t, len(n.entries), quickListNodeMaxEntries)
+		bytes := 0
+		for _, v := range n.entries {
+			bytes += len(v)
+		}
+		assert.Equal(t, bytes, n.bytes)
+		length += len(n.entries)
+		nodes++
+		prev = n
+	}
+	assert.Equal(t, prev, q.tail)
+	assert.Equal(t, length, q.length)
+	assert.Equal(t, nodes, q.nodes)
+}
+
+func TestQuickList_PushPop(t *testing.T) {
+	q := CreateQuickList()
+	q.Push(ListHead, "a", "b", "c")
+	q.Push(ListTail, "d", "e")
+	assert.Equal(t, []string{"c", "b", "a", "d", "e"}, quickListValues(q))
+	assert.Equal(t, 5, q.Len())
+
+	v, ok := q.Pop(ListHead)
+	assert.True(t, ok)
+	assert.Equal(t, "c", v)
+	v, ok = q.Pop(ListTail)
+	assert.True(t, ok)
+	assert.Equal(t, "e", v)
+	for q.Len() > 0 {
+		q.Pop(ListTail)
+	}
+	_, ok = q.Pop(ListHead)
+	assert.False(t, ok)
+	assert.Nil(t, q.head)
+	assert.Nil(t, q.tail)
+	assert.EqualValues(t, quickListSize, q.GetMemUsage())
+}
+
+func TestQuickList_Nodes(t *testing.T) {
+	q := CreateQuickList()
+	for i := 0; i < 1000; i++ {
+		q.Push(ListTail, strconv.Itoa(i))
+	}
+	checkQuickList(t, q)
+	assert.Equal(t, (1000+quickListNodeMaxEntries-1)/quickListNodeMaxEntries, q.nodes)
+	for i := 0; i < 1000; i++ {
+		v, ok := q.Index(i)
+		assert.True(t, ok)
+		assert.Equal(t, strconv.Itoa(i), v)
+	}
+
+	// big elements fill the nodes by size
+	q = CreateQuickList()
+	big := strings.Repeat("x", quickListNodeMaxBytes/2)
+	q.Push(ListTail, big, big, big, strings.Repeat("y", 2*quickListNodeMaxBytes))
+	checkQuickList(t, q)
+	assert.Equal(t, 3, q.nodes)
+}
+
+func TestQuickList_Index(t *testing.T) {
+	q := CreateQuickList()
+	q.Push(ListTail, "a", "b", "c")
+	v, _ := q.Index(0)
+	assert.Equal(t, "a", v)
+	v, _ = q.Index(-1)
+	assert.Equal(t, "c", v)
+	v, _ = q.Index(-3)
+	assert.Equal(t, "a", v)
+	_, ok := q.Index(3)
+	assert.False(t, ok)
+	_, ok = q.Index(-4)
+	assert.False(t, ok)
+
+	assert.True(t, q.Set(-1, "z"))
+	assert.False(t, q.Set(5, "z"))
+	assert.Equal(t, []string{"a", "b", "z"}, quickListValues(q))
+}
+
+func TestQuickList_Range(t *testing.T) {
+	q := CreateQuickList()
+	q.Push(ListTail, "a", "b", "c", "d")
+	assert.Equal(t, []string{"a", "b", "c", "d"}, q.Range(0, -1))
+	assert.Equal(t, []string{"b", "c"}, q.Range(1, 2))
+	assert.Equal(t, []string{"c", "d"}, q.Range(-2, 100))
+	assert.Equal(t, []string{"a"}, q.Range(-100, 0))
+	assert.Equal(t, []string{}, q.Range(2, 1))
+	assert.Equal(t, []string{}, q.Range(5, 10))
+
+	q.Trim(1, -2)
+	assert.Equal(t, []string{"b", "c"}, quickListValues(q))
+	q.Trim(3, 2)
+	assert.Equal(t, 0, q.Len())
+	assert.Nil(t, q.head)
+}
+
+func TestQuickList_RemInsertPos(t *testing.T) {
+	q := CreateQuickList()
+	q.Push(ListTail, "a", "x", "b", "x", "c", "x")
+	assert.Equal(t, []int{1, 3, 5}, q.Pos("x", 1, 0, 0))
+	assert.Equal(t, []int{3, 5}, q.Pos("x", 2, 0, 0))
+	assert.Equal(t, []int{5, 3}, q.Pos("x", -1, 2, 0))
+	assert.Equal(t, []int{1}, q.Pos("x", 1, 0, 3))
+	assert.Equal(t, []int{}, q.Pos("y", 1, 0, 0))
+
+	assert.Equal(t, 1, q.Rem(-1, "x"))
+	assert.Equal(t, []string{"a", "x", "b", "x", "c"}, quickListValues(q))
+	assert.Equal(t, 1, q.Rem(1, "x"))
+	assert.Equal(t, []string{"a", "b", "x", "c"}, quickListValues(q))
+	assert.Equal(t, 5, q.Insert("x", "y", false))
+	assert.Equal(t, 6, q.Insert("x", "z", true))
+	assert.Equal(t, -1, q.Insert("w", "z", true))
+	assert.Equal(t, []string{"a", "b", "y", "x", "z", "c"}, quickListValues(q))
+	assert.Equal(t, 0, q.Rem(0, "w"))
+	q.Push(ListTail, "x")
+	assert.Equal(t, 2, q.Rem(0, "x"))
+}
+
+// TestQuickList_Random compares the quicklist with a slice after random operations
+func TestQuickList_Random(t *testing.T) {
+	q := CreateQuickList()
+	var ref []string
+	for i := 0; i < 20000; i++ {
+		v := strconv.Itoa(rand.Intn(50))
+		switch op := rand.Intn(10); {
+		case op < 3:
+			q.Push(ListHead, v)
+			ref = append([]string{v}, ref...)
+		case op < 6:
+			q.Push(ListTail, v)
+			ref = append(ref, v)
+		case op == 6 && len(ref) > 0:
+			q.Pop(ListHead)
+			ref = ref[1:]
+		case op == 7 && len(ref) > 0:
+			q.Pop(ListTail)
+			ref = ref[:len(ref)-1]
+		case op == 8:
+			pivot := strconv.Itoa(rand.Intn(50))
+			if q.Insert(pivot, v, true) > 0 {
+				for j := range ref {
+					if ref[j] == pivot {
+						ref = append(ref[:j+1], append([]string{v}, ref[j+1:]...)...)
+						break
+					}
+				}
+			}
+		case op == 9 && rand.Intn(20) == 0:
+			removed := q.Rem(1, v)
+			for j := range ref {
+				if ref[j] == v {
+					ref = append(ref[:j], ref[j+1:]...)
+					assert.Equal(t, 1, removed)
+					break
+				}
+			}
+		}
+	}
+	checkQuickList(t, q)
+	assert.Equal(t, ref, quickListValues(q))
+	if len(ref) > 0 {
+		assert.Equal(t, ref, q.Range(0, -1))
+		mid := len(ref) / 2
+		v, _ := q.Index(mid)
+		assert.Equal(t, ref[mid], v)
+	}
+}
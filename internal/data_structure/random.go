@@ -0,0 +1,43 @@
+package data_structure
+
+import "math/rand"
+
+// MaxRandomCount bounds the number of random positions that may repeat, which are all allocated before replying
+const MaxRandomCount = 1024 * 1024
+
+/*
+randomPositions returns random positions in [0, n), for HRANDFIELD and SRANDMEMBER: count distinct positions if
+count > 0, or all the positions if there are fewer, and -count positions that may repeat if count < 0, at most
+MaxRandomCount.
+*/
+func randomPositions(n int, count int) []int {
+	if n == 0 {
+		return nil
+	}
+	var positions []int
+	switch {
+	case count < 0:
+		positions = make([]int, -max(count, -MaxRandomCount))
+		for i := range positions {
+			positions[i] = rand.Intn(n)
+		}
+	case count >= n:
+		positions = make([]int, n)
+		for i := range positions {
+			positions[i] = i
+		}
+	case count*3 > n:
+		// most of the positions are returned, a permutation is faster than picking them one by one
+		positions = rand.Perm(n)[:count]
+	default:
+		picked := make(map[int]struct{}, count)
+		for len(positions) < count {
+			i := rand.Intn(n)
+			if _, ok := picked[i]; !ok {
+				picked[i] = struct{}{}
+				positions = append(positions, i)
+			}
+		}
+	}
+	return positions
+}
@@ -1,6 +1,7 @@
 package data_structure
 
 import (
+	"math"
 	"reflect"
 )
 
@@ -98,9 +99,88 @@ func (sb *SBChain) GetFilterNumber() int {
 }
 
 func (sb *SBChain) GetMemUsage() uint64 {
-	return uint64(reflect.TypeOf(*sb).Size())
+	res := uint64(reflect.TypeOf(*sb).Size())
+	for i := 0; i < len(sb.filters); i++ {
+		res += uint64(reflect.TypeOf(sb.filters[i]).Size()) + uint64(reflect.TypeOf(*sb.filters[i].bloom).Size()) +
+			sb.filters[i].bloom.bytes
+	}
+	return res
 }
 
 func (sb *SBChain) GetGrowthFactor() uint64 {
 	return sb.growthFactor
 }
+
+// MarshalBinary encodes the whole chain, including the bit array of every filter
+func (sb *SBChain) MarshalBinary() ([]byte, error) {
+	var b []byte
+	b = appendUint64(b, sb.size)
+	b = appendUint64(b, sb.growthFactor)
+	b = appendUint32(b, uint32(len(sb.filters)))
+	for _, link := range sb.filters {
+		b = appendUint64(b, link.size)
+		b = appendUint32(b, uint32(link.bloom.Hashes))
+		b = appendUint64(b, link.bloom.Entries)
+		b = appendFloat64(b, link.bloom.Error)
+		b = appendFloat64(b, link.bloom.bitPerEntry)
+		b = appendUint64(b, link.bloom.bytes)
+		b = append(b, link.bloom.bf...)
+	}
+	return b, nil
+}
+
+/*
+UnmarshalBinary decodes a chain encoded by MarshalBinary. The payload can come from a client with BF.LOADCHUNK,
+so the parameters of every filter are checked against the ones CreateBloomFilter computes from its capacity
+and error rate, and the filters against each other: a forged number of hashes or size would make every
+following add or lookup loop for a long time, or index out of the bit array.
+*/
+func (sb *SBChain) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	sb.size = r.uint64()
+	sb.growthFactor = r.uint64()
+	if sb.growthFactor == 0 || sb.growthFactor > math.MaxUint32 {
+		return ErrCorruptedPayload
+	}
+	n := r.uint32()
+	sb.filters = nil
+	var size uint64
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		link := SBLink{
+			size:  r.uint64(),
+			bloom: &Bloom{},
+		}
+		bloom := link.bloom
+		bloom.Hashes = int(r.uint32())
+		bloom.Entries = r.uint64()
+		bloom.Error = r.float64()
+		bloom.bitPerEntry = r.float64()
+		bloom.bytes = r.uint64()
+		if !(bloom.Error > 0 && bloom.Error < 1) || bloom.Entries == 0 || link.size > bloom.Entries {
+			return ErrCorruptedPayload
+		}
+		bitPerEntry, bytes, hashes := bloomParams(bloom.Entries, bloom.Error)
+		if bloom.bitPerEntry != bitPerEntry || bloom.bytes != bytes || bloom.Hashes != hashes {
+			return ErrCorruptedPayload
+		}
+		// a filter is added when the previous one is full, with a bigger capacity and a tighter error rate
+		if i > 0 {
+			prev := sb.filters[i-1]
+			if prev.size != prev.bloom.Entries || bloom.Entries != prev.bloom.Entries*sb.growthFactor ||
+				bloom.Error != prev.bloom.Error*ErrorTighteningRatio {
+				return ErrCorruptedPayload
+			}
+		}
+		bloom.bits = bloom.bytes * 8
+		bloom.bf = r.bytes(bloom.bytes)
+		size += link.size
+		sb.filters = append(sb.filters, link)
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+	if len(sb.filters) == 0 || size != sb.size {
+		return ErrCorruptedPayload
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package data_structure
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -54,3 +57,59 @@ func TestSBChain_Add(t *testing.T) {
 	}
 	assert.False(t, sb.Exist("50"))
 }
+
+func TestSBChain_MarshalBinary(t *testing.T) {
+	sb := CreateSBChain(10, 0.01, 2)
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, sb.Add(fmt.Sprintf("%d", i)))
+	}
+	data, err := sb.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &SBChain{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, sb.size, loaded.size)
+	assert.EqualValues(t, sb.growthFactor, loaded.growthFactor)
+	assert.EqualValues(t, len(sb.filters), len(loaded.filters))
+	for i := 0; i < 50; i++ {
+		assert.True(t, loaded.Exist(fmt.Sprintf("%d", i)))
+	}
+	assert.Equal(t, ErrCorruptedPayload, loaded.UnmarshalBinary(data[:len(data)-1]))
+}
+
+func TestSBChain_UnmarshalCorrupted(t *testing.T) {
+	sb := CreateSBChain(10, 0.01, 2)
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, sb.Add(fmt.Sprintf("%d", i)))
+	}
+	data, _ := sb.MarshalBinary()
+
+	// the number of hashes of the first filter, after the size, the growth factor, the number of filters
+	// and the size of the filter
+	forged := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(forged[28:], math.MaxUint32)
+	assert.Equal(t, ErrCorruptedPayload, (&SBChain{}).UnmarshalBinary(forged))
+	// the growth factor
+	forged = append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(forged[8:], 0)
+	assert.Equal(t, ErrCorruptedPayload, (&SBChain{}).UnmarshalBinary(forged))
+
+	// a payload accepted after random corruptions is usable
+	for i := 0; i < 5000; i++ {
+		corrupted := append([]byte(nil), data...)
+		for j := rand.Intn(3); j >= 0; j-- {
+			corrupted[rand.Intn(len(corrupted))] = byte(rand.Intn(256))
+		}
+		loaded := &SBChain{}
+		if loaded.UnmarshalBinary(corrupted) != nil {
+			continue
+		}
+		for _, link := range loaded.filters {
+			assert.LessOrEqual(t, link.bloom.Hashes, 64)
+		}
+		for j := 0; j < 100; j++ {
+			assert.Nil(t, loaded.Add(fmt.Sprintf("x%d", j)))
+			assert.True(t, loaded.Exist(fmt.Sprintf("x%d", j)))
+		}
+	}
+}
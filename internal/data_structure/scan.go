@@ -0,0 +1,22 @@
+package data_structure
+
+/*
+Cursor based iteration, used by SCAN, SSCAN and ZSCAN.
+
+The elements are stored in a dense slice, and an element is removed by moving the last element of the slice
+into its position. The slice is scanned backward from its end and the cursor is the number of positions
+that remain to be visited: an element added during the scan is appended after them, and the only element
+that can move is the last one, which either stays among the positions to visit or has already been
+returned. So every element present during the whole scan is returned at least once, maybe more than once.
+*/
+
+// scanRange returns the positions [start, end) of a slice of length n to visit for cursor, visiting up
+// to count positions, and the cursor of the next call, which is 0 once the scan is complete
+func scanRange(n int, cursor uint64, count int) (start int, end int, next uint64) {
+	end = n
+	if cursor != 0 && cursor < uint64(n) {
+		end = int(cursor)
+	}
+	start = max(end-count, 0)
+	return start, end, uint64(start)
+}
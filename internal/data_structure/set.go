@@ -7,21 +7,39 @@ type Set interface {
 	IsMember(member string) int
 	MIsMember(members ...string) []int
 	Members() []string
+	// Pop removes and returns count random members, count >= 0, or all the members if there are fewer
 	Pop(count int) []string
+	// Rand returns count distinct random members if count >= 0, and -count members that may repeat otherwise
 	Rand(count int) []string
+	// Scan calls fn for up to count members starting at cursor, and returns the cursor of the next call,
+	// 0 once every member present during the whole scan was returned
+	Scan(cursor uint64, count int, fn func(member string)) uint64
+	GetMemUsage() uint64
+	// Encoding returns the encoding of the set, constant.ObjEncodingIntset, ObjEncodingListpack or ObjEncodingHashTable
+	Encoding() uint8
+	// Dup returns a copy of the set with the same encoding, for key
+	Dup(key string) Set
 }
 
+// MultiSetOperator computes the operations between several sets, a nil Set being an empty set like a missing key.
+// The Store variants return a new set, created for the key dest.
 type MultiSetOperator interface {
+	// Move moves the members found in src to dest, which can't be nil, and returns how many were found
 	Move(src, dest Set, members ...string) int
 	Inter(keys ...Set) []string
-	InterCard(keys ...Set) int
-	InterStore(keys ...Set) Set
+	// InterCard returns the size of the intersection, counting at most limit members if limit isn't 0
+	InterCard(limit int, keys ...Set) int
+	InterStore(dest string, keys ...Set) Set
 	Diff(keys ...Set) []string
-	DiffStore(keys ...Set) Set
+	DiffStore(dest string, keys ...Set) Set
 	Union(keys ...Set) []string
-	UnionStore(keys ...Set) Set
+	UnionStore(dest string, keys ...Set) Set
 }
 
 func CreateSet(key string) Set {
 	return newSimpleSet(key)
 }
+
+func CreateMultiSetOperator() MultiSetOperator {
+	return setOperator{}
+}
@@ -0,0 +1,127 @@
+package data_structure
+
+import "sort"
+
+// setOperator implements MultiSetOperator with the methods of Set only, so it works whatever the encoding
+type setOperator struct{}
+
+func (setOperator) Move(src, dest Set, members ...string) int {
+	if src == nil {
+		return 0
+	}
+	moved := 0
+	for _, m := range members {
+		if src.IsMember(m) == 0 {
+			continue
+		}
+		moved++
+		// moving to the same set changes nothing
+		if dest != src {
+			src.Rem(m)
+			dest.Add(m)
+		}
+	}
+	return moved
+}
+
+/*
+inter calls fn for every member of the intersection, until fn returns false. The members of the smallest
+set are looked up in the others, from the smallest to the largest, so that a missing member is found early.
+*/
+func (setOperator) inter(keys []Set, fn func(member string) bool) {
+	sets := make([]Set, len(keys))
+	for i, s := range keys {
+		if s == nil || s.Size() == 0 {
+			return
+		}
+		sets[i] = s
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		return sets[i].Size() < sets[j].Size()
+	})
+	for _, m := range sets[0].Members() {
+		found := true
+		for _, s := range sets[1:] {
+			if s.IsMember(m) == 0 {
+				found = false
+				break
+			}
+		}
+		if found && !fn(m) {
+			return
+		}
+	}
+}
+
+func (o setOperator) Inter(keys ...Set) []string {
+	res := make([]string, 0)
+	o.inter(keys, func(member string) bool {
+		res = append(res, member)
+		return true
+	})
+	return res
+}
+
+func (o setOperator) InterCard(limit int, keys ...Set) int {
+	card := 0
+	o.inter(keys, func(member string) bool {
+		card++
+		return limit == 0 || card < limit
+	})
+	return card
+}
+
+func (o setOperator) InterStore(dest string, keys ...Set) Set {
+	res := CreateSet(dest)
+	res.Add(o.Inter(keys...)...)
+	return res
+}
+
+// diff calls fn for every member of the first set which isn't in any other set
+func (setOperator) diff(keys []Set, fn func(member string)) {
+	if len(keys) == 0 || keys[0] == nil {
+		return
+	}
+	for _, m := range keys[0].Members() {
+		found := false
+		for _, s := range keys[1:] {
+			if s != nil && s.IsMember(m) == 1 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fn(m)
+		}
+	}
+}
+
+func (o setOperator) Diff(keys ...Set) []string {
+	res := make([]string, 0)
+	o.diff(keys, func(member string) {
+		res = append(res, member)
+	})
+	return res
+}
+
+func (o setOperator) DiffStore(dest string, keys ...Set) Set {
+	res := CreateSet(dest)
+	o.diff(keys, func(member string) {
+		res.Add(member)
+	})
+	return res
+}
+
+func (o setOperator) Union(keys ...Set) []string {
+	return o.UnionStore("", keys...).Members()
+}
+
+func (setOperator) UnionStore(dest string, keys ...Set) Set {
+	res := CreateSet(dest)
+	for _, s := range keys {
+		if s != nil {
+			res.Add(s.Members()...)
+		}
+	}
+	return res
+}
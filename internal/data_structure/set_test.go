@@ -0,0 +1,173 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func createTestSet(members ...string) Set {
+	s := CreateSet("test")
+	s.Add(members...)
+	return s
+}
+
+func TestSetOperator_Inter(t *testing.T) {
+	o := CreateMultiSetOperator()
+	a := createTestSet("a", "b", "c", "d")
+	b := createTestSet("b", "c", "d", "e")
+	c := createTestSet("c", "d")
+	assert.ElementsMatch(t, []string{"c", "d"}, o.Inter(a, b, c))
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, o.Inter(a))
+	assert.Empty(t, o.Inter(a, nil))
+	assert.Empty(t, o.Inter(a, createTestSet("x")))
+
+	assert.EqualValues(t, 3, o.InterCard(0, a, b))
+	assert.EqualValues(t, 2, o.InterCard(2, a, b))
+	assert.EqualValues(t, 3, o.InterCard(10, a, b))
+	assert.EqualValues(t, 0, o.InterCard(0, nil, b))
+
+	res := o.InterStore("dest", a, b)
+	assert.ElementsMatch(t, []string{"b", "c", "d"}, res.Members())
+	// the sets are left unchanged
+	assert.EqualValues(t, 4, a.Size())
+}
+
+func TestSetOperator_UnionAndDiff(t *testing.T) {
+	o := CreateMultiSetOperator()
+	a := createTestSet("a", "b", "c")
+	b := createTestSet("b", "d")
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, o.Union(a, nil, b))
+	assert.Empty(t, o.Union(nil))
+	assert.EqualValues(t, 4, o.UnionStore("dest", a, b).Size())
+
+	assert.ElementsMatch(t, []string{"a", "c"}, o.Diff(a, b))
+	assert.ElementsMatch(t, []string{"a", "c"}, o.Diff(a, nil, b))
+	assert.ElementsMatch(t, []string{"d"}, o.Diff(b, a))
+	assert.Empty(t, o.Diff(nil, a))
+	assert.ElementsMatch(t, []string{"a", "c"}, o.DiffStore("dest", a, b).Members())
+}
+
+func TestSetOperator_Move(t *testing.T) {
+	o := CreateMultiSetOperator()
+	a := createTestSet("a", "b")
+	b := createTestSet("c")
+	assert.EqualValues(t, 1, o.Move(a, b, "a", "x"))
+	assert.ElementsMatch(t, []string{"b"}, a.Members())
+	assert.ElementsMatch(t, []string{"a", "c"}, b.Members())
+	assert.EqualValues(t, 1, o.Move(b, b, "c"))
+	assert.EqualValues(t, 2, b.Size())
+	assert.EqualValues(t, 0, o.Move(nil, b, "a"))
+}
+
+func TestSet_Encoding(t *testing.T) {
+	s := createTestSet("3", "1", "2", "-5")
+	assert.EqualValues(t, constant.ObjEncodingIntset, s.Encoding())
+	assert.EqualValues(t, []string{"-5", "1", "2", "3"}, s.Members())
+	// not in the canonical form of an integer
+	assert.EqualValues(t, 0, s.IsMember("01"))
+	assert.EqualValues(t, 0, s.Rem("+1", "x"))
+	assert.EqualValues(t, 1, s.Rem("2"))
+	assert.EqualValues(t, []string{"-5", "1", "3"}, s.Members())
+
+	// a member that is not an integer converts to a listpack
+	assert.EqualValues(t, 1, s.Add("01"))
+	assert.EqualValues(t, constant.ObjEncodingListpack, s.Encoding())
+	assert.ElementsMatch(t, []string{"-5", "1", "3", "01"}, s.Members())
+	assert.EqualValues(t, 1, s.IsMember("1"))
+	assert.EqualValues(t, 0, s.Add("1", "01"))
+	assert.EqualValues(t, 1, s.Rem("-5"))
+	assert.EqualValues(t, 3, s.Size())
+
+	for i := s.Size(); i < config.SetMaxListpackEntries; i++ {
+		s.Add("m" + strconv.Itoa(i))
+	}
+	assert.EqualValues(t, constant.ObjEncodingListpack, s.Encoding())
+	s.Add("another")
+	assert.EqualValues(t, constant.ObjEncodingHashTable, s.Encoding())
+	assert.EqualValues(t, config.SetMaxListpackEntries+1, s.Size())
+	assert.EqualValues(t, 1, s.IsMember("01"))
+	// it is never converted back
+	s.Rem(s.Members()[2:]...)
+	assert.EqualValues(t, constant.ObjEncodingHashTable, s.Encoding())
+	assert.EqualValues(t, 2, s.Size())
+
+	long := createTestSet("1", strings.Repeat("x", config.SetMaxListpackValue+1))
+	assert.EqualValues(t, constant.ObjEncodingHashTable, long.Encoding())
+
+	ints := createTestSet()
+	for i := 0; i < config.SetMaxIntsetEntries; i++ {
+		ints.Add(strconv.Itoa(i))
+	}
+	assert.EqualValues(t, constant.ObjEncodingIntset, ints.Encoding())
+	ints.Add("-1")
+	assert.EqualValues(t, constant.ObjEncodingHashTable, ints.Encoding())
+	assert.EqualValues(t, config.SetMaxIntsetEntries+1, ints.Size())
+	assert.EqualValues(t, 1, ints.IsMember("-1"))
+}
+
+func TestSet_CompactEncodings(t *testing.T) {
+	for _, s := range []Set{createTestSet("1", "2", "3", "4"), createTestSet("a", "b", "c", "d")} {
+		dup := s.Dup("test")
+		assert.EqualValues(t, s.Encoding(), dup.Encoding())
+		assert.EqualValues(t, s.GetMemUsage(), dup.GetMemUsage())
+
+		var scanned []string
+		next := s.Scan(0, 1, func(member string) {
+			scanned = append(scanned, member)
+		})
+		assert.EqualValues(t, 0, next)
+		assert.ElementsMatch(t, s.Members(), scanned)
+
+		popped := s.Pop(3)
+		assert.Len(t, popped, 3)
+		assert.EqualValues(t, 1, s.Size())
+		assert.EqualValues(t, 0, s.MIsMember(popped...)[0])
+		assert.EqualValues(t, 4, dup.Size())
+	}
+}
+
+func TestSet_RandAndPop(t *testing.T) {
+	members := make([]string, 1000)
+	for i := range members {
+		members[i] = "m" + strconv.Itoa(i)
+	}
+	s := createTestSet(members...)
+	assert.EqualValues(t, constant.ObjEncodingHashTable, s.Encoding())
+
+	for _, count := range []int{1, 10, 400, 999} {
+		res := s.Rand(count)
+		assert.Len(t, res, count)
+		assert.Subset(t, members, res)
+		distinct := make(map[string]struct{})
+		for _, m := range res {
+			distinct[m] = struct{}{}
+		}
+		assert.Len(t, distinct, count)
+	}
+	assert.ElementsMatch(t, members, s.Rand(2000))
+	assert.Len(t, s.Rand(-3000), 3000)
+	assert.Len(t, s.Rand(math.MinInt), MaxRandomCount)
+	assert.Empty(t, s.Rand(0))
+
+	small := createTestSet("1")
+	assert.EqualValues(t, []string{"1", "1", "1"}, small.Rand(-3))
+
+	popped := s.Pop(600)
+	assert.Len(t, popped, 600)
+	assert.EqualValues(t, 400, s.Size())
+	for _, m := range popped {
+		assert.EqualValues(t, 0, s.IsMember(m))
+	}
+	rest := s.Pop(2000)
+	assert.Len(t, rest, 400)
+	assert.ElementsMatch(t, members, append(popped, rest...))
+	assert.EqualValues(t, 0, s.Size())
+	assert.Empty(t, s.Rand(-1))
+	s.Add("a")
+	assert.EqualValues(t, []string{"a"}, s.Members())
+}
@@ -1,27 +1,139 @@
 package data_structure
 
-import "math/rand"
+import (
+	"math/rand"
+	"memkv/internal/config"
+	"memkv/internal/constant"
+	"reflect"
+)
 
-// simpleSet simply use native hashmap to store keys
+/*
+simpleSet stores the members of a set with one of three encodings, like Redis:
+  - intset, while every member is an integer: the members are sorted integers in ints, found by a binary search
+  - listpack, for a few short members: the members are found by a linear search of members
+  - hashtable: dict indexes members
+
+A new set is an intset. It is converted to the listpack encoding when it gets a member that is not an integer,
+if it is small enough, and to the hashtable encoding past config.SetMaxIntsetEntries integers,
+config.SetMaxListpackEntries members, or with a member longer than config.SetMaxListpackValue.
+It is never converted back.
+*/
 type simpleSet struct {
-	key  string
-	dict map[string]struct{}
+	key      string
+	encoding uint8
+	// ints holds the members of the intset encoding
+	ints intset
+	// members holds the members of the other encodings densely, for Scan and random members
+	members []string
+	// dict maps every member to its position in members, nil unless the encoding is hashtable
+	dict map[string]int
+	// membersMemUsage is the memory used by the members and their index
+	membersMemUsage uint64
+}
+
+var simpleSetSize = uint64(reflect.TypeOf(simpleSet{}).Size())
+
+func (s *simpleSet) memberMemUsage(member string) uint64 {
+	switch s.encoding {
+	case constant.ObjEncodingIntset:
+		return 8
+	case constant.ObjEncodingListpack:
+		return StringMemUsage(member)
+	}
+	return MapEntryOverhead + StringMemUsage(member) + 8 + StringHeaderSize
 }
 
 func newSimpleSet(key string) Set {
 	return &simpleSet{
-		key:  key,
-		dict: make(map[string]struct{}),
+		key:      key,
+		encoding: constant.ObjEncodingIntset,
+	}
+}
+
+// Encoding returns constant.ObjEncodingIntset, constant.ObjEncodingListpack or constant.ObjEncodingHashTable
+func (s *simpleSet) Encoding() uint8 {
+	return s.encoding
+}
+
+// convert switches to the listpack or hashtable encoding
+func (s *simpleSet) convert(encoding uint8) {
+	if s.encoding == constant.ObjEncodingIntset {
+		s.members = make([]string, len(s.ints))
+		for i := range s.ints {
+			s.members[i] = s.ints.get(i)
+		}
+		s.ints = nil
+	}
+	s.encoding = encoding
+	if encoding == constant.ObjEncodingHashTable {
+		s.dict = make(map[string]int, len(s.members))
+		for i, m := range s.members {
+			s.dict[m] = i
+		}
 	}
+	s.membersMemUsage = 0
+	for _, m := range s.members {
+		s.membersMemUsage += s.memberMemUsage(m)
+	}
+}
+
+// find returns the position of member in members, -1 if it doesn't exist. The set must not be an intset.
+func (s *simpleSet) find(member string) int {
+	if s.dict != nil {
+		if i, ok := s.dict[member]; ok {
+			return i
+		}
+		return -1
+	}
+	for i, m := range s.members {
+		if m == member {
+			return i
+		}
+	}
+	return -1
+}
+
+// member returns the member at position i, in [0, Size())
+func (s *simpleSet) member(i int) string {
+	if s.encoding == constant.ObjEncodingIntset {
+		return s.ints.get(i)
+	}
+	return s.members[i]
 }
 
 func (s *simpleSet) Add(members ...string) int {
 	added := 0
 	for _, m := range members {
-		if _, exist := s.dict[m]; !exist {
-			s.dict[m] = struct{}{}
-			added++
+		if s.encoding == constant.ObjEncodingIntset {
+			if v, ok := parseSetInt(m); ok {
+				if s.ints.add(v) {
+					s.membersMemUsage += s.memberMemUsage(m)
+					added++
+					if len(s.ints) > config.SetMaxIntsetEntries {
+						s.convert(constant.ObjEncodingHashTable)
+					}
+				}
+				continue
+			}
+			if len(s.ints) < config.SetMaxListpackEntries && len(m) <= config.SetMaxListpackValue {
+				s.convert(constant.ObjEncodingListpack)
+			} else {
+				s.convert(constant.ObjEncodingHashTable)
+			}
+		}
+		if s.find(m) >= 0 {
+			continue
 		}
+		if s.encoding == constant.ObjEncodingListpack &&
+			(len(s.members) >= config.SetMaxListpackEntries || len(m) > config.SetMaxListpackValue) {
+			s.convert(constant.ObjEncodingHashTable)
+		}
+		if s.dict != nil {
+			s.dict[m] = len(s.members)
+		}
+		s.members = append(s.members, m)
+		s.membersMemUsage += s.memberMemUsage(m)
+		added++
 	}
 	return added
 }
@@ -29,20 +141,60 @@ func (s *simpleSet) Add(members ...string) int {
 func (s *simpleSet) Rem(members ...string) int {
 	removed := 0
 	for _, m := range members {
-		if _, exist := s.dict[m]; exist {
-			delete(s.dict, m)
+		if s.remove(m) {
 			removed++
 		}
 	}
 	return removed
 }
 
+/*
+remove deletes member m and returns true if it existed. The listpack encoding keeps the order of the other
+members, the hashtable encoding moves the last member into the position of the deleted one.
+*/
+func (s *simpleSet) remove(m string) bool {
+	if s.encoding == constant.ObjEncodingIntset {
+		v, ok := parseSetInt(m)
+		if !ok || !s.ints.remove(v) {
+			return false
+		}
+		s.membersMemUsage -= s.memberMemUsage(m)
+		return true
+	}
+	pos := s.find(m)
+	if pos < 0 {
+		return false
+	}
+	s.membersMemUsage -= s.memberMemUsage(m)
+	last := len(s.members) - 1
+	if s.dict == nil {
+		copy(s.members[pos:], s.members[pos+1:])
+	} else {
+		s.members[pos] = s.members[last]
+		s.dict[s.members[pos]] = pos
+		delete(s.dict, m)
+	}
+	s.members[last] = ""
+	s.members = s.members[:last]
+	return true
+}
+
 func (s *simpleSet) Size() int {
-	return len(s.dict)
+	if s.encoding == constant.ObjEncodingIntset {
+		return len(s.ints)
+	}
+	return len(s.members)
 }
 
 func (s *simpleSet) IsMember(member string) int {
-	_, exist := s.dict[member]
+	var exist bool
+	if s.encoding == constant.ObjEncodingIntset {
+		if v, ok := parseSetInt(member); ok {
+			_, exist = s.ints.search(v)
+		}
+	} else {
+		exist = s.find(member) >= 0
+	}
 	if exist {
 		return 1
 	}
@@ -58,39 +210,78 @@ func (s *simpleSet) MIsMember(members ...string) []int {
 }
 
 func (s *simpleSet) Members() []string {
-	m := make([]string, 0, len(s.dict))
-	for k, _ := range s.dict {
-		m = append(m, k)
+	m := make([]string, s.Size())
+	for i := range m {
+		m[i] = s.member(i)
 	}
 	return m
 }
 
+/*
+Pop removes and returns count random members, or all the members if there are fewer. Every member is picked
+at its position in O(1), so the hashtable encoding pops a member in constant time.
+*/
 func (s *simpleSet) Pop(count int) []string {
-	randKeys := s.Rand(count)
-	for _, k := range randKeys {
-		delete(s.dict, k)
+	if count >= s.Size() {
+		popped := s.Members()
+		s.ints, s.members, s.membersMemUsage = nil, nil, 0
+		if s.dict != nil {
+			s.dict = make(map[string]int)
+		}
+		return popped
+	}
+	popped := make([]string, count)
+	for i := range popped {
+		popped[i] = s.member(rand.Intn(s.Size()))
+		s.remove(popped[i])
 	}
-	return randKeys
+	return popped
 }
 
-// TODO: optimize
+/*
+Rand returns random members, like SRANDMEMBER: count distinct members if count > 0, or all the members if there
+are fewer, and -count members that may repeat if count < 0, at most MaxRandomCount. The members are picked at
+their position, without copying the set.
+*/
 func (s *simpleSet) Rand(count int) []string {
-	temp := make([]string, 0, s.Size())
-	for k := range s.dict {
-		temp = append(temp, k)
-	}
-
-	res := make([]string, count)
-	r := make(map[int]struct{})
-	for i := 0; i < count; i++ {
-		for {
-			picked := rand.Intn(s.Size())
-			if _, ok := r[picked]; !ok {
-				res[i] = temp[picked]
-				r[picked] = struct{}{}
-				break
-			}
-		}
+	positions := randomPositions(s.Size(), count)
+	res := make([]string, len(positions))
+	for i, pos := range positions {
+		res[i] = s.member(pos)
 	}
 	return res
 }
+
+// Dup returns a copy of the set with the same encoding, for key
+func (s *simpleSet) Dup(key string) Set {
+	dup := &simpleSet{
+		key:             key,
+		encoding:        s.encoding,
+		ints:            append(intset(nil), s.ints...),
+		members:         append([]string(nil), s.members...),
+		membersMemUsage: s.membersMemUsage,
+	}
+	if s.dict != nil {
+		dup.dict = make(map[string]int, len(s.dict))
+		for m, i := range s.dict {
+			dup.dict[m] = i
+		}
+	}
+	return dup
+}
+
+func (s *simpleSet) GetMemUsage() uint64 {
+	return simpleSetSize + uint64(len(s.key)) + s.membersMemUsage
+}
+
+// Scan returns an intset or a listpack in a single call, like Redis does
+func (s *simpleSet) Scan(cursor uint64, count int, fn func(member string)) uint64 {
+	if s.dict == nil {
+		count = s.Size()
+	}
+	start, end, next := scanRange(s.Size(), cursor, count)
+	for i := end - 1; i >= start; i-- {
+		fn(s.member(i))
+	}
+	return next
+}
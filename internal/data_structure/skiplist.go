@@ -1,7 +1,9 @@
 package data_structure
 
 import (
+	"math"
 	"math/rand"
+	"strconv"
 	"strings"
 )
 
@@ -56,6 +58,95 @@ func (zr ZRange) ValueLteMax(value float64) bool {
 	return value <= zr.max
 }
 
+/*
+ParseZRange parses the bounds of a score range, like ZCOUNT and ZRANGEBYSCORE: a float, or "-inf" and "+inf",
+excluded if prefixed by "(". Returns false if a bound is not valid.
+*/
+func ParseZRange(min, max string) (ZRange, bool) {
+	var zr ZRange
+	var ok bool
+	if zr.min, zr.minex, ok = parseScoreBound(min); !ok {
+		return zr, false
+	}
+	if zr.max, zr.maxex, ok = parseScoreBound(max); !ok {
+		return zr, false
+	}
+	return zr, true
+}
+
+func parseScoreBound(s string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(s, "(")
+	if exclusive {
+		s = s[1:]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, false, false
+	}
+	return v, exclusive, true
+}
+
+/*
+ZLexRange is a range of elements for ZRANGE BYLEX, which is meaningful when all the elements have the same
+score and are thus sorted lexicographically
+*/
+type ZLexRange struct {
+	min, max     string
+	minex, maxex bool /* are min or max exclusive? */
+	// minInf and maxInf are -1 for the bound "-", lower than any element, 1 for "+", and 0 for an element
+	minInf, maxInf int
+}
+
+/*
+ParseZLexRange parses the bounds of a lexicographical range: "-" and "+", or an element prefixed by "[" if it
+is included or by "(" if it is excluded. Returns false if a bound is not valid.
+*/
+func ParseZLexRange(min, max string) (ZLexRange, bool) {
+	var zr ZLexRange
+	var ok bool
+	if zr.min, zr.minex, zr.minInf, ok = parseLexBound(min); !ok {
+		return zr, false
+	}
+	if zr.max, zr.maxex, zr.maxInf, ok = parseLexBound(max); !ok {
+		return zr, false
+	}
+	return zr, true
+}
+
+func parseLexBound(s string) (string, bool, int, bool) {
+	switch {
+	case s == "-":
+		return "", false, -1, true
+	case s == "+":
+		return "", false, 1, true
+	case strings.HasPrefix(s, "("):
+		return s[1:], true, 0, true
+	case strings.HasPrefix(s, "["):
+		return s[1:], false, 0, true
+	}
+	return "", false, 0, false
+}
+
+func (zr ZLexRange) ValueGteMin(value string) bool {
+	if zr.minInf != 0 {
+		return zr.minInf < 0
+	}
+	if zr.minex {
+		return value > zr.min
+	}
+	return value >= zr.min
+}
+
+func (zr ZLexRange) ValueLteMax(value string) bool {
+	if zr.maxInf != 0 {
+		return zr.maxInf > 0
+	}
+	if zr.maxex {
+		return value < zr.max
+	}
+	return value <= zr.max
+}
+
 func (sl *Skiplist) randomLevel() int {
 	level := 1
 	for rand.Intn(2) == 1 {
@@ -288,3 +379,74 @@ func (sl *Skiplist) InRange(zr ZRange) bool {
 	}
 	return true
 }
+
+/*
+Find the last node that is contained in the range
+Return nil if not found
+*/
+func (sl *Skiplist) FindLastInRange(zr ZRange) *SkiplistNode {
+	if !sl.InRange(zr) {
+		return nil
+	}
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && zr.ValueLteMax(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+	if !zr.ValueGteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+// FindFirstInLexRange returns the first node that is contained in the lexicographical range, nil if not found
+func (sl *Skiplist) FindFirstInLexRange(zr ZLexRange) *SkiplistNode {
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !zr.ValueGteMin(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+	}
+	x = x.levels[0].forward
+	if x == nil || !zr.ValueLteMax(x.ele) {
+		return nil
+	}
+	return x
+}
+
+// FindLastInLexRange returns the last node that is contained in the lexicographical range, nil if not found
+func (sl *Skiplist) FindLastInLexRange(zr ZLexRange) *SkiplistNode {
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && zr.ValueLteMax(x.levels[i].forward.ele) {
+			x = x.levels[i].forward
+		}
+	}
+	if x == sl.head || !zr.ValueGteMin(x.ele) {
+		return nil
+	}
+	return x
+}
+
+/*
+Find the node at the 1-based rank, following the spans down from the highest level, so in O(log n).
+Return nil if the rank is out of range
+*/
+func (sl *Skiplist) GetElementByRank(rank uint32) *SkiplistNode {
+	if rank == 0 || rank > sl.length {
+		return nil
+	}
+	x := sl.head
+	var traversed uint32 = 0
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
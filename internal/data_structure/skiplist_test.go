@@ -258,3 +258,46 @@ func TestSkiplist_FindFirstInRange(t *testing.T) {
 	// (50, 100]
 	assert.Nil(t, sl.FindFirstInRange(zr))
 }
+
+func TestSkiplist_GetElementByRank(t *testing.T) {
+	sl := CreateSkiplist()
+	for i := 1; i <= 200; i++ {
+		sl.Insert(float64(i), "k")
+	}
+	for rank := uint32(1); rank <= 200; rank++ {
+		assert.EqualValues(t, rank, sl.GetElementByRank(rank).score)
+	}
+	assert.Nil(t, sl.GetElementByRank(0))
+	assert.Nil(t, sl.GetElementByRank(201))
+}
+
+func TestSkiplist_FindInRange(t *testing.T) {
+	sl := CreateSkiplist()
+	for i := 1; i <= 5; i++ {
+		sl.Insert(float64(10*i), "k"+string(rune('0'+i)))
+	}
+	zr, ok := ParseZRange("(10", "40")
+	assert.True(t, ok)
+	assert.EqualValues(t, 20, sl.FindFirstInRange(zr).score)
+	assert.EqualValues(t, 40, sl.FindLastInRange(zr).score)
+	zr, _ = ParseZRange("-inf", "(10")
+	assert.Nil(t, sl.FindFirstInRange(zr))
+	assert.Nil(t, sl.FindLastInRange(zr))
+	_, ok = ParseZRange("x", "10")
+	assert.False(t, ok)
+	_, ok = ParseZRange("1", "nan")
+	assert.False(t, ok)
+
+	lr, ok := ParseZLexRange("(k1", "[k4")
+	assert.True(t, ok)
+	assert.EqualValues(t, "k2", sl.FindFirstInLexRange(lr).ele)
+	assert.EqualValues(t, "k4", sl.FindLastInLexRange(lr).ele)
+	lr, _ = ParseZLexRange("-", "+")
+	assert.EqualValues(t, "k1", sl.FindFirstInLexRange(lr).ele)
+	assert.EqualValues(t, "k5", sl.FindLastInLexRange(lr).ele)
+	lr, _ = ParseZLexRange("+", "-")
+	assert.Nil(t, sl.FindFirstInLexRange(lr))
+	assert.Nil(t, sl.FindLastInLexRange(lr))
+	_, ok = ParseZLexRange("k1", "+")
+	assert.False(t, ok)
+}
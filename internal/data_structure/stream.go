@@ -0,0 +1,569 @@
+package data_structure
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Stream is an append-only log of entries, like the Redis stream. Every entry has a unique StreamID and a list
+of fields and values, and the entries are indexed by ID in a BTree, so that they can be read in both directions
+from any ID and trimmed from the oldest one. IDs only grow: the last ID is the greatest ID ever added to the
+stream, even when that entry was deleted since.
+
+Consumer groups share the entries of a stream between consumers. A group remembers the last ID it delivered,
+and every entry delivered to one of its consumers is pending until the consumer acknowledges it. The pending
+entries are indexed by ID twice: in the pending entries list (PEL) of the group, and in the PEL of the
+consumer that owns them.
+*/
+
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxStreamID is the greatest possible ID, the + of XRANGE
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// CompareStreamIDs returns -1 if a < b, 0 if a == b and 1 if a > b
+func CompareStreamIDs(a, b StreamID) int {
+	switch {
+	case a.Ms != b.Ms:
+		if a.Ms < b.Ms {
+			return -1
+		}
+		return 1
+	case a.Seq != b.Seq:
+		if a.Seq < b.Seq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// Next returns the smallest ID greater than id, false if id is MaxStreamID
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Prev returns the greatest ID lower than id, false if id is 0-0
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// ParseStreamID parses an ID of the form <ms>-<seq>, or <ms> alone whose sequence is then missingSeq
+func ParseStreamID(s string, missingSeq uint64) (StreamID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: missingSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	return StreamID{Ms: ms, Seq: seq}, true
+}
+
+type StreamEntry struct {
+	ID StreamID
+	// Fields are the fields and their values, interleaved. It is nil for a pending entry that was
+	// deleted from the stream.
+	Fields []string
+}
+
+type Stream struct {
+	entries *BTree[StreamID, []string]
+	lastID  StreamID
+	groups  map[string]*ConsumerGroup
+	// entriesMemUsage is the memory used by the entries
+	entriesMemUsage uint64
+}
+
+type ConsumerGroup struct {
+	name      string
+	lastID    StreamID
+	pel       *BTree[StreamID, *PendingEntry]
+	consumers map[string]*Consumer
+}
+
+type Consumer struct {
+	name string
+	pel  *BTree[StreamID, *PendingEntry]
+}
+
+// PendingEntry is an entry delivered to a consumer of a group and not acknowledged yet
+type PendingEntry struct {
+	consumer *Consumer
+	// DeliveryTime is the unix time in ms of the last delivery
+	DeliveryTime  uint64
+	DeliveryCount uint64
+}
+
+var streamSize = uint64(reflect.TypeOf(Stream{}).Size())
+var consumerGroupSize = uint64(reflect.TypeOf(ConsumerGroup{}).Size())
+var consumerSize = uint64(reflect.TypeOf(Consumer{}).Size())
+var pendingEntrySize = uint64(reflect.TypeOf(PendingEntry{}).Size())
+
+const (
+	// streamEntryOverhead is the memory used by an entry besides its fields: its ID, the slice of
+	// its fields and the slack of the tree nodes
+	streamEntryOverhead = 64
+	// pendingEntryOverhead is the memory used by the items of a pending entry in the PEL of its
+	// group and of its consumer
+	pendingEntryOverhead = 2 * 32
+)
+
+func CreateStream() *Stream {
+	return &Stream{
+		entries: CreateBTree[StreamID, []string](CompareStreamIDs),
+		groups:  map[string]*ConsumerGroup{},
+	}
+}
+
+func streamEntryMemUsage(fields []string) uint64 {
+	usage := uint64(streamEntryOverhead)
+	for _, f := range fields {
+		usage += StringMemUsage(f)
+	}
+	return usage
+}
+
+func (s *Stream) Len() int {
+	return s.entries.Len()
+}
+
+// LastID returns the greatest ID ever added to the stream
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// SetLastID sets the last ID, which must not be lower than the ID of the last entry
+func (s *Stream) SetLastID(id StreamID) {
+	s.lastID = id
+}
+
+// LastEntryID returns the ID of the last entry, false if the stream is empty
+func (s *Stream) LastEntryID() (StreamID, bool) {
+	id, _, ok := s.entries.Max()
+	return id, ok
+}
+
+/*
+NextID returns the ID of an entry added at the unix time ms: the time and a sequence of 0, or the ID
+following the last ID if the time is not greater than its time. It returns false if the last ID is
+MaxStreamID.
+*/
+func (s *Stream) NextID(ms uint64) (StreamID, bool) {
+	if ms > s.lastID.Ms {
+		return StreamID{Ms: ms}, true
+	}
+	return s.lastID.Next()
+}
+
+// Add appends an entry whose ID must be greater than the last ID
+func (s *Stream) Add(id StreamID, fields []string) {
+	s.entries.Set(id, fields)
+	s.lastID = id
+	s.entriesMemUsage += streamEntryMemUsage(fields)
+}
+
+func (s *Stream) Get(id StreamID) ([]string, bool) {
+	return s.entries.Get(id)
+}
+
+// Delete deletes the entry of id and returns true if it existed, it stays pending in the groups
+func (s *Stream) Delete(id StreamID) bool {
+	fields, ok := s.entries.Delete(id)
+	if ok {
+		s.entriesMemUsage -= streamEntryMemUsage(fields)
+	}
+	return ok
+}
+
+// Range returns up to count entries, all if count <= 0, whose IDs are between start and end included,
+// in descending order if rev is true
+func (s *Stream) Range(start StreamID, end StreamID, count int, rev bool) []StreamEntry {
+	res := make([]StreamEntry, 0)
+	if CompareStreamIDs(start, end) > 0 {
+		return res
+	}
+	collect := func(id StreamID, fields []string) bool {
+		if (!rev && CompareStreamIDs(id, end) > 0) || (rev && CompareStreamIDs(id, start) < 0) {
+			return false
+		}
+		res = append(res, StreamEntry{ID: id, Fields: fields})
+		return count <= 0 || len(res) < count
+	}
+	if rev {
+		s.entries.Descend(end, collect)
+	} else {
+		s.entries.Ascend(start, collect)
+	}
+	return res
+}
+
+// trim deletes the oldest entries while keep returns false, up to limit entries if limit > 0, and returns
+// the number of deleted entries
+func (s *Stream) trim(limit int, keep func(id StreamID) bool) int {
+	deleted := 0
+	for limit <= 0 || deleted < limit {
+		id, _, ok := s.entries.Min()
+		if !ok || keep(id) {
+			break
+		}
+		s.Delete(id)
+		deleted++
+	}
+	return deleted
+}
+
+// TrimMaxLen deletes the oldest entries until the stream has maxLen entries, and returns the number of deleted
+// entries. No more than limit entries are deleted if limit > 0.
+func (s *Stream) TrimMaxLen(maxLen int, limit int) int {
+	return s.trim(limit, func(id StreamID) bool {
+		return s.Len() <= maxLen
+	})
+}
+
+// TrimMinID deletes the entries whose ID is lower than minID, and returns the number of deleted entries.
+// No more than limit entries are deleted if limit > 0.
+func (s *Stream) TrimMinID(minID StreamID, limit int) int {
+	return s.trim(limit, func(id StreamID) bool {
+		return CompareStreamIDs(id, minID) >= 0
+	})
+}
+
+// CreateGroup creates the group name that delivers the entries after lastID, false if it already exists
+func (s *Stream) CreateGroup(name string, lastID StreamID) (*ConsumerGroup, bool) {
+	if _, ok := s.groups[name]; ok {
+		return nil, false
+	}
+	g := &ConsumerGroup{
+		name:      name,
+		lastID:    lastID,
+		pel:       CreateBTree[StreamID, *PendingEntry](CompareStreamIDs),
+		consumers: map[string]*Consumer{},
+	}
+	s.groups[name] = g
+	return g, true
+}
+
+// Group returns the group name, nil if it doesn't exist
+func (s *Stream) Group(name string) *ConsumerGroup {
+	return s.groups[name]
+}
+
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups returns the groups ordered by name
+func (s *Stream) Groups() []*ConsumerGroup {
+	groups := make([]*ConsumerGroup, 0, len(s.groups))
+	for _, g := range s.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].name < groups[j].name
+	})
+	return groups
+}
+
+// Dup returns a copy of the stream, its groups included
+func (s *Stream) Dup() *Stream {
+	dup := CreateStream()
+	s.entries.ForEach(func(id StreamID, fields []string) {
+		dup.Add(id, fields)
+	})
+	dup.lastID = s.lastID
+	for _, g := range s.groups {
+		dupGroup, _ := dup.CreateGroup(g.name, g.lastID)
+		for _, c := range g.consumers {
+			dupGroup.CreateConsumer(c.name)
+		}
+		g.pel.ForEach(func(id StreamID, pe *PendingEntry) {
+			dupGroup.Claim(id, dupGroup.consumers[pe.consumer.name], pe.DeliveryTime, pe.DeliveryCount)
+		})
+	}
+	return dup
+}
+
+func (g *ConsumerGroup) Name() string {
+	return g.name
+}
+
+// LastID returns the ID of the last entry delivered by the group
+func (g *ConsumerGroup) LastID() StreamID {
+	return g.lastID
+}
+
+func (g *ConsumerGroup) SetLastID(id StreamID) {
+	g.lastID = id
+}
+
+// Consumer returns the consumer name, nil if it doesn't exist
+func (g *ConsumerGroup) Consumer(name string) *Consumer {
+	return g.consumers[name]
+}
+
+// CreateConsumer returns the consumer name, and true if it was created
+func (g *ConsumerGroup) CreateConsumer(name string) (*Consumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &Consumer{name: name, pel: CreateBTree[StreamID, *PendingEntry](CompareStreamIDs)}
+	g.consumers[name] = c
+	return c, true
+}
+
+// DeleteConsumer deletes the consumer name and its pending entries, and returns the number of pending entries
+// it had, false if it doesn't exist
+func (g *ConsumerGroup) DeleteConsumer(name string) (int, bool) {
+	c, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	pending := c.pel.Len()
+	c.pel.ForEach(func(id StreamID, _ *PendingEntry) {
+		g.pel.Delete(id)
+	})
+	delete(g.consumers, name)
+	return pending, true
+}
+
+// Consumers returns the consumers ordered by name
+func (g *ConsumerGroup) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		consumers = append(consumers, c)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].name < consumers[j].name
+	})
+	return consumers
+}
+
+/*
+ReadNew delivers to consumer up to count entries, all if count <= 0, added after the last ID of the group,
+which is moved to the last delivered entry. Unless noAck is true, the delivered entries become pending
+with the delivery time now.
+*/
+func (g *ConsumerGroup) ReadNew(s *Stream, consumer *Consumer, count int, now uint64, noAck bool) []StreamEntry {
+	start, ok := g.lastID.Next()
+	if !ok {
+		return make([]StreamEntry, 0)
+	}
+	entries := s.Range(start, MaxStreamID, count, false)
+	for _, e := range entries {
+		g.lastID = e.ID
+		if !noAck {
+			g.Claim(e.ID, consumer, now, 1)
+		}
+	}
+	return entries
+}
+
+/*
+ReadPending delivers again to consumer up to count of its pending entries, all if count <= 0, starting at
+start. Their delivery time becomes now and their delivery count is incremented. The entries deleted from the
+stream are returned without fields.
+*/
+func (g *ConsumerGroup) ReadPending(s *Stream, consumer *Consumer, start StreamID, count int, now uint64) []StreamEntry {
+	entries := make([]StreamEntry, 0)
+	consumer.pel.Ascend(start, func(id StreamID, pe *PendingEntry) bool {
+		fields, _ := s.Get(id)
+		entries = append(entries, StreamEntry{ID: id, Fields: fields})
+		pe.DeliveryTime = now
+		pe.DeliveryCount++
+		return count <= 0 || len(entries) < count
+	})
+	return entries
+}
+
+// Pending returns the pending entry of id, nil if it is not pending
+func (g *ConsumerGroup) Pending(id StreamID) *PendingEntry {
+	pe, _ := g.pel.Get(id)
+	return pe
+}
+
+func (g *ConsumerGroup) PendingLen() int {
+	return g.pel.Len()
+}
+
+// PendingBounds returns the lowest and the greatest pending IDs, false if no entry is pending
+func (g *ConsumerGroup) PendingBounds() (StreamID, StreamID, bool) {
+	first, _, ok := g.pel.Min()
+	last, _, _ := g.pel.Max()
+	return first, last, ok
+}
+
+// PendingRange calls fn for the pending entries whose IDs are between start and end included, in ascending
+// order, until fn returns false. Only the entries of consumer are visited if it is not nil.
+func (g *ConsumerGroup) PendingRange(start StreamID, end StreamID, consumer *Consumer, fn func(id StreamID, pe *PendingEntry) bool) {
+	pel := g.pel
+	if consumer != nil {
+		pel = consumer.pel
+	}
+	pel.Ascend(start, func(id StreamID, pe *PendingEntry) bool {
+		return CompareStreamIDs(id, end) <= 0 && fn(id, pe)
+	})
+}
+
+// Claim makes id a pending entry of consumer, whether it was pending or not, with the given delivery
+// time and count
+func (g *ConsumerGroup) Claim(id StreamID, consumer *Consumer, deliveryTime uint64, deliveryCount uint64) {
+	pe, ok := g.pel.Get(id)
+	if ok {
+		pe.consumer.pel.Delete(id)
+	} else {
+		pe = &PendingEntry{}
+		g.pel.Set(id, pe)
+	}
+	pe.consumer = consumer
+	pe.DeliveryTime = deliveryTime
+	pe.DeliveryCount = deliveryCount
+	consumer.pel.Set(id, pe)
+}
+
+// Ack removes id from the pending entries, and returns true if it was pending
+func (g *ConsumerGroup) Ack(id StreamID) bool {
+	pe, ok := g.pel.Delete(id)
+	if ok {
+		pe.consumer.pel.Delete(id)
+	}
+	return ok
+}
+
+func (g *ConsumerGroup) memUsage() uint64 {
+	usage := consumerGroupSize + StringMemUsage(g.name) + uint64(g.pel.Len())*(pendingEntrySize+pendingEntryOverhead)
+	for _, c := range g.consumers {
+		usage += MapEntryOverhead + consumerSize + StringMemUsage(c.name)
+	}
+	return usage
+}
+
+func (c *Consumer) Name() string {
+	return c.name
+}
+
+func (c *Consumer) PendingLen() int {
+	return c.pel.Len()
+}
+
+// Consumer returns the name of the consumer that owns the entry
+func (pe *PendingEntry) Consumer() string {
+	return pe.consumer.name
+}
+
+func (s *Stream) GetMemUsage() uint64 {
+	usage := streamSize + s.entriesMemUsage
+	for _, g := range s.groups {
+		usage += MapEntryOverhead + g.memUsage()
+	}
+	return usage
+}
+
+func appendStreamID(b []byte, id StreamID) []byte {
+	return appendUint64(appendUint64(b, id.Ms), id.Seq)
+}
+
+func (r *payloadReader) streamID() StreamID {
+	ms := r.uint64()
+	return StreamID{Ms: ms, Seq: r.uint64()}
+}
+
+// MarshalBinary encodes the entries, the last ID, and the groups with their consumers and pending entries
+func (s *Stream) MarshalBinary() ([]byte, error) {
+	b := appendStreamID(nil, s.lastID)
+	b = appendUint64(b, uint64(s.entries.Len()))
+	s.entries.ForEach(func(id StreamID, fields []string) {
+		b = appendStreamID(b, id)
+		b = appendUint32(b, uint32(len(fields)))
+		for _, f := range fields {
+			b = appendString(b, f)
+		}
+	})
+	groups := s.Groups()
+	b = appendUint32(b, uint32(len(groups)))
+	for _, g := range groups {
+		b = appendString(b, g.name)
+		b = appendStreamID(b, g.lastID)
+		consumers := g.Consumers()
+		b = appendUint32(b, uint32(len(consumers)))
+		for _, c := range consumers {
+			b = appendString(b, c.name)
+		}
+		b = appendUint64(b, uint64(g.pel.Len()))
+		g.pel.ForEach(func(id StreamID, pe *PendingEntry) {
+			b = appendStreamID(b, id)
+			b = appendString(b, pe.consumer.name)
+			b = appendUint64(b, pe.DeliveryTime)
+			b = appendUint64(b, pe.DeliveryCount)
+		})
+	}
+	return b, nil
+}
+
+func (s *Stream) UnmarshalBinary(data []byte) error {
+	*s = *CreateStream()
+	r := &payloadReader{data: data}
+	lastID := r.streamID()
+	n := r.uint64()
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		id := r.streamID()
+		fields := make([]string, 0)
+		for j := r.uint32(); j > 0 && r.err == nil; j-- {
+			fields = append(fields, r.string())
+		}
+		s.Add(id, fields)
+	}
+	s.lastID = lastID
+	for i := r.uint32(); i > 0 && r.err == nil; i-- {
+		g, ok := s.CreateGroup(r.string(), r.streamID())
+		if !ok {
+			return ErrCorruptedPayload
+		}
+		for j := r.uint32(); j > 0 && r.err == nil; j-- {
+			g.CreateConsumer(r.string())
+		}
+		for j := r.uint64(); j > 0 && r.err == nil; j-- {
+			id := r.streamID()
+			c := g.consumers[r.string()]
+			deliveryTime := r.uint64()
+			deliveryCount := r.uint64()
+			if c == nil {
+				return ErrCorruptedPayload
+			}
+			g.Claim(id, c, deliveryTime, deliveryCount)
+		}
+	}
+	return r.done()
+}
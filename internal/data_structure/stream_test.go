@@ -0,0 +1,173 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func streamIDs(entries []StreamEntry) []string {
+	res := make([]string, 0)
+	for _, e := range entries {
+		res = append(res, e.ID.String())
+	}
+	return res
+}
+
+func TestStreamID(t *testing.T) {
+	id, ok := ParseStreamID("1526919030474-55", 0)
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{Ms: 1526919030474, Seq: 55}, id)
+	assert.Equal(t, "1526919030474-55", id.String())
+	id, ok = ParseStreamID("15", math.MaxUint64)
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{Ms: 15, Seq: math.MaxUint64}, id)
+	for _, s := range []string{"", "-", "1-", "a-1", "1-2-3", "-1", "18446744073709551616"} {
+		_, ok = ParseStreamID(s, 0)
+		assert.False(t, ok, s)
+	}
+
+	next, ok := StreamID{Ms: 1, Seq: math.MaxUint64}.Next()
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{Ms: 2}, next)
+	_, ok = MaxStreamID.Next()
+	assert.False(t, ok)
+	prev, ok := StreamID{Ms: 2}.Prev()
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{Ms: 1, Seq: math.MaxUint64}, prev)
+	_, ok = StreamID{}.Prev()
+	assert.False(t, ok)
+
+	assert.Equal(t, -1, CompareStreamIDs(StreamID{1, 5}, StreamID{2, 0}))
+	assert.Equal(t, 1, CompareStreamIDs(StreamID{1, 5}, StreamID{1, 4}))
+	assert.Equal(t, 0, CompareStreamIDs(StreamID{1, 5}, StreamID{1, 5}))
+}
+
+func TestStreamAddRangeTrim(t *testing.T) {
+	s := CreateStream()
+	id, _ := s.NextID(100)
+	assert.Equal(t, StreamID{Ms: 100}, id)
+	for i := uint64(1); i <= 10; i++ {
+		s.Add(StreamID{Ms: i, Seq: i}, []string{"f", "v"})
+	}
+	assert.Equal(t, 10, s.Len())
+	id, _ = s.NextID(5)
+	assert.Equal(t, StreamID{Ms: 10, Seq: 11}, id)
+	id, _ = s.NextID(20)
+	assert.Equal(t, StreamID{Ms: 20}, id)
+
+	assert.Equal(t, []string{"3-3", "4-4", "5-5"}, streamIDs(s.Range(StreamID{Ms: 3}, StreamID{Ms: 5, Seq: 5}, 0, false)))
+	assert.Equal(t, []string{"5-5", "4-4"}, streamIDs(s.Range(StreamID{Ms: 3}, StreamID{Ms: 5, Seq: 5}, 2, true)))
+	assert.Equal(t, []string{}, streamIDs(s.Range(StreamID{Ms: 5}, StreamID{Ms: 3}, 0, false)))
+	assert.Equal(t, 10, len(s.Range(StreamID{}, MaxStreamID, 0, false)))
+
+	assert.True(t, s.Delete(StreamID{Ms: 10, Seq: 10}))
+	assert.False(t, s.Delete(StreamID{Ms: 10, Seq: 10}))
+	last, _ := s.LastEntryID()
+	assert.Equal(t, StreamID{Ms: 9, Seq: 9}, last)
+	// the last ID doesn't go back
+	assert.Equal(t, StreamID{Ms: 10, Seq: 10}, s.LastID())
+
+	assert.Equal(t, 2, s.TrimMaxLen(5, 2))
+	assert.Equal(t, 2, s.TrimMaxLen(5, 0))
+	assert.Equal(t, 5, s.Len())
+	assert.Equal(t, 2, s.TrimMinID(StreamID{Ms: 7}, 0))
+	assert.Equal(t, []string{"7-7", "8-8", "9-9"}, streamIDs(s.Range(StreamID{}, MaxStreamID, 0, false)))
+	assert.Equal(t, 0, s.TrimMaxLen(5, 0))
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	s := CreateStream()
+	for i := uint64(1); i <= 5; i++ {
+		s.Add(StreamID{Ms: i}, []string{"n", "v"})
+	}
+	g, ok := s.CreateGroup("g", StreamID{})
+	assert.True(t, ok)
+	_, ok = s.CreateGroup("g", StreamID{})
+	assert.False(t, ok)
+	alice, created := g.CreateConsumer("alice")
+	assert.True(t, created)
+	bob, _ := g.CreateConsumer("bob")
+
+	assert.Equal(t, []string{"1-0", "2-0"}, streamIDs(g.ReadNew(s, alice, 2, 1000, false)))
+	assert.Equal(t, []string{"3-0", "4-0", "5-0"}, streamIDs(g.ReadNew(s, bob, 0, 1000, false)))
+	assert.Equal(t, []string{}, streamIDs(g.ReadNew(s, bob, 0, 1000, false)))
+	assert.Equal(t, StreamID{Ms: 5}, g.LastID())
+	assert.Equal(t, 5, g.PendingLen())
+	assert.Equal(t, 2, alice.PendingLen())
+
+	assert.True(t, g.Ack(StreamID{Ms: 1}))
+	assert.False(t, g.Ack(StreamID{Ms: 1}))
+	assert.Equal(t, 1, alice.PendingLen())
+
+	// history of bob, with a deleted entry
+	s.Delete(StreamID{Ms: 4})
+	entries := g.ReadPending(s, bob, StreamID{}, 0, 2000)
+	assert.Equal(t, []string{"3-0", "4-0", "5-0"}, streamIDs(entries))
+	assert.Nil(t, entries[1].Fields)
+	pe := g.Pending(StreamID{Ms: 3})
+	assert.Equal(t, "bob", pe.Consumer())
+	assert.EqualValues(t, 2000, pe.DeliveryTime)
+	assert.EqualValues(t, 2, pe.DeliveryCount)
+
+	g.Claim(StreamID{Ms: 3}, alice, 3000, 5)
+	assert.Equal(t, "alice", g.Pending(StreamID{Ms: 3}).Consumer())
+	assert.Equal(t, 2, alice.PendingLen())
+	assert.Equal(t, 2, bob.PendingLen())
+
+	var ids []string
+	g.PendingRange(StreamID{}, MaxStreamID, alice, func(id StreamID, pe *PendingEntry) bool {
+		ids = append(ids, id.String())
+		return true
+	})
+	assert.Equal(t, []string{"2-0", "3-0"}, ids)
+
+	pending, ok := g.DeleteConsumer("bob")
+	assert.True(t, ok)
+	assert.Equal(t, 2, pending)
+	assert.Equal(t, 2, g.PendingLen())
+	assert.Equal(t, 1, len(g.Consumers()))
+
+	// NOACK reads don't add pending entries
+	s.Add(StreamID{Ms: 6}, []string{"n", "v"})
+	assert.Equal(t, 1, len(g.ReadNew(s, alice, 0, 1000, true)))
+	assert.Equal(t, 2, g.PendingLen())
+
+	assert.True(t, s.DestroyGroup("g"))
+	assert.False(t, s.DestroyGroup("g"))
+	assert.Nil(t, s.Group("g"))
+}
+
+func TestStreamMarshalBinary(t *testing.T) {
+	s := CreateStream()
+	for i := uint64(1); i <= 100; i++ {
+		s.Add(StreamID{Ms: i, Seq: 1}, []string{"field", "value", "empty", ""})
+	}
+	s.Delete(StreamID{Ms: 100, Seq: 1})
+	g, _ := s.CreateGroup("g", StreamID{})
+	c, _ := g.CreateConsumer("c")
+	g.CreateConsumer("idle")
+	g.ReadNew(s, c, 10, 1234, false)
+	s.CreateGroup("empty", StreamID{Ms: 7})
+
+	data, err := s.MarshalBinary()
+	assert.Nil(t, err)
+	loaded := &Stream{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.Equal(t, s.Range(StreamID{}, MaxStreamID, 0, false), loaded.Range(StreamID{}, MaxStreamID, 0, false))
+	assert.Equal(t, StreamID{Ms: 100, Seq: 1}, loaded.LastID())
+	assert.Equal(t, 2, len(loaded.Groups()))
+	lg := loaded.Group("g")
+	assert.Equal(t, StreamID{Ms: 10, Seq: 1}, lg.LastID())
+	assert.Equal(t, 10, lg.PendingLen())
+	assert.Equal(t, 10, lg.Consumer("c").PendingLen())
+	assert.NotNil(t, lg.Consumer("idle"))
+	assert.EqualValues(t, 1234, lg.Pending(StreamID{Ms: 1, Seq: 1}).DeliveryTime)
+	assert.Equal(t, StreamID{Ms: 7}, loaded.Group("empty").LastID())
+	assert.Equal(t, s.GetMemUsage(), loaded.GetMemUsage())
+
+	dup := s.Dup()
+	assert.Equal(t, data, func() []byte { b, _ := dup.MarshalBinary(); return b }())
+
+	assert.Equal(t, ErrCorruptedPayload, loaded.UnmarshalBinary(data[:len(data)-3]))
+}
@@ -0,0 +1,382 @@
+package data_structure
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Implementation of the merging t-digest
+// https://arxiv.org/abs/1902.04023
+
+const TDigestDefaultCompression = 100
+
+type tdCentroid struct {
+	mean   float64
+	weight float64
+}
+
+/*
+TDigest estimates the quantiles of a stream of values. The values are summarized by centroids, a mean and the
+number of values merged into it, sorted by mean. The scale function k1 limits the weight of a centroid by its
+quantile, so that the centroids at the tails stay small and give accurate extreme quantiles, while the ones in
+the middle absorb many values. The number of centroids is about the compression.
+New values are buffered as centroids of weight 1, and merged into the digest when the buffer is full.
+*/
+type TDigest struct {
+	compression    float64
+	merged         []tdCentroid
+	mergedWeight   float64
+	unmerged       []tdCentroid
+	unmergedWeight float64
+	min            float64
+	max            float64
+	compressions   uint64
+}
+
+func CreateTDigest(compression float64) *TDigest {
+	return &TDigest{
+		compression: compression,
+		min:         math.MaxFloat64,
+		max:         -math.MaxFloat64,
+	}
+}
+
+// GetCapacity returns the size of the buffer of unmerged values
+func (t *TDigest) GetCapacity() int {
+	return int(6*t.compression) + 10
+}
+
+func (t *TDigest) Add(value float64) {
+	t.add(value, 1)
+}
+
+func (t *TDigest) add(mean float64, weight float64) {
+	t.min = math.Min(t.min, mean)
+	t.max = math.Max(t.max, mean)
+	t.unmerged = append(t.unmerged, tdCentroid{mean: mean, weight: weight})
+	t.unmergedWeight += weight
+	if len(t.unmerged) >= t.GetCapacity() {
+		t.compress()
+	}
+}
+
+// Merge adds the values summarized by other
+func (t *TDigest) Merge(other *TDigest) {
+	if other.mergedWeight+other.unmergedWeight == 0 {
+		return
+	}
+	for _, c := range other.centroids() {
+		t.add(c.mean, c.weight)
+	}
+	// the extreme values of other may have been merged into centroids
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+}
+
+// Reset removes all the values
+func (t *TDigest) Reset() {
+	*t = *CreateTDigest(t.compression)
+}
+
+// compress merges the buffered values into the centroids
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+	t.merged = t.mergeCentroids()
+	t.mergedWeight += t.unmergedWeight
+	t.unmerged = t.unmerged[:0]
+	t.unmergedWeight = 0
+	t.compressions++
+}
+
+/*
+centroids returns the centroids the buffered values are merged into. The queries don't compress the digest
+themselves, so that its content only depends on the values added, and the AOF rebuilds the same digest.
+*/
+func (t *TDigest) centroids() []tdCentroid {
+	if len(t.unmerged) == 0 {
+		return t.merged
+	}
+	return t.mergeCentroids()
+}
+
+// mergeCentroids merges the centroids and the buffered values, and returns the new centroids
+func (t *TDigest) mergeCentroids() []tdCentroid {
+	all := make([]tdCentroid, 0, len(t.merged)+len(t.unmerged))
+	all = append(all, t.merged...)
+	all = append(all, t.unmerged...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+	total := t.mergedWeight + t.unmergedWeight
+	res := make([]tdCentroid, 0, len(t.merged)+1)
+	cur := all[0]
+	var weightSoFar float64
+	weightLimit := total * t.quantileLimit(0)
+	for _, c := range all[1:] {
+		if weightSoFar+cur.weight+c.weight <= weightLimit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		weightSoFar += cur.weight
+		res = append(res, cur)
+		weightLimit = total * t.quantileLimit(weightSoFar/total)
+		cur = c
+	}
+	return append(res, cur)
+}
+
+// quantileLimit returns the greatest quantile a centroid starting at quantile q may reach, k1(limit) = k1(q) + 1
+// with k1(q) = compression / pi * asin(2q - 1)
+func (t *TDigest) quantileLimit(q float64) float64 {
+	k := math.Asin(2*q-1) + math.Pi/t.compression
+	if k >= math.Pi/2 {
+		return 1
+	}
+	return (math.Sin(k) + 1) / 2
+}
+
+// weightedAverage returns the average of x1 and x2 weighted by w1 and w2, kept between x1 and x2
+func weightedAverage(x1 float64, w1 float64, x2 float64, w2 float64) float64 {
+	if x1 > x2 {
+		x1, w1, x2, w2 = x2, w2, x1, w1
+	}
+	x := (x1*w1 + x2*w2) / (w1 + w2)
+	return math.Max(x1, math.Min(x, x2))
+}
+
+/*
+Quantile returns an estimation of the value at quantile q, between 0 and 1, or NaN if the digest is empty.
+Every centroid is taken as half of its weight on each side of its mean, the value is interpolated between
+the means of the two centroids around q, or between a mean and the min or max at the tails.
+*/
+func (t *TDigest) Quantile(q float64) float64 {
+	centroids := t.centroids()
+	n := len(centroids)
+	if n == 0 {
+		return math.NaN()
+	}
+	total := t.mergedWeight + t.unmergedWeight
+	index := q * total
+	if index < 1 {
+		return t.min
+	}
+	first, last := centroids[0], centroids[n-1]
+	if first.weight > 1 && index < first.weight/2 {
+		return t.min + (index-1)/(first.weight/2-1)*(first.mean-t.min)
+	}
+	if index > total-1 {
+		return t.max
+	}
+	// index <= total-1 here, so a last centroid of weight 2 has nothing to interpolate
+	if last.weight > 2 && total-index <= last.weight/2 {
+		return t.max - (total-index-1)/(last.weight/2-1)*(t.max-last.mean)
+	}
+
+	weightSoFar := first.weight / 2
+	for i := 0; i < n-1; i++ {
+		dw := (centroids[i].weight + centroids[i+1].weight) / 2
+		if weightSoFar+dw > index {
+			// a centroid of weight 1 is a single value, which takes the whole unit around its mean
+			var leftUnit, rightUnit float64
+			if centroids[i].weight == 1 {
+				if index-weightSoFar < 0.5 {
+					return centroids[i].mean
+				}
+				leftUnit = 0.5
+			}
+			if centroids[i+1].weight == 1 {
+				if weightSoFar+dw-index <= 0.5 {
+					return centroids[i+1].mean
+				}
+				rightUnit = 0.5
+			}
+			z1 := index - weightSoFar - leftUnit
+			z2 := weightSoFar + dw - index - rightUnit
+			return weightedAverage(centroids[i].mean, z2, centroids[i+1].mean, z1)
+		}
+		weightSoFar += dw
+	}
+	return t.max
+}
+
+// CDF returns an estimation of the fraction of the values lower than x, plus half the values equal to x,
+// or NaN if the digest is empty
+func (t *TDigest) CDF(x float64) float64 {
+	centroids := t.centroids()
+	n := len(centroids)
+	if n == 0 {
+		return math.NaN()
+	}
+	total := t.mergedWeight + t.unmergedWeight
+	if x < t.min {
+		return 0
+	}
+	if x > t.max {
+		return 1
+	}
+	if n == 1 {
+		if t.max == t.min {
+			return 0.5
+		}
+		return (x - t.min) / (t.max - t.min)
+	}
+
+	first, last := centroids[0], centroids[n-1]
+	if x < first.mean {
+		if x == t.min {
+			return 0.5 / total
+		}
+		// the min is a value of weight 1, the rest of the left half of the first centroid is between
+		return (1 + (x-t.min)/(first.mean-t.min)*(first.weight/2-1)) / total
+	}
+	if x > last.mean {
+		if x == t.max {
+			return 1 - 0.5/total
+		}
+		return 1 - (1+(t.max-x)/(t.max-last.mean)*(last.weight/2-1))/total
+	}
+
+	var weightSoFar float64
+	for i := 0; i < n-1; i++ {
+		c, next := centroids[i], centroids[i+1]
+		if c.mean == x {
+			var dw float64
+			for ; i < n && centroids[i].mean == x; i++ {
+				dw += centroids[i].weight
+			}
+			return (weightSoFar + dw/2) / total
+		}
+		if x < next.mean {
+			var leftExcluded, rightExcluded float64
+			if c.weight == 1 {
+				if next.weight == 1 {
+					// two single values, x is between them
+					return (weightSoFar + 1) / total
+				}
+				leftExcluded = 0.5
+			} else if next.weight == 1 {
+				rightExcluded = 0.5
+			}
+			dw := (c.weight + next.weight) / 2
+			base := weightSoFar + c.weight/2 + leftExcluded
+			return (base + (dw-leftExcluded-rightExcluded)*(x-c.mean)/(next.mean-c.mean)) / total
+		}
+		weightSoFar += c.weight
+	}
+	// x is the mean of the last centroid
+	return 1 - last.weight/2/total
+}
+
+// Rank returns an estimation of the number of values lower than x, plus half the values equal to x.
+// It returns -1 if x is lower than all the values, and -2 if the digest is empty.
+func (t *TDigest) Rank(x float64) int64 {
+	total := t.mergedWeight + t.unmergedWeight
+	if total == 0 {
+		return -2
+	}
+	if x < t.min {
+		return -1
+	}
+	if x > t.max {
+		return int64(total)
+	}
+	return int64(math.Round(t.CDF(x) * total))
+}
+
+// GetMin returns the lowest value added, or NaN if the digest is empty
+func (t *TDigest) GetMin() float64 {
+	if t.mergedWeight+t.unmergedWeight == 0 {
+		return math.NaN()
+	}
+	return t.min
+}
+
+// GetMax returns the greatest value added, or NaN if the digest is empty
+func (t *TDigest) GetMax() float64 {
+	if t.mergedWeight+t.unmergedWeight == 0 {
+		return math.NaN()
+	}
+	return t.max
+}
+
+func (t *TDigest) GetCompression() float64 {
+	return t.compression
+}
+
+func (t *TDigest) GetMergedNodes() int {
+	return len(t.merged)
+}
+
+func (t *TDigest) GetUnmergedNodes() int {
+	return len(t.unmerged)
+}
+
+func (t *TDigest) GetMergedWeight() float64 {
+	return t.mergedWeight
+}
+
+func (t *TDigest) GetUnmergedWeight() float64 {
+	return t.unmergedWeight
+}
+
+func (t *TDigest) GetCompressions() uint64 {
+	return t.compressions
+}
+
+func (t *TDigest) GetMemUsage() uint64 {
+	centroidSize := uint64(reflect.TypeOf(tdCentroid{}).Size())
+	return uint64(reflect.TypeOf(*t).Size()) + centroidSize*uint64(cap(t.merged)+cap(t.unmerged))
+}
+
+// MarshalBinary encodes the compression, the bounds, and the merged and buffered centroids
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 56+16*(len(t.merged)+len(t.unmerged)))
+	b = appendFloat64(b, t.compression)
+	b = appendFloat64(b, t.min)
+	b = appendFloat64(b, t.max)
+	b = appendUint64(b, t.compressions)
+	for _, centroids := range [][]tdCentroid{t.merged, t.unmerged} {
+		b = appendUint32(b, uint32(len(centroids)))
+		for _, c := range centroids {
+			b = appendFloat64(b, c.mean)
+			b = appendFloat64(b, c.weight)
+		}
+	}
+	return b, nil
+}
+
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	t.compression = r.float64()
+	t.min = r.float64()
+	t.max = r.float64()
+	t.compressions = r.uint64()
+	if r.err != nil || !(t.compression >= 1) {
+		return ErrCorruptedPayload
+	}
+	readCentroids := func() ([]tdCentroid, float64) {
+		n := r.uint32()
+		if uint64(n)*16 > uint64(len(r.data)) {
+			r.err = ErrCorruptedPayload
+			return nil, 0
+		}
+		centroids := make([]tdCentroid, n)
+		var weight float64
+		for i := range centroids {
+			centroids[i].mean = r.float64()
+			centroids[i].weight = r.float64()
+			weight += centroids[i].weight
+		}
+		return centroids, weight
+	}
+	t.merged, t.mergedWeight = readCentroids()
+	t.unmerged, t.unmergedWeight = readCentroids()
+	if r.err == nil && len(t.unmerged) >= t.GetCapacity() {
+		return ErrCorruptedPayload
+	}
+	return r.done()
+}
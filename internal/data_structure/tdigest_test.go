@@ -0,0 +1,99 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigest_Empty(t *testing.T) {
+	td := CreateTDigest(TDigestDefaultCompression)
+	assert.True(t, math.IsNaN(td.Quantile(0.5)))
+	assert.True(t, math.IsNaN(td.CDF(1)))
+	assert.True(t, math.IsNaN(td.GetMin()))
+	assert.True(t, math.IsNaN(td.GetMax()))
+	assert.EqualValues(t, -2, td.Rank(1))
+}
+
+func TestTDigest_SmallSets(t *testing.T) {
+	td := CreateTDigest(TDigestDefaultCompression)
+	td.Add(5)
+	assert.EqualValues(t, 5, td.Quantile(0))
+	assert.EqualValues(t, 5, td.Quantile(0.5))
+	assert.EqualValues(t, 5, td.Quantile(1))
+	assert.EqualValues(t, 0.5, td.CDF(5))
+	assert.EqualValues(t, 0, td.CDF(4))
+	assert.EqualValues(t, 1, td.CDF(6))
+
+	td = CreateTDigest(TDigestDefaultCompression)
+	for i := 1; i <= 10; i++ {
+		td.Add(float64(i))
+	}
+	assert.EqualValues(t, 1, td.GetMin())
+	assert.EqualValues(t, 10, td.GetMax())
+	assert.EqualValues(t, 1, td.Quantile(0))
+	assert.EqualValues(t, 10, td.Quantile(1))
+	assert.EqualValues(t, 6, td.Quantile(0.5))
+	assert.EqualValues(t, 0.25, td.CDF(3))
+	assert.EqualValues(t, 0.3, td.CDF(3.5))
+	assert.EqualValues(t, -1, td.Rank(0))
+	assert.EqualValues(t, 3, td.Rank(3.5))
+	assert.EqualValues(t, 10, td.Rank(11))
+	// the queries don't merge the buffered values
+	assert.EqualValues(t, 10, td.GetUnmergedNodes())
+}
+
+func TestTDigest_Accuracy(t *testing.T) {
+	td := CreateTDigest(TDigestDefaultCompression)
+	r := rand.New(rand.NewSource(42))
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = r.ExpFloat64()
+		td.Add(values[i])
+	}
+	sort.Float64s(values)
+	assert.Greater(t, td.GetCompressions(), uint64(0))
+	assert.Less(t, td.GetMergedNodes(), 200)
+	for _, q := range []float64{0.001, 0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		expected := values[int(q*float64(len(values)))]
+		assert.InDelta(t, expected, td.Quantile(q), expected*0.05, "quantile %v", q)
+		assert.InDelta(t, q, td.CDF(expected), q*0.05, "cdf %v", q)
+	}
+	assert.EqualValues(t, values[0], td.Quantile(0))
+	assert.EqualValues(t, values[len(values)-1], td.Quantile(1))
+}
+
+func TestTDigest_Merge(t *testing.T) {
+	a := CreateTDigest(TDigestDefaultCompression)
+	b := CreateTDigest(TDigestDefaultCompression)
+	for i := 0; i < 10000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(10000 + i))
+	}
+	a.Merge(b)
+	assert.EqualValues(t, 20000, a.GetMergedWeight()+a.GetUnmergedWeight())
+	assert.EqualValues(t, 0, a.GetMin())
+	assert.EqualValues(t, 19999, a.GetMax())
+	assert.InDelta(t, 10000, a.Quantile(0.5), 100)
+
+	a.Reset()
+	assert.True(t, math.IsNaN(a.Quantile(0.5)))
+	assert.EqualValues(t, TDigestDefaultCompression, a.GetCompression())
+}
+
+func TestTDigest_MarshalBinary(t *testing.T) {
+	td := CreateTDigest(50)
+	for i := 0; i < 1000; i++ {
+		td.Add(float64(i % 97))
+	}
+	data, err := td.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &TDigest{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, td, loaded)
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:20]))
+}
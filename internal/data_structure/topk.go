@@ -0,0 +1,297 @@
+package data_structure
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Implementation of Top-K with the HeavyKeeper algorithm
+// https://www.usenix.org/system/files/conference/atc18/atc18-gong.pdf
+
+const TopKDefaultWidth = 8
+const TopKDefaultDepth = 7
+const TopKDefaultDecay = 0.9
+
+// topKDecayTableSize is the number of powers of the decay that are precomputed
+const topKDecayTableSize = 256
+
+// topKFpSeed is the seed of the hash that fingerprints the items, the rows use the seeds 0 to depth-1 like CMS
+const topKFpSeed = ABigSeed
+
+/*
+TopK keeps the k items seen most often. Like a Count-Min Sketch it has depth rows of width buckets, an item being
+counted in one bucket per row. A bucket counts a single item, identified by its fingerprint: an item that finds
+its bucket taken by another one decays the count of the other item with a probability of decay^count, and takes
+the bucket when that count reaches 0. Items with small counts are quickly replaced while heavy hitters keep
+their buckets, so the greatest count of an item in its buckets estimates its frequency. The k items with the
+greatest estimations are kept in a min-heap.
+The decay draws numbers from a pseudo-random generator stored in the TopK, so that the same commands always
+give the same result, and the AOF rebuilds the same TopK.
+*/
+type TopK struct {
+	k       uint32
+	width   uint32
+	depth   uint32
+	decay   float64
+	buckets []topKBucket
+	// heap is a min-heap of k items ordered by count, the empty items have a count of 0
+	heap []TopKItem
+	// decayTable holds decay^i for the small counts
+	decayTable [topKDecayTableSize]float64
+	rng        uint64
+}
+
+type topKBucket struct {
+	fp    uint32
+	count uint32
+}
+
+type TopKItem struct {
+	Item  string
+	Count uint32
+	fp    uint32
+}
+
+func CreateTopK(k uint32, width uint32, depth uint32, decay float64) *TopK {
+	t := &TopK{
+		k:       k,
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		buckets: make([]topKBucket, width*depth),
+		heap:    make([]TopKItem, k),
+		rng:     uint64(ABigSeed),
+	}
+	t.initDecayTable()
+	return t
+}
+
+func (t *TopK) initDecayTable() {
+	for i := range t.decayTable {
+		t.decayTable[i] = math.Pow(t.decay, float64(i))
+	}
+}
+
+// random returns a pseudo-random number in [0, 1), from a xorshift64* generator
+func (t *TopK) random() float64 {
+	t.rng ^= t.rng >> 12
+	t.rng ^= t.rng << 25
+	t.rng ^= t.rng >> 27
+	return float64((t.rng*2685821657736338717)>>11) / (1 << 53)
+}
+
+// decayChance returns the probability that a count is decremented
+func (t *TopK) decayChance(count uint32) float64 {
+	if count < topKDecayTableSize {
+		return t.decayTable[count]
+	}
+	return math.Pow(t.decayTable[topKDecayTableSize-1], float64(count/(topKDecayTableSize-1))) *
+		t.decayTable[count%(topKDecayTableSize-1)]
+}
+
+// heapIndex returns the position of item in the heap, -1 if it isn't there
+func (t *TopK) heapIndex(item string, fp uint32) int {
+	for i := range t.heap {
+		if t.heap[i].fp == fp && t.heap[i].Count > 0 && t.heap[i].Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// heapifyUp moves the item at i up the heap until it is greater than its parent
+func (t *TopK) heapifyUp(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if t.heap[parent].Count <= t.heap[i].Count {
+			return
+		}
+		t.heap[i], t.heap[parent] = t.heap[parent], t.heap[i]
+		i = parent
+	}
+}
+
+// heapifyDown moves the item at i down the heap until it is lower than its children
+func (t *TopK) heapifyDown(i int) {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(t.heap) && t.heap[child].Count < t.heap[smallest].Count {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		t.heap[i], t.heap[smallest] = t.heap[smallest], t.heap[i]
+		i = smallest
+	}
+}
+
+/*
+IncrBy counts item increment more times. It returns the item expelled from the top k to make room for item,
+and true, or false if no item was expelled.
+*/
+func (t *TopK) IncrBy(item string, increment uint32) (string, bool) {
+	fp := calcHash(item, topKFpSeed)
+	var maxCount uint32
+	for i := uint32(0); i < t.depth; i++ {
+		b := &t.buckets[i*t.width+calcHash(item, i)%t.width]
+		switch {
+		case b.count == 0:
+			b.fp = fp
+			b.count = increment
+		case b.fp == fp:
+			if math.MaxUint32-b.count < increment {
+				b.count = math.MaxUint32
+			} else {
+				b.count += increment
+			}
+		default:
+			for left := increment; left > 0; left-- {
+				if t.random() < t.decayChance(b.count) {
+					b.count--
+					if b.count == 0 {
+						b.fp = fp
+						b.count = left
+						break
+					}
+				}
+			}
+		}
+		if b.fp == fp {
+			maxCount = max(maxCount, b.count)
+		}
+	}
+
+	if maxCount == 0 || maxCount < t.heap[0].Count {
+		return "", false
+	}
+	if i := t.heapIndex(item, fp); i >= 0 {
+		// the count may also be lower if the buckets of the item decayed
+		t.heap[i].Count = maxCount
+		t.heapifyUp(i)
+		t.heapifyDown(i)
+		return "", false
+	}
+	expelled := t.heap[0]
+	t.heap[0] = TopKItem{Item: item, Count: maxCount, fp: fp}
+	t.heapifyDown(0)
+	return expelled.Item, expelled.Count > 0
+}
+
+// Add counts item once more, and returns the item it expelled from the top k like IncrBy
+func (t *TopK) Add(item string) (string, bool) {
+	return t.IncrBy(item, 1)
+}
+
+// Query returns true if item is in the top k
+func (t *TopK) Query(item string) bool {
+	return t.heapIndex(item, calcHash(item, topKFpSeed)) >= 0
+}
+
+// Count returns the estimated count of item, the greatest count of its buckets
+func (t *TopK) Count(item string) uint32 {
+	fp := calcHash(item, topKFpSeed)
+	var count uint32
+	for i := uint32(0); i < t.depth; i++ {
+		b := t.buckets[i*t.width+calcHash(item, i)%t.width]
+		if b.fp == fp {
+			count = max(count, b.count)
+		}
+	}
+	return count
+}
+
+// List returns the items of the top k, from the most frequent
+func (t *TopK) List() []TopKItem {
+	items := make([]TopKItem, 0, len(t.heap))
+	for _, item := range t.heap {
+		if item.Count > 0 {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Item < items[j].Item
+	})
+	return items
+}
+
+func (t *TopK) GetK() uint32 {
+	return t.k
+}
+
+func (t *TopK) GetWidth() uint32 {
+	return t.width
+}
+
+func (t *TopK) GetDepth() uint32 {
+	return t.depth
+}
+
+func (t *TopK) GetDecay() float64 {
+	return t.decay
+}
+
+func (t *TopK) GetMemUsage() uint64 {
+	res := uint64(reflect.TypeOf(*t).Size()) + 8*uint64(len(t.buckets)) +
+		uint64(len(t.heap))*uint64(reflect.TypeOf(TopKItem{}).Size())
+	for _, item := range t.heap {
+		res += uint64(len(item.Item))
+	}
+	return res
+}
+
+// MarshalBinary encodes the dimensions, the buckets, the heap and the state of the random generator
+func (t *TopK) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 32+8*len(t.buckets))
+	b = appendUint32(b, t.k)
+	b = appendUint32(b, t.width)
+	b = appendUint32(b, t.depth)
+	b = appendFloat64(b, t.decay)
+	b = appendUint64(b, t.rng)
+	for _, bucket := range t.buckets {
+		b = appendUint32(b, bucket.fp)
+		b = appendUint32(b, bucket.count)
+	}
+	for _, item := range t.heap {
+		b = appendString(b, item.Item)
+		b = appendUint32(b, item.Count)
+		b = appendUint32(b, item.fp)
+	}
+	return b, nil
+}
+
+func (t *TopK) UnmarshalBinary(data []byte) error {
+	r := &payloadReader{data: data}
+	t.k = r.uint32()
+	t.width = r.uint32()
+	t.depth = r.uint32()
+	t.decay = r.float64()
+	t.rng = r.uint64()
+	if r.err != nil || t.k == 0 || t.width == 0 || t.depth == 0 ||
+		uint64(t.width)*uint64(t.depth)*8 > uint64(len(r.data)) {
+		return ErrCorruptedPayload
+	}
+	t.buckets = make([]topKBucket, t.width*t.depth)
+	for i := range t.buckets {
+		t.buckets[i].fp = r.uint32()
+		t.buckets[i].count = r.uint32()
+	}
+	// every item takes at least 12 bytes
+	if uint64(t.k)*12 > uint64(len(r.data)) {
+		return ErrCorruptedPayload
+	}
+	t.heap = make([]TopKItem, t.k)
+	for i := range t.heap {
+		t.heap[i].Item = r.string()
+		t.heap[i].Count = r.uint32()
+		t.heap[i].fp = r.uint32()
+	}
+	t.initDecayTable()
+	return r.done()
+}
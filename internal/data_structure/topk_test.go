@@ -0,0 +1,90 @@
+package data_structure
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCreateTopK(t *testing.T) {
+	topk := CreateTopK(3, 8, 7, 0.9)
+	assert.EqualValues(t, 56, len(topk.buckets))
+	assert.EqualValues(t, 3, len(topk.heap))
+	assert.EqualValues(t, 1, topk.decayTable[0])
+	assert.InDelta(t, 0.81, topk.decayTable[2], 1e-9)
+	assert.InDelta(t, topk.decayTable[255]*topk.decayTable[255]*topk.decayTable[10], topk.decayChance(520), 1e-12)
+	assert.Empty(t, topk.List())
+}
+
+func TestTopK_IncrBy(t *testing.T) {
+	topk := CreateTopK(2, 50, 4, 0.9)
+	_, expelled := topk.Add("a")
+	assert.False(t, expelled)
+	_, expelled = topk.IncrBy("b", 5)
+	assert.False(t, expelled)
+	assert.EqualValues(t, 1, topk.Count("a"))
+	assert.EqualValues(t, 5, topk.Count("b"))
+	assert.True(t, topk.Query("a"))
+
+	// the least frequent item is expelled
+	item, expelled := topk.IncrBy("c", 3)
+	assert.True(t, expelled)
+	assert.EqualValues(t, "a", item)
+	assert.False(t, topk.Query("a"))
+	assert.EqualValues(t, []TopKItem{{Item: "b", Count: 5}, {Item: "c", Count: 3}}, stripFp(topk.List()))
+
+	// an item of the top k doesn't expel anything
+	_, expelled = topk.IncrBy("c", 10)
+	assert.False(t, expelled)
+	assert.EqualValues(t, []TopKItem{{Item: "c", Count: 13}, {Item: "b", Count: 5}}, stripFp(topk.List()))
+}
+
+func stripFp(items []TopKItem) []TopKItem {
+	for i := range items {
+		items[i].fp = 0
+	}
+	return items
+}
+
+func TestTopK_HeavyHitters(t *testing.T) {
+	topk := CreateTopK(5, 100, 5, 0.9)
+	for round := 0; round < 100; round++ {
+		for i := 0; i < 5; i++ {
+			topk.IncrBy(fmt.Sprintf("heavy%d", i), uint32(10+i))
+		}
+		for i := 0; i < 50; i++ {
+			topk.Add(fmt.Sprintf("light%d-%d", round, i))
+		}
+	}
+	list := topk.List()
+	assert.EqualValues(t, 5, len(list))
+	for i, item := range list {
+		assert.EqualValues(t, fmt.Sprintf("heavy%d", 4-i), item.Item)
+	}
+	assert.InDelta(t, 1400, topk.Count("heavy4"), 140)
+}
+
+func TestTopK_Deterministic(t *testing.T) {
+	a := CreateTopK(3, 4, 2, 0.9)
+	b := CreateTopK(3, 4, 2, 0.9)
+	for i := 0; i < 1000; i++ {
+		a.IncrBy(fmt.Sprintf("%d", i%37), uint32(i%5+1))
+		b.IncrBy(fmt.Sprintf("%d", i%37), uint32(i%5+1))
+	}
+	assert.EqualValues(t, a, b)
+}
+
+func TestTopK_MarshalBinary(t *testing.T) {
+	topk := CreateTopK(3, 10, 4, 0.8)
+	for i := 0; i < 100; i++ {
+		topk.IncrBy(fmt.Sprintf("%d", i%7), uint32(i%3+1))
+	}
+	data, err := topk.MarshalBinary()
+	assert.Nil(t, err)
+
+	loaded := &TopK{}
+	assert.Nil(t, loaded.UnmarshalBinary(data))
+	assert.EqualValues(t, topk, loaded)
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:len(data)-1]))
+	assert.NotNil(t, loaded.UnmarshalBinary(data[:40]))
+}
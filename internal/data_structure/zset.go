@@ -1,5 +1,7 @@
 package data_structure
 
+import "reflect"
+
 const ZAddInNX = 1 << 1 /* Only add new elements. Don't update already existing elements. */
 const ZAddInXX = 1 << 2 /* Only update elements that already exist. Don't add new elements. */
 
@@ -9,8 +11,27 @@ const ZAddOutUpdated = 1 << 2 /* The element already existed, score updated. */
 
 type ZSet struct {
 	zskiplist *Skiplist
-	// map from ele to score
-	dict map[string]float64
+	// map from ele to its score and its position in eles
+	dict map[string]zsetEntry
+	// eles holds the elements densely, for Scan
+	eles []string
+	// elesMemUsage is the memory used by the elements, in the skiplist, in dict and in eles
+	elesMemUsage uint64
+}
+
+type zsetEntry struct {
+	score float64
+	pos   int
+}
+
+var zsetSize = uint64(reflect.TypeOf(ZSet{}).Size() + reflect.TypeOf(Skiplist{}).Size())
+var skiplistNodeSize = uint64(reflect.TypeOf(SkiplistNode{}).Size())
+var skiplistLevelSize = uint64(reflect.TypeOf(SkiplistLevel{}).Size())
+
+// zsetEntryMemUsage returns the memory used by ele: a skiplist node, which has 2 levels on average,
+// a dict entry and a slot in eles sharing the bytes of ele with the node
+func zsetEntryMemUsage(ele string) uint64 {
+	return skiplistNodeSize + 2*skiplistLevelSize + MapEntryOverhead + 2*StringHeaderSize + 16 + uint64(len(ele))
 }
 
 func (zs *ZSet) Add(score float64, ele string, flag int) (int, int) {
@@ -20,13 +41,13 @@ func (zs *ZSet) Add(score float64, ele string, flag int) (int, int) {
 	if len(ele) == 0 {
 		return 0, ZAddOutNop
 	}
-	if curScore, exist := zs.dict[ele]; exist {
+	if entry, exist := zs.dict[ele]; exist {
 		if nx != 0 {
 			return 1, ZAddOutNop
 		}
-		if curScore != score {
-			znode := zs.zskiplist.UpdateScore(curScore, ele, score)
-			zs.dict[ele] = znode.score
+		if entry.score != score {
+			znode := zs.zskiplist.UpdateScore(entry.score, ele, score)
+			zs.dict[ele] = zsetEntry{score: znode.score, pos: entry.pos}
 			return 1, ZAddOutUpdated
 		}
 		return 1, ZAddOutNop
@@ -37,7 +58,9 @@ func (zs *ZSet) Add(score float64, ele string, flag int) (int, int) {
 		return 1, ZAddOutNop
 	}
 	znode := zs.zskiplist.Insert(score, ele)
-	zs.dict[ele] = znode.score
+	zs.dict[ele] = zsetEntry{score: znode.score, pos: len(zs.eles)}
+	zs.eles = append(zs.eles, ele)
+	zs.elesMemUsage += zsetEntryMemUsage(ele)
 	return 1, ZAddOutAdded
 }
 
@@ -45,12 +68,20 @@ func (zs *ZSet) Add(score float64, ele string, flag int) (int, int) {
 Return 1 if element existed and was deleted, 0 otherwise
 */
 func (zs *ZSet) Del(ele string) int {
-	score, exist := zs.dict[ele]
+	entry, exist := zs.dict[ele]
 	if !exist {
 		return 0
 	}
+	// move the last element into the position of ele
+	last := zs.eles[len(zs.eles)-1]
+	zs.eles[entry.pos] = last
+	lastEntry := zs.dict[last]
+	lastEntry.pos = entry.pos
+	zs.dict[last] = lastEntry
+	zs.eles = zs.eles[:len(zs.eles)-1]
 	delete(zs.dict, ele)
-	zs.zskiplist.Delete(score, ele)
+	zs.zskiplist.Delete(entry.score, ele)
+	zs.elesMemUsage -= zsetEntryMemUsage(ele)
 	return 1
 }
 
@@ -62,10 +93,11 @@ one with the highest score.
 */
 func (zs *ZSet) GetRank(ele string, reverse bool) (rank int64, score float64) {
 	setSize := zs.zskiplist.length
-	score, exist := zs.dict[ele]
+	entry, exist := zs.dict[ele]
 	if !exist {
 		return -1, 0
 	}
+	score = entry.score
 	rank = int64(zs.zskiplist.GetRank(score, ele))
 	if reverse {
 		rank = int64(setSize) - rank
@@ -76,21 +108,135 @@ func (zs *ZSet) GetRank(ele string, reverse bool) (rank int64, score float64) {
 }
 
 func (zs *ZSet) GetScore(ele string) (int, float64) {
-	score, exist := zs.dict[ele]
+	entry, exist := zs.dict[ele]
 	if !exist {
 		return -1, 0
 	}
-	return 0, score
+	return 0, entry.score
 }
 
 func (zs *ZSet) Len() int {
 	return len(zs.dict)
 }
 
+// ForEach calls fn for every element, in ascending order of score
+func (zs *ZSet) ForEach(fn func(ele string, score float64)) {
+	for x := zs.zskiplist.head.levels[0].forward; x != nil; x = x.levels[0].forward {
+		fn(x.ele, x.score)
+	}
+}
+
+// RangeByRank calls fn for the elements from the 0-based rank start to stop included, which must be valid ranks,
+// in descending order of score if reverse
+func (zs *ZSet) RangeByRank(start int, stop int, reverse bool, fn func(ele string, score float64)) {
+	rank := start + 1
+	if reverse {
+		rank = zs.Len() - start
+	}
+	x := zs.zskiplist.GetElementByRank(uint32(rank))
+	for n := stop - start + 1; n > 0 && x != nil; n-- {
+		fn(x.ele, x.score)
+		x = zs.next(x, reverse)
+	}
+}
+
+/*
+RangeByScore calls fn for the elements with a score in zr, in descending order of score if reverse. The first
+offset elements are skipped, and at most count elements are returned if count >= 0.
+*/
+func (zs *ZSet) RangeByScore(zr ZRange, reverse bool, offset int, count int, fn func(ele string, score float64)) {
+	var first *SkiplistNode
+	if reverse {
+		first = zs.zskiplist.FindLastInRange(zr)
+	} else {
+		first = zs.zskiplist.FindFirstInRange(zr)
+	}
+	zs.rangeFrom(first, reverse, offset, count, func(x *SkiplistNode) bool {
+		if reverse {
+			return zr.ValueGteMin(x.score)
+		}
+		return zr.ValueLteMax(x.score)
+	}, fn)
+}
+
+// RangeByLex is like RangeByScore for the elements in the lexicographical range zr
+func (zs *ZSet) RangeByLex(zr ZLexRange, reverse bool, offset int, count int, fn func(ele string, score float64)) {
+	var first *SkiplistNode
+	if reverse {
+		first = zs.zskiplist.FindLastInLexRange(zr)
+	} else {
+		first = zs.zskiplist.FindFirstInLexRange(zr)
+	}
+	zs.rangeFrom(first, reverse, offset, count, func(x *SkiplistNode) bool {
+		if reverse {
+			return zr.ValueGteMin(x.ele)
+		}
+		return zr.ValueLteMax(x.ele)
+	}, fn)
+}
+
+// rangeFrom calls fn for the nodes from first while inRange, after skipping offset nodes by their rank
+func (zs *ZSet) rangeFrom(first *SkiplistNode, reverse bool, offset int, count int, inRange func(x *SkiplistNode) bool,
+	fn func(ele string, score float64)) {
+	if first == nil {
+		return
+	}
+	x := first
+	if offset > 0 {
+		rank := int(zs.zskiplist.GetRank(first.score, first.ele))
+		if reverse {
+			rank -= offset
+		} else {
+			rank += offset
+		}
+		if rank < 1 || rank > zs.Len() {
+			return
+		}
+		x = zs.zskiplist.GetElementByRank(uint32(rank))
+	}
+	for ; x != nil && count != 0 && inRange(x); count-- {
+		fn(x.ele, x.score)
+		x = zs.next(x, reverse)
+	}
+}
+
+func (zs *ZSet) next(x *SkiplistNode, reverse bool) *SkiplistNode {
+	if reverse {
+		return x.backward
+	}
+	return x.levels[0].forward
+}
+
+// Count returns the number of elements with a score in zr, from the ranks of the first and the last ones
+func (zs *ZSet) Count(zr ZRange) int {
+	first := zs.zskiplist.FindFirstInRange(zr)
+	if first == nil {
+		return 0
+	}
+	last := zs.zskiplist.FindLastInRange(zr)
+	return int(zs.zskiplist.GetRank(last.score, last.ele) - zs.zskiplist.GetRank(first.score, first.ele) + 1)
+}
+
+// Scan calls fn for up to count elements starting at cursor, and returns the cursor of the next call.
+// See scan.go for the guarantees.
+func (zs *ZSet) Scan(cursor uint64, count int, fn func(ele string, score float64)) uint64 {
+	start, end, next := scanRange(len(zs.eles), cursor, count)
+	for i := end - 1; i >= start; i-- {
+		ele := zs.eles[i]
+		fn(ele, zs.dict[ele].score)
+	}
+	return next
+}
+
+func (zs *ZSet) GetMemUsage() uint64 {
+	// the head of the skiplist has all the levels
+	return zsetSize + skiplistNodeSize + SkiplistMaxLevel*skiplistLevelSize + zs.elesMemUsage
+}
+
 func CreateZSet() *ZSet {
 	zs := ZSet{
 		zskiplist: CreateSkiplist(),
-		dict:      map[string]float64{},
+		dict:      map[string]zsetEntry{},
 	}
 	return &zs
 }
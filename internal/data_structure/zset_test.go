@@ -2,6 +2,7 @@ package data_structure
 
 import (
 	"github.com/stretchr/testify/assert"
+	"strconv"
 	"testing"
 )
 
@@ -31,7 +32,7 @@ func TestZSet_Add_AddNew(t *testing.T) {
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
 	v, ok := zs.dict["k1"]
 	assert.True(t, ok)
-	assert.EqualValues(t, 10.0, v)
+	assert.EqualValues(t, 10.0, v.score)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
 	assert.EqualValues(t, 10, zs.zskiplist.head.levels[0].forward.score)
 	assert.EqualValues(t, 1, zs.zskiplist.length)
@@ -40,7 +41,7 @@ func TestZSet_Add_AddNew(t *testing.T) {
 	v, ok = zs.dict["k2"]
 	assert.EqualValues(t, 1, ret)
 	assert.True(t, ok)
-	assert.EqualValues(t, 20, v)
+	assert.EqualValues(t, 20, v.score)
 	assert.EqualValues(t, "k2", zs.zskiplist.tail.ele)
 	assert.EqualValues(t, 20, zs.zskiplist.tail.score)
 	assert.EqualValues(t, 2, zs.zskiplist.length)
@@ -53,7 +54,7 @@ func TestZSet_Add_UpdateExist(t *testing.T) {
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
 	v, ok := zs.dict["k1"]
 	assert.True(t, ok)
-	assert.EqualValues(t, 10.0, v)
+	assert.EqualValues(t, 10.0, v.score)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
 	assert.EqualValues(t, 10, zs.zskiplist.head.levels[0].forward.score)
 	assert.EqualValues(t, 1, zs.zskiplist.length)
@@ -63,7 +64,7 @@ func TestZSet_Add_UpdateExist(t *testing.T) {
 	assert.EqualValues(t, 1, ret)
 	assert.EqualValues(t, ZAddOutUpdated, flagOut)
 	assert.True(t, ok)
-	assert.EqualValues(t, 5, v)
+	assert.EqualValues(t, 5, v.score)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
 	assert.EqualValues(t, 5, zs.zskiplist.head.levels[0].forward.score)
 	assert.EqualValues(t, 1, zs.zskiplist.length)
@@ -76,7 +77,7 @@ func TestZSet_Add_AddDuplicateEleAndScore(t *testing.T) {
 	assert.EqualValues(t, ZAddOutAdded, flagOut)
 	v, ok := zs.dict["k1"]
 	assert.True(t, ok)
-	assert.EqualValues(t, 10.0, v)
+	assert.EqualValues(t, 10.0, v.score)
 	assert.EqualValues(t, "k1", zs.zskiplist.head.levels[0].forward.ele)
 	assert.EqualValues(t, 10, zs.zskiplist.head.levels[0].forward.score)
 	assert.EqualValues(t, 1, zs.zskiplist.length)
@@ -161,3 +162,77 @@ func TestZSet_GetRank(t *testing.T) {
 	assert.EqualValues(t, 0, rank)
 	assert.EqualValues(t, 40.0, score)
 }
+
+func TestZSet_Scan(t *testing.T) {
+	zs := CreateZSet()
+	for i := 0; i < 100; i++ {
+		zs.Add(float64(i), strconv.Itoa(i), 0)
+	}
+	seen := map[string]float64{}
+	var cursor uint64 = 0
+	for {
+		cursor = zs.Scan(cursor, 10, func(ele string, score float64) {
+			seen[ele] = score
+		})
+		// delete one of the elements 0-9 and add one
+		zs.Del(strconv.Itoa(len(seen) % 10))
+		zs.Add(1000+float64(len(seen)), "new"+strconv.Itoa(len(seen)), 0)
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 10; i < 100; i++ {
+		assert.EqualValues(t, i, seen[strconv.Itoa(i)])
+	}
+	assert.EqualValues(t, zs.Len(), len(zs.eles))
+}
+
+func collectZSetRange(fn func(fn func(ele string, score float64))) []string {
+	var res []string
+	fn(func(ele string, score float64) {
+		res = append(res, ele)
+	})
+	return res
+}
+
+func TestZSet_Ranges(t *testing.T) {
+	zs := CreateZSet()
+	for i := 0; i < 10; i++ {
+		zs.Add(float64(i), strconv.Itoa(i), 0)
+	}
+	assert.EqualValues(t, []string{"2", "3", "4"}, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByRank(2, 4, false, fn)
+	}))
+	assert.EqualValues(t, []string{"7", "6", "5"}, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByRank(2, 4, true, fn)
+	}))
+
+	zr, _ := ParseZRange("(2", "8")
+	assert.EqualValues(t, 6, zs.Count(zr))
+	assert.EqualValues(t, []string{"3", "4", "5", "6", "7", "8"}, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByScore(zr, false, 0, -1, fn)
+	}))
+	assert.EqualValues(t, []string{"5", "6"}, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByScore(zr, false, 2, 2, fn)
+	}))
+	assert.EqualValues(t, []string{"6", "5", "4", "3"}, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByScore(zr, true, 2, -1, fn)
+	}))
+	assert.Empty(t, collectZSetRange(func(fn func(string, float64)) {
+		zs.RangeByScore(zr, false, 6, -1, fn)
+	}))
+	zr, _ = ParseZRange("8", "2")
+	assert.EqualValues(t, 0, zs.Count(zr))
+
+	lex := CreateZSet()
+	for _, ele := range []string{"a", "b", "c", "d", "e"} {
+		lex.Add(0, ele, 0)
+	}
+	lr, _ := ParseZLexRange("[b", "(e")
+	assert.EqualValues(t, []string{"b", "c", "d"}, collectZSetRange(func(fn func(string, float64)) {
+		lex.RangeByLex(lr, false, 0, -1, fn)
+	}))
+	assert.EqualValues(t, []string{"c", "b"}, collectZSetRange(func(fn func(string, float64)) {
+		lex.RangeByLex(lr, true, 1, 5, fn)
+	}))
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"memkv/internal/config"
 	"memkv/internal/constant"
@@ -21,19 +23,39 @@ var eStatus int32 = constant.EngineStatusWaiting
 func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
 	defer wg.Done()
 	<-signals
-	for atomic.LoadInt32(&eStatus) == constant.EngineStatusBusy {
+	// wait for the event loop to finish the current batch of events, it stops as soon as it sees the new status
+	for !atomic.CompareAndSwapInt32(&eStatus, constant.EngineStatusWaiting, constant.EngineStatusShuttingDown) {
 	}
 	log.Println("Shutting down gracefully")
+	if err := core.CloseAOF(); err != nil {
+		log.Println("can't close the append only file:", err)
+	}
 	os.Exit(0)
 }
 
-func readCommandFD(fd int) (*core.MemKVCmd, error) {
-	var buf = make([]byte, 512)
-	n, err := syscall.Read(fd, buf)
+var readBuf = make([]byte, config.ReadChunkSize)
+
+// readCommandsFD reads what is available on the client socket into its query buffer
+// and returns every complete command buffered so far. A partial command stays
+// in the buffer until the next readiness event brings the rest of it.
+func readCommandsFD(comm *core.FDComm) ([]*core.MemKVCmd, error) {
+	n, err := syscall.Read(comm.Fd, readBuf)
 	if err != nil {
+		if err == syscall.EAGAIN {
+			return nil, nil
+		}
 		return nil, err
 	}
-	return core.ParseCmd(buf[:n])
+	if n == 0 {
+		return nil, io.EOF
+	}
+	comm.QueryBuf = append(comm.QueryBuf, readBuf[:n]...)
+	if len(comm.QueryBuf) > config.MaxQueryBufferSize {
+		return nil, errors.New("query buffer limit exceeded")
+	}
+	cmds, consumed, err := core.ParseCmds(comm.QueryBuf)
+	comm.ConsumeQueryBuf(consumed)
+	return cmds, err
 }
 
 func responseRw(cmd *core.MemKVCmd, rw io.ReadWriter) {
@@ -47,11 +69,44 @@ func responseErrorRw(err error, rw io.ReadWriter) {
 	rw.Write([]byte(fmt.Sprintf("-%s%s", err, core.CRLF)))
 }
 
+// flushClient writes the pending replies of a client. When the socket can't take all of them,
+// we monitor it for writability and send the rest once the client has read some data.
+func flushClient(ioMultiplexer io_multiplexing.IOMultiplexer, comm *core.FDComm) error {
+	done, err := comm.Flush()
+	if err != nil {
+		return err
+	}
+	event := io_multiplexing.Event{
+		Fd: comm.Fd,
+		Op: io_multiplexing.OpWrite,
+	}
+	if done {
+		return ioMultiplexer.Unmonitor(event)
+	}
+	return ioMultiplexer.Monitor(event)
+}
+
 func RunAsyncTCPServer(wg *sync.WaitGroup) error {
 	defer wg.Done()
 	log.Println("starting an asynchronous TCP server on", config.Host, config.Port)
 
+	// the AOF is always at least as recent as the snapshot, so it wins when enabled
+	if config.AOFEnabled {
+		if err := core.LoadAOF(); err != nil {
+			log.Println(err)
+			return err
+		}
+		if err := core.OpenAOF(); err != nil {
+			log.Println(err)
+			return err
+		}
+	} else if err := core.LoadSnapshot(); err != nil {
+		log.Println(err)
+		return err
+	}
+
 	var events = make([]io_multiplexing.Event, config.MaxConnection)
+	clients := make(map[int]*core.FDComm)
 	clientNumber := 0
 
 	// Create a server socket. A socket is an endpoint for communication between client and server
@@ -73,6 +128,12 @@ func RunAsyncTCPServer(wg *sync.WaitGroup) error {
 		return err
 	}
 
+	// Allow restarting the server right away, while connections of the previous process are in TIME_WAIT
+	if err = syscall.SetsockoptInt(serverFD, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		log.Println(err)
+		return err
+	}
+
 	// Bind the IP and the port to the server socket FD.
 	ip4 := net.ParseIP(config.Host)
 	if err = syscall.Bind(serverFD, &syscall.SockaddrInet4{
@@ -106,9 +167,23 @@ func RunAsyncTCPServer(wg *sync.WaitGroup) error {
 		return err
 	}
 
+	closeClient := func(comm *core.FDComm) {
+		core.FreeClient(comm)
+		ioMultiplexer.Unmonitor(io_multiplexing.Event{Fd: comm.Fd, Op: io_multiplexing.OpRead})
+		ioMultiplexer.Unmonitor(io_multiplexing.Event{Fd: comm.Fd, Op: io_multiplexing.OpWrite})
+		syscall.Close(comm.Fd)
+		delete(clients, comm.Fd)
+		clientNumber--
+		log.Println("client quit")
+	}
+
+	// the background tasks run every cron period, between two batches of events
+	cronPeriod := time.Second / time.Duration(config.Hz)
+	nextCron := time.Now().Add(cronPeriod)
+
 	for atomic.LoadInt32(&eStatus) != constant.EngineStatusShuttingDown {
-		// check if any FD is ready for an IO
-		events, err = ioMultiplexer.Check()
+		// check if any FD is ready for an IO, and wake up in time for the next cron
+		events, err = ioMultiplexer.Check(max(time.Until(nextCron), 0))
 		if err != nil {
 			continue
 		}
@@ -141,21 +216,63 @@ func RunAsyncTCPServer(wg *sync.WaitGroup) error {
 				}); err != nil {
 					return err
 				}
-			} else {
-				// the Client FD is ready for reading, means an existing client is sending a command
-				comm := core.FDComm{Fd: int(events[i].Fd)}
-				cmd, err := readCommandFD(comm.Fd)
-				if err != nil {
-					syscall.Close(events[i].Fd)
-					clientNumber--
-					log.Println("client quit")
-					atomic.SwapInt32(&eStatus, constant.EngineStatusWaiting)
-					continue
+				clients[connFD] = &core.FDComm{Fd: connFD}
+				continue
+			}
+
+			comm, ok := clients[events[i].Fd]
+			if !ok {
+				continue
+			}
+			if events[i].Op == io_multiplexing.OpWrite {
+				// the Client FD is ready for writing, means the client has read some replies
+				// and there is room in the socket for the rest
+				if err = flushClient(ioMultiplexer, comm); err != nil || comm.CloseAsap {
+					closeClient(comm)
 				}
+				continue
+			}
+
+			// the Client FD is ready for reading, means an existing client is sending one or more commands
+			cmds, err := readCommandsFD(comm)
+			// execute every complete command in the order they were sent, even when the
+			// read also hit an error, so that a pipeline followed by a protocol error
+			// still gets its replies
+			for _, cmd := range cmds {
 				responseRw(cmd, comm)
 			}
-			atomic.SwapInt32(&eStatus, constant.EngineStatusWaiting)
+			if err != nil {
+				core.FlushAOF()
+				if err != io.EOF {
+					responseErrorRw(err, comm)
+					comm.Flush()
+				}
+				closeClient(comm)
+				continue
+			}
+			// writes must reach the AOF before they are acknowledged
+			core.FlushAOF()
+			if err = flushClient(ioMultiplexer, comm); err != nil || comm.CloseAsap {
+				closeClient(comm)
+			}
+		}
+		if !time.Now().Before(nextCron) {
+			core.ServerCron()
+			nextCron = time.Now().Add(cronPeriod)
+		}
+		// the clients unblocked by a timeout may have run write commands
+		core.FlushAOF()
+		// send the messages published to the subscribers, and the replies of the unblocked clients
+		for _, comm := range core.ClientsPendingWrite() {
+			if clients[comm.Fd] != comm {
+				// closed during this loop
+				continue
+			}
+			if err = flushClient(ioMultiplexer, comm); err != nil || comm.CloseAsap {
+				closeClient(comm)
+			}
 		}
+		atomic.SwapInt32(&eStatus, constant.EngineStatusWaiting)
 	}
 
 	return nil
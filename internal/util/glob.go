@@ -0,0 +1,95 @@
+package util
+
+/*
+GlobMatch reports whether s matches the glob-style pattern, with the syntax of Redis KEYS: a star matches
+any sequence, ? any character, [abc], [^abc] and [a-z] a set of characters, and \ escapes the next character.
+It only remembers the last star met: when the rest of the pattern fails, the last star matches one more
+character and the rest is tried again. The earlier stars never need to match more, as the last one can
+absorb anything they would, so it runs in O(len(pattern) * len(s)) instead of exponential time.
+*/
+func GlobMatch(pattern string, s string) bool {
+	p, i := 0, 0
+	// starP is the position in pattern just after the last star, -1 before the first one, and
+	// starI the position in s the rest of the pattern is tried from
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) && pattern[p] == '*' {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			starP, starI = p, i
+			continue
+		}
+		if p < len(pattern) {
+			if match, n := matchOne(pattern[p:], s[i]); match {
+				p += n
+				i++
+				continue
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		starI++
+		p, i = starP, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchOne matches c against the element at the start of pattern, which is not a star, and returns
+// the length of the element
+func matchOne(pattern string, c byte) (bool, int) {
+	switch pattern[0] {
+	case '?':
+		return true, 1
+	case '[':
+		match, rest := matchClass(pattern[1:], c)
+		return match, len(pattern) - len(rest)
+	case '\\':
+		if len(pattern) >= 2 {
+			return pattern[1] == c, 2
+		}
+	}
+	return pattern[0] == c, 1
+}
+
+// matchClass matches c against the character class at the start of pattern, just after '[', and returns
+// the pattern after the closing ']'. An unterminated class extends to the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == c {
+				match = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				match = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				match = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		// skip ']'
+		pattern = pattern[1:]
+	}
+	return match != not, pattern
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"abc", "abc", true},
+		{"abc", "abcd", false},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*", "abc", true},
+		{"a*", "ba", false},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcb", false},
+		{"a**c", "abc", true},
+		{"*a*b*", "xxaxxbxx", true},
+		{"*a*b*", "xxbxxaxx", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"?", "", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h[c-a]llo", "hbllo", true},
+		{"[\\]]", "]", true},
+		{"[a-]", "-", true},
+		{"[abc", "b", true},
+		{"[abc", "bc", false},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"\\?", "?", true},
+		{"\\[a]", "[a]", true},
+		{"a\\", "a\\", true},
+		{"*\\*", "ab*", true},
+		{"*[0-9]", "key1", true},
+		{"*[0-9]", "key", false},
+		{"user:*:name", "user:42:name", true},
+		{"user:*:name", "user:42:email", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.match, GlobMatch(c.pattern, c.s), "%q %q", c.pattern, c.s)
+	}
+}
+
+func TestGlobMatchPathological(t *testing.T) {
+	// the stars would backtrack in exponential time
+	pattern := strings.Repeat("*a", 30) + "*b"
+	s := strings.Repeat("a", 100)
+	start := time.Now()
+	assert.False(t, GlobMatch(pattern, s))
+	assert.True(t, GlobMatch(pattern, s+"b"))
+	assert.Less(t, time.Since(start), time.Second)
+}